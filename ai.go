@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"commandref/api"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// `ai add "find large files modified this week"` asks a language model for
+// a command, shows it for editing and saves it. The model is reached
+// through the "ai" config section: an OpenAI-compatible endpoint, a local
+// Ollama, or the commandref backend (the default with hosted storage).
+
+// aiProposal is what every provider is asked to produce.
+type aiProposal struct {
+	Title   string   `json:"title"`
+	Command string   `json:"command"`
+	Tags    []string `json:"tags"`
+	Notes   string   `json:"notes"`
+}
+
+func runAI(args []string) {
+	if len(args) == 0 || args[0] != "add" {
+		exitErr(errors.New(`usage: commandref ai add "<what the command should do>" [--yes] [--tags t1,t2] [--collection name]`))
+	}
+	fs := flag.NewFlagSet("ai add", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "save the proposal without asking")
+	tags := fs.String("tags", "", "comma-separated tags to add to the proposed ones")
+	coll := fs.String("collection", "", "file it in this collection")
+	var words []string
+	rest := args[1:]
+	for len(rest) > 0 {
+		_ = fs.Parse(rest)
+		rest = fs.Args()
+		if len(rest) > 0 {
+			words = append(words, rest[0])
+			rest = rest[1:]
+		}
+	}
+	request := strings.TrimSpace(strings.Join(words, " "))
+	if request == "" {
+		exitErr(errors.New("describe the command, e.g. commandref ai add \"find large files modified this week\""))
+	}
+	if aiProvider() == "" {
+		exitErr(errors.New(`no AI provider: set "ai" in the config, e.g. {"ai": {"provider": "ollama"}}`))
+	}
+	interactive := canPrompt()
+
+	for {
+		fmt.Fprintln(os.Stderr, "Asking", aiProviderName()+"...")
+		p, err := aiPropose(request)
+		if err != nil {
+			exitErr(err)
+		}
+		p.Tags = append(p.Tags, parseTags(*tags)...)
+		printProposal(p)
+		if *yes || opts.Yes {
+			saveProposal(p, *coll)
+			return
+		}
+		if !interactive {
+			exitErr(errors.New("not saved: there is no terminal to ask; rerun with --yes to save without asking"))
+		}
+		for {
+			answer, err := readLine("[s]ave, [e]dit, [r]etry or [q]uit? ")
+			if err != nil {
+				return
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "s", "save", "y", "yes":
+				saveProposal(p, *coll)
+				return
+			case "e", "edit":
+				editProposal(&p)
+				printProposal(p)
+				continue
+			case "r", "retry":
+			case "q", "quit", "n", "no":
+				return
+			default:
+				continue
+			}
+			break
+		}
+	}
+}
+
+func printProposal(p aiProposal) {
+	fmt.Println()
+	fmt.Println("Title:   " + p.Title)
+	fmt.Println("Command: " + colorize("36", p.Command))
+	if len(p.Tags) > 0 {
+		fmt.Println("Tags:    " + strings.Join(p.Tags, ","))
+	}
+	if p.Notes != "" {
+		fmt.Println("Notes:   " + p.Notes)
+	}
+	fmt.Println()
+}
+
+// editProposal asks for each field again; an empty answer keeps it.
+func editProposal(p *aiProposal) {
+	ask := func(label, cur string) string {
+		answer, err := readLine(fmt.Sprintf("%s [%s]: ", label, cur))
+		if err != nil || strings.TrimSpace(answer) == "" {
+			return cur
+		}
+		return strings.TrimSpace(answer)
+	}
+	p.Title = ask("Title", p.Title)
+	p.Command = ask("Command", p.Command)
+	p.Tags = parseTags(ask("Tags", strings.Join(p.Tags, ",")))
+	p.Notes = ask("Notes", p.Notes)
+}
+
+func saveProposal(p aiProposal, coll string) {
+	it := Item{
+		Title:   strings.TrimSpace(p.Title),
+		Command: normalizeCommand(p.Command),
+		Tags:    parseTags(strings.Join(p.Tags, ",")),
+		Notes:   strings.TrimSpace(p.Notes),
+	}
+	if it.Title == "" || it.Command == "" {
+		exitErr(errors.New("the proposal needs a title and a command"))
+	}
+	if strings.TrimSpace(coll) != "" {
+		it.Collection = resolveCollection(coll)
+	}
+	if err := applyTransforms(&it); err != nil {
+		exitErr(err)
+	}
+	note := guardSyncRules(it)
+	created, err := openStore().Create(it)
+	if err != nil {
+		exitErr(err)
+	}
+	fmt.Printf("Saved #%d: %s\n", created.ID, created.Title)
+	if note != "" {
+		fmt.Println(note)
+	}
+}
+
+// aiProvider is "openai", "ollama" or "backend"; unset means the backend
+// when the library is hosted.
+func aiProvider() string {
+	if p := strings.ToLower(strings.TrimSpace(cfg.AI.Provider)); p != "" {
+		return p
+	}
+	if !usingLocalStore() {
+		return "backend"
+	}
+	return ""
+}
+
+func aiProviderName() string {
+	switch aiProvider() {
+	case "backend":
+		return "the commandref backend"
+	}
+	return aiProvider() + " (" + aiModel() + ")"
+}
+
+func aiModel() string {
+	if cfg.AI.Model != "" {
+		return cfg.AI.Model
+	}
+	if aiProvider() == "ollama" {
+		return "llama3.1"
+	}
+	return "gpt-4o-mini"
+}
+
+func aiPrompt(request string) string {
+	return fmt.Sprintf(`Write one shell command for: %s
+
+It runs in %s on %s. Answer with only a JSON object, no prose:
+{"title": "short title", "command": "the command", "tags": ["a", "few", "tags"], "notes": "one sentence on what it does, or empty"}
+Use $1, $2... where the user has to fill something in.`, request, filepath.Base(runShell()), runtime.GOOS)
+}
+
+func aiPropose(request string) (aiProposal, error) {
+	var p aiProposal
+	switch aiProvider() {
+	case "backend":
+		c := api.New()
+		c.Workspace = currentWorkspace().ID
+		body := map[string]string{"prompt": request, "shell": filepath.Base(runShell()), "os": runtime.GOOS}
+		if err := c.DoJSON("POST", "/v1/ai/suggest", body, &p); err != nil {
+			return p, fmt.Errorf("backend: %s", strings.TrimSpace(err.Error()))
+		}
+	case "openai", "ollama":
+		text, err := aiChat(aiPrompt(request))
+		if err != nil {
+			return p, err
+		}
+		if p, err = parseProposal(text); err != nil {
+			return p, err
+		}
+	default:
+		return p, fmt.Errorf("unknown ai provider %q (have: openai, ollama, backend)", cfg.AI.Provider)
+	}
+	if strings.TrimSpace(p.Command) == "" {
+		return p, fmt.Errorf("no command in the answer")
+	}
+	if p.Title == "" {
+		p.Title = request
+	}
+	return p, nil
+}
+
+// aiChat sends one user message and returns the reply's text.
+func aiChat(prompt string) (string, error) {
+	messages := []map[string]string{{"role": "user", "content": prompt}}
+	var url string
+	var body map[string]any
+	if aiProvider() == "ollama" {
+		url = aiURL("/api/chat")
+		body = map[string]any{"model": aiModel(), "messages": messages, "stream": false, "format": "json"}
+	} else {
+		url = aiURL("/chat/completions")
+		body = map[string]any{"model": aiModel(), "messages": messages, "temperature": 0.2}
+	}
+	respBody, err := aiPost(url, body)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Message struct { // ollama
+			Content string `json:"content"`
+		} `json:"message"`
+		Choices []struct { // openai
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("%s: unexpected answer: %w", url, err)
+	}
+	if len(out.Choices) > 0 {
+		return out.Choices[0].Message.Content, nil
+	}
+	return out.Message.Content, nil
+}
+
+// aiPost sends a JSON body to the configured model endpoint.
+func aiPost(url string, body any) ([]byte, error) {
+	b, _ := json.Marshal(body)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := credential("COMMANDREF_AI_KEY", cfg.AI.APIKey, "ai.api_key"); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s: %s", url, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// aiURL is the configured endpoint (or the provider's default) plus path.
+func aiURL(path string) string {
+	def := "https://api.openai.com/v1"
+	if aiProvider() == "ollama" {
+		def = "http://127.0.0.1:11434"
+	}
+	return strings.TrimRight(orDefault(cfg.AI.URL, def), "/") + path
+}
+
+// parseProposal reads the JSON object out of a model's reply, which may
+// come wrapped in a code fence or a sentence.
+func parseProposal(text string) (aiProposal, error) {
+	var p aiProposal
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return p, fmt.Errorf("the answer isn't a proposal: %s", strings.TrimSpace(text))
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &p); err != nil {
+		return p, fmt.Errorf("the answer isn't a proposal: %w", err)
+	}
+	return p, nil
+}
+
+func orDefault(s, def string) string {
+	if strings.TrimSpace(s) == "" {
+		return def
+	}
+	return s
+}
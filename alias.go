@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// commands referencing positional params need a function, not an alias
+var positionalRef = regexp.MustCompile(`\$(@|\*|[1-9]|\{[1-9@*])`)
+
+func runAlias(args []string) {
+	if len(args) == 0 || args[0] != "export" {
+		exitErr(errors.New("usage: commandref alias export [--tag t] [--shell zsh|bash|fish] [--prefix p]"))
+	}
+
+	fs := flag.NewFlagSet("alias export", flag.ExitOnError)
+	tag := fs.String("tag", "", "only export items with this tag")
+	shell := fs.String("shell", "zsh", "target shell: zsh, bash or fish")
+	prefix := fs.String("prefix", "", "prefix for generated names (e.g. cr-)")
+	_ = fs.Parse(args[1:])
+
+	if *shell != "zsh" && *shell != "bash" && *shell != "fish" {
+		exitErr(errors.New("--shell must be zsh, bash or fish"))
+	}
+
+	items, err := openStore().List()
+	if err != nil {
+		exitErr(err)
+	}
+	sortByID(items)
+
+	want := strings.ToLower(strings.TrimSpace(*tag))
+	used := map[string]bool{}
+
+	fmt.Printf("# generated by commandref alias export; do not edit\n")
+	for _, it := range items {
+		if want != "" && !hasTag(it, want) {
+			continue
+		}
+		name := aliasName(*prefix, it, used)
+		if name == "" {
+			fmt.Fprintf(os.Stderr, "skipping #%d: title has no usable characters\n", it.ID)
+			continue
+		}
+		fmt.Printf("\n# #%d %s\n", it.ID, it.Title)
+		fmt.Print(aliasDefinition(*shell, name, it.Command))
+	}
+}
+
+func hasTag(it Item, tag string) bool {
+	for _, t := range it.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func aliasName(prefix string, it Item, used map[string]bool) string {
+	base := slugify(it.Title)
+	if base == "" {
+		return ""
+	}
+	base = prefix + base
+	name := base
+	for n := 2; used[name]; n++ {
+		name = base + "-" + strconv.Itoa(n)
+	}
+	used[name] = true
+	return name
+}
+
+func aliasDefinition(shell, name, command string) string {
+	multiline := strings.Contains(command, "\n")
+	needsFunc := multiline || positionalRef.MatchString(command)
+
+	if shell == "fish" {
+		if !needsFunc {
+			return fmt.Sprintf("alias %s %s\n", name, shellQuote(command))
+		}
+		// fish has no $@; hand the body to sh so the saved syntax keeps working
+		return fmt.Sprintf("function %s\n    sh -c %s %s $argv\nend\n", name, shellQuote(command), name)
+	}
+
+	if !needsFunc {
+		return fmt.Sprintf("alias %s=%s\n", name, shellQuote(command))
+	}
+	return fmt.Sprintf("%s() {\n%s\n}\n", name, indent(command, "    "))
+}
+
+// shellQuote single-quotes s for POSIX shells (and fish).
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func indent(s, pad string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = pad + l
+	}
+	return strings.Join(lines, "\n")
+}
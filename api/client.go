@@ -3,15 +3,50 @@ package api
 import (
 	"bytes"
 	"commandref/auth"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 )
 
 type Client struct {
 	BaseURL string
+
+	// team workspace ID; empty means the personal library
+	Workspace string
+}
+
+// Waiting, when set, is told about every request as it goes out and gets
+// back a func to call when the response has arrived, e.g. to show a
+// spinner meanwhile.
+var Waiting func(request string) (done func())
+
+// UserAgent is sent with every request; the CLI sets it to
+// "commandref/<version> (<os>/<arch>)".
+var UserAgent = "commandref"
+
+// ErrNotLoggedIn is returned for every request while there is no session.
+var ErrNotLoggedIn = errors.New("not logged in. run: commandref login (or commandref setup to keep commands on this machine)")
+
+// HTTPError is returned for non-2xx responses; the message is the response
+// body and the request ID, which the backend logs under the same ID.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	RequestID  string
+}
+
+func (e *HTTPError) Error() string {
+	if e.RequestID == "" {
+		return e.Body
+	}
+	return strings.TrimSpace(e.Body) + " (request " + e.RequestID + ")"
 }
 
 func New() *Client {
@@ -22,39 +57,163 @@ func New() *Client {
 	return &Client{BaseURL: base}
 }
 
+// Path scopes a /v1/... path to the client's workspace, e.g. /v1/commands
+// becomes /v1/workspaces/<id>/commands.
+func (c *Client) Path(p string) string {
+	if c.Workspace == "" {
+		return p
+	}
+	return "/v1/workspaces/" + url.PathEscape(c.Workspace) + strings.TrimPrefix(p, "/v1")
+}
+
 func (c *Client) DoJSON(method, path string, in any, out any) error {
-	sess, err := auth.LoadSession()
+	var body []byte
+	if in != nil {
+		body, _ = json.Marshal(in)
+	}
+	status, respBody, id, err := c.Send(method, path, body)
 	if err != nil {
 		return err
 	}
-	if sess == nil || sess.Token == "" {
-		return fmt.Errorf("not logged in. run: commandref login")
+	if status >= 300 {
+		return &HTTPError{StatusCode: status, Body: string(respBody), RequestID: id}
+	}
+	if out != nil {
+		return json.Unmarshal(respBody, out)
 	}
+	return nil
+}
 
-	var body io.Reader
-	if in != nil {
-		b, _ := json.Marshal(in)
-		body = bytes.NewReader(b)
+// Do sends an authenticated request with an optional JSON body and returns
+// the status and body as they came, whatever the status.
+func (c *Client) Do(method, path string, body []byte) (int, []byte, error) {
+	status, respBody, _, err := c.Send(method, path, body)
+	return status, respBody, err
+}
+
+// Send is Do that also returns the request ID it was sent with.
+func (c *Client) Send(method, path string, body []byte) (int, []byte, string, error) {
+	sess, err := auth.LoadSession()
+	if err != nil {
+		return 0, nil, "", err
+	}
+	if sess == nil || sess.Token == "" {
+		return 0, nil, "", ErrNotLoggedIn
 	}
 
-	req, _ := http.NewRequest(method, c.BaseURL+path, body)
-	req.Header.Set("Authorization", "Bearer "+sess.Token)
-	if in != nil {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, r)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	id := setHeaders(req, sess.Token)
+	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	if Waiting != nil {
+		defer Waiting(method + " " + path)()
+	}
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return 0, nil, id, err
 	}
 	defer res.Body.Close()
 
-	respBody, _ := io.ReadAll(res.Body)
-	if res.StatusCode >= 300 {
-		return fmt.Errorf("%s", string(respBody))
+	respBody, err := io.ReadAll(res.Body)
+	return res.StatusCode, respBody, id, err
+}
+
+// Stream opens a long-lived GET (e.g. a server-sent events endpoint) and
+// returns the response body for the caller to read and close. lastEventID,
+// if set, lets the server replay what was missed since a disconnect.
+func (c *Client) Stream(path, lastEventID string) (io.ReadCloser, error) {
+	h := http.Header{"Accept": {"text/event-stream"}}
+	if lastEventID != "" {
+		h.Set("Last-Event-ID", lastEventID)
 	}
-	if out != nil {
-		return json.Unmarshal(respBody, out)
+	return c.get(path, h)
+}
+
+// EachJSON GETs a JSON array and calls each with its elements as they are
+// decoded, so a large response is never held in memory all at once.
+func (c *Client) EachJSON(path string, each func(json.RawMessage) error) error {
+	body, err := c.get(path, http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		return err
 	}
-	return nil
+	defer body.Close()
+	dec := json.NewDecoder(body)
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('[') {
+		return fmt.Errorf("expected a JSON array from %s", path)
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if err := each(raw); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
 }
+
+// get sends an authenticated GET and returns the body of a 2xx response
+// unread.
+func (c *Client) get(path string, h http.Header) (io.ReadCloser, error) {
+	sess, err := auth.LoadSession()
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil || sess.Token == "" {
+		return nil, ErrNotLoggedIn
+	}
+
+	req, _ := http.NewRequest("GET", c.BaseURL+path, nil)
+	req.Header = h
+	id := setHeaders(req, sess.Token)
+
+	done := func() {}
+	if Waiting != nil {
+		done = Waiting("GET " + path)
+	}
+	res, err := http.DefaultClient.Do(req)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return nil, &HTTPError{StatusCode: res.StatusCode, Body: string(b), RequestID: id}
+	}
+	return res.Body, nil
+}
+
+// setHeaders adds what every request carries and returns its new request
+// ID, which a user can quote when reporting a failure.
+func setHeaders(req *http.Request, token string) string {
+	req.Header.Set("Authorization", "Bearer "+token)
+	return PrepareRequest(req)
+}
+
+// PrepareRequest is setHeaders without the login, for requests that go
+// around Client: logging in, public share links.
+func PrepareRequest(req *http.Request) string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("X-Request-ID", id)
+	return id
+}
+
+// the auth package can't import this one
+func init() { auth.PrepareRequest = PrepareRequest }
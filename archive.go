@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Archived items stay in the library but are left out of list, search and
+// the picker unless asked for with --archived.
+
+func runArchive(args []string, archive bool) {
+	verb := "archive"
+	if !archive {
+		verb = "unarchive"
+	}
+	refs, _ := splitIDArgs(args)
+	ids, err := parseTargetIDs(refs)
+	if err != nil {
+		exitErr(fmt.Errorf("%w (usage: commandref %s <id>...)", err, verb))
+	}
+	if len(ids) == 1 {
+		refuseProjectID(ids[0])
+	}
+
+	st := openStore()
+	if code := forEachID(ids, func(id int) (string, error) {
+		if id < 0 {
+			return "", fmt.Errorf("a project command; edit it in %s", projectFileName)
+		}
+		it, err := st.Get(id)
+		if err != nil {
+			return "", err
+		}
+		if it.Archived == archive {
+			return fmt.Sprintf("#%d is already %sd", it.ID, verb), nil
+		}
+		if _, err := st.Update(id, map[string]any{"archived": archive}); err != nil {
+			return "", err
+		}
+		if archive {
+			return fmt.Sprintf("Archived #%d: %s", it.ID, it.Title), nil
+		}
+		return fmt.Sprintf("Unarchived #%d: %s", it.ID, it.Title), nil
+	}); code != 0 {
+		os.Exit(code)
+	}
+	if archive {
+		fmt.Println("(see: commandref list --archived)")
+	}
+}
+
+// byArchived keeps the archived items, or everything else.
+func byArchived(items []Item, archived bool) []Item {
+	var out []Item
+	for _, it := range items {
+		if it.Archived == archived {
+			out = append(out, it)
+		}
+	}
+	return out
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"commandref/paths"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// `ask "how did I port-forward to the staging db?"` ranks the library by
+// meaning rather than by words: items and the question are turned into
+// embeddings by the model in the "ai" config (or the backend ranks them
+// itself). Without a model, or when it can't be reached, it falls back to
+// ranking by the question's words.
+
+type askMatch struct {
+	it     Item
+	score  float64
+	reason string
+}
+
+// words too common in questions to say anything
+var askStopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "how": true, "what": true, "which": true,
+	"did": true, "do": true, "does": true, "i": true, "my": true, "me": true,
+	"to": true, "of": true, "in": true, "on": true, "for": true, "with": true,
+	"is": true, "it": true, "that": true, "this": true, "and": true, "or": true,
+	"was": true, "again": true, "command": true, "can": true, "from": true,
+}
+
+func runAsk(args []string) {
+	fs := flag.NewFlagSet("ask", flag.ExitOnError)
+	n := fs.Int("n", 5, "how many matches to show")
+	keyword := fs.Bool("keyword", false, "rank by words only, without a model")
+	var words []string
+	for rest := args; len(rest) > 0; {
+		_ = fs.Parse(rest)
+		if rest = fs.Args(); len(rest) > 0 {
+			words, rest = append(words, rest[0]), rest[1:]
+		}
+	}
+	question := strings.TrimSpace(strings.Join(words, " "))
+	if question == "" {
+		exitErr(errors.New(`usage: commandref ask "how did I port-forward to the staging db?" [-n 5] [--keyword]`))
+	}
+
+	items, freshness, err := listWithFreshness()
+	if err != nil {
+		exitErr(err)
+	}
+	items = append(byArchived(items, false), projectItems()...)
+
+	var matches []askMatch
+	switch {
+	case *keyword:
+		matches = keywordMatches(question, items)
+	case aiProvider() == "":
+		fmt.Println("(keyword search: no model set up under \"ai\" in the config)")
+		matches = keywordMatches(question, items)
+	case freshness != "live" && freshness != "local library":
+		fmt.Printf("(keyword search: %s)\n", freshness)
+		matches = keywordMatches(question, items)
+	default:
+		if matches, err = semanticMatches(question, items); err != nil {
+			fmt.Printf("(keyword search: %s)\n", strings.TrimSpace(err.Error()))
+			matches = keywordMatches(question, items)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("(nothing matches)")
+		return
+	}
+	for i, m := range matches[:min(len(matches), *n)] {
+		if opts.Accessible {
+			fmt.Printf("Match %d: item %s, %s\n  Command: %s\n  Why: %s\n", i+1, displayID(m.it), m.it.Title, m.it.Command, m.reason)
+			continue
+		}
+		fmt.Printf("%d. %s) %s%s%s\n", i+1, displayID(m.it), iconPrefix(m.it), m.it.Title, renderTags(m.it.Tags))
+		fmt.Println("   " + colorize("36", strings.ReplaceAll(m.it.Command, "\n", "\n   ")))
+		fmt.Println("   " + colorize("90", m.reason))
+	}
+}
+
+// sharedTerms lists the question's words found in the item, by field.
+func sharedTerms(question string, it Item) (score float64, reason string) {
+	var found []string
+	for _, w := range indexTerms(question) {
+		if askStopWords[w] {
+			continue
+		}
+		for _, f := range []struct {
+			name   string
+			text   string
+			weight float64
+		}{{"title", it.Title, 2}, {"tags", strings.Join(it.Tags, " "), 2}, {"command", it.Command, 1}, {"notes", it.Notes, 1}} {
+			if wordDistance(w, indexTerms(f.text)) == 0 {
+				score += f.weight
+				found = append(found, fmt.Sprintf("%q in the %s", w, f.name))
+				break
+			}
+		}
+	}
+	if len(found) > 0 {
+		reason = "mentions " + strings.Join(found, ", ")
+	}
+	return score, reason
+}
+
+func keywordMatches(question string, items []Item) []askMatch {
+	var out []askMatch
+	for _, it := range items {
+		if score, reason := sharedTerms(question, it); score > 0 {
+			out = append(out, askMatch{it, score, reason})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	return out
+}
+
+func semanticMatches(question string, items []Item) ([]askMatch, error) {
+	if aiProvider() == "backend" {
+		return backendMatches(question, items)
+	}
+	if e2eEnabled() {
+		return nil, fmt.Errorf("the library is encrypted, so it isn't sent to the model")
+	}
+	items = embeddable(items)
+	vecs, err := itemEmbeddings(items)
+	if err != nil {
+		return nil, err
+	}
+	q, err := aiEmbed([]string{question})
+	if err != nil {
+		return nil, err
+	}
+	var out []askMatch
+	for i, it := range items {
+		sim := cosine(q[0], vecs[i])
+		reason := fmt.Sprintf("close in meaning (%.0f%%)", 100*sim)
+		if _, why := sharedTerms(question, it); why != "" {
+			reason += "; " + why
+		}
+		out = append(out, askMatch{it, sim, reason})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	// only what's nearly as close as the best match
+	for i := range out {
+		if out[i].score <= 0 || out[i].score < out[0].score*0.6 {
+			return out[:i], nil
+		}
+	}
+	return out, nil
+}
+
+// backendMatches lets the backend rank the library itself.
+func backendMatches(question string, items []Item) ([]askMatch, error) {
+	if e2eEnabled() {
+		return nil, fmt.Errorf("the backend can't read encrypted items")
+	}
+	c := newAPIClient()
+	var res []struct {
+		ID     int     `json:"id"`
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	}
+	if err := c.DoJSON("POST", c.Path("/v1/ai/ask"), map[string]any{"query": question}, &res); err != nil {
+		return nil, err
+	}
+	byID := map[int]Item{}
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+	var out []askMatch
+	for _, r := range res {
+		if it, ok := byID[r.ID]; ok {
+			out = append(out, askMatch{it, r.Score, r.Reason})
+		}
+	}
+	return out, nil
+}
+
+// embeddable leaves out what must not leave the machine: --no-log items,
+// project commands and what the sync rules keep local.
+func embeddable(items []Item) []Item {
+	var out []Item
+	for _, it := range items {
+		if why, err := publishBlocked(it); why == "" && err == nil {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func embedText(it Item) string {
+	return strings.Join([]string{it.Title, strings.Join(it.Tags, " "), it.Command, it.Notes}, "\n")
+}
+
+// embeddingCache keeps item vectors between runs, keyed by the item and
+// invalidated by a hash of its text.
+type embeddingCache struct {
+	Model   string                     `json:"model"`
+	Vectors map[string]cachedEmbedding `json:"vectors"`
+}
+
+type cachedEmbedding struct {
+	Hash string    `json:"hash"`
+	Vec  []float64 `json:"vec"`
+}
+
+func embeddingCachePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "embeddings"+profileSuffix()+".json"), nil
+}
+
+func itemEmbeddings(items []Item) ([][]float64, error) {
+	model := aiProvider() + ":" + aiEmbedModel()
+	cache := embeddingCache{Model: model, Vectors: map[string]cachedEmbedding{}}
+	p, err := embeddingCachePath()
+	if err != nil {
+		return nil, err
+	}
+	if b, err := os.ReadFile(p); err == nil {
+		var c embeddingCache
+		if json.Unmarshal(b, &c) == nil && c.Model == model && c.Vectors != nil {
+			cache = c
+		}
+	}
+
+	keys := make([]string, len(items))
+	hashes := make([]string, len(items))
+	var missing []int
+	var texts []string
+	for i, it := range items {
+		keys[i] = it.UUID
+		if keys[i] == "" {
+			keys[i] = displayID(it)
+		}
+		sum := sha256.Sum256([]byte(embedText(it)))
+		hashes[i] = hex.EncodeToString(sum[:8])
+		if e, ok := cache.Vectors[keys[i]]; !ok || e.Hash != hashes[i] {
+			missing = append(missing, i)
+			texts = append(texts, embedText(it))
+		}
+	}
+	if len(missing) > 0 {
+		if len(missing) > 20 {
+			fmt.Fprintf(os.Stderr, "(indexing %d items for ask...)\n", len(missing))
+		}
+		for start := 0; start < len(texts); start += 64 {
+			end := min(start+64, len(texts))
+			vecs, err := aiEmbed(texts[start:end])
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range vecs {
+				i := missing[start+k]
+				cache.Vectors[keys[i]] = cachedEmbedding{hashes[i], v}
+			}
+		}
+		kept := map[string]cachedEmbedding{} // without deleted items
+		for _, k := range keys {
+			kept[k] = cache.Vectors[k]
+		}
+		cache.Vectors = kept
+		if b, err := json.Marshal(cache); err == nil {
+			_ = writeFileAtomic(p, b, 0600)
+		}
+	}
+
+	out := make([][]float64, len(items))
+	for i := range items {
+		out[i] = cache.Vectors[keys[i]].Vec
+	}
+	return out, nil
+}
+
+func aiEmbedModel() string {
+	if cfg.AI.EmbedModel != "" {
+		return cfg.AI.EmbedModel
+	}
+	if aiProvider() == "ollama" {
+		return "nomic-embed-text"
+	}
+	return "text-embedding-3-small"
+}
+
+// aiEmbed turns texts into vectors with the configured provider.
+func aiEmbed(texts []string) ([][]float64, error) {
+	url := aiURL("/embeddings")
+	if aiProvider() == "ollama" {
+		url = aiURL("/api/embed")
+	}
+	body, err := aiPost(url, map[string]any{"model": aiEmbedModel(), "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Embeddings [][]float64 `json:"embeddings"` // ollama
+		Data       []struct {  // openai
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("%s: unexpected answer: %w", url, err)
+	}
+	for _, d := range out.Data {
+		out.Embeddings = append(out.Embeddings, d.Embedding)
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("%s: got %d embeddings for %d texts", url, len(out.Embeddings), len(texts))
+	}
+	return out.Embeddings, nil
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
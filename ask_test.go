@@ -0,0 +1,72 @@
+package main
+
+import (
+	"commandref/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSemanticMatchesSendsOnlyEmbeddable(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Input []string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.Input...)
+		var out struct {
+			Data []map[string][]float64 `json:"data"`
+		}
+		for range body.Input {
+			out.Data = append(out.Data, map[string][]float64{"embedding": {1, 0}})
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+
+	items := []Item{
+		{ID: 1, UUID: "u1", Title: "port forward", Command: "ssh -L 5432:db:5432 bastion"},
+		{ID: 2, UUID: "u2", Title: "root password", Command: "mysql -pS3cret", NoLog: true},
+		{ID: 3, UUID: "u3", Title: "vpn", Command: "openvpn corp.ovpn", Tags: []string{"private"}},
+	}
+	tests := []struct {
+		name     string
+		e2e      bool
+		wantErr  bool
+		wantSent []string // titles that reached the model
+	}{
+		{"plain", false, false, []string{"port forward"}},
+		{"encrypted library", true, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("COMMANDREF_HOME", home)
+			sent = nil
+			cfg = &config.Config{Storage: "api"}
+			cfg.AI.Provider, cfg.AI.URL = "openai", srv.URL
+			cfg.Sync.Exclude = []string{"tag:private"}
+			cfg.Encryption.Enabled = tt.e2e
+			cfg.Encryption.KeyFile = filepath.Join(home, "e2e.key")
+			resolvedWorkspace = &workspace{}
+			t.Cleanup(func() { resolvedWorkspace = nil })
+
+			_, err := semanticMatches("how do I reach the db", items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			var titles []string
+			for _, s := range sent {
+				if s != "how do I reach the db" {
+					titles = append(titles, strings.Split(s, "\n")[0])
+				}
+			}
+			if !slices.Equal(titles, tt.wantSent) {
+				t.Errorf("sent %q, want %q", titles, tt.wantSent)
+			}
+		})
+	}
+}
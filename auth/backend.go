@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 )
 
 type CommandrefAuthResponse struct {
@@ -16,11 +17,28 @@ type CommandrefAuthResponse struct {
 	Picture string `json:"picture"`
 }
 
-func exchangeViaBackend(code, verifier, redirectURI string) (*CommandrefAuthResponse, error) {
-	apiBase := os.Getenv("COMMANDREF_API_BASE")
-	if apiBase == "" {
-		apiBase = "http://127.0.0.1:8080"
+// PrepareRequest adds the headers every backend request carries (the api
+// package sets it up: User-Agent and X-Request-ID) and returns the request
+// ID, for error messages.
+var PrepareRequest = func(req *http.Request) string { return "" }
+
+// requestFailed words a failed request's error, quoting its ID.
+func requestFailed(what, body, id string) error {
+	if id == "" {
+		return fmt.Errorf("%s: %s", what, body)
 	}
+	return fmt.Errorf("%s: %s (request %s)", what, strings.TrimSpace(body), id)
+}
+
+func apiBase() string {
+	base := os.Getenv("COMMANDREF_API_BASE")
+	if base == "" {
+		base = "http://127.0.0.1:8080"
+	}
+	return base
+}
+
+func exchangeViaBackend(code, verifier, redirectURI string) (*CommandrefAuthResponse, error) {
 
 	payload := map[string]string{
 		"code":          code,
@@ -29,8 +47,9 @@ func exchangeViaBackend(code, verifier, redirectURI string) (*CommandrefAuthResp
 	}
 	b, _ := json.Marshal(payload)
 
-	req, _ := http.NewRequest("POST", apiBase+"/v1/auth/google/exchange", bytes.NewReader(b))
+	req, _ := http.NewRequest("POST", apiBase()+"/v1/auth/google/exchange", bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
+	id := PrepareRequest(req)
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -40,7 +59,7 @@ func exchangeViaBackend(code, verifier, redirectURI string) (*CommandrefAuthResp
 
 	body, _ := io.ReadAll(res.Body)
 	if res.StatusCode >= 300 {
-		return nil, fmt.Errorf("backend exchange failed: %s", string(body))
+		return nil, requestFailed("backend exchange failed", string(body), id)
 	}
 
 	var out CommandrefAuthResponse
@@ -55,3 +74,32 @@ func exchangeViaBackend(code, verifier, redirectURI string) (*CommandrefAuthResp
 
 	return &out, nil
 }
+
+// validateToken asks the backend who a token belongs to; used when the user
+// pastes a token obtained from the web app instead of doing OAuth here.
+func validateToken(token string) (*CommandrefAuthResponse, error) {
+	req, _ := http.NewRequest("GET", apiBase()+"/v1/me", nil)
+	id := PrepareRequest(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("token was rejected by the backend")
+	}
+	if res.StatusCode >= 300 {
+		return nil, requestFailed("token validation failed", string(body), id)
+	}
+
+	var out CommandrefAuthResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	out.Token = token
+	return &out, nil
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 const defaultGoogleClientID = "YOUR_DESKTOP_CLIENT_ID.apps.googleusercontent.com"
@@ -34,3 +35,28 @@ func Login() error {
 	// NEXT: send tokens.IDToken to your backend, get your JWT, store it.
 	return nil
 }
+
+// LoginWithToken saves a session for a token the user obtained elsewhere
+// (e.g. copied from the web app when OAuth is blocked on their network),
+// after checking it with the backend.
+func LoginWithToken(token string) (*Session, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("empty token")
+	}
+
+	resp, err := validateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Session{
+		Token: token,
+		Email: resp.Email,
+		Name:  resp.Name,
+	}
+	if err := SaveSession(s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
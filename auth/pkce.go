@@ -57,7 +57,6 @@ func LoginWithGooglePKCE(clientID string) (*CommandrefAuthResponse, error) {
 
 	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
-		fmt.Println("query:", q)
 		if q.Get("state") != state {
 			http.Error(w, "Invalid state", http.StatusBadRequest)
 			return
@@ -70,7 +69,6 @@ func LoginWithGooglePKCE(clientID string) (*CommandrefAuthResponse, error) {
 			return
 		}
 		code := q.Get("code")
-		fmt.Println("code:", code)
 		if code == "" {
 			http.Error(w, "Missing code", http.StatusBadRequest)
 			return
@@ -90,9 +88,9 @@ func LoginWithGooglePKCE(clientID string) (*CommandrefAuthResponse, error) {
 	// 2) Build auth URL and open browser
 	authURL := buildGoogleAuthURL(clientID, redirectURI, state, challenge)
 	fmt.Println("Opening browser for Google login...")
-	fmt.Println("authURL: ", authURL) // fallback in case browser open fails
-
-	_ = openBrowser(authURL)
+	if err := openBrowser(authURL); err != nil {
+		fmt.Println("Open this URL to log in:", authURL)
+	}
 
 	// 3) Wait for callback or timeout
 	var code string
@@ -138,15 +136,10 @@ func exchangeCodeForTokens(clientID, code, verifier, redirectURI string) (*Googl
 	form.Set("code_verifier", verifier)
 	form.Set("redirect_uri", redirectURI)
 	form.Set("grant_type", "authorization_code")
-	// form.Set("client_secret", "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")
-
-	fmt.Printf("headers: " + verifier + " " + redirectURI + " " + clientID + "\n")
 
 	req, _ := http.NewRequest("POST", "https://oauth2.googleapis.com/token", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	fmt.Println("req:", req)
-
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -154,7 +147,6 @@ func exchangeCodeForTokens(clientID, code, verifier, redirectURI string) (*Googl
 	defer res.Body.Close()
 
 	body, _ := io.ReadAll(res.Body)
-	fmt.Println("response body:", string(body))
 
 	var tr GoogleTokenResponse
 	_ = json.Unmarshal(body, &tr)
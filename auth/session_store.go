@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"commandref/keychain"
+	"commandref/paths"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -13,22 +15,44 @@ type Session struct {
 	Email     string `json:"email"`
 	Name      string `json:"name"`
 	CreatedAt string `json:"createdAt"`
+	// the backend that issued the token
+	APIBase string `json:"apiBase,omitempty"`
+	// the token is in the OS keychain, not in the file
+	Keychain bool `json:"keychain,omitempty"`
 }
 
-func sessionPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+// UseKeychain makes SaveSession put the token in the OS keychain
+// ("session_storage": "keychain" in the config).
+var UseKeychain bool
+
+// SessionFile is where the session is kept (without the token when it's
+// in the keychain).
+func SessionFile() (string, error) {
+	return sessionPath()
+}
+
+func keychainAccount() string {
+	// ":" can't appear in a {{secret:NAME}}, so this never collides
+	return "session:" + orDefault(os.Getenv("COMMANDREF_PROFILE"), "default")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
 	}
-	return filepath.Join(home, ".commandref", "session.json"), nil
+	return s
 }
 
-func ensureCommandrefDir() error {
-	p, err := sessionPath()
+func sessionPath() (string, error) {
+	dir, err := paths.DataDir()
 	if err != nil {
-		return err
+		return "", err
+	}
+	// every --profile keeps its own login
+	if p := os.Getenv("COMMANDREF_PROFILE"); p != "" {
+		return filepath.Join(dir, "session-"+p+".json"), nil
 	}
-	return os.MkdirAll(filepath.Dir(p), 0755)
+	return filepath.Join(dir, "session.json"), nil
 }
 
 func SaveSession(s Session) error {
@@ -36,10 +60,6 @@ func SaveSession(s Session) error {
 		return fmt.Errorf("empty token")
 	}
 
-	if err := ensureCommandrefDir(); err != nil {
-		return err
-	}
-
 	p, err := sessionPath()
 	if err != nil {
 		return err
@@ -49,6 +69,16 @@ func SaveSession(s Session) error {
 	if s.CreatedAt == "" {
 		s.CreatedAt = time.Now().Format(time.RFC3339)
 	}
+	if s.APIBase == "" {
+		s.APIBase = apiBase()
+	}
+	s.Keychain = UseKeychain
+	if s.Keychain {
+		if err := keychain.Set(keychainAccount(), s.Token); err != nil {
+			return fmt.Errorf("saving the token in the keychain: %w", err)
+		}
+		s.Token = ""
+	}
 
 	b, err := json.MarshalIndent(s, "", " ")
 	if err != nil {
@@ -82,6 +112,11 @@ func LoadSession() (*Session, error) {
 	if err := json.Unmarshal(b, &s); err != nil {
 		return nil, err
 	}
+	if s.Keychain {
+		if s.Token, err = keychain.Get(keychainAccount()); err != nil {
+			return nil, fmt.Errorf("the login token isn't in the keychain (%v); run: commandref login", err)
+		}
+	}
 	if s.Token == "" {
 		return nil, nil
 	}
@@ -93,6 +128,9 @@ func ClearSession() error {
 	if err != nil {
 		return err
 	}
+	if s, _ := LoadSession(); s != nil && s.Keychain {
+		_ = keychain.Delete(keychainAccount())
+	}
 	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Commands that take several items (`rm 3 7 9-12`, `copy`, `show`,
+// `archive`, `tag`) read them with parseIDArgs and go through forEachID,
+// which runs the API requests a few at a time and reports per item.
+
+var idRange = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+// maxRange keeps a typo like 1-10000 from turning into ten thousand requests.
+const maxRange = 500
+
+// bulkWorkers is how many API requests a bulk command has in flight.
+const bulkWorkers = 6
+
+// splitIDArgs takes the leading item references off args; the rest (from
+// the first flag on) is left for the command's flag set.
+func splitIDArgs(args []string) (refs, rest []string) {
+	for i, a := range args {
+		if strings.HasPrefix(a, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// parseIDArgs reads item references: IDs, slugs, UUID prefixes and ranges
+// like 9-12, in order and without repeats.
+func parseIDArgs(refs []string) ([]int, error) {
+	return parseIDRefs(refs, refCached)
+}
+
+// parseViewIDs is parseIDArgs for show and copy (see parseViewID).
+func parseViewIDs(refs []string) ([]int, error) {
+	return parseIDRefs(refs, refLegacy|refCached)
+}
+
+// parseTargetIDs is parseIDArgs for commands that change the items (see
+// parseTargetID).
+func parseTargetIDs(refs []string) ([]int, error) {
+	return parseIDRefs(refs, 0)
+}
+
+// parseIDRefs is parseIDArgs, read as how says (see parseItemRef).
+func parseIDRefs(refs []string, how int) ([]int, error) {
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("missing <id>")
+	}
+	var ids []int
+	seen := map[int]bool{}
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, ref := range refs {
+		if m := idRange.FindStringSubmatch(ref); m != nil {
+			from, _ := strconv.Atoi(m[1])
+			to, _ := strconv.Atoi(m[2])
+			if from <= 0 || to < from {
+				return nil, fmt.Errorf("invalid range: %s", ref)
+			}
+			if to-from >= maxRange {
+				return nil, fmt.Errorf("range %s is over %d items", ref, maxRange)
+			}
+			for n := from; n <= to; n++ {
+				id := n
+				if how&refLegacy != 0 {
+					id = resolveLegacyID(n)
+				}
+				add(id)
+			}
+			continue
+		}
+		id, err := parseItemRef(ref, how)
+		if err != nil {
+			return nil, err
+		}
+		add(id)
+	}
+	return ids, nil
+}
+
+// forEachID calls fn for every ID, several at once against the API (a local
+// library is one file, so there it goes one by one), and prints each
+// result in order: fn's message, or the error. It returns the exit code:
+// 0, 3 if items were only missing, else that of the other failure.
+func forEachID(ids []int, fn func(id int) (string, error)) int {
+	msgs := make([]string, len(ids))
+	errs := make([]error, len(ids))
+	workers := 1
+	if !usingLocalStore() {
+		workers = bulkWorkers
+		// send what was queued offline now, rather than have every worker try
+		if s, ok := openStore().(apiStore); ok {
+			_ = s.reachable()
+		}
+		// and settle the workspace and the key before they all ask
+		currentWorkspace()
+		if e2eConfigured() {
+			_, _ = currentE2EKey()
+		}
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			msgs[i], errs[i] = fn(id)
+		}()
+	}
+	wg.Wait()
+
+	code, failed := 0, 0
+	for i, id := range ids {
+		err := errs[i]
+		if err == nil {
+			if msgs[i] != "" {
+				fmt.Println(msgs[i])
+			}
+			continue
+		}
+		failed++
+		c := exitCodeOf(err)
+		if code == 0 || code == exitNotFound {
+			code = c
+		}
+		switch {
+		case opts.ErrorFormat == "json":
+			fmt.Fprintln(os.Stderr, errorJSON(c, err, map[string]any{"id": idLabel(id)}))
+		case c == exitNotFound:
+			fmt.Fprintf(os.Stderr, "#%s: not found\n", idLabel(id))
+		default:
+			fmt.Fprintf(os.Stderr, "#%s: error: %v\n", idLabel(id), err)
+		}
+	}
+	if len(ids) > 1 && failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d failed\n", failed, len(ids))
+	}
+	return code
+}
+
+func idLabel(id int) string {
+	if id < 0 {
+		return "p" + strconv.Itoa(-id)
+	}
+	return strconv.Itoa(id)
+}
+
+// pickIDs opens the multi-select picker over the live items keep accepts
+// (all of them if nil), starting with query as the filter.
+func pickIDs(query string, keep func(Item) bool) ([]int, error) {
+	items, err := openStore().List()
+	if err != nil {
+		return nil, err
+	}
+	items = byArchived(items, false)
+	if keep != nil {
+		items = slices.DeleteFunc(items, func(it Item) bool { return !keep(it) })
+	}
+	sortByID(items)
+	picked, err := pickItems(items, query)
+	if err != nil {
+		if errors.Is(err, errPickCancelled) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ids := make([]int, len(picked))
+	for i, it := range picked {
+		ids[i] = it.ID
+	}
+	return ids, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// itemCache is the last full listing we got from the backend.
+type itemCache struct {
+	FetchedAt string `json:"fetchedAt"`
+	Items     []Item `json:"items"`
+
+	// changes on every write, including our own edits patched in; the search
+	// index records which revision it was built from
+	Rev string `json:"rev"`
+}
+
+func cachePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	// one cache per workspace, so switching never shows another team's items
+	return filepath.Join(dir, "commands"+librarySuffix()+".json"), nil
+}
+
+func saveItemCache(items []Item) {
+	writeItemCache(&itemCache{FetchedAt: time.Now().UTC().Format(time.RFC3339), Items: items})
+}
+
+func writeItemCache(c *itemCache) {
+	p, err := cachePath()
+	if err != nil {
+		return
+	}
+	c.Rev = strconv.FormatInt(time.Now().UnixNano(), 36)
+	b, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	if writeFileAtomic(p, b, 0600) == nil {
+		saveIndex(buildIndex(c.Items, c.Rev))
+	}
+}
+
+func loadItemCache() (*itemCache, error) {
+	p, err := cachePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var c itemCache
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// isNetworkErr reports whether err means the backend couldn't be reached at all.
+func isNetworkErr(err error) bool {
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// listWithFreshness lists the library for read-only reports. When the backend
+// is unreachable the store falls back to the cache; freshness says how old
+// that data is.
+func listWithFreshness() (items []Item, freshness string, err error) {
+	if usingLocalStore() {
+		items, err := localStore{}.List()
+		return items, "local library", err
+	}
+
+	quietOffline = true
+	items, err = openStore().List()
+	quietOffline = false
+	if err != nil {
+		return nil, "", err
+	}
+	if servedOffline != nil {
+		return items, "offline, cached " + cacheAge(servedOffline), nil
+	}
+	return items, "live", nil
+}
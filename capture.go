@@ -0,0 +1,105 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultCaptureKB = 64
+
+// capturedOutput is the last output recorded by `run --capture` for an item.
+type capturedOutput struct {
+	CapturedAt string `json:"capturedAt"`
+	ExitCode   int    `json:"exitCode"`
+	Truncated  bool   `json:"truncated"`
+	Output     string `json:"output"`
+}
+
+// tailBuffer keeps only the last max bytes written to it. stdout and stderr
+// share one buffer so the record reads the way the terminal did.
+type tailBuffer struct {
+	mu        sync.Mutex
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func captureLimit() int {
+	kb := defaultCaptureKB
+	if cfg != nil && cfg.CaptureMaxKB > 0 {
+		kb = cfg.CaptureMaxKB
+	}
+	return kb * 1024
+}
+
+func outputPath(id int) (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "outputs")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strconv.Itoa(id)+".json"), nil
+}
+
+func saveCapturedOutput(id, exitCode int, t *tailBuffer) error {
+	t.mu.Lock()
+	rec := capturedOutput{
+		CapturedAt: time.Now().UTC().Format(time.RFC3339),
+		ExitCode:   exitCode,
+		Truncated:  t.truncated,
+		Output:     string(t.buf),
+	}
+	t.mu.Unlock()
+
+	p, err := outputPath(id)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+func loadCapturedOutput(id int) (*capturedOutput, error) {
+	p, err := outputPath(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var rec capturedOutput
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
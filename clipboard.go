@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Terminals commonly cap OSC 52 payloads around 100k of base64.
+const osc52MaxBytes = 74994
+
+// copyToClipboard tries the native clipboard tools for this OS and falls
+// back to the OSC 52 escape sequence, which the local terminal emulator
+// handles even when we're running on a remote host over SSH.
+func copyToClipboard(text string) error {
+	mode := ""
+	if cfg != nil {
+		mode = strings.ToLower(cfg.Clipboard)
+	}
+	if mode == "osc52" || (mode == "" && inSSHSession()) {
+		return osc52Copy(text)
+	}
+
+	for _, argv := range nativeClipboardCommands() {
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return osc52Copy(text)
+}
+
+func inSSHSession() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+func nativeClipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	default:
+		var cmds [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, []string{"wl-copy"})
+		}
+		if os.Getenv("DISPLAY") != "" {
+			cmds = append(cmds,
+				[]string{"xclip", "-selection", "clipboard"},
+				[]string{"xsel", "--clipboard", "--input"})
+		}
+		return cmds
+	}
+}
+
+func osc52Copy(text string) error {
+	if len(text) > osc52MaxBytes {
+		return fmt.Errorf("command too large for OSC 52 clipboard (%d bytes, max %d)", len(text), osc52MaxBytes)
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("no clipboard tool found and no terminal for OSC 52")
+	}
+	defer tty.Close()
+
+	_, err = tty.WriteString(osc52Sequence(text))
+	return err
+}
+
+func osc52Sequence(text string) string {
+	return passthrough("\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a")
+}
+
+// passthrough wraps an escape sequence so tmux/screen hand it on to the
+// outer terminal instead of swallowing it.
+func passthrough(seq string) string {
+	switch {
+	case os.Getenv("TMUX") != "":
+		// tmux passthrough: wrap in DCS and double every ESC inside
+		return "\033Ptmux;" + strings.ReplaceAll(seq, "\033", "\033\033") + "\033\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		return "\033P" + seq + "\033\\"
+	}
+	return seq
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collection is a named notebook of items. An item belongs to at most one
+// collection, stored by name in its "collection" field; the collections
+// themselves are an API resource (/v1/collections) or, with local storage,
+// a list in commands.json.
+type collection struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func listCollections() ([]collection, error) {
+	if usingLocalStore() {
+		db, err := loadDB()
+		if err != nil {
+			return nil, err
+		}
+		// synced libraries may carry item collections we never created here
+		out := append([]collection(nil), db.Collections...)
+		for _, it := range db.Items {
+			if it.Collection != "" && findCollection(out, it.Collection) == nil {
+				out = append(out, collection{Name: it.Collection})
+			}
+		}
+		return out, nil
+	}
+	c := newAPIClient()
+	var out []collection
+	if err := c.DoJSON("GET", c.Path("/v1/collections"), nil, &out); err != nil {
+		return nil, apiErr(err)
+	}
+	return out, nil
+}
+
+func createCollection(name string) (*collection, error) {
+	if usingLocalStore() {
+		db, err := loadDB()
+		if err != nil {
+			return nil, err
+		}
+		col := collection{ID: len(db.Collections) + 1, Name: name, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+		for _, c := range db.Collections {
+			if c.ID >= col.ID {
+				col.ID = c.ID + 1
+			}
+		}
+		db.Collections = append(db.Collections, col)
+		if err := saveDB(db); err != nil {
+			return nil, err
+		}
+		if usingGitStore() {
+			return &col, gitCommit("Add collection " + name)
+		}
+		return &col, nil
+	}
+	c := newAPIClient()
+	var created collection
+	if err := c.DoJSON("POST", c.Path("/v1/collections"), map[string]any{"name": name}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func deleteCollection(col collection) error {
+	if usingLocalStore() {
+		db, err := loadDB()
+		if err != nil {
+			return err
+		}
+		kept := db.Collections[:0]
+		for _, c := range db.Collections {
+			if !strings.EqualFold(c.Name, col.Name) {
+				kept = append(kept, c)
+			}
+		}
+		db.Collections = kept
+		if err := saveDB(db); err != nil {
+			return err
+		}
+		if usingGitStore() {
+			return gitCommit("Remove collection " + col.Name)
+		}
+		return nil
+	}
+	c := newAPIClient()
+	return apiErr(c.DoJSON("DELETE", c.Path("/v1/collections/"+strconv.Itoa(col.ID)), nil, nil))
+}
+
+func findCollection(cols []collection, name string) *collection {
+	for i := range cols {
+		if strings.EqualFold(cols[i].Name, name) {
+			return &cols[i]
+		}
+	}
+	return nil
+}
+
+// resolveCollection returns the collection's stored name, or exits with
+// not found (3) if there is no such collection.
+func resolveCollection(name string) string {
+	cols, err := listCollections()
+	if err != nil {
+		exitErr(err)
+	}
+	col := findCollection(cols, strings.TrimSpace(name))
+	if col == nil {
+		exitWith(exitNotFound, fmt.Errorf("no collection %q (create it with: commandref collection create %q)", name, name))
+	}
+	return col.Name
+}
+
+func inCollection(items []Item, name string) []Item {
+	var out []Item
+	for _, it := range items {
+		if strings.EqualFold(it.Collection, name) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func runCollection(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list", "ls":
+		cols, err := listCollections()
+		if err != nil {
+			exitErr(err)
+		}
+		if len(cols) == 0 {
+			fmt.Println("(no collections) create one with: commandref collection create <name>")
+			return
+		}
+		counts := map[string]int{}
+		if items, err := openStore().List(); err == nil {
+			for _, it := range items {
+				counts[strings.ToLower(it.Collection)]++
+			}
+		}
+		sort.Slice(cols, func(i, j int) bool { return strings.ToLower(cols[i].Name) < strings.ToLower(cols[j].Name) })
+		for _, c := range cols {
+			fmt.Printf("%s  (%d)\n", c.Name, counts[strings.ToLower(c.Name)])
+		}
+
+	case "create":
+		if len(args) < 2 || strings.TrimSpace(args[1]) == "" {
+			exitErr(errors.New("usage: commandref collection create <name>"))
+		}
+		name := strings.TrimSpace(args[1])
+		cols, err := listCollections()
+		if err != nil {
+			exitErr(err)
+		}
+		if findCollection(cols, name) != nil {
+			exitErr(fmt.Errorf("collection %q already exists", name))
+		}
+		col, err := createCollection(name)
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Printf("Created collection %s\n", col.Name)
+
+	case "rm":
+		fs := flag.NewFlagSet("collection rm", flag.ExitOnError)
+		force := fs.Bool("force", false, "also remove a non-empty collection (its items are kept, outside any collection)")
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			exitErr(errors.New("usage: commandref collection rm [--force] <name>"))
+		}
+		cols, err := listCollections()
+		if err != nil {
+			exitErr(err)
+		}
+		col := findCollection(cols, fs.Arg(0))
+		if col == nil {
+			exitWith(exitNotFound, fmt.Errorf("no collection %q", fs.Arg(0)))
+		}
+		st := openStore()
+		items, err := st.List()
+		if err != nil {
+			exitErr(err)
+		}
+		members := inCollection(items, col.Name)
+		if len(members) > 0 && !*force {
+			exitErr(fmt.Errorf("%s has %d items; move them first or use --force", col.Name, len(members)))
+		}
+		for _, it := range members {
+			if _, err := st.Update(it.ID, map[string]any{"collection": ""}); err != nil {
+				exitErr(err)
+			}
+		}
+		if col.ID != 0 || !usingLocalStore() {
+			if err := deleteCollection(*col); err != nil {
+				exitErr(err)
+			}
+		}
+		fmt.Printf("Removed collection %s\n", col.Name)
+
+	default:
+		exitErr(fmt.Errorf("unknown collection command %q (list, create, rm)", args[0]))
+	}
+}
+
+// runMove is `commandref mv <id> --collection <name>`; --collection ""
+// takes the item out of its collection.
+func runMove(args []string) {
+	id, to, err := parseMoveArgs(args)
+	if err != nil {
+		exitErr(err)
+	}
+	refuseProjectID(id)
+
+	name := ""
+	if strings.TrimSpace(to) != "" {
+		name = resolveCollection(to)
+	}
+	st := openStore()
+	it := mustGetItem(st, id)
+	if strings.EqualFold(it.Collection, name) {
+		fmt.Printf("#%d is already there\n", it.ID)
+		return
+	}
+	if _, err := st.Update(id, map[string]any{"collection": name}); err != nil {
+		exitErr(err)
+	}
+	if name == "" {
+		fmt.Printf("Moved #%d out of %s\n", it.ID, it.Collection)
+		return
+	}
+	fmt.Printf("Moved #%d to %s\n", it.ID, name)
+}
+
+// parseMoveArgs wants --collection spelled out: without it a stray
+// `mv 12 work` would quietly take #12 out of its collection.
+func parseMoveArgs(args []string) (id int, to string, err error) {
+	usage := errors.New(`usage: commandref mv <id> --collection <name>  (--collection "" takes it out)`)
+	if len(args) == 0 {
+		return 0, "", usage
+	}
+	if id, err = parseTargetID(args[0]); err != nil {
+		return 0, "", err
+	}
+	fs := flag.NewFlagSet("mv", flag.ExitOnError)
+	fs.StringVar(&to, "collection", "", `target collection ("" to take it out of its collection)`)
+	_ = fs.Parse(args[1:])
+	given := false
+	fs.Visit(func(f *flag.Flag) { given = given || f.Name == "collection" })
+	if !given || fs.NArg() > 0 {
+		return 0, "", usage
+	}
+	return id, to, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"commandref/config"
+	"testing"
+)
+
+func TestParseMoveArgs(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{Storage: "local"}
+	if _, err := (localStore{}).Create(Item{Title: "Ping", Command: "ping example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantTo  string
+		wantErr bool
+	}{
+		{"into a collection", []string{"1", "--collection", "net"}, "net", false},
+		{"out of its collection", []string{"1", "--collection", ""}, "", false},
+		{"out, with =", []string{"1", "--collection="}, "", false},
+		{"no flag", []string{"1"}, "", true},
+		{"bare name", []string{"1", "net"}, "", true},
+		{"no id", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, to, err := parseMoveArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (id != 1 || to != tt.wantTo) {
+				t.Errorf("got #%d to %q, want #1 to %q", id, to, tt.wantTo)
+			}
+		})
+	}
+}
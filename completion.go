@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Shell completion and the Ctrl-G widget run on every keypress, so they
+// never touch the network: they read the item cache (or the local library)
+// within completionBudget, and when the cache is stale or missing they
+// start a background refresh, at most once per refreshDebounce.
+const (
+	completionBudget = 150 * time.Millisecond
+	refreshDebounce  = 30 * time.Second
+)
+
+var commandNames = []string{
+	"add", "ai", "alias", "api", "archive", "ask", "collection", "copied", "copy", "daemon", "digest", "doctor",
+	"edit", "encryption", "explain", "export", "import", "jobs", "keys", "kill", "list", "login", "logout",
+	"logs", "merge-store", "migrate", "mv", "pair", "pick", "playbook", "project", "publish", "recent", "rm", "run", "runs",
+	"scripts", "search", "secret", "secure", "setup", "share", "show", "slugs", "stats", "suggest", "sync", "tag", "tags", "test",
+	"unarchive", "unshare", "version", "watch", "whoami", "widget", "workflow",
+	"workspace", "completion",
+}
+
+// cachedItems returns what's known locally, or nothing if that takes
+// longer than the budget.
+func cachedItems() []Item {
+	ch := make(chan []Item, 1)
+	go func() { ch <- readCachedItems() }()
+	select {
+	case items := <-ch:
+		return items
+	case <-time.After(completionBudget):
+		return nil
+	}
+}
+
+func readCachedItems() []Item {
+	if usingLocalStore() {
+		db, err := loadDB()
+		if err != nil {
+			return nil
+		}
+		return db.Items
+	}
+	c, err := loadItemCache()
+	if err != nil {
+		triggerRefresh()
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339, c.FetchedAt); err != nil || time.Since(t) > searchCacheTTL {
+		triggerRefresh()
+	}
+	return c.Items
+}
+
+// triggerRefresh starts `commandref __refresh` in the background unless one
+// was started within refreshDebounce.
+func triggerRefresh() {
+	p, err := cachePath()
+	if err != nil {
+		return
+	}
+	stamp := p + ".refresh"
+	if st, err := os.Stat(stamp); err == nil && time.Since(st.ModTime()) < refreshDebounce {
+		return
+	}
+	if os.WriteFile(stamp, nil, 0600) != nil {
+		return
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return
+	}
+	args := []string{"__refresh"}
+	if opts.Workspace != "" {
+		args = append([]string{"--workspace", opts.Workspace}, args...)
+	}
+	cmd := exec.Command(self, args...)
+	detachProcess(cmd)
+	if cmd.Start() == nil {
+		_ = cmd.Process.Release()
+	}
+}
+
+// runRefresh is the background half of triggerRefresh: a listing, which
+// rewrites the cache.
+func runRefresh() {
+	quietOffline = true
+	_, _ = openStore().List()
+}
+
+// runComplete is `commandref __complete <what>`, called by the completion
+// scripts: one candidate per line, with a tab before its description.
+func runComplete(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	switch args[0] {
+	case "commands":
+		for _, c := range commandNames {
+			fmt.Println(c)
+		}
+	case "ids":
+		items := byArchived(cachedItems(), false)
+		sortByID(items)
+		for _, it := range append(items, projectItems()...) {
+			fmt.Printf("%s\t%s\n", displayID(it), strings.ReplaceAll(it.Title, "\t", " "))
+		}
+	case "tags", "collections":
+		seen := map[string]bool{}
+		for _, it := range cachedItems() {
+			if args[0] == "tags" {
+				for _, t := range it.Tags {
+					seen[t] = true
+				}
+			} else if it.Collection != "" {
+				seen[it.Collection] = true
+			}
+		}
+		var out []string
+		for v := range seen {
+			out = append(out, v)
+		}
+		sort.Strings(out)
+		for _, v := range out {
+			fmt.Println(v)
+		}
+	}
+}
+
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		exitErr(errors.New("usage: commandref completion zsh|bash|fish"))
+	}
+	switch args[0] {
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		exitErr(fmt.Errorf("unsupported shell: %s", args[0]))
+	}
+}
+
+const zshCompletion = `# commandref completion: eval "$(commandref completion zsh)"
+_commandref() {
+  local -a vals
+  if (( CURRENT == 2 )); then
+    vals=(${(f)"$(commandref __complete commands 2>/dev/null)"})
+    compadd -- $vals
+    return
+  fi
+  case $words[CURRENT-1] in
+    --tags|--exclude-tag)
+      vals=(${(f)"$(commandref __complete tags 2>/dev/null)"}); compadd -- $vals; return ;;
+    --collection)
+      vals=(${(f)"$(commandref __complete collections 2>/dev/null)"}); compadd -- $vals; return ;;
+  esac
+  case $words[2] in
+    show|run|copy|edit|explain|rm|share|unshare|archive|unarchive|mv)
+      (( CURRENT == 3 )) || return
+      vals=(${${(f)"$(commandref __complete ids 2>/dev/null)"}//$'\t'/:})
+      _describe 'command' vals ;;
+  esac
+}
+compdef _commandref commandref
+`
+
+const bashCompletion = `# commandref completion: eval "$(commandref completion bash)"
+_commandref() {
+  local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} what=
+  if (( COMP_CWORD == 1 )); then
+    what=commands
+  elif [[ $prev == --tags || $prev == --exclude-tag ]]; then
+    what=tags
+  elif [[ $prev == --collection ]]; then
+    what=collections
+  elif (( COMP_CWORD == 2 )); then
+    case ${COMP_WORDS[1]} in
+      show|run|copy|edit|explain|rm|share|unshare|archive|unarchive|mv) what=ids ;;
+    esac
+  fi
+  [[ -n $what ]] || return
+  local line vals=()
+  while IFS= read -r line; do
+    vals+=("${line%%$'\t'*}")
+  done < <(commandref __complete $what 2>/dev/null)
+  COMPREPLY=($(compgen -W "${vals[*]}" -- "$cur"))
+}
+complete -F _commandref commandref
+`
+
+const fishCompletion = `# commandref completion: commandref completion fish | source
+complete -c commandref -f
+complete -c commandref -n '__fish_use_subcommand' -a '(commandref __complete commands 2>/dev/null)'
+complete -c commandref -n '__fish_seen_subcommand_from show run copy edit explain rm share unshare archive unarchive mv; and test (count (commandline -opc)) -eq 2' -a '(commandref __complete ids 2>/dev/null)'
+complete -c commandref -l tags -x -a '(commandref __complete tags 2>/dev/null)'
+complete -c commandref -l exclude-tag -x -a '(commandref __complete tags 2>/dev/null)'
+complete -c commandref -l collection -x -a '(commandref __complete collections 2>/dev/null)'
+`
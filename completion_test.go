@@ -0,0 +1,14 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommandNames(t *testing.T) {
+	for _, c := range []string{"workflow", "playbook", "pick", "completion"} {
+		if !slices.Contains(commandNames, c) {
+			t.Errorf("%s is not completed", c)
+		}
+	}
+}
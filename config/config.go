@@ -0,0 +1,270 @@
+package config
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type Config struct {
+	// tag name -> color name ("red", "bright-blue") or 256-color number ("208")
+	TagColors map[string]string `json:"tag_colors"`
+
+	// plain labeled output without color or decoration (same as --accessible)
+	Accessible bool `json:"accessible"`
+
+	// check for new releases (at most once a day) and print a notice
+	UpdateCheck bool `json:"update_check"`
+
+	// "datetime" (default), "relative", "date", "rfc3339" or a Go layout
+	TimeFormat string `json:"time_format"`
+	// "local" (default) or "utc"
+	TimeZone string `json:"time_zone"`
+
+	// HMAC secret for `export --post`; COMMANDREF_WEBHOOK_SECRET overrides it
+	ExportWebhookSecret string `json:"export_webhook_secret"`
+
+	// "" picks automatically (OSC 52 over SSH, else pbcopy/wl-copy/xclip/xsel/clip);
+	// "osc52" always uses the terminal escape sequence
+	Clipboard string `json:"clipboard"`
+
+	// "api" (default) keeps the library on the backend; "local" keeps it in
+	// commands.json in the data dir and needs no login; "git" is like local,
+	// but in a git repo (sync.git) with a commit for every change
+	Storage string `json:"storage"`
+
+	// where the login token is kept: "file" (default: session.json in the
+	// data dir) or "keychain" (the OS keychain; `commandref secure` moves it)
+	SessionStorage string `json:"session_storage"`
+
+	// how items are named in output: "number" (default), "slug" (its slug,
+	// or the number while it has none) or "uuid" (the first 8 hex digits);
+	// every form works as an <id> argument
+	IDDisplay string `json:"id_display"`
+
+	// columns of `list --long` (default id, title, cmd, tags); also
+	// collection, notes, created, updated, used, runs, copies, last-used
+	ListColumns []string `json:"list_columns"`
+
+	// log every `copy` for `commandref copied` (off by default)
+	CopyHistory bool `json:"copy_history"`
+
+	// how much output `run --capture` keeps per item (default 64)
+	CaptureMaxKB int `json:"capture_max_kb"`
+
+	// a desktop notification when a `run` (or --detach job) takes longer
+	// than this, e.g. "30s" or "2m"; empty or "off" never notifies
+	NotifyAfter string `json:"notify_after"`
+
+	Sync SyncConfig `json:"sync"`
+
+	Hooks HooksConfig `json:"hooks"`
+
+	Daemon DaemonConfig `json:"daemon"`
+
+	Picker PickerConfig `json:"picker"`
+
+	// shell for `run`: "/bin/zsh" (default), "bash", "sh"..., or on Windows
+	// "powershell" (default), "pwsh" or "cmd"
+	Shell string `json:"shell"`
+	// rewrite $VAR / ${VAR} in commands to %VAR% (cmd) or $env:VAR
+	// (PowerShell) when running them there
+	TranslateEnvRefs bool `json:"translate_env_refs"`
+
+	// `run` marks where each command starts and ends with OSC 133 and
+	// iTerm2/WezTerm user vars when stdout is a terminal; "off" disables it
+	TerminalMarks string `json:"terminal_marks"`
+
+	// provider for `explain`: a shell command that gets
+	// COMMANDREF_EXPLAIN_PROGRAM ("git commit") and COMMANDREF_EXPLAIN_TOKEN
+	// (a flag, or empty for the program itself) and prints a one-line
+	// description, or nothing to fall back to the bundled table and man
+	ExplainCommand string `json:"explain_command"`
+
+	// where {{secret:NAME}} placeholders come from after the environment
+	// and before the OS keychain: a shell command that gets
+	// COMMANDREF_SECRET=NAME and prints the value (e.g. "pass show db/$COMMANDREF_SECRET")
+	SecretCommand string `json:"secret_command"`
+
+	AI AIConfig `json:"ai"`
+
+	Encryption EncryptionConfig `json:"encryption"`
+
+	// named backends for --profile (or COMMANDREF_PROFILE), e.g.
+	// {"staging": {"api_base": "https://staging.example.com"}}; each keeps
+	// its own login and caches, and may set a shell and env for `run`
+	Profiles map[string]ProfileConfig `json:"profiles"`
+
+	// workspace name or ID ("personal" for your own library) -> shell and
+	// env for `run`, e.g. {"work": {"shell": "bash", "env": {"HTTPS_PROXY":
+	// "http://proxy:3128"}}}; these win over the profile's
+	Workspaces map[string]RunDefaults `json:"workspaces"`
+
+	// tag -> hosts (globs ok) that `run --host` may target for items with
+	// that tag, e.g. {"db": ["prod-db*", "staging-db"]}
+	RunHosts map[string][]string `json:"run_hosts"`
+}
+
+// AIConfig is the model behind `ai add` and `ask`.
+type AIConfig struct {
+	// "openai" (or any OpenAI-compatible endpoint), "ollama" or "backend";
+	// empty means the backend when storage is "api"
+	Provider string `json:"provider"`
+	// default https://api.openai.com/v1 or http://127.0.0.1:11434 (ollama)
+	URL string `json:"url"`
+	// default gpt-4o-mini, or llama3.1 for ollama
+	Model string `json:"model"`
+	// for `ask`: default text-embedding-3-small, or nomic-embed-text
+	EmbedModel string `json:"embed_model"`
+	// COMMANDREF_AI_KEY takes precedence
+	APIKey string `json:"api_key"`
+}
+
+// EncryptionConfig encrypts item fields on this machine before they reach
+// the backend (storage "api"); see `commandref encryption`.
+type EncryptionConfig struct {
+	Enabled bool `json:"enabled"`
+	// file holding a 32-byte key, raw or hex (`encryption keygen` makes one);
+	// without it the key comes from a passphrase
+	KeyFile string `json:"key_file"`
+	// shell command printing the passphrase; COMMANDREF_E2E_PASSPHRASE
+	// takes precedence
+	PassphraseCommand string `json:"passphrase_command"`
+	// keys replaced by `encryption rotate`, still tried for decrypting;
+	// COMMANDREF_E2E_OLD_PASSPHRASE overrides old_passphrase_command
+	OldKeyFiles          []string `json:"old_key_files"`
+	OldPassphraseCommand string   `json:"old_passphrase_command"`
+	// default command and notes; also title, workdir, preRun, postRun
+	// (an encrypted title can't be searched by the backend)
+	Fields []string `json:"fields"`
+}
+
+// PickerConfig weighs what orders the picker (`pick`, Ctrl-G) and
+// `suggest`: how well an item matches the query, how recently it was used,
+// how often, and whether it's used in the current directory or repo. Each
+// defaults to 1; 0 leaves it out.
+type PickerConfig struct {
+	MatchWeight     *float64 `json:"match_weight"`
+	RecencyWeight   *float64 `json:"recency_weight"`
+	FrequencyWeight *float64 `json:"frequency_weight"`
+	ContextWeight   *float64 `json:"context_weight"`
+}
+
+type ProfileConfig struct {
+	APIBase string `json:"api_base"`
+	RunDefaults
+}
+
+// RunDefaults apply to items that don't say otherwise: the shell replaces
+// the top-level "shell", the env goes under the item's own (values may use
+// {{secret:NAME}}).
+type RunDefaults struct {
+	Shell string            `json:"shell"`
+	Env   map[string]string `json:"env"`
+}
+
+type DaemonConfig struct {
+	// listen address for `commandref daemon` (default 127.0.0.1:7878)
+	Addr string `json:"addr"`
+	// browser origins allowed to call the daemon, e.g.
+	// "chrome-extension://<id>"; empty allows any extension origin
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// HooksConfig holds shell commands run on state changes. They get
+// COMMANDREF_EVENT plus event metadata in their environment.
+type HooksConfig struct {
+	// after a successful login: COMMANDREF_EMAIL, COMMANDREF_NAME
+	OnLogin string `json:"on_login"`
+	// after logout, with the metadata of the session that ended
+	OnLogout string `json:"on_logout"`
+	// when the stored token is replaced for the same account (logging in
+	// again while still logged in)
+	OnTokenRefresh string `json:"on_token_refresh"`
+
+	// around every `run`, before the item's own pre_run/post_run; they get
+	// COMMANDREF_ITEM_ID, _TITLE, _COMMAND, _TAGS and COMMANDREF_ARGS, and
+	// post_run also COMMANDREF_EXIT_CODE and COMMANDREF_DURATION_MS. A
+	// failing pre_run hook aborts the run.
+	PreRun  string `json:"pre_run"`
+	PostRun string `json:"post_run"`
+
+	// after a successful `sync`, with COMMANDREF_SYNC_WITH (the backend or
+	// peer); e.g. "commandref export --format raycast --out ~/raycast"
+	PostSync string `json:"post_sync"`
+}
+
+type SyncConfig struct {
+	// default backend for `commandref sync` ("webdav")
+	Backend string `json:"backend"`
+
+	// shell command printing the encryption passphrase (e.g. "pass show commandref");
+	// COMMANDREF_SYNC_PASSPHRASE takes precedence
+	PassphraseCommand string `json:"passphrase_command"`
+
+	// rules like "tag:secret" or "title:~password" choosing which items
+	// sync; exclude wins over include (see syncrules.go)
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+
+	WebDAV WebDAVConfig `json:"webdav"`
+	Gist   GistConfig   `json:"gist"`
+	Git    GitConfig    `json:"git"`
+}
+
+type GitConfig struct {
+	// repository holding commands.json (default: "library" in the data dir)
+	Dir string `json:"dir"`
+	// cloned on first use if Dir isn't a repo yet; `sync --backend git`
+	// pulls from and pushes to its origin
+	Remote string `json:"remote"`
+	// default "main"
+	Branch string `json:"branch"`
+}
+
+type WebDAVConfig struct {
+	// file URL, or a collection URL ending in "/" (e.g. a Nextcloud
+	// https://host/remote.php/dav/files/<user>/commandref/)
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	// COMMANDREF_WEBDAV_PASSWORD takes precedence; use an app password
+	Password string `json:"password"`
+}
+
+type GistConfig struct {
+	// gist to sync with; empty creates a secret gist on the first push
+	ID string `json:"id"`
+	// GitHub token with the gist scope; COMMANDREF_GIST_TOKEN takes precedence
+	Token string `json:"token"`
+}
+
+func Path() (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Load reads the config file. A missing file is not an error.
+func Load() (*Config, error) {
+	p, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"commandref/paths"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// With "copy_history": true, every `copy` is logged to copies.jsonl, so
+// `commandref copied` can answer which variant went to the clipboard an
+// hour ago. The log keeps the command as saved and the text as copied, but
+// secrets filled in at copy time are named, never written down.
+
+type copyRecord struct {
+	ItemID    int      `json:"itemId"`
+	Title     string   `json:"title"`
+	Command   string   `json:"command"`        // as saved, literal secrets masked
+	Text      string   `json:"text,omitempty"` // as copied, if not the same; masked too
+	Expanded  bool     `json:"expanded"`
+	Secrets   []string `json:"secrets,omitempty"`
+	Target    string   `json:"target"` // "clipboard" or "stdout"
+	UpdatedAt string   `json:"updatedAt"`
+	At        string   `json:"at"`
+	Host      string   `json:"host"`
+}
+
+func copiesPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "copies.jsonl"), nil
+}
+
+// recordCopy appends to the copy log when it's turned on; like the run
+// log it skips noLog items and project commands.
+func recordCopy(it *Item, text, target string) {
+	if !cfg.CopyHistory || it.NoLog || isProjectItem(*it) {
+		return
+	}
+	rec := copyRecord{
+		ItemID:    it.ID,
+		Title:     it.Title,
+		Command:   redact(it.Command),
+		Expanded:  text != it.Command,
+		Target:    target,
+		UpdatedAt: it.UpdatedAt,
+		At:        time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	for _, m := range secretRef.FindAllStringSubmatch(it.Command, -1) {
+		rec.Secrets = append(rec.Secrets, m[1])
+	}
+	if t := redact(unresolveSecrets(text, rec.Secrets)); t != rec.Command {
+		rec.Text = t
+	}
+	rec.Host, _ = os.Hostname()
+
+	p, err := copiesPath()
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(b, '\n'))
+}
+
+// unresolveSecrets puts the {{secret:NAME}} placeholders back where the
+// values of the named secrets are in text, longest value first.
+func unresolveSecrets(text string, names []string) string {
+	type secret struct{ name, value string }
+	var found []secret
+	for _, n := range names {
+		if v, _, err := lookupSecret(n); err == nil && v != "" {
+			found = append(found, secret{n, v})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return len(found[i].value) > len(found[j].value) })
+	for _, s := range found {
+		text = strings.ReplaceAll(text, s.value, "{{secret:"+s.name+"}}")
+	}
+	return text
+}
+
+func loadCopyRecords() ([]copyRecord, error) {
+	p, err := copiesPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []copyRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var r copyRecord
+		if json.Unmarshal(sc.Bytes(), &r) == nil {
+			out = append(out, r)
+		}
+	}
+	return out, sc.Err()
+}
+
+func runCopied(args []string) {
+	fs := flag.NewFlagSet("copied", flag.ExitOnError)
+	id := fs.Int("id", 0, "only copies of this item")
+	since := fs.String("since", "", "only copies within this long, e.g. 2h or 7d")
+	limit := fs.Int("limit", 20, "show at most this many (most recent first; 0 = all)")
+	clear := fs.Bool("clear", false, "delete the copy log")
+	_ = fs.Parse(args)
+
+	if *clear {
+		p, err := copiesPath()
+		if err == nil {
+			if err = os.Remove(p); os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Cleared the copy log")
+		return
+	}
+	var cutoff time.Time
+	if *since != "" {
+		d, err := parseExpiry(*since)
+		if err != nil {
+			exitErr(err)
+		}
+		cutoff = time.Now().Add(-d)
+	}
+
+	recs, err := loadCopyRecords()
+	if err != nil {
+		exitErr(err)
+	}
+	// flag copies of a command that has been edited since
+	current := map[int]string{}
+	for _, it := range readCachedItems() {
+		current[it.ID] = it.Command
+	}
+
+	shown := 0
+	for i := len(recs) - 1; i >= 0; i-- {
+		r := recs[i]
+		at, _ := time.Parse(time.RFC3339Nano, r.At)
+		if (*id != 0 && r.ItemID != *id) || at.Before(cutoff) {
+			continue
+		}
+		if *limit > 0 && shown == *limit {
+			break
+		}
+		shown++
+
+		notes := []string{r.Target}
+		switch {
+		case len(r.Secrets) > 0:
+			notes = append(notes, "secrets filled in: "+strings.Join(r.Secrets, ", "))
+		case r.Expanded:
+			notes = append(notes, "expanded")
+		}
+		if c, ok := current[r.ItemID]; ok && redact(c) != r.Command {
+			notes = append(notes, colorize("33", "edited since"))
+		} else if !ok && len(current) > 0 {
+			notes = append(notes, "item gone")
+		}
+		fmt.Printf("%s  #%d %s  [%s, %s]\n", formatTime(r.At), r.ItemID, r.Title, strings.Join(notes, ", "), r.Host)
+		fmt.Printf("    %s\n", strings.ReplaceAll(orDefault(r.Text, r.Command), "\n", "\n    "))
+	}
+	if shown == 0 {
+		if !cfg.CopyHistory {
+			fmt.Println(`(no copies recorded; turn the log on with "copy_history": true in the config)`)
+			return
+		}
+		fmt.Println("(no copies recorded)")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"commandref/config"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecordCopy(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	t.Setenv("DB_PASS", "hunter2xyz")
+	cfg = &config.Config{CopyHistory: true}
+
+	tests := []struct {
+		name     string
+		command  string
+		text     string // as copied; "" is the command with its secrets resolved
+		wantText string
+	}{
+		{"plain", "uptime", "", ""},
+		{"secret", "mysql -p{{secret:DB_PASS}} app", "", ""},
+		{"rendered", "mysql -p{{secret:DB_PASS}} $1", "mysql -phunter2xyz app_prod", "mysql -p{{secret:DB_PASS}} app_prod"},
+		{"literal secret", "curl -u $1 https://example.com", "curl -u admin:s3cretpass https://example.com", "curl -u admin:**** https://example.com"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := tt.text
+			if text == "" {
+				var err error
+				if text, err = resolveSecrets(tt.command); err != nil {
+					t.Fatal(err)
+				}
+			}
+			recordCopy(&Item{ID: i + 1, Title: tt.name, Command: tt.command}, text, "stdout")
+
+			recs, err := loadCopyRecords()
+			if err != nil || len(recs) != i+1 {
+				t.Fatalf("%d records, %v", len(recs), err)
+			}
+			if got := recs[i].Text; got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+	p, _ := copiesPath()
+	b, _ := os.ReadFile(p)
+	for _, s := range []string{"hunter2xyz", "s3cretpass"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("%s is in the copy log:\n%s", s, b)
+		}
+	}
+}
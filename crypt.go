@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	sealedFormat = "commandref-sealed"
+	pbkdf2Iter   = 600_000
+)
+
+// sealedBlob is the at-rest format for anything we hand to a third party.
+type sealedBlob struct {
+	Format string `json:"format"`
+	KDF    string `json:"kdf"`
+	Iter   int    `json:"iter"`
+	Salt   []byte `json:"salt"`
+	Nonce  []byte `json:"nonce"`
+	Data   []byte `json:"data"`
+}
+
+func syncPassphrase() (string, error) {
+	if p := os.Getenv("COMMANDREF_SYNC_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	p, err := commandPassphrase(cfg.Sync.PassphraseCommand)
+	if err != nil || p != "" {
+		return p, err
+	}
+	return "", fmt.Errorf("no sync passphrase; set COMMANDREF_SYNC_PASSPHRASE or sync.passphrase_command")
+}
+
+// commandPassphrase runs a passphrase_command ("" if there is none).
+func commandPassphrase(cmdline string) (string, error) {
+	if cmdline == "" {
+		return "", nil
+	}
+	out, err := exec.Command("/bin/sh", "-c", cmdline).Output()
+	if err != nil {
+		return "", fmt.Errorf("passphrase_command failed: %w", err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+func seal(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := passphraseAEAD(passphrase, salt, pbkdf2Iter)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedBlob{
+		Format: sealedFormat,
+		KDF:    "pbkdf2-sha256",
+		Iter:   pbkdf2Iter,
+		Salt:   salt,
+		Nonce:  nonce,
+		Data:   gcm.Seal(nil, nonce, plaintext, []byte(sealedFormat)),
+	})
+}
+
+func unseal(passphrase string, blob []byte) ([]byte, error) {
+	var sb sealedBlob
+	if err := json.Unmarshal(blob, &sb); err != nil || sb.Format != sealedFormat {
+		return nil, fmt.Errorf("remote data is not an encrypted commandref library")
+	}
+	gcm, err := passphraseAEAD(passphrase, sb.Salt, sb.Iter)
+	if err != nil {
+		return nil, err
+	}
+	out, err := gcm.Open(nil, sb.Nonce, sb.Data, []byte(sealedFormat))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt remote library (wrong passphrase?)")
+	}
+	return out, nil
+}
+
+func passphraseAEAD(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iter, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"commandref/paths"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDaemonAddr = "127.0.0.1:7878"
+	pairingCodeTTL    = 5 * time.Minute
+
+	// a wrong code this many times and it's gone: run pair again
+	pairingMaxAttempts = 5
+)
+
+// pairInterval is how long POST /v1/pair makes the next attempt wait.
+var pairInterval = time.Second
+
+// pairingState lives in pairing.json: the code `pair` is showing right now
+// and the clients (browser extensions) that have paired. Only token hashes
+// are stored.
+type pairingState struct {
+	Code      string         `json:"code,omitempty"`
+	CodeUntil string         `json:"codeUntil,omitempty"`
+	Attempts  int            `json:"attempts,omitempty"`
+	Clients   []pairedClient `json:"clients"`
+}
+
+type pairedClient struct {
+	Name      string `json:"name"`
+	TokenHash string `json:"tokenHash"`
+	PairedAt  string `json:"pairedAt"`
+}
+
+func pairingPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pairing.json"), nil
+}
+
+// pairingMu guards pairing.json for the load, change, save cycle in the
+// daemon and in pair.
+var pairingMu sync.Mutex
+
+func loadPairing() (*pairingState, error) {
+	p, err := pairingPath()
+	if err != nil {
+		return nil, err
+	}
+	var st pairingState
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &st, nil
+		}
+		return nil, err
+	}
+	return &st, json.Unmarshal(b, &st)
+}
+
+func savePairing(st *pairingState) error {
+	p, err := pairingPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+func hashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
+}
+
+func runPair(args []string) {
+	fs := flag.NewFlagSet("pair", flag.ExitOnError)
+	list := fs.Bool("list", false, "list paired clients")
+	revoke := fs.String("revoke", "", "unpair the client with this name")
+	_ = fs.Parse(args)
+
+	pairingMu.Lock()
+	defer pairingMu.Unlock()
+	st, err := loadPairing()
+	if err != nil {
+		exitErr(err)
+	}
+
+	switch {
+	case *list:
+		if len(st.Clients) == 0 {
+			fmt.Println("(no paired clients)")
+			return
+		}
+		for _, c := range st.Clients {
+			fmt.Printf("%s  (paired %s)\n", c.Name, formatTime(c.PairedAt))
+		}
+		return
+	case *revoke != "":
+		n := len(st.Clients)
+		st.Clients = slices.DeleteFunc(st.Clients, func(c pairedClient) bool { return c.Name == *revoke })
+		if len(st.Clients) == n {
+			exitWith(exitNotFound, errNotFound)
+		}
+		if err := savePairing(st); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Unpaired", *revoke)
+		return
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		exitErr(err)
+	}
+	st.Code = fmt.Sprintf("%06d", n.Int64())
+	st.CodeUntil = time.Now().Add(pairingCodeTTL).UTC().Format(time.RFC3339)
+	st.Attempts = 0
+	if err := savePairing(st); err != nil {
+		exitErr(err)
+	}
+	fmt.Printf("Pairing code: %s\n", colorize("1", st.Code))
+	fmt.Printf("Enter it in the browser extension within %s (the daemon must be running: commandref daemon)\n", pairingCodeTTL)
+}
+
+// daemonOriginAllowed decides CORS: the configured extension origins, or if
+// none are configured any browser-extension origin. Web pages never are, so
+// a random site can't talk to the daemon from your browser.
+func daemonOriginAllowed(origin string) bool {
+	if origin == "" {
+		return true // not a browser (curl, scripts)
+	}
+	if len(cfg.Daemon.AllowedOrigins) > 0 {
+		return slices.Contains(cfg.Daemon.AllowedOrigins, origin)
+	}
+	return strings.HasPrefix(origin, "chrome-extension://") ||
+		strings.HasPrefix(origin, "moz-extension://") ||
+		strings.HasPrefix(origin, "safari-web-extension://")
+}
+
+func writeDaemonJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func daemonError(w http.ResponseWriter, code int, msg string) {
+	writeDaemonJSON(w, code, map[string]string{"error": msg})
+}
+
+// pairedClientFor returns the client owning the bearer token, if any.
+func pairedClientFor(r *http.Request) *pairedClient {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	pairingMu.Lock()
+	st, err := loadPairing()
+	pairingMu.Unlock()
+	if err != nil {
+		return nil
+	}
+	h := hashToken(token)
+	for i := range st.Clients {
+		if subtle.ConstantTimeCompare([]byte(st.Clients[i].TokenHash), []byte(h)) == 1 {
+			return &st.Clients[i]
+		}
+	}
+	return nil
+}
+
+// daemonMu runs requests and the cache refresh one at a time: the store,
+// the caches and the secret and key lookups they share aren't safe for
+// concurrent use.
+var daemonMu sync.Mutex
+
+// pairLimit spaces out pairing attempts, so the six digits can't be
+// guessed at network speed.
+var pairLimit struct {
+	sync.Mutex
+	last time.Time
+}
+
+func pairAllowed() bool {
+	pairLimit.Lock()
+	defer pairLimit.Unlock()
+	if time.Since(pairLimit.last) < pairInterval {
+		return false
+	}
+	pairLimit.last = time.Now()
+	return true
+}
+
+func daemonHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/ping", func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonJSON(w, 200, map[string]any{"ok": true, "version": version, "paired": pairedClientFor(r) != nil})
+	})
+
+	mux.HandleFunc("POST /v1/pair", func(w http.ResponseWriter, r *http.Request) {
+		// only the extension pairs; scripts and curl don't need a token
+		if origin := r.Header.Get("Origin"); origin == "" || !daemonOriginAllowed(origin) {
+			daemonError(w, 403, "pairing needs the browser extension")
+			return
+		}
+		if !pairAllowed() {
+			w.Header().Set("Retry-After", "1")
+			daemonError(w, 429, "too many pairing attempts; wait a moment")
+			return
+		}
+		var req struct {
+			Code string `json:"code"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			daemonError(w, 400, "bad request")
+			return
+		}
+		pairingMu.Lock()
+		defer pairingMu.Unlock()
+		st, err := loadPairing()
+		if err != nil {
+			daemonError(w, 500, err.Error())
+			return
+		}
+		until, _ := time.Parse(time.RFC3339, st.CodeUntil)
+		if st.Code == "" || time.Now().After(until) {
+			daemonError(w, 403, "wrong or expired pairing code; run: commandref pair")
+			return
+		}
+		// hashed so the comparison doesn't depend on the length typed either
+		if subtle.ConstantTimeCompare([]byte(hashToken(st.Code)), []byte(hashToken(strings.TrimSpace(req.Code)))) != 1 {
+			st.Attempts++
+			if st.Attempts >= pairingMaxAttempts {
+				st.Code, st.CodeUntil, st.Attempts = "", "", 0
+			}
+			if err := savePairing(st); err != nil {
+				daemonError(w, 500, err.Error())
+				return
+			}
+			daemonError(w, 403, "wrong or expired pairing code; run: commandref pair")
+			return
+		}
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			daemonError(w, 500, err.Error())
+			return
+		}
+		tok := hex.EncodeToString(token)
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			name = "browser-" + time.Now().Format("20060102-150405")
+		}
+		st.Code, st.CodeUntil, st.Attempts = "", "", 0 // one use
+		st.Clients = append(st.Clients, pairedClient{Name: name, TokenHash: hashToken(tok), PairedAt: time.Now().UTC().Format(time.RFC3339)})
+		if err := savePairing(st); err != nil {
+			daemonError(w, 500, err.Error())
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s  paired %s\n", formatTimeValue(time.Now()), name)
+		writeDaemonJSON(w, 200, map[string]string{"token": tok, "name": name})
+	})
+
+	mux.HandleFunc("POST /v1/commands", func(w http.ResponseWriter, r *http.Request) {
+		client := pairedClientFor(r)
+		if client == nil {
+			daemonError(w, 401, "not paired")
+			return
+		}
+		var req struct {
+			Title     string   `json:"title"`
+			Command   string   `json:"command"`
+			Tags      []string `json:"tags"`
+			Notes     string   `json:"notes"`
+			SourceURL string   `json:"sourceUrl"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			daemonError(w, 400, "bad request")
+			return
+		}
+		it := Item{
+			Title:   strings.TrimSpace(req.Title),
+			Command: normalizeCommand(req.Command),
+			Tags:    parseTags(strings.Join(req.Tags, ",")),
+			Notes:   strings.TrimSpace(req.Notes),
+		}
+		if it.Command == "" {
+			daemonError(w, 400, "command is required")
+			return
+		}
+		if it.Title == "" {
+			it.Title = strings.SplitN(it.Command, "\n", 2)[0]
+		}
+		if req.SourceURL != "" {
+			it.Notes = strings.TrimSpace(it.Notes + "\n\nSource: " + req.SourceURL)
+		}
+		created, err := openStore().Create(it)
+		if errors.Is(err, errKeptLocal) {
+			daemonError(w, 403, err.Error())
+			return
+		}
+		if err != nil {
+			daemonError(w, 502, err.Error())
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s  %s saved #%d %s\n", formatTimeValue(time.Now()), client.Name, created.ID, redact(created.Title))
+		writeDaemonJSON(w, 201, created)
+	})
+
+	return corsMiddleware(mux)
+}
+
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !daemonOriginAllowed(origin) {
+			daemonError(w, 403, "origin not allowed")
+			return
+		}
+		if origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(204)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func runDaemon(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "install":
+			runDaemonInstall(args[1:])
+			return
+		case "uninstall":
+			runDaemonUninstall()
+			return
+		}
+	}
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	addr := fs.String("addr", "", "listen address (default "+defaultDaemonAddr+", or daemon.addr in config)")
+	idle := fs.Duration("idle-timeout", 0, "exit after this long without requests (for socket activation; 0 = never)")
+	refresh := fs.Duration("refresh", 5*time.Minute, "relist the hosted library this often while running (0 = never)")
+	launchd := fs.Bool("launchd", false, "take the listening socket from launchd on stdin (set by daemon install)")
+	_ = fs.Parse(args)
+
+	if *addr == "" {
+		*addr = cfg.Daemon.Addr
+	}
+	if *addr == "" {
+		*addr = defaultDaemonAddr
+	}
+	l, by, err := activationListener(*launchd)
+	if err == nil && l == nil {
+		l, err = net.Listen("tcp", *addr)
+	}
+	if err != nil {
+		exitErr(err)
+	}
+	if by != "" {
+		fmt.Fprintf(os.Stderr, "commandref daemon started by %s on http://%s\n", by, l.Addr())
+	} else {
+		fmt.Fprintf(os.Stderr, "commandref daemon listening on http://%s (pair a browser extension with: commandref pair)\n", l.Addr())
+	}
+
+	var idleT idleTracker
+	idleT.touch()
+	handler := daemonHandler()
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idleT.touch()
+			daemonMu.Lock()
+			defer daemonMu.Unlock()
+			handler.ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if *idle > 0 {
+		go func() {
+			for range time.Tick(min(*idle/4, time.Minute) + time.Second) {
+				if idleT.idleFor() >= *idle {
+					// the service manager keeps the socket and starts us again
+					_ = srv.Close()
+					return
+				}
+			}
+		}()
+	}
+	keepCacheFresh(*refresh)
+	if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+		exitErr(err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"commandref/paths"
+	"flag"
+	"fmt"
+	"html"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The daemon doesn't have to stay resident: `daemon install` hands the
+// listening socket to systemd (socket activation) or launchd (on-demand
+// start), which start the daemon on the first connection, and with
+// --idle-timeout it exits again once nobody has called for a while.
+
+const launchdLabel = "com.commandref.daemon"
+
+// activationListener returns the socket the service manager opened for the
+// daemon and who that was, or nil when the daemon was started by hand.
+// launchd is told apart by --launchd in the plist; a socket on stdin alone
+// could be ssh.
+func activationListener(launchd bool) (net.Listener, string, error) {
+	// systemd: LISTEN_FDS sockets starting at fd 3, meant for LISTEN_PID
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid == os.Getpid() {
+		n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		if n < 1 {
+			return nil, "", fmt.Errorf("systemd passed no socket")
+		}
+		l, err := net.FileListener(os.NewFile(3, "systemd-socket"))
+		return l, "systemd", err
+	}
+	// launchd with inetdCompatibility Wait=true: the listening socket is
+	// stdin (and stdout and stderr)
+	if st, err := os.Stdin.Stat(); launchd && err == nil && st.Mode()&os.ModeSocket != 0 {
+		l, err := net.FileListener(os.Stdin)
+		if err != nil {
+			return nil, "", err
+		}
+		// the log can't go to the socket
+		if dir, err := paths.DataDir(); err == nil {
+			if f, err := os.OpenFile(filepath.Join(dir, "daemon.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600); err == nil {
+				os.Stdout, os.Stderr = f, f
+			}
+		}
+		return l, "launchd", nil
+	}
+	return nil, "", nil
+}
+
+// idleTracker remembers when the daemon last served a request.
+type idleTracker struct {
+	last atomic.Int64
+}
+
+func (t *idleTracker) touch() {
+	t.last.Store(time.Now().UnixNano())
+}
+
+func (t *idleTracker) idleFor() time.Duration {
+	return time.Since(time.Unix(0, t.last.Load()))
+}
+
+// keepCacheFresh relists the hosted library now and every interval while
+// the daemon runs, so completion and the picker find a fresh cache.
+func keepCacheFresh(interval time.Duration) {
+	if usingLocalStore() || interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			daemonMu.Lock()
+			runRefresh()
+			daemonMu.Unlock()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// daemonEnvVars are what `daemon install` carries over into the unit.
+var daemonEnvVars = []string{"COMMANDREF_PROFILE", "COMMANDREF_WORKSPACE", "COMMANDREF_HOME", "COMMANDREF_API_BASE"}
+
+// daemonEnv keeps the profile, data dir and so on this shell uses, and
+// nothing else: tokens and passphrases have no business in a unit file.
+func daemonEnv(environ []string) []string {
+	var env []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(daemonEnvVars, name) || strings.HasPrefix(name, "XDG_") {
+			env = append(env, kv)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+func runDaemonInstall(args []string) {
+	fs := flag.NewFlagSet("daemon install", flag.ExitOnError)
+	addr := fs.String("addr", "", "listen address (default "+defaultDaemonAddr+", or daemon.addr in config)")
+	idle := fs.Duration("idle-timeout", 10*time.Minute, "exit after this long without requests (0 = stay running)")
+	_ = fs.Parse(args)
+	if *addr == "" {
+		*addr = orDefault(cfg.Daemon.Addr, defaultDaemonAddr)
+	}
+	host, port, err := net.SplitHostPort(*addr)
+	if err != nil {
+		exitErr(fmt.Errorf("--addr: %w", err))
+	}
+	self, err := os.Executable()
+	if err != nil {
+		exitErr(err)
+	}
+	daemonArgs := []string{self, "daemon", "--idle-timeout", idle.String()}
+	if runtime.GOOS == "darwin" {
+		daemonArgs = append(daemonArgs, "--launchd")
+	}
+	env := daemonEnv(os.Environ())
+
+	switch runtime.GOOS {
+	case "linux":
+		dir, err := systemdUserDir()
+		if err != nil {
+			exitErr(err)
+		}
+		socket := fmt.Sprintf("[Unit]\nDescription=commandref local API socket\n\n[Socket]\nListenStream=%s\n\n[Install]\nWantedBy=sockets.target\n", *addr)
+		service := "[Unit]\nDescription=commandref daemon\nRequires=commandref.socket\n\n[Service]\nExecStart=" + strings.Join(systemdQuote(daemonArgs), " ") + "\n"
+		for _, kv := range env {
+			service += "Environment=" + strings.Join(systemdQuote([]string{kv}), "") + "\n"
+		}
+		for name, body := range map[string]string{"commandref.socket": socket, "commandref.service": service} {
+			if err := writeUnit(filepath.Join(dir, name), body); err != nil {
+				exitErr(err)
+			}
+		}
+		fmt.Println("Wrote commandref.socket and commandref.service to", dir)
+		fmt.Println("Enable with: systemctl --user daemon-reload && systemctl --user enable --now commandref.socket")
+	case "darwin":
+		p, err := launchdPlistPath()
+		if err != nil {
+			exitErr(err)
+		}
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key><string>` + launchdLabel + `</string>
+  <key>ProgramArguments</key>
+  <array>
+`)
+		for _, a := range daemonArgs {
+			b.WriteString("    <string>" + html.EscapeString(a) + "</string>\n")
+		}
+		b.WriteString("  </array>\n")
+		if len(env) > 0 {
+			b.WriteString("  <key>EnvironmentVariables</key>\n  <dict>\n")
+			for _, kv := range env {
+				k, v, _ := strings.Cut(kv, "=")
+				b.WriteString("    <key>" + html.EscapeString(k) + "</key><string>" + html.EscapeString(v) + "</string>\n")
+			}
+			b.WriteString("  </dict>\n")
+		}
+		b.WriteString(`  <key>Sockets</key>
+  <dict>
+    <key>Listeners</key>
+    <dict>
+      <key>SockNodeName</key><string>` + html.EscapeString(host) + `</string>
+      <key>SockServiceName</key><string>` + html.EscapeString(port) + `</string>
+    </dict>
+  </dict>
+  <key>inetdCompatibility</key>
+  <dict><key>Wait</key><true/></dict>
+</dict>
+</plist>
+`)
+		if err := writeUnit(p, b.String()); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Wrote", p)
+		fmt.Printf("Load with: launchctl bootstrap gui/%d %s\n", os.Getuid(), p)
+	default:
+		exitErr(fmt.Errorf("daemon install knows systemd (Linux) and launchd (macOS), not %s; run commandref daemon from your startup instead", runtime.GOOS))
+	}
+}
+
+func runDaemonUninstall() {
+	var files []string
+	switch runtime.GOOS {
+	case "linux":
+		dir, err := systemdUserDir()
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Println("First stop it with: systemctl --user disable --now commandref.socket commandref.service")
+		files = []string{filepath.Join(dir, "commandref.socket"), filepath.Join(dir, "commandref.service")}
+	case "darwin":
+		p, err := launchdPlistPath()
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Printf("First stop it with: launchctl bootout gui/%d/%s\n", os.Getuid(), launchdLabel)
+		files = []string{p}
+	default:
+		exitErr(fmt.Errorf("nothing is installed on %s", runtime.GOOS))
+	}
+	removed := 0
+	for _, f := range files {
+		if err := os.Remove(f); err == nil {
+			fmt.Println("Removed", f)
+			removed++
+		} else if !os.IsNotExist(err) {
+			exitErr(err)
+		}
+	}
+	if removed == 0 {
+		fmt.Println("(nothing was installed)")
+	}
+}
+
+func systemdUserDir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "systemd", "user"), nil
+}
+
+func launchdPlistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+func writeUnit(p, body string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, []byte(body), 0600)
+}
+
+// systemdQuote quotes the words of an ExecStart= or Environment= line.
+func systemdQuote(words []string) []string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		if strings.ContainsAny(w, " \t\"\\'$%") {
+			w = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`).Replace(w) + `"`
+		}
+		out[i] = w
+	}
+	return out
+}
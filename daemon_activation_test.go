@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDaemonEnv(t *testing.T) {
+	got := daemonEnv([]string{
+		"COMMANDREF_PROFILE=work",
+		"COMMANDREF_E2E_PASSPHRASE=hunter2",
+		"COMMANDREF_AI_KEY=sk-1",
+		"COMMANDREF_GIST_TOKEN=ghp_1",
+		"XDG_CONFIG_HOME=/home/u/.config",
+		"COMMANDREF_HOME=/srv/cr",
+		"PATH=/usr/bin",
+		"COMMANDREF_PROFILE_X=1",
+	})
+	want := []string{"COMMANDREF_HOME=/srv/cr", "COMMANDREF_PROFILE=work", "XDG_CONFIG_HOME=/home/u/.config"}
+	if !slices.Equal(got, want) {
+		t.Errorf("daemonEnv = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"commandref/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonPair(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	pairInterval = 0
+	t.Cleanup(func() { pairInterval = time.Second })
+
+	const ext = "chrome-extension://abc"
+	setCode := func() {
+		st := &pairingState{Code: "123456", CodeUntil: time.Now().Add(time.Minute).UTC().Format(time.RFC3339)}
+		if err := savePairing(st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pair := func(origin, code string) int {
+		r := httptest.NewRequest("POST", "/v1/pair", strings.NewReader(`{"code":"`+code+`"}`))
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		daemonHandler().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		wrong  int // wrong codes tried first
+		code   string
+		want   int
+	}{
+		{"right code", ext, 0, "123456", 200},
+		{"no origin", "", 0, "123456", 403},
+		{"web page", "https://evil.example", 0, "123456", 403},
+		{"after a few wrong codes", ext, pairingMaxAttempts - 1, "123456", 200},
+		{"code burned by wrong codes", ext, pairingMaxAttempts, "123456", 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCode()
+			for range tt.wrong {
+				if got := pair(ext, "000000"); got != 403 {
+					t.Fatalf("wrong code: status %d, want 403", got)
+				}
+			}
+			if got := pair(tt.origin, tt.code); got != tt.want {
+				t.Errorf("status %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("rate limited", func(t *testing.T) {
+		setCode()
+		pairInterval = time.Hour
+		defer func() { pairInterval = 0 }()
+		pairLimit.last = time.Time{}
+		pair(ext, "000000")
+		if got := pair(ext, "123456"); got != http.StatusTooManyRequests {
+			t.Errorf("status %d, want 429", got)
+		}
+	})
+}
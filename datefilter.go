@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateFilter keeps items created or updated within a range, for list and
+// search: --created-after, --created-before (or --before), --updated-since
+// and --updated-before.
+type dateFilter struct {
+	createdAfter, createdBefore time.Time
+	updatedSince, updatedBefore time.Time
+}
+
+var dateFilterFlags = map[string]string{
+	"created-after":  "only items created after this (30d, 2w, 12h, yesterday, 2026-01-31)",
+	"created-before": "only items created before this",
+	"before":         "same as --created-before",
+	"updated-since":  "only items edited since this, e.g. 30d",
+	"updated-before": "only items not edited since this, e.g. 365d",
+}
+
+func (d *dateFilter) register(fs *flag.FlagSet) {
+	for name, help := range dateFilterFlags {
+		fs.Func(name, help, func(v string) error { return d.set(name, v) })
+	}
+}
+
+// parseArg handles the flags in hand-parsed argument lists (search);
+// it reports how many arguments it used.
+func (d *dateFilter) parseArg(args []string, i int) (int, error) {
+	a := strings.TrimLeft(args[i], "-")
+	if a == args[i] {
+		return 0, nil
+	}
+	name, value, hasValue := strings.Cut(a, "=")
+	if _, ok := dateFilterFlags[name]; !ok {
+		return 0, nil
+	}
+	n := 1
+	if !hasValue {
+		if i+1 >= len(args) {
+			return 0, fmt.Errorf("--%s needs a value", name)
+		}
+		value, n = args[i+1], 2
+	}
+	if err := d.set(name, value); err != nil {
+		return 0, fmt.Errorf("--%s: %w", name, err)
+	}
+	return n, nil
+}
+
+func (d *dateFilter) set(name, value string) error {
+	t, err := parseTimeArg(value)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "created-after":
+		d.createdAfter = t
+	case "created-before", "before":
+		d.createdBefore = t
+	case "updated-since":
+		d.updatedSince = t
+	case "updated-before":
+		d.updatedBefore = t
+	}
+	return nil
+}
+
+func (d dateFilter) active() bool {
+	return d != dateFilter{}
+}
+
+func (d dateFilter) apply(items []Item) []Item {
+	if !d.active() {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		if inRange(it.CreatedAt, d.createdAfter, d.createdBefore) && inRange(it.UpdatedAt, d.updatedSince, d.updatedBefore) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// inRange reports whether ts lies between after and before (zero means
+// open). Items without a usable timestamp only pass open ranges.
+func inRange(ts string, after, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return false
+	}
+	return (after.IsZero() || t.After(after)) && (before.IsZero() || t.Before(before))
+}
+
+// parseTimeArg reads a point in time: an age ("30d", "2w", "12h"),
+// "today", "yesterday", a date (local midnight) or an RFC3339 time.
+func parseTimeArg(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch strings.ToLower(s) {
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+	for suffix, days := range map[string]int{"d": 1, "w": 7, "y": 365} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if v, err := strconv.Atoi(n); err == nil && v >= 0 {
+				return now.AddDate(0, 0, -v*days), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't read %q as a time (try 30d, 2w, 12h, yesterday or 2026-01-31)", s)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// requirement is a binary a bundle's commands need, with an optional minimum
+// version ("jq>=1.6" on the command line).
+type requirement struct {
+	Name string `json:"name"`
+	Min  string `json:"min,omitempty"`
+}
+
+func (r requirement) String() string {
+	if r.Min == "" {
+		return r.Name
+	}
+	return r.Name + " >=" + r.Min
+}
+
+// requireFlag collects repeated --require flags.
+type requireFlag []requirement
+
+func (r *requireFlag) String() string {
+	parts := make([]string, len(*r))
+	for i, req := range *r {
+		parts[i] = req.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *requireFlag) Set(v string) error {
+	name, min, _ := strings.Cut(v, ">=")
+	name, min = strings.TrimSpace(name), strings.TrimSpace(min)
+	if name == "" {
+		return fmt.Errorf("expected NAME or NAME>=VERSION, got %q", v)
+	}
+	if min != "" {
+		if _, ok := parseVersion(min); !ok {
+			return fmt.Errorf("bad version in %q", v)
+		}
+	}
+	*r = append(*r, requirement{Name: name, Min: min})
+	return nil
+}
+
+var versionInOutput = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// installedVersion asks the binary for its --version and picks out the
+// first x.y[.z]. Nothing else is tried: `make version` would run a recipe.
+func installedVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, _ := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	return versionInOutput.FindString(string(out))
+}
+
+// plainCommandName rejects paths and what would read as an option.
+func plainCommandName(name string) bool {
+	return !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, "-")
+}
+
+// versionChecks reports whether any requirement needs a binary run to
+// check it.
+func versionChecks(reqs []requirement) bool {
+	return slices.ContainsFunc(reqs, func(r requirement) bool { return r.Min != "" && plainCommandName(r.Name) })
+}
+
+func requirementList(reqs []requirement) string {
+	var names []string
+	for _, r := range reqs {
+		if r.Min != "" && plainCommandName(r.Name) {
+			names = append(names, r.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// missingRequirements returns one line per unmet requirement. A bundle
+// names the binaries, so only plain command names on PATH count, and their
+// versions are only asked for when probe is set.
+func missingRequirements(reqs []requirement, probe bool) []string {
+	var missing []string
+	for _, r := range reqs {
+		if !plainCommandName(r.Name) {
+			missing = append(missing, fmt.Sprintf("skipped %q: not a command name", r.Name))
+			continue
+		}
+		path, err := exec.LookPath(r.Name)
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("you're missing %s", r))
+			continue
+		}
+		if r.Min == "" {
+			continue
+		}
+		if !probe {
+			missing = append(missing, fmt.Sprintf("didn't check which %s version you have (need >=%s)", r.Name, r.Min))
+			continue
+		}
+		have := installedVersion(path)
+		switch {
+		case have == "":
+			missing = append(missing, fmt.Sprintf("couldn't tell which %s version you have (need >=%s)", r.Name, r.Min))
+		case newerVersion(r.Min, have):
+			missing = append(missing, fmt.Sprintf("you have %s %s but need >=%s", r.Name, have, r.Min))
+		}
+	}
+	return missing
+}
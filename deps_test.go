@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMissingRequirements(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the tool")
+	}
+	dir := t.TempDir()
+	ran := filepath.Join(dir, "ran")
+	script := "#!/bin/sh\necho \"$@\" >> '" + ran + "'\n[ \"$1\" = --version ] && echo 'faketool 2.3.1'\n"
+	if err := os.WriteFile(filepath.Join(dir, "faketool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	tests := []struct {
+		name    string
+		req     requirement
+		probe   bool
+		want    string // in the one line reported; "" for none
+		wantRan string // the args the tool was run with
+	}{
+		{"new enough", requirement{"faketool", "2.0"}, true, "", "--version\n"},
+		{"too old", requirement{"faketool", "3.0"}, true, "you have faketool 2.3.1 but need >=3.0", "--version\n"},
+		{"no version needed", requirement{"faketool", ""}, true, "", ""},
+		{"not asked to run it", requirement{"faketool", "2.0"}, false, "didn't check which faketool version", ""},
+		{"missing", requirement{"nosuchtool", ""}, true, "you're missing nosuchtool", ""},
+		{"a path", requirement{filepath.Join(dir, "faketool"), "2.0"}, true, "not a command name", ""},
+		{"an option", requirement{"-faketool", "2.0"}, true, "not a command name", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(ran)
+			got := strings.Join(missingRequirements([]requirement{tt.req}, tt.probe), "\n")
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			b, _ := os.ReadFile(ran)
+			if string(b) != tt.wantRan {
+				t.Errorf("tool ran with %q, want %q", b, tt.wantRan)
+			}
+		})
+	}
+}
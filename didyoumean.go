@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Suggestions for searches and lookups that found nothing: items whose
+// title or tags are a few typos away from the query, or whose ID is one
+// keystroke away from the one asked for.
+
+const maxSuggestions = 3
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// wordDistance is how far word is from the closest of words, counting a
+// word that starts with it as a match.
+func wordDistance(word string, words []string) int {
+	n := len([]rune(word))
+	best := n
+	for _, w := range words {
+		if strings.HasPrefix(w, word) {
+			return 0
+		}
+		head := []rune(w)
+		head = head[:min(len(head), n)]
+		best = min(best, editDistance(word, w), editDistance(word, string(head))+1)
+	}
+	return best
+}
+
+// similarItems ranks items by how close their title and tags are to the
+// query; every query word has to be within a typo or two of some word.
+func similarItems(items []Item, query string) []Item {
+	words := indexTerms(query)
+	if len(words) == 0 {
+		return nil
+	}
+	type scored struct {
+		it    Item
+		score int
+	}
+	var hits []scored
+	for _, it := range items {
+		hay := indexTerms(it.Title + " " + strings.Join(it.Tags, " "))
+		total, ok := 0, true
+		for _, w := range words {
+			d := wordDistance(w, hay)
+			if d > max(1, len([]rune(w))/3) {
+				ok = false
+				break
+			}
+			total += d
+		}
+		if ok {
+			hits = append(hits, scored{it, total})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score < hits[j].score })
+	var out []Item
+	for _, h := range hits[:min(len(hits), maxSuggestions)] {
+		out = append(out, h.it)
+	}
+	return out
+}
+
+// nearIDs finds items whose ID is one typo away from id (12 for 21 or 122).
+func nearIDs(items []Item, id int) []Item {
+	want := strconv.Itoa(id)
+	var out []Item
+	for _, it := range items {
+		if it.ID > 0 && editDistance(want, strconv.Itoa(it.ID)) == 1 {
+			out = append(out, it)
+		}
+	}
+	sortByID(out)
+	return out[:min(len(out), maxSuggestions)]
+}
+
+func printDidYouMean(w io.Writer, items []Item) {
+	switch len(items) {
+	case 0:
+	case 1:
+		fmt.Fprintf(w, "did you mean: #%s %s?\n", displayID(items[0]), items[0].Title)
+	default:
+		fmt.Fprintln(w, "did you mean:")
+		for _, it := range items {
+			fmt.Fprintf(w, "  #%s %s\n", displayID(it), it.Title)
+		}
+	}
+}
+
+// suggestionPool is what suggestions are picked from: the library (from
+// the cache when the backend can't be reached) without archived items.
+func suggestionPool() []Item {
+	items, _, err := listWithFreshness()
+	if err != nil {
+		return nil
+	}
+	return byArchived(items, false)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runDigest summarizes the last week (or --days) of the library in a form
+// meant for pasting into a team channel.
+func runDigest(args []string) {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	format := fs.String("format", "markdown", `"markdown", "slack" (mrkdwn) or "text"`)
+	days := fs.Int("days", 7, "how far back to look")
+	staleDays := fs.Int("stale-days", 90, "items neither used nor edited for this long are due for review")
+	_ = fs.Parse(args)
+
+	if *format != "markdown" && *format != "slack" && *format != "text" {
+		exitErr(fmt.Errorf("unknown --format %q (markdown, slack or text)", *format))
+	}
+
+	items, _, err := listWithFreshness()
+	if err != nil {
+		exitErr(err)
+	}
+	runs, err := loadRunRecords()
+	if err != nil {
+		exitErr(fmt.Errorf("reading run history: %w", err))
+	}
+	usage, _ := loadUsage()
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -*days)
+	byID := map[int]Item{}
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+	title := func(id int, fallback string) string {
+		if it, ok := byID[id]; ok {
+			return it.Title
+		}
+		return fallback
+	}
+
+	var added []Item
+	for _, it := range items {
+		if t, err := time.Parse(time.RFC3339, it.CreatedAt); err == nil && t.After(since) {
+			added = append(added, it)
+		}
+	}
+	sortByID(added)
+
+	runCounts := map[int]int{}
+	failCounts := map[int]int{}
+	lastFail := map[int]runRecord{}
+	titles := map[int]string{}
+	for _, r := range runs {
+		if t, err := time.Parse(time.RFC3339Nano, r.StartedAt); err != nil || !t.After(since) {
+			continue
+		}
+		runCounts[r.ItemID]++
+		titles[r.ItemID] = r.Title
+		if r.ExitCode != 0 {
+			failCounts[r.ItemID]++
+			lastFail[r.ItemID] = r
+		}
+	}
+
+	staleBefore := now.AddDate(0, 0, -*staleDays)
+	var stale []Item
+	lastTouched := map[int]string{}
+	for _, it := range items {
+		if it.Archived {
+			continue // already dealt with
+		}
+		last := it.UpdatedAt
+		if u := usage.get(it.ID).LastUsedAt; u > last {
+			last = u
+		}
+		if t, err := time.Parse(time.RFC3339, last); err == nil && t.Before(staleBefore) {
+			stale = append(stale, it)
+			lastTouched[it.ID] = last
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool { return lastTouched[stale[i].ID] < lastTouched[stale[j].ID] })
+
+	d := digestWriter{format: *format}
+	d.heading(1, fmt.Sprintf("commandref digest: %s to %s", since.Format("Jan 2"), now.Format("Jan 2, 2006")))
+
+	d.heading(2, fmt.Sprintf("New commands (%d)", len(added)))
+	for _, it := range added[:min(len(added), digestTopN)] {
+		d.item(it.ID, it.Title, "")
+	}
+	d.more(len(added))
+	d.none(len(added), "nothing new")
+
+	d.heading(2, "Most run")
+	top := sortedIDCounts(runCounts)
+	for _, kc := range top[:min(len(top), digestTopN)] {
+		d.item(kc.id, title(kc.id, titles[kc.id]), plural(kc.n, "run"))
+	}
+	d.none(len(top), "no runs recorded")
+
+	fails := sortedIDCounts(failCounts)
+	d.heading(2, fmt.Sprintf("Failures (%d)", len(fails)))
+	for _, kc := range fails[:min(len(fails), digestTopN)] {
+		r := lastFail[kc.id]
+		d.item(kc.id, title(kc.id, r.Title), fmt.Sprintf("%d of %d runs failed, last exit %d", kc.n, runCounts[kc.id], r.ExitCode))
+	}
+	d.more(len(fails))
+	d.none(len(fails), "no failed runs")
+
+	d.heading(2, fmt.Sprintf("Due for review (%d)", len(stale)))
+	for _, it := range stale[:min(len(stale), digestTopN)] {
+		d.item(it.ID, it.Title, "untouched since "+formatTime(lastTouched[it.ID]))
+	}
+	d.more(len(stale))
+	d.none(len(stale), fmt.Sprintf("nothing older than %d days", *staleDays))
+
+	fmt.Print(d.b.String())
+}
+
+const digestTopN = 10
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+type idCount struct {
+	id, n int
+}
+
+func sortedIDCounts(m map[int]int) []idCount {
+	out := make([]idCount, 0, len(m))
+	for id, n := range m {
+		out = append(out, idCount{id, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].n != out[j].n {
+			return out[i].n > out[j].n
+		}
+		return out[i].id < out[j].id
+	})
+	return out
+}
+
+// digestWriter renders the few building blocks the digest needs in each
+// output format.
+type digestWriter struct {
+	format string
+	b      strings.Builder
+}
+
+func (d *digestWriter) heading(level int, s string) {
+	if d.b.Len() > 0 {
+		d.b.WriteString("\n")
+	}
+	switch {
+	case d.format == "markdown":
+		d.b.WriteString(strings.Repeat("#", level+1) + " " + s + "\n")
+	case d.format == "slack":
+		d.b.WriteString("*" + s + "*\n")
+	case level == 1:
+		d.b.WriteString(s + "\n" + strings.Repeat("=", len(s)) + "\n")
+	default:
+		d.b.WriteString(s + ":\n")
+	}
+}
+
+func (d *digestWriter) item(id int, title, detail string) {
+	if detail != "" {
+		detail = " (" + detail + ")"
+	}
+	switch d.format {
+	case "markdown":
+		fmt.Fprintf(&d.b, "- `#%d` %s%s\n", id, title, detail)
+	case "slack":
+		fmt.Fprintf(&d.b, "• `#%d` %s%s\n", id, title, detail)
+	default:
+		fmt.Fprintf(&d.b, "  #%d %s%s\n", id, title, detail)
+	}
+}
+
+func (d *digestWriter) more(total int) {
+	if total > digestTopN {
+		d.line(fmt.Sprintf("…and %d more", total-digestTopN))
+	}
+}
+
+func (d *digestWriter) none(total int, msg string) {
+	if total == 0 {
+		d.line(msg)
+	}
+}
+
+func (d *digestWriter) line(s string) {
+	switch d.format {
+	case "markdown", "slack":
+		d.b.WriteString("_" + s + "_\n")
+	default:
+		d.b.WriteString("  " + s + "\n")
+	}
+}
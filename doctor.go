@@ -0,0 +1,61 @@
+package main
+
+import (
+	"commandref/auth"
+	"commandref/config"
+	"fmt"
+	"os"
+)
+
+// runDoctor checks the setup: config, storage, backend and login, then
+// what is kept in plain text. It exits 1 when something is broken.
+func runDoctor() {
+	fmt.Printf("commandref %s (commit %s)\n", version, buildCommit())
+	broken := false
+	bad := func(s string) string {
+		broken = true
+		return colorize("31", s)
+	}
+
+	if p, err := config.Path(); err == nil {
+		if _, err := os.Stat(p); err != nil {
+			fmt.Printf("Config: %s (none; using the defaults)\n", tildePath(p))
+		} else {
+			fmt.Printf("Config: %s\n", tildePath(p))
+		}
+	}
+
+	switch {
+	case usingLocalStore():
+		p, _ := dbPath()
+		state := "ok"
+		if _, err := loadDB(); err != nil {
+			state = bad(err.Error())
+		}
+		fmt.Printf("Storage: %s, %s (%s)\n", orDefault(cfg.Storage, "api"), tildePath(p), state)
+	default:
+		s := openStore().(apiStore)
+		state := "reachable"
+		var items []Item
+		if err := apiErr(s.c.DoJSON("GET", s.c.Path("/v1/commands"), nil, &items)); err != nil {
+			state = bad(err.Error())
+		}
+		fmt.Printf("Storage: api, %s (%s)\n", s.c.BaseURL, state)
+		if ws := currentWorkspace(); ws.ID != "" {
+			fmt.Println("Workspace:", ws.label())
+		}
+		if sess, err := auth.LoadSession(); err != nil {
+			fmt.Println("Login:", bad(err.Error()))
+		} else if sess == nil {
+			fmt.Println("Login:", bad("not logged in; run: commandref login"))
+		} else {
+			fmt.Println("Login:", sess.Email)
+		}
+	}
+
+	fmt.Println()
+	printSecurity(true)
+	if broken {
+		os.Exit(1)
+	}
+}
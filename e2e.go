@@ -0,0 +1,597 @@
+package main
+
+import (
+	"commandref/paths"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// With "encryption": {"enabled": true}, the fields in encryption.fields are
+// encrypted here before anything is sent to the backend, and decrypted
+// again when items come back, so list/show/run work as before. A value is
+// stored as
+//
+//	crf1:<key id>:<base64 nonce+ciphertext>
+//
+// The key is a 32-byte key file, or derived from a passphrase with a salt
+// kept in the data dir (its ID carries the salt, so other machines with the
+// same passphrase can read it). `encryption rotate` moves everything to the
+// current key; replaced keys stay readable through old_key_files and
+// old_passphrase_command until then.
+
+const e2ePrefix = "crf1:"
+
+var e2eAllFields = []string{"title", "command", "notes", "workdir", "preRun", "postRun"}
+
+type e2eKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// The key caches are shared by bulk commands' workers (see forEachID); each
+// has its lock, held while it's filled so a passphrase command or the key
+// derivation runs once.
+var (
+	e2eCurrent    *e2eKey
+	e2eCurrentMu  sync.Mutex
+	e2eDerived    = map[string]cipher.AEAD{} // salt+passphrase -> key
+	e2eDerivedMu  sync.Mutex
+	e2eWarn       sync.Once
+	e2eKeyFiles   = map[string]*e2eKey{}
+	e2eKeyFilesMu sync.Mutex
+	e2ePasses     = map[string]string{} // env var -> passphrase
+	e2ePassesMu   sync.Mutex
+)
+
+// e2eEnabled reports whether writes to the backend get encrypted. Team
+// workspace items never are: the key is personal, so teammates couldn't
+// read them.
+func e2eEnabled() bool {
+	return e2eConfigured() && currentWorkspace().ID == ""
+}
+
+func e2eConfigured() bool {
+	return cfg != nil && cfg.Encryption.Enabled && !usingLocalStore()
+}
+
+// errWorkspaceE2E is for the commands that would encrypt team items.
+var errWorkspaceE2E = errors.New("encryption uses your personal key, so team workspace items stay in plain text; switch to your own library first: commandref workspace switch personal")
+
+func e2eFields() ([]string, error) {
+	fields := cfg.Encryption.Fields
+	if len(fields) == 0 {
+		return []string{"command", "notes"}, nil
+	}
+	for _, f := range fields {
+		if !slices.Contains(e2eAllFields, f) {
+			return nil, fmt.Errorf("encryption.fields: can't encrypt %q (have: %s)", f, strings.Join(e2eAllFields, ", "))
+		}
+	}
+	return fields, nil
+}
+
+func e2eField(it *Item, name string) *string {
+	switch name {
+	case "title":
+		return &it.Title
+	case "command":
+		return &it.Command
+	case "notes":
+		return &it.Notes
+	case "workdir":
+		return &it.Workdir
+	case "preRun":
+		return &it.PreRun
+	case "postRun":
+		return &it.PostRun
+	}
+	return nil
+}
+
+func isSealedValue(s string) bool {
+	return strings.HasPrefix(s, e2ePrefix)
+}
+
+// sealedKeyID is the key a value was encrypted with ("" if it is plain).
+func sealedKeyID(s string) string {
+	if !isSealedValue(s) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(s, e2ePrefix), ":")
+	return id
+}
+
+// e2eSeal returns a copy of an item payload or patch with the configured
+// fields encrypted; it is returned as is when encryption is off.
+func e2eSeal(body map[string]any) (map[string]any, error) {
+	if !e2eEnabled() {
+		return body, nil
+	}
+	return sealFields(body)
+}
+
+// e2eSealFor is e2eSeal for a write queued for base (a collection path),
+// which may belong to another workspace than the current one.
+func e2eSealFor(base string, body map[string]any) (map[string]any, error) {
+	if !e2eConfigured() || strings.HasPrefix(base, "/v1/workspaces/") {
+		return body, nil
+	}
+	return sealFields(body)
+}
+
+func sealFields(body map[string]any) (map[string]any, error) {
+	fields, err := e2eFields()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]any, len(body))
+	for k, v := range body {
+		out[k] = v
+	}
+	for _, f := range fields {
+		s, ok := out[f].(string)
+		if !ok || s == "" || isSealedValue(s) {
+			continue
+		}
+		if out[f], err = sealValue(f, s); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func sealValue(field, plain string) (string, error) {
+	k, err := currentE2EKey()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := k.aead.Seal(nonce, nonce, []byte(plain), []byte("commandref-e2e:"+field))
+	return e2ePrefix + k.id + ":" + base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+func openValue(field, sealed string) (string, error) {
+	id, enc, ok := strings.Cut(strings.TrimPrefix(sealed, e2ePrefix), ":")
+	data, err := base64.RawStdEncoding.DecodeString(enc)
+	if !ok || err != nil {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	keys, err := e2eKeysFor(id)
+	if err != nil {
+		return "", err
+	}
+	for _, aead := range keys {
+		if len(data) < aead.NonceSize() {
+			break
+		}
+		nonce, ct := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if plain, err := aead.Open(nil, nonce, ct, []byte("commandref-e2e:"+field)); err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", fmt.Errorf("no key for %s here", id)
+}
+
+// decryptItem decrypts whatever fields of it are encrypted. A value no
+// key here opens stays as it is, with one warning per run.
+func decryptItem(it *Item) {
+	for _, f := range e2eAllFields {
+		p := e2eField(it, f)
+		if !isSealedValue(*p) {
+			continue
+		}
+		plain, err := openValue(f, *p)
+		if err != nil {
+			e2eWarn.Do(func() {
+				fmt.Fprintf(os.Stderr, "warning: #%s is encrypted and can't be decrypted: %v (see: commandref encryption status)\n", displayID(*it), err)
+			})
+			continue
+		}
+		*p = plain
+	}
+}
+
+func decryptItems(items []Item) {
+	for i := range items {
+		decryptItem(&items[i])
+	}
+}
+
+// sealedField names a field of it still encrypted ("" if none).
+func sealedField(it Item) string {
+	for _, f := range e2eAllFields {
+		if isSealedValue(*e2eField(&it, f)) {
+			return f
+		}
+	}
+	return ""
+}
+
+// currentE2EKey is the key new values are encrypted with.
+func currentE2EKey() (*e2eKey, error) {
+	e2eCurrentMu.Lock()
+	defer e2eCurrentMu.Unlock()
+	if e2eCurrent != nil {
+		return e2eCurrent, nil
+	}
+	var err error
+	if cfg.Encryption.KeyFile != "" {
+		e2eCurrent, err = loadE2EKeyFile(cfg.Encryption.KeyFile)
+		return e2eCurrent, err
+	}
+	pass, err := e2ePassphrase("COMMANDREF_E2E_PASSPHRASE", cfg.Encryption.PassphraseCommand)
+	if err != nil {
+		return nil, err
+	}
+	if pass == "" {
+		return nil, fmt.Errorf("no encryption key: set encryption.key_file, or COMMANDREF_E2E_PASSPHRASE or encryption.passphrase_command")
+	}
+	salt, err := e2eSalt()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := derivedE2EKey(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	e2eCurrent = &e2eKey{id: "p" + base64.RawURLEncoding.EncodeToString(salt), aead: aead}
+	return e2eCurrent, nil
+}
+
+// e2eKeysFor lists the keys here that may have made key ID id.
+func e2eKeysFor(id string) ([]cipher.AEAD, error) {
+	var out []cipher.AEAD
+	if strings.HasPrefix(id, "k") {
+		for _, f := range append([]string{cfg.Encryption.KeyFile}, cfg.Encryption.OldKeyFiles...) {
+			if f == "" {
+				continue
+			}
+			k, err := loadE2EKeyFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if k.id == id {
+				out = append(out, k.aead)
+			}
+		}
+		return out, nil
+	}
+	salt, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(id, "p"))
+	if err != nil || !strings.HasPrefix(id, "p") {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	for _, src := range [][2]string{
+		{"COMMANDREF_E2E_PASSPHRASE", cfg.Encryption.PassphraseCommand},
+		{"COMMANDREF_E2E_OLD_PASSPHRASE", cfg.Encryption.OldPassphraseCommand},
+	} {
+		pass, err := e2ePassphrase(src[0], src[1])
+		if err != nil {
+			return nil, err
+		}
+		if pass == "" {
+			continue
+		}
+		aead, err := derivedE2EKey(pass, salt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, aead)
+	}
+	return out, nil
+}
+
+func e2ePassphrase(env, cmdline string) (string, error) {
+	e2ePassesMu.Lock()
+	defer e2ePassesMu.Unlock()
+	if p, ok := e2ePasses[env]; ok {
+		return p, nil
+	}
+	p := os.Getenv(env)
+	if p == "" {
+		var err error
+		if p, err = commandPassphrase(cmdline); err != nil {
+			return "", err
+		}
+	}
+	e2ePasses[env] = p
+	return p, nil
+}
+
+// derivedE2EKey runs the (slow) key derivation once per salt and passphrase.
+func derivedE2EKey(pass string, salt []byte) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(pass))
+	k := string(salt) + string(sum[:])
+	e2eDerivedMu.Lock()
+	defer e2eDerivedMu.Unlock()
+	if aead, ok := e2eDerived[k]; ok {
+		return aead, nil
+	}
+	aead, err := passphraseAEAD(pass, salt, pbkdf2Iter)
+	if err != nil {
+		return nil, err
+	}
+	e2eDerived[k] = aead
+	return aead, nil
+}
+
+// e2eSalt is this machine's salt for passphrase keys, made on first use.
+func e2eSalt() ([]byte, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.Join(dir, "e2e-salt")
+	if b, err := os.ReadFile(p); err == nil && len(b) == 16 {
+		return b, nil
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(p, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func loadE2EKeyFile(path string) (*e2eKey, error) {
+	e2eKeyFilesMu.Lock()
+	defer e2eKeyFilesMu.Unlock()
+	if k, ok := e2eKeyFiles[path]; ok {
+		return k, nil
+	}
+	b, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %w", err)
+	}
+	key := b
+	if s := strings.TrimSpace(string(b)); len(s) == 64 {
+		if h, err := hex.DecodeString(s); err == nil {
+			key = h
+		}
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key %s: want 32 bytes (or 64 hex digits), got %d", path, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	k := &e2eKey{id: "k" + hex.EncodeToString(sum[:])[:10], aead: aead}
+	e2eKeyFiles[path] = k
+	return k, nil
+}
+
+func runEncryption(args []string) {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	switch args[0] {
+	case "status":
+		runEncryptionStatus()
+	case "rotate":
+		runEncryptionRotate(args[1:])
+	case "keygen":
+		runEncryptionKeygen(args[1:])
+	default:
+		exitErr(errors.New("usage: commandref encryption [status] | encryption rotate [--dry-run] | encryption keygen <file>"))
+	}
+}
+
+// rawItems lists the library as stored on the backend, still encrypted.
+func rawItems() (apiStore, []Item) {
+	if usingLocalStore() {
+		exitErr(errors.New("encryption protects what is sent to the backend; the local library never leaves this machine"))
+	}
+	s := openStore().(apiStore)
+	var items []Item
+	err := s.reachable()
+	if err == nil {
+		err = apiErr(s.c.DoJSON("GET", s.c.Path("/v1/commands"), nil, &items))
+	}
+	if err != nil {
+		exitErr(err)
+	}
+	return s, items
+}
+
+func runEncryptionStatus() {
+	_, items := rawItems()
+	switch {
+	case e2eConfigured() && !e2eEnabled():
+		fmt.Println("Encryption: off for team workspace items (your key is personal)")
+	case !e2eEnabled():
+		fmt.Println("Encryption: off")
+	case cfg.Encryption.KeyFile != "":
+		fmt.Println("Encryption: on, key file " + cfg.Encryption.KeyFile)
+	default:
+		fmt.Println("Encryption: on, passphrase")
+	}
+	fields, err := e2eFields()
+	if err != nil {
+		exitErr(err)
+	}
+	current := ""
+	if e2eEnabled() {
+		k, err := currentE2EKey()
+		if err != nil {
+			exitErr(err)
+		}
+		current = k.id
+		fmt.Printf("Fields: %s\nKey: %s\n", strings.Join(fields, ", "), current)
+	}
+
+	byKey := map[string]int{}
+	plain, stale := 0, 0
+	for _, it := range items {
+		ids := map[string]bool{}
+		needs := false
+		for _, f := range e2eAllFields {
+			v := *e2eField(&it, f)
+			if id := sealedKeyID(v); id != "" {
+				ids[id] = true
+			} else if e2eEnabled() && v != "" && slices.Contains(fields, f) {
+				needs = true
+			}
+		}
+		for id := range ids {
+			byKey[id]++
+			if id != current {
+				needs = true
+			}
+		}
+		if len(ids) == 0 {
+			plain++
+		}
+		if needs {
+			stale++
+		}
+	}
+	fmt.Printf("Items: %d\n", len(items))
+	var keys []string
+	for id := range byKey {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+	for _, id := range keys {
+		note := ""
+		switch ks, err := e2eKeysFor(id); {
+		case id == current:
+			note = " (current key)"
+		case err != nil || len(ks) == 0:
+			note = " (no such key here)"
+		}
+		fmt.Printf("  %d encrypted with %s%s\n", byKey[id], id, note)
+	}
+	fmt.Printf("  %d in plain text\n", plain)
+	if stale > 0 {
+		fmt.Printf("%s to update; run: commandref encryption rotate\n", plural(stale, "item"))
+	}
+}
+
+// runEncryptionRotate rewrites the library as the config says: every
+// configured field encrypted with the current key, everything else (all of
+// it, with encryption off) in plain text. Nothing is written unless every
+// encrypted value can be read.
+func runEncryptionRotate(args []string) {
+	fs := flag.NewFlagSet("encryption rotate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only count what would be rewritten")
+	_ = fs.Parse(args)
+	if currentWorkspace().ID != "" {
+		exitErr(errWorkspaceE2E)
+	}
+	s, items := rawItems()
+	var fields []string
+	if e2eEnabled() {
+		var err error
+		if fields, err = e2eFields(); err != nil {
+			exitErr(err)
+		}
+		if _, err := currentE2EKey(); err != nil {
+			exitErr(err)
+		}
+	}
+
+	patches := map[int]map[string]any{}
+	var unreadable []string
+	for _, it := range items {
+		patch := map[string]any{}
+		for _, f := range e2eAllFields {
+			v := *e2eField(&it, f)
+			want := e2eEnabled() && v != "" && slices.Contains(fields, f)
+			if id := sealedKeyID(v); id != "" {
+				if want && id == e2eCurrent.id {
+					continue
+				}
+				plain, err := openValue(f, v)
+				if err != nil {
+					unreadable = append(unreadable, fmt.Sprintf("#%d %s: %v", it.ID, f, err))
+					continue
+				}
+				v = plain
+			} else if !want {
+				continue
+			}
+			if want {
+				sealed, err := sealValue(f, v)
+				if err != nil {
+					exitErr(err)
+				}
+				v = sealed
+			}
+			patch[f] = v
+		}
+		if len(patch) > 0 {
+			patches[it.ID] = patch
+		}
+	}
+	if len(unreadable) > 0 {
+		exitErr(fmt.Errorf("these can't be decrypted with the keys here (add the old key under old_key_files or old_passphrase_command):\n  %s", strings.Join(unreadable, "\n  ")))
+	}
+	if *dryRun || len(patches) == 0 {
+		fmt.Printf("%s to rewrite\n", plural(len(patches), "item"))
+		return
+	}
+
+	done := 0
+	for _, it := range items {
+		patch, ok := patches[it.ID]
+		if !ok {
+			continue
+		}
+		var updated Item
+		if err := s.c.DoJSON("PATCH", s.c.Path(fmt.Sprintf("/v1/commands/%d", it.ID)), patch, &updated); err != nil {
+			exitErr(fmt.Errorf("#%d: %v (%d of %d rewritten; run rotate again to finish)", it.ID, strings.TrimSpace(err.Error()), done, len(patches)))
+		}
+		done++
+	}
+	_, _ = s.List() // refresh the offline cache
+	fmt.Printf("Rewrote %s\n", plural(done, "item"))
+	if len(cfg.Encryption.OldKeyFiles) > 0 || cfg.Encryption.OldPassphraseCommand != "" {
+		fmt.Println("Nothing needs the old keys any more; they can go from the config.")
+	}
+}
+
+func runEncryptionKeygen(args []string) {
+	if len(args) != 1 {
+		exitErr(errors.New("usage: commandref encryption keygen <file>"))
+	}
+	if err := writeE2EKey(expandHome(args[0])); err != nil {
+		exitErr(err)
+	}
+	fmt.Printf("Wrote a new key to %s. Keep a copy somewhere safe: without it the encrypted fields are lost.\n", args[0])
+	fmt.Printf("Use it with {\"encryption\": {\"enabled\": true, \"key_file\": %q}} and run: commandref encryption rotate\n", args[0])
+}
+
+// writeE2EKey writes a new random key to p, which must not exist yet.
+func writeE2EKey(p string) error {
+	if _, err := os.Stat(p); err == nil {
+		return fmt.Errorf("%s exists; a key is never overwritten", p)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(hex.EncodeToString(key)+"\n"), 0600)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"commandref/config"
+	"crypto/cipher"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestE2ESealWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("COMMANDREF_HOME", dir)
+	key := filepath.Join(dir, "e2e.key")
+	if err := writeE2EKey(key); err != nil {
+		t.Fatal(err)
+	}
+	cfg = &config.Config{}
+	cfg.Encryption.Enabled, cfg.Encryption.KeyFile = true, key
+	t.Cleanup(func() { resolvedWorkspace = nil })
+
+	tests := []struct {
+		name       string
+		workspace  string // current one
+		base       string // where a queued write goes
+		wantSealed bool
+	}{
+		{"personal", "", "/v1/commands", true},
+		{"team workspace", "w1", "/v1/workspaces/w1/commands", false},
+		{"personal write flushed from a workspace", "w1", "/v1/commands", true},
+		{"workspace write flushed from personal", "", "/v1/workspaces/w1/commands", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvedWorkspace = &workspace{ID: tt.workspace}
+			body := map[string]any{"command": "echo hi"}
+
+			sealed, err := e2eSealFor(tt.base, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isSealedValue(sealed["command"].(string)); got != tt.wantSealed {
+				t.Errorf("queued write sealed = %v, want %v", got, tt.wantSealed)
+			}
+			if (tt.base == "/v1/commands") != (tt.workspace == "") {
+				return // e2eSeal is for writes to the current workspace
+			}
+			sealed, err = e2eSeal(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isSealedValue(sealed["command"].(string)); got != tt.wantSealed {
+				t.Errorf("write sealed = %v, want %v", got, tt.wantSealed)
+			}
+		})
+	}
+}
+
+func TestDecryptItemConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("COMMANDREF_HOME", dir)
+	t.Setenv("COMMANDREF_E2E_PASSPHRASE", "")
+	count := filepath.Join(dir, "asked")
+	cfg = &config.Config{}
+	cfg.Encryption.Enabled = true
+	cfg.Encryption.PassphraseCommand = "echo x >> '" + count + "'; echo hunter2"
+	resolvedWorkspace = &workspace{}
+	t.Cleanup(func() { resolvedWorkspace = nil })
+	reset := func() {
+		e2eCurrent = nil
+		e2eDerived = map[string]cipher.AEAD{}
+		e2eKeyFiles = map[string]*e2eKey{}
+		e2ePasses = map[string]string{}
+	}
+	reset()
+	sealed, err := sealValue("command", "make deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset()
+	_ = os.Remove(count)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			it := Item{ID: 1, Command: sealed}
+			decryptItem(&it)
+			if it.Command != "make deploy" {
+				t.Errorf("decrypted to %q", it.Command)
+			}
+		}()
+	}
+	wg.Wait()
+	b, _ := os.ReadFile(count)
+	if n := strings.Count(string(b), "x"); n != 1 {
+		t.Errorf("passphrase_command ran %d times, want once", n)
+	}
+}
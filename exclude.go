@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+)
+
+// exclusion drops items from list and search results: a tag (exact,
+// case-insensitive) or a word in the title, command or notes.
+type exclusion struct {
+	field string // "tags", "title", "command" or "notes"
+	value string // lower case
+}
+
+// excludeTagFlag collects repeated --exclude-tag flags (comma lists ok).
+type excludeTagFlag []exclusion
+
+func (e *excludeTagFlag) String() string {
+	tags := make([]string, len(*e))
+	for i, x := range *e {
+		tags[i] = x.value
+	}
+	return strings.Join(tags, ",")
+}
+
+func (e *excludeTagFlag) Set(s string) error {
+	for _, t := range parseTags(s) {
+		*e = append(*e, exclusion{"tags", strings.ToLower(t)})
+	}
+	return nil
+}
+
+// splitNegations pulls -tag:x, -title:x, -cmd:x and -notes:x out of the
+// search words. Other words starting with "-" are searched as usual, so
+// `search ls -la` still works.
+func splitNegations(words []string) (kept []string, ex []exclusion) {
+	for _, w := range words {
+		field, value, ok := strings.Cut(strings.TrimPrefix(w, "-"), ":")
+		if !strings.HasPrefix(w, "-") || !ok || value == "" {
+			kept = append(kept, w)
+			continue
+		}
+		switch strings.ToLower(field) {
+		case "tag", "tags":
+			field = "tags"
+		case "title":
+			field = "title"
+		case "cmd", "command":
+			field = "command"
+		case "notes":
+			field = "notes"
+		default:
+			kept = append(kept, w)
+			continue
+		}
+		ex = append(ex, exclusion{field, strings.ToLower(value)})
+	}
+	return kept, ex
+}
+
+func excludeItems(items []Item, ex []exclusion) []Item {
+	if len(ex) == 0 {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		if !excluded(it, ex) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func excluded(it Item, ex []exclusion) bool {
+	for _, x := range ex {
+		if x.field == "tags" {
+			for _, t := range it.Tags {
+				if strings.EqualFold(t, x.value) {
+					return true
+				}
+			}
+			continue
+		}
+		if strings.Contains(strings.ToLower(itemField(it, x.field)), x.value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"commandref/api"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes are a contract scripts can rely on:
+//
+//	0    success
+//	1    declined or cancelled at a prompt, or a check failed (test)
+//	2    usage, validation or any other error
+//	3    not found: an item, collection, workflow, job, share...
+//	4    the clipboard couldn't be written
+//	5    the command couldn't be started (run)
+//	6    not logged in, or the backend refused the credentials
+//	7    the backend couldn't be reached
+//	124  run --timeout stopped the command
+//
+// Once it has started, `run` exits with the command's own exit code.
+//
+// With --error-format json (or COMMANDREF_ERROR_FORMAT=json) a failure is
+// reported as one JSON object on stderr instead:
+//
+//	{"error": "not logged in...", "kind": "auth", "code": 6}
+const (
+	exitDeclined  = 1
+	exitError     = 2
+	exitNotFound  = 3
+	exitClipboard = 4
+	exitRun       = 5
+	exitAuth      = 6
+	exitNetwork   = 7
+)
+
+var exitKinds = map[int]string{
+	exitDeclined:  "declined",
+	exitError:     "error",
+	exitNotFound:  "not_found",
+	exitClipboard: "clipboard",
+	exitRun:       "run",
+	exitAuth:      "auth",
+	exitNetwork:   "network",
+}
+
+// exitCodeOf picks the exit code for a failure from what caused it.
+func exitCodeOf(err error) int {
+	var he *api.HTTPError
+	httpStatus := 0
+	if errors.As(err, &he) {
+		httpStatus = he.StatusCode
+	}
+	switch {
+	case errors.Is(err, errNotFound) || httpStatus == 404:
+		return exitNotFound
+	case errors.Is(err, api.ErrNotLoggedIn) || httpStatus == 401 || httpStatus == 403:
+		return exitAuth
+	case isNetworkErr(err) || errors.Is(err, errBackendDown):
+		return exitNetwork
+	}
+	return exitError
+}
+
+// exitErr reports err and exits with the code for it.
+func exitErr(err error) {
+	exitWith(exitCodeOf(err), err)
+}
+
+func exitWith(code int, err error) {
+	reportErr(code, err)
+	os.Exit(code)
+}
+
+// errorJSON is a failure as --error-format json prints it, with extra
+// fields (such as the item) added.
+func errorJSON(code int, err error, extra map[string]any) string {
+	m := map[string]any{"error": err.Error(), "kind": exitKinds[code], "code": code}
+	var he *api.HTTPError
+	if errors.As(err, &he) && he.RequestID != "" {
+		m["requestId"] = he.RequestID
+	}
+	for k, v := range extra {
+		m[k] = v
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false) // usage lines have <id> and the like
+	_ = enc.Encode(m)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// reportErr prints a failure as "error: ..." (plain "..." when something
+// wasn't found), or as JSON with --error-format json.
+func reportErr(code int, err error) {
+	if opts.ErrorFormat == "json" {
+		fmt.Fprintln(os.Stderr, errorJSON(code, err, nil))
+		return
+	}
+	switch code {
+	case exitNotFound:
+		fmt.Fprintln(os.Stderr, err)
+	case exitRun:
+		fmt.Fprintln(os.Stderr, "run error:", err)
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+}
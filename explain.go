@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// `explain` breaks a saved command into programs, flags and operators and
+// says what each one does. Descriptions come from the explain_command
+// config setting (a provider script), then the table bundled in
+// explain_data.go, then the installed man pages.
+
+type flagDoc struct {
+	arg  bool // takes a value ("-m MSG")
+	desc string
+}
+
+type progDoc struct {
+	desc  string
+	flags map[string]flagDoc
+}
+
+type explainRow struct {
+	indent bool
+	token  string
+	desc   string
+}
+
+// programs that run the command after them
+var commandWrappers = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "time": true, "nohup": true,
+	"exec": true, "xargs": true, "nice": true, "watch": true, "command": true,
+}
+
+var shellOperators = map[string]string{
+	"|":    "pipe: the output goes to the next command",
+	"||":   "run the next command only if this one failed",
+	"&&":   "run the next command only if this one succeeded",
+	";":    "then run the next command",
+	"&":    "run in the background",
+	">":    "write the output to a file (replacing it)",
+	">>":   "append the output to a file",
+	"<":    "read the input from a file",
+	"2>":   "write errors to a file",
+	"2>&1": "send errors where the output goes",
+	"&>":   "write output and errors to a file",
+	"|&":   "pipe output and errors to the next command",
+}
+
+var (
+	envAssignment  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+	onlyPositional = regexp.MustCompile(`^\$(?:\{([1-9@*])\}|([1-9@*]))$`)
+)
+
+func runExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	command := fs.String("cmd", "", "explain this command instead of a saved one")
+	var rest []string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		rest, args = args[:1], args[1:]
+	}
+	_ = fs.Parse(args)
+	rest = append(rest, fs.Args()...)
+
+	if *command == "" {
+		if len(rest) != 1 {
+			exitErr(errors.New("usage: commandref explain <id> | --cmd \"...\""))
+		}
+		id, err := parseID(rest[0])
+		if err != nil {
+			exitErr(err)
+		}
+		it := mustGetItem(openStore(), id)
+		fmt.Printf("#%s %s%s\n", displayID(*it), iconPrefix(*it), it.Title)
+		*command = it.Command
+	}
+	fmt.Println("  " + colorize("36", strings.ReplaceAll(normalizeCommand(*command), "\n", "\n  ")))
+	fmt.Println()
+
+	rows := explainCommand(*command)
+	if opts.Accessible {
+		for _, r := range rows {
+			if r.desc == "" {
+				r.desc = "no description"
+			}
+			fmt.Printf("%s: %s\n", r.token, r.desc)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	for _, r := range rows {
+		tok := r.token
+		if r.indent {
+			tok = "  " + tok
+		}
+		fmt.Fprintf(w, "%s\t%s\n", tok, r.desc)
+	}
+	_ = w.Flush()
+}
+
+// explainCommand walks the tokens of command: a new program starts at the
+// beginning of each pipeline stage and after a wrapper like sudo (and that
+// wrapper's own flags).
+func explainCommand(command string) []explainRow {
+	var rows []explainRow
+	var prog string // "" until the stage's program is known
+	var doc *progDoc
+	wrapper, sub := false, false
+	tokens := shellWords(normalizeCommand(command))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if desc, ok := shellOperators[tok]; ok {
+			rows = append(rows, explainRow{token: tok, desc: desc})
+			if strings.ContainsAny(tok, "|&;") {
+				prog, doc = "", nil
+			} else if i+1 < len(tokens) {
+				// the file name belongs with the redirection
+				i++
+				rows[len(rows)-1].token += " " + tokens[i]
+			}
+			continue
+		}
+		if prog == "" || wrapper && !strings.HasPrefix(tok, "-") {
+			if envAssignment.MatchString(tok) {
+				name := tok[:strings.Index(tok, "=")]
+				rows = append(rows, explainRow{token: tok, desc: "sets " + name + " for this command"})
+				continue
+			}
+			prog, doc, sub = tok, lookupProgram(tok), false
+			wrapper = commandWrappers[tok]
+			// `git commit`, `docker run`: the subcommand has its own flags
+			for i+1 < len(tokens) && isWord(tokens[i+1]) {
+				d := lookupProgram(prog + " " + tokens[i+1])
+				if d == nil {
+					break
+				}
+				i++
+				prog, doc, sub = prog+" "+tokens[i], d, true
+			}
+			rows = append(rows, explainRow{token: prog, desc: docDesc(doc)})
+			continue
+		}
+		row := explainRow{indent: true, token: tok}
+		switch {
+		case onlyPositional.MatchString(tok):
+			row.desc = "argument " + strings.Trim(tok, "${}") + " given to `commandref run`"
+		case strings.HasPrefix(tok, "-") && tok != "-" && tok != "--":
+			var takesArg bool
+			row.desc, takesArg = explainFlag(prog, doc, tok)
+			if takesArg && !strings.Contains(tok, "=") && i+1 < len(tokens) {
+				if _, op := shellOperators[tokens[i+1]]; !op {
+					i++
+					row.token += " " + tokens[i]
+				}
+			}
+		case !sub && isWord(tok):
+			// a subcommand after global flags: `kubectl -n prod logs`
+			if d := lookupProgram(prog + " " + tok); d != nil {
+				prog, doc, sub = prog+" "+tok, d, true
+				row.desc = d.desc
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func isWord(tok string) bool {
+	if tok == "" || strings.HasPrefix(tok, "-") || strings.ContainsAny(tok, "$'\"/=") {
+		return false
+	}
+	_, op := shellOperators[tok]
+	return !op
+}
+
+func docDesc(doc *progDoc) string {
+	if doc == nil {
+		return ""
+	}
+	return doc.desc
+}
+
+// explainFlag describes one flag token: "--name=value", "-x" or a bundle
+// of short flags like "-la".
+func explainFlag(prog string, doc *progDoc, tok string) (string, bool) {
+	name := tok
+	if i := strings.Index(tok, "="); i > 0 && strings.HasPrefix(tok, "--") {
+		name = tok[:i]
+	}
+	if d, ok := flagDescription(prog, doc, name); ok {
+		return d.desc, d.arg
+	}
+	if strings.HasPrefix(tok, "--") || len(tok) <= 2 {
+		return "", false
+	}
+	// -la is -l -a; a flag taking a value swallows the rest (-n5)
+	var parts []string
+	for i, r := range tok[1:] {
+		d, ok := flagDescription(prog, doc, "-"+string(r))
+		if !ok {
+			return "", false
+		}
+		if d.arg && i+2 < len(tok) {
+			return "-" + string(r) + " " + tok[i+2:] + ": " + d.desc, false
+		}
+		parts = append(parts, "-"+string(r)+": "+d.desc)
+		if d.arg {
+			return strings.Join(parts, "; "), true
+		}
+	}
+	return strings.Join(parts, "; "), false
+}
+
+func flagDescription(prog string, doc *progDoc, name string) (flagDoc, bool) {
+	if desc := providerDescription(prog, name); desc != "" {
+		return flagDoc{desc: desc}, true
+	}
+	if doc != nil {
+		d, ok := doc.flags[name]
+		return d, ok
+	}
+	return flagDoc{}, false
+}
+
+// lookupProgram finds what's known about a program (or "prog sub"); nil
+// when nothing is.
+func lookupProgram(prog string) *progDoc {
+	if desc := providerDescription(prog, ""); desc != "" {
+		doc := &progDoc{desc: desc, flags: map[string]flagDoc{}}
+		if known := bundledDoc(prog); known != nil {
+			doc.flags = known.flags
+		}
+		return doc
+	}
+	if doc := bundledDoc(prog); doc != nil {
+		return doc
+	}
+	return manDoc(prog)
+}
+
+var providerCache = map[string]string{}
+
+// providerDescription asks the explain_command script, if one is set. It
+// gets COMMANDREF_EXPLAIN_PROGRAM ("git commit") and COMMANDREF_EXPLAIN_TOKEN
+// (a flag, or empty for the program) and prints a one-line description, or
+// nothing.
+func providerDescription(prog, token string) string {
+	if cfg == nil || cfg.ExplainCommand == "" {
+		return ""
+	}
+	key := prog + "\x00" + token
+	if d, ok := providerCache[key]; ok {
+		return d
+	}
+	cmd := exec.Command("/bin/sh", "-c", cfg.ExplainCommand)
+	cmd.Env = append(os.Environ(), "COMMANDREF_EXPLAIN_PROGRAM="+prog, "COMMANDREF_EXPLAIN_TOKEN="+token)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	d := ""
+	if err == nil {
+		d, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+	}
+	providerCache[key] = d
+	return d
+}
+
+var bundled map[string]*progDoc
+
+func bundledDoc(prog string) *progDoc {
+	if bundled == nil {
+		bundled = parseExplainData(explainData)
+	}
+	return bundled[prog]
+}
+
+// parseExplainData reads the bundled table: "prog: description" lines,
+// each followed by indented "-f, --flag ARG: description" lines.
+func parseExplainData(data string) map[string]*progDoc {
+	docs := map[string]*progDoc{}
+	var cur *progDoc
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		head, desc, ok := strings.Cut(strings.TrimSpace(line), ": ")
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			cur = &progDoc{desc: desc, flags: map[string]flagDoc{}}
+			docs[head] = cur
+			continue
+		}
+		if cur != nil {
+			addFlags(cur.flags, head, desc)
+		}
+	}
+	return docs
+}
+
+// addFlags registers the flags named in an option header like
+// "-a, --all" or "-L PORT:HOST:PORT".
+func addFlags(flags map[string]flagDoc, head, desc string) {
+	for _, part := range strings.Split(head, ",") {
+		f := strings.Fields(part)
+		if len(f) == 0 || !strings.HasPrefix(f[0], "-") {
+			continue
+		}
+		name, arg := f[0], len(f) > 1
+		if n, _, ok := strings.Cut(name, "="); ok {
+			name, arg = n, true
+		}
+		if n, _, ok := strings.Cut(name, "["); ok {
+			name = n
+		}
+		flags[name] = flagDoc{arg: arg, desc: desc}
+	}
+}
+
+var manCache = map[string]*progDoc{}
+
+// manDoc parses the program's man page (git-commit(1) for "git commit"):
+// the NAME line describes it, and indented lines starting with a dash
+// are options, followed by (or ending in) their description.
+func manDoc(prog string) *progDoc {
+	page := strings.ReplaceAll(prog, " ", "-")
+	if doc, ok := manCache[page]; ok {
+		return doc
+	}
+	manCache[page] = nil
+	if strings.ContainsAny(page, "/$'\"") {
+		return nil
+	}
+	cmd := exec.Command("man", page)
+	cmd.Env = append(os.Environ(), "MANPAGER=cat", "PAGER=cat", "MANWIDTH=200", "MAN_KEEP_FORMATTING=0")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	doc := &progDoc{flags: map[string]flagDoc{}}
+	lines := strings.Split(stripOverstrike(string(out)), "\n")
+	section := ""
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRightFunc(lines[i], unicode.IsSpace)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if line == trimmed {
+			section = trimmed
+			continue
+		}
+		if section == "NAME" && doc.desc == "" {
+			if _, d, ok := strings.Cut(trimmed, " - "); ok {
+				doc.desc = d
+			} else if _, d, ok := strings.Cut(trimmed, " – "); ok {
+				doc.desc = d
+			}
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		// BSD style puts the description on the same line after a gap
+		head, desc := trimmed, ""
+		if h, d, ok := strings.Cut(trimmed, "   "); ok {
+			head, desc = h, strings.TrimSpace(d)
+		} else if i+1 < len(lines) && indentOf(lines[i+1]) > indentOf(line) {
+			desc = strings.TrimSpace(lines[i+1])
+		}
+		if desc == "" {
+			continue
+		}
+		if s, _, ok := strings.Cut(desc, ". "); ok {
+			desc = s
+		}
+		addFlags(doc.flags, head, strings.TrimSuffix(desc, "."))
+	}
+	if doc.desc == "" && len(doc.flags) == 0 {
+		return nil
+	}
+	manCache[page] = doc
+	return doc
+}
+
+// stripOverstrike removes the x\bx bold and _\bx underline man emits for
+// terminals.
+func stripOverstrike(s string) string {
+	if !strings.Contains(s, "\b") {
+		return s
+	}
+	var b []rune
+	for _, r := range s {
+		if r == '\b' && len(b) > 0 {
+			b = b[:len(b)-1]
+			continue
+		}
+		b = append(b, r)
+	}
+	return string(b)
+}
+
+// shellWords splits a command line into words and operators, the way a
+// POSIX shell would, but keeping quotes as written.
+func shellWords(s string) []string {
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, cur.String())
+			cur.Reset()
+		}
+	}
+	rs := []rune(s)
+	quote := rune(0)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote != 0:
+			cur.WriteRune(r)
+			if r == '\\' && quote == '"' && i+1 < len(rs) {
+				i++
+				cur.WriteRune(rs[i])
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			cur.WriteRune(r)
+		case r == '\\' && i+1 < len(rs):
+			if rs[i+1] == '\n' {
+				i++
+				continue
+			}
+			cur.WriteRune(r)
+			i++
+			cur.WriteRune(rs[i])
+		case r == ' ' || r == '\t':
+			flush()
+		case r == '\n':
+			flush()
+			words = append(words, ";")
+		case strings.ContainsRune("|&;<>", r):
+			// 2>, 2>&1: a lone digit before > belongs to the operator
+			op := ""
+			if r == '>' && cur.String() == "2" {
+				op = "2"
+				cur.Reset()
+			}
+			flush()
+			op += string(r)
+			for i+1 < len(rs) && strings.ContainsRune("|&>", rs[i+1]) {
+				if _, ok := shellOperators[op+string(rs[i+1])]; !ok && !strings.HasPrefix(op+string(rs[i+1]), "2>&") {
+					break
+				}
+				i++
+				op += string(rs[i])
+			}
+			if op == "2>&" && i+1 < len(rs) && rs[i+1] == '1' {
+				i++
+				op += "1"
+			}
+			words = append(words, op)
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,408 @@
+package main
+
+// explainData is what `explain` knows without man pages: the programs
+// people save most, and the flags that make their one-liners hard to
+// read. Indented lines are flags; an upper-case word after a flag means
+// it takes a value.
+const explainData = `
+sudo: run the rest of the line as root (or another user)
+  -u USER: run as USER instead of root
+  -E, --preserve-env: keep the current environment
+  -i, --login: start a login shell as the target user
+env: run the rest of the line with a modified environment
+  -i, --ignore-environment: start with an empty environment
+  -u NAME, --unset NAME: remove NAME from the environment
+time: run the rest of the line and report how long it took
+nohup: run the rest of the line immune to hangups (keeps going after logout)
+nice: run the rest of the line with a lower priority
+  -n N: adjust the priority by N
+watch: run the rest of the line repeatedly, showing its output
+  -n SECONDS, --interval SECONDS: seconds between runs
+  -d, --differences: highlight what changed
+xargs: run the rest of the line with arguments read from input
+  -0, --null: input items are separated by NUL (pairs with find -print0)
+  -I REPLACE: put each input item where REPLACE appears
+  -n N: at most N arguments per run
+  -P N: run up to N in parallel
+  -r, --no-run-if-empty: don't run at all on empty input
+
+ls: list directory contents
+  -l: long format: permissions, owner, size, date
+  -a, --all: include entries starting with .
+  -A, --almost-all: include dotfiles except . and ..
+  -h, --human-readable: sizes like 1K 234M 2G
+  -t: sort by modification time, newest first
+  -r, --reverse: reverse the sort order
+  -S: sort by size, largest first
+  -R, --recursive: list subdirectories too
+  -1: one entry per line
+cp: copy files
+  -r, -R, --recursive: copy directories recursively
+  -a, --archive: copy recursively, keeping permissions, times and links
+  -f, --force: overwrite without asking
+  -i, --interactive: ask before overwriting
+  -v, --verbose: print each file copied
+mv: move or rename files
+  -f, --force: overwrite without asking
+  -i, --interactive: ask before overwriting
+  -n, --no-clobber: never overwrite
+  -v, --verbose: print each file moved
+rm: remove files
+  -r, -R, --recursive: remove directories and their contents
+  -f, --force: ignore missing files, never ask
+  -i: ask before every removal
+  -v, --verbose: print each file removed
+mkdir: create directories
+  -p, --parents: create missing parents, no error if it exists
+chmod: change file permissions
+  -R, --recursive: change files and directories recursively
+chown: change file owner and group
+  -R, --recursive: change files and directories recursively
+ln: create links
+  -s, --symbolic: make a symbolic link
+  -f, --force: replace an existing file
+cat: print files
+  -n, --number: number the lines
+head: print the first lines of files
+  -n N, --lines N: the first N lines
+  -c N, --bytes N: the first N bytes
+tail: print the last lines of files
+  -n N, --lines N: the last N lines
+  -f: keep printing lines as the file grows
+  -F: like -f, and follow the file if it is rotated
+grep: print lines matching a pattern
+  -i, --ignore-case: ignore case
+  -v, --invert-match: print the lines that don't match
+  -r, --recursive: search directories recursively
+  -R, --dereference-recursive: search recursively, following symlinks
+  -n, --line-number: show line numbers
+  -l, --files-with-matches: print only the names of matching files
+  -c, --count: print only a count of matching lines
+  -E, --extended-regexp: pattern is an extended regex
+  -F, --fixed-strings: pattern is a plain string, not a regex
+  -o, --only-matching: print only the matched parts
+  -w, --word-regexp: match whole words only
+  -A N, --after-context N: also print N lines after each match
+  -B N, --before-context N: also print N lines before each match
+  -C N, --context N: also print N lines around each match
+  -q, --quiet: print nothing, only set the exit status
+  --include GLOB: only search files matching GLOB
+  --exclude GLOB: skip files matching GLOB
+  --color WHEN: highlight matches (always, never, auto)
+find: search for files in a directory tree
+  -name PATTERN: file name matches PATTERN
+  -iname PATTERN: like -name, ignoring case
+  -path PATTERN: path matches PATTERN
+  -type TYPE: file type (f file, d directory, l symlink)
+  -mtime N: modified N days ago (+N more than, -N less than)
+  -mmin N: modified N minutes ago (+N more than, -N less than)
+  -size N: size N (e.g. +100M for more than 100 MiB)
+  -maxdepth N: descend at most N directory levels
+  -mindepth N: skip the first N levels
+  -newer FILE: modified more recently than FILE
+  -user NAME: owned by NAME
+  -empty: empty files and directories
+  -exec CMD: run CMD for each file ({} is the file, ends at \; or +)
+  -delete: delete the files found
+  -print0: print names separated by NUL (pairs with xargs -0)
+  -prune: don't descend into this directory
+  -o: or: either expression may match
+  -not: negate the next expression
+sed: stream editor: rewrite text line by line
+  -i, --in-place: edit files in place
+  -n, --quiet: print only lines asked for with p
+  -e SCRIPT: add SCRIPT to the commands
+  -E, -r, --regexp-extended: use extended regexes
+awk: pattern scanning and text processing language
+  -F SEP: field separator
+  -v VAR=VALUE: set a variable before the program runs
+sort: sort lines
+  -n, --numeric-sort: compare as numbers
+  -h, --human-numeric-sort: compare sizes like 2K and 1G
+  -r, --reverse: largest first
+  -u, --unique: drop duplicates
+  -k KEY, --key KEY: sort by a field, e.g. -k2 or -k2,2
+  -t SEP: field separator
+uniq: drop repeated adjacent lines
+  -c, --count: prefix lines with how often they occur
+  -d, --repeated: only print duplicated lines
+wc: count lines, words and bytes
+  -l, --lines: count lines
+  -w, --words: count words
+  -c, --bytes: count bytes
+cut: print selected parts of lines
+  -d SEP, --delimiter SEP: field separator
+  -f LIST, --fields LIST: fields to print, e.g. 1,3 or 2-
+  -c LIST: characters to print
+tr: translate or delete characters
+  -d: delete the characters
+  -s: squeeze repeats into one
+tee: copy input to files and the output
+  -a, --append: append to the files
+tar: create or extract archives
+  -c, --create: create an archive
+  -x, --extract: extract an archive
+  -t, --list: list the archive's contents
+  -f FILE, --file FILE: archive file (- is stdin/stdout)
+  -z, --gzip: compress with gzip (.tar.gz)
+  -j, --bzip2: compress with bzip2 (.tar.bz2)
+  -J, --xz: compress with xz (.tar.xz)
+  -v, --verbose: list files as they are processed
+  -C DIR, --directory DIR: change to DIR first
+du: disk usage of files and directories
+  -h, --human-readable: sizes like 1K 234M 2G
+  -s, --summarize: only a total for each argument
+  -d N, --max-depth N: totals down to N levels
+df: free disk space per file system
+  -h, --human-readable: sizes like 1K 234M 2G
+ps: list processes
+  -e: all processes
+  -f: full format
+kill: send a signal to processes
+  -9: SIGKILL: stop immediately, no cleanup
+  -15: SIGTERM: ask to stop (the default)
+  -s SIGNAL: send SIGNAL
+pkill: signal processes by name
+  -f: match the whole command line, not just the name
+lsof: list open files
+  -i ADDR: network connections, e.g. -i :8080 for a port
+  -n: don't resolve host names
+  -P: don't resolve port names
+ss: show sockets
+  -t: TCP
+  -u: UDP
+  -l: listening sockets only
+  -n: numeric addresses and ports
+  -p: show the owning process
+curl: transfer data from or to a URL
+  -X METHOD, --request METHOD: HTTP method
+  -H HEADER, --header HEADER: add a request header
+  -d DATA, --data DATA: send DATA as the request body (POST)
+  --data-raw DATA: send DATA as is
+  -F FIELD, --form FIELD: multipart form field
+  -o FILE, --output FILE: write the body to FILE
+  -O, --remote-name: save under the file name from the URL
+  -L, --location: follow redirects
+  -s, --silent: no progress meter or errors
+  -S, --show-error: show errors even with -s
+  -f, --fail: fail on HTTP errors, without printing the error page
+  -i, --include: include response headers
+  -I, --head: only fetch the headers
+  -k, --insecure: skip TLS certificate verification
+  -u USER:PASSWORD, --user USER:PASSWORD: basic auth credentials
+  -v, --verbose: show the request and response details
+  --retry N: retry N times on transient errors
+wget: download files
+  -O FILE: write to FILE (- for stdout)
+  -q, --quiet: no output
+  -c, --continue: resume a partial download
+ssh: OpenSSH remote login client
+  -p PORT: port to connect to
+  -i FILE: identity (private key) file
+  -L PORT:HOST:HOSTPORT: forward a local port to HOST:HOSTPORT via the server
+  -R PORT:HOST:HOSTPORT: forward a port on the server to HOST:HOSTPORT here
+  -D PORT: SOCKS proxy on a local port
+  -N: don't run a remote command (just forward ports)
+  -f: go to the background after authenticating
+  -J HOST: connect through the jump host HOST
+  -A: forward the SSH agent
+  -t: force a terminal (for interactive remote commands)
+  -v: verbose: debug connection problems
+  -o OPTION: set a config option, e.g. -o StrictHostKeyChecking=no
+scp: copy files over SSH
+  -r: copy directories recursively
+  -P PORT: port to connect to
+  -i FILE: identity (private key) file
+rsync: fast incremental file copy, locally or over SSH
+  -a, --archive: recursive, keeping permissions, times, links and owners
+  -v, --verbose: list files as they are copied
+  -z, --compress: compress during transfer
+  -P: show progress and keep partial files
+  -n, --dry-run: only show what would be done
+  --delete: delete files at the destination that the source lacks
+  --exclude PATTERN: skip files matching PATTERN
+  -e COMMAND: remote shell to use, e.g. -e "ssh -p 2222"
+systemctl: control systemd services
+  --user: the user's services instead of the system's
+  --now: with enable/disable, also start/stop it
+journalctl: read the systemd journal
+  -u UNIT, --unit UNIT: only messages from UNIT
+  -f, --follow: keep printing new messages
+  -n N, --lines N: the last N messages
+  -e: jump to the end
+  --since TIME: messages since TIME, e.g. "1 hour ago"
+jq: JSON processor
+  -r, --raw-output: print strings without quotes
+  -c, --compact-output: one line per value
+  -s, --slurp: read all inputs into one array
+  -e, --exit-status: exit status from the last output value
+  --arg NAME VALUE: set $NAME to the string VALUE
+openssl: cryptography toolkit
+  -in FILE: input file
+  -out FILE: output file
+  -noout: don't print the encoded form
+  -text: print in human readable form
+  -connect HOST:PORT: server to connect to
+  -servername NAME: SNI server name
+
+git: distributed version control
+  -C DIR: run as if started in DIR
+  -c NAME=VALUE: set a config value for this command
+git add: stage changes for the next commit
+  -A, --all: stage all changes, including removals
+  -p, --patch: choose hunks interactively
+  -u, --update: stage changes to tracked files only
+git commit: record staged changes
+  -m MSG, --message MSG: use MSG as the commit message
+  -a, --all: stage all tracked changes first
+  --amend: replace the last commit
+  --no-edit: keep the existing message
+  --fixup COMMIT: make a fixup commit for COMMIT
+  --no-verify: skip the pre-commit and commit-msg hooks
+  -S, --gpg-sign: sign the commit
+git push: send commits to a remote
+  -u, --set-upstream: remember the remote branch as upstream
+  -f, --force: overwrite the remote branch
+  --force-with-lease: force, unless the remote changed since the last fetch
+  --tags: push tags too
+  --delete: delete the remote branch
+git pull: fetch and integrate remote changes
+  --rebase: rebase local commits instead of merging
+  --ff-only: only fast-forward
+git fetch: download objects and refs from a remote
+  --all: from all remotes
+  -p, --prune: drop remote-tracking branches that no longer exist
+  --tags: fetch all tags
+git clone: copy a repository
+  --depth N: only the last N commits
+  -b BRANCH, --branch BRANCH: check out BRANCH
+  --recurse-submodules: clone submodules too
+git checkout: switch branches or restore files
+  -b BRANCH: create BRANCH and switch to it
+  -B BRANCH: create or reset BRANCH and switch to it
+git switch: switch branches
+  -c BRANCH, --create BRANCH: create BRANCH and switch to it
+git branch: list, create or delete branches
+  -a, --all: include remote-tracking branches
+  -d, --delete: delete a merged branch
+  -D: delete a branch even if unmerged
+  -m, --move: rename a branch
+  -v, --verbose: show the last commit of each branch
+git log: show commit history
+  --oneline: one line per commit
+  --graph: draw the branch graph
+  --all: all branches
+  -n N: only N commits
+  -p, --patch: show each commit's diff
+  --stat: show changed files
+  --since DATE: commits newer than DATE
+  --author PATTERN: commits by matching authors
+  --decorate: show branch and tag names
+git diff: show changes
+  --cached, --staged: staged changes instead of unstaged
+  --stat: summary of changed files
+  --name-only: only the changed file names
+git reset: move HEAD, optionally resetting the index and files
+  --hard: also discard changes in the working tree
+  --soft: keep changes staged
+  --mixed: keep changes, unstaged (the default)
+git rebase: reapply commits on top of another base
+  -i, --interactive: edit the list of commits first
+  --onto NEWBASE: rebase onto NEWBASE
+  --continue: continue after resolving conflicts
+  --abort: give up and go back
+  --autosquash: apply fixup! commits automatically
+git stash: set changes aside
+  -u, --include-untracked: stash untracked files too
+  -m MSG, --message MSG: describe the stash
+git status: show the working tree status
+  -s, --short: short format
+  -b, --branch: show the branch in short format
+git clean: remove untracked files
+  -f, --force: actually remove them
+  -d: remove untracked directories too
+  -x: remove ignored files too
+  -n, --dry-run: only show what would be removed
+
+docker: container runtime client
+docker run: create and start a container
+  -d, --detach: run in the background
+  -i, --interactive: keep stdin open
+  -t, --tty: allocate a terminal
+  --rm: remove the container when it exits
+  -p PORTS, --publish PORTS: publish a port, host:container
+  -v VOLUME, --volume VOLUME: mount a volume, host:container
+  -e VAR=VALUE, --env VAR=VALUE: set an environment variable
+  --env-file FILE: read environment variables from FILE
+  --name NAME: name the container
+  -w DIR, --workdir DIR: working directory inside the container
+  --network NETWORK: connect to NETWORK
+  --entrypoint CMD: override the image's entrypoint
+  -u USER, --user USER: run as USER
+  --restart POLICY: restart policy, e.g. unless-stopped
+docker exec: run a command in a running container
+  -i, --interactive: keep stdin open
+  -t, --tty: allocate a terminal
+  -u USER, --user USER: run as USER
+  -e VAR=VALUE, --env VAR=VALUE: set an environment variable
+docker ps: list containers
+  -a, --all: include stopped containers
+  -q, --quiet: only IDs
+  -f FILTER, --filter FILTER: filter, e.g. status=exited
+docker logs: print a container's logs
+  -f, --follow: keep printing new output
+  --tail N: only the last N lines
+  --since TIME: logs since TIME, e.g. 10m
+docker build: build an image from a Dockerfile
+  -t NAME, --tag NAME: name (and tag) the image
+  -f FILE, --file FILE: Dockerfile to use
+  --no-cache: don't use cached layers
+  --build-arg VAR=VALUE: set a build argument
+docker rm: remove containers
+  -f, --force: stop running containers first
+docker system: manage Docker itself
+docker system prune: remove unused data
+  -a, --all: all unused images, not only dangling ones
+  -f, --force: don't ask
+  --volumes: prune volumes too
+docker compose: run multi-container apps
+  -f FILE, --file FILE: compose file
+  -d, --detach: run in the background
+
+kubectl: Kubernetes command line client
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+  -A, --all-namespaces: all namespaces
+  --context NAME: kubeconfig context to use
+kubectl get: list resources
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+  -A, --all-namespaces: all namespaces
+  -o FORMAT, --output FORMAT: output format (wide, yaml, json, jsonpath=...)
+  -l SELECTOR, --selector SELECTOR: filter by labels, e.g. app=web
+  -w, --watch: keep watching for changes
+kubectl describe: show details of resources
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+kubectl logs: print a container's logs
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+  -f, --follow: keep printing new output
+  -c NAME, --container NAME: container in the pod
+  --tail N: only the last N lines
+  -p, --previous: logs of the previous (crashed) instance
+  --since DURATION: logs newer than DURATION, e.g. 1h
+kubectl exec: run a command in a container
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+  -i, --stdin: pass stdin to the container
+  -t, --tty: allocate a terminal
+  -c NAME, --container NAME: container in the pod
+kubectl apply: create or update resources from files
+  -f FILE, --filename FILE: file, directory or URL
+  -k DIR, --kustomize DIR: kustomization directory
+  --dry-run MODE: only show what would happen (client or server)
+kubectl delete: delete resources
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+  -f FILE, --filename FILE: resources from FILE
+  --force: delete immediately
+  --grace-period N: seconds to wait (0 with --force for right away)
+kubectl port-forward: forward local ports to a pod
+  -n NAMESPACE, --namespace NAMESPACE: namespace to use
+  --address ADDR: local address to listen on
+`
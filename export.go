@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"commandref/paths"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const exportFormatVersion = 1
+
+// exportBundle is the on-disk export format. Field order is fixed by the
+// struct, and deliberately carries no "exported at" stamp so that exporting an
+// unchanged library twice yields identical bytes.
+type exportBundle struct {
+	Version int    `json:"version"`
+	Items   []Item `json:"items"`
+
+	// only set by incremental (--since-last) exports
+	Since   string            `json:"since,omitempty"`
+	Deleted []exportTombstone `json:"deleted,omitempty"`
+
+	// binaries the commands need; import checks them
+	Requires []requirement `json:"requires,omitempty"`
+}
+
+type exportTombstone struct {
+	ID        int    `json:"id"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// exportState is the watermark left behind by the previous export:
+// what each item's updatedAt was when it was last exported.
+type exportState struct {
+	ExportedAt string         `json:"exportedAt"`
+	Items      map[int]string `json:"items"`
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "", "write to file instead of stdout (a directory for --format markdown and raycast)")
+	format := fs.String("format", "json", `"json" bundle, "ndjson" (one item per line, streamed), "markdown" (one note per item for Obsidian/Notion), "dash" (snippet XML), "navi" (.cheat file), "raycast" (script commands), "alfred" (.alfredsnippets) or "csv" / "tsv" (for spreadsheets)`)
+	sinceLast := fs.Bool("since-last", false, "only emit items changed since the previous export, plus deletions")
+	postURL := fs.String("post", "", "POST the export to this URL (signed with export_webhook_secret)")
+	sign := fs.Bool("sign", false, "sign the bundle with your key (see: commandref keys generate)")
+	var requires requireFlag
+	fs.Var(&requires, "require", "binary the commands need, e.g. 'jq>=1.6' (repeatable)")
+	coll := fs.String("collection", "", "only export items in this collection")
+	columns := fs.String("columns", "", "columns for --format csv / tsv (default: id,title,cmd,tags,collection,notes,created,updated)")
+	_ = fs.Parse(args)
+
+	switch *format {
+	case "json":
+	case "markdown", "md":
+		if *out == "" || *sinceLast || *postURL != "" || *sign {
+			exitErr(errors.New("--format markdown needs --out <dir> and works without --since-last, --post or --sign"))
+		}
+	case "dash", "navi":
+		if *sinceLast || *postURL != "" || *sign {
+			exitErr(fmt.Errorf("--format %s works without --since-last, --post or --sign", *format))
+		}
+	case "raycast", "alfred":
+		if *out == "" || *sinceLast || *postURL != "" || *sign {
+			where := "<dir>"
+			if *format == "alfred" {
+				where = "<file.alfredsnippets>"
+			}
+			exitErr(fmt.Errorf("--format %s needs --out %s and works without --since-last, --post or --sign", *format, where))
+		}
+	case "csv", "tsv", "ndjson":
+		if *sinceLast || *postURL != "" || *sign {
+			exitErr(fmt.Errorf("--format %s works without --since-last, --post or --sign", *format))
+		}
+	default:
+		exitErr(fmt.Errorf("unknown --format %q (json, ndjson, markdown, dash, navi, raycast, alfred, csv or tsv)", *format))
+	}
+
+	var key *signingKey
+	if *sign {
+		k, err := loadSigningKey()
+		if err != nil {
+			exitErr(err)
+		}
+		if k == nil {
+			exitErr(errors.New("no signing key; run: commandref keys generate"))
+		}
+		key = k
+	}
+
+	if *format == "ndjson" {
+		exportNDJSON(*out, *coll)
+		return
+	}
+
+	items, err := openStore().List()
+	if err != nil {
+		exitErr(err)
+	}
+	if *coll != "" {
+		if *sinceLast {
+			exitErr(errors.New("--collection cannot be combined with --since-last"))
+		}
+		items = inCollection(items, resolveCollection(*coll))
+	}
+
+	if comma, ok := delimiter(*format); ok {
+		cols := csvColumns
+		if *columns != "" {
+			if cols, err = parseColumns(*columns); err != nil {
+				exitErr(err)
+			}
+		}
+		sortByID(items)
+		// a sheet gets passed around; secrets stay masked unless --reveal
+		items = displayItems(items)
+		var buf bytes.Buffer
+		if err := writeDelimited(&buf, items, cols, comma); err != nil {
+			exitErr(err)
+		}
+		if *out == "" {
+			os.Stdout.Write(buf.Bytes())
+			return
+		}
+		if err := writeFileAtomic(*out, buf.Bytes(), 0644); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d rows to %s\n", len(items), *out)
+		return
+	}
+
+	if *format == "dash" || *format == "navi" {
+		sortByID(items)
+		b, noun := naviExport(items), "cheats"
+		if *format == "dash" {
+			if b, err = dashExport(items); err != nil {
+				exitErr(err)
+			}
+			noun = "snippets"
+		}
+		if *out == "" {
+			os.Stdout.Write(b)
+			return
+		}
+		// navi's cheats directory (navi info cheats-path) takes a file of our own
+		if st, err := os.Stat(*out); err == nil && st.IsDir() && *format == "navi" {
+			*out = filepath.Join(*out, "commandref.cheat")
+		}
+		if err := writeFileAtomic(*out, b, 0644); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d %s to %s\n", len(items), noun, *out)
+		return
+	}
+
+	switch *format {
+	case "raycast":
+		sortByID(items)
+		if err := writeRaycastExport(*out, items); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d script commands to %s (add the directory in Raycast: Extensions > Script Commands)\n", len(items), *out)
+		return
+	case "alfred":
+		sortByID(items)
+		b, err := alfredExport(items)
+		if err == nil {
+			err = writeFileAtomic(*out, b, 0644)
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d snippets to %s (open it to import into Alfred)\n", len(items), *out)
+		return
+	}
+
+	if *format != "json" {
+		if err := writeMarkdownExport(*out, items); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d notes to %s\n", len(items), *out)
+		return
+	}
+
+	prev, err := loadExportState()
+	if err != nil {
+		exitErr(fmt.Errorf("reading export state: %w", err))
+	}
+
+	bundle := exportBundle{Version: exportFormatVersion, Items: normalizeForExport(items)}
+	if *sinceLast {
+		bundle = incrementalBundle(bundle.Items, prev)
+	}
+	bundle.Requires = requires
+
+	b, err := marshalExport(bundle)
+	if err != nil {
+		exitErr(err)
+	}
+	if key != nil {
+		if b, err = signBundle(b, key); err != nil {
+			exitErr(err)
+		}
+	}
+
+	if *postURL != "" {
+		if err := postExport(*postURL, b); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Posted %d commands to %s\n", len(bundle.Items), *postURL)
+	}
+	if *out != "" {
+		if err := writeFileAtomic(*out, b, 0644); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d commands to %s\n", len(bundle.Items), *out)
+	} else if *postURL == "" {
+		os.Stdout.Write(b)
+	}
+
+	// only advance the watermark once the export actually went out
+	if err := saveExportState(items); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save export state:", err)
+	}
+}
+
+// postExport sends the payload to a webhook. When a secret is configured the
+// body is signed: X-Commandref-Signature: sha256=<hex hmac of body>.
+func postExport(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	secret := credential("COMMANDREF_WEBHOOK_SECRET", cfg.ExportWebhookSecret, "export_webhook_secret")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Commandref-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	} else {
+		fmt.Fprintln(os.Stderr, "warning: no export_webhook_secret configured; sending unsigned payload")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func incrementalBundle(items []Item, prev *exportState) exportBundle {
+	bundle := exportBundle{Version: exportFormatVersion, Since: prev.ExportedAt, Items: []Item{}}
+
+	seen := map[int]bool{}
+	for _, it := range items {
+		seen[it.ID] = true
+		if last, ok := prev.Items[it.ID]; ok && last == it.UpdatedAt {
+			continue
+		}
+		bundle.Items = append(bundle.Items, it)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for id := range prev.Items {
+		if !seen[id] {
+			bundle.Deleted = append(bundle.Deleted, exportTombstone{ID: id, DeletedAt: now})
+		}
+	}
+	sort.Slice(bundle.Deleted, func(i, j int) bool { return bundle.Deleted[i].ID < bundle.Deleted[j].ID })
+	return bundle
+}
+
+func exportStatePath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "export-state.json"), nil
+}
+
+func loadExportState() (*exportState, error) {
+	st := &exportState{Items: map[int]string{}}
+	p, err := exportStatePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	if st.Items == nil {
+		st.Items = map[int]string{}
+	}
+	return st, nil
+}
+
+func saveExportState(items []Item) error {
+	st := exportState{ExportedAt: time.Now().UTC().Format(time.RFC3339), Items: map[int]string{}}
+	for _, it := range normalizeForExport(items) {
+		st.Items[it.ID] = it.UpdatedAt
+	}
+	p, err := exportStatePath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0644)
+}
+
+func marshalExport(bundle exportBundle) ([]byte, error) {
+	b, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// normalizeForExport returns a copy sorted by ID with sorted tags and
+// timestamps rewritten to UTC RFC3339.
+func normalizeForExport(items []Item) []Item {
+	out := make([]Item, len(items))
+	copy(out, items)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	for i := range out {
+		tags := append([]string{}, out[i].Tags...)
+		sort.Strings(tags)
+		out[i].Tags = tags
+		out[i].CreatedAt = normalizeTimestamp(out[i].CreatedAt)
+		out[i].UpdatedAt = normalizeTimestamp(out[i].UpdatedAt)
+	}
+	return out
+}
+
+func normalizeTimestamp(ts string) string {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return ts
+	}
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+func writeFileAtomic(p string, b []byte, perm os.FileMode) error {
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
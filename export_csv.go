@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// CSV/TSV for spreadsheets: a header row, then one row per item with the
+// same columns `list --long` has. Cells keep their full text (newlines
+// and all, quoted) and timestamps stay RFC 3339 so they sort. A cell a
+// spreadsheet would take for a formula gets a leading ' (see spreadsheetCell).
+
+var csvColumns = []string{"id", "title", "cmd", "tags", "collection", "notes", "created", "updated"}
+
+func writeDelimited(w io.Writer, items []Item, cols []string, comma rune) error {
+	usage, _ := loadUsage()
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		row[i] = strings.ToLower(listColumns[c].header)
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for _, it := range items {
+		u := usage.get(it.ID)
+		for i, c := range cols {
+			switch c {
+			case "title":
+				row[i] = it.Title
+			case "cmd":
+				row[i] = it.Command
+			case "notes":
+				row[i] = it.Notes
+			case "created":
+				row[i] = it.CreatedAt
+			case "updated":
+				row[i] = it.UpdatedAt
+			case "last-used":
+				row[i] = u.LastUsedAt
+			default:
+				row[i] = listColumns[c].cell(it, u)
+			}
+		}
+		for i := range row {
+			row[i] = spreadsheetCell(row[i])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// spreadsheetCell keeps a spreadsheet from running a cell as a formula: a command
+// like "=HYPERLINK(...)" or "@SUM(...)" would otherwise do just that when
+// the export is opened.
+func spreadsheetCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// delimiter is the field separator for --format csv / tsv.
+func delimiter(format string) (rune, bool) {
+	switch format {
+	case "csv":
+		return ',', true
+	case "tsv":
+		return '\t', true
+	}
+	return 0, false
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestSpreadsheetCell(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"make deploy", "make deploy"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1", "'+1"},
+		{"-rf", "'-rf"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"a=b", "a=b"},
+		{"2026-01-01T00:00:00Z", "2026-01-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		if got := spreadsheetCell(tt.in); got != tt.want {
+			t.Errorf("spreadsheetCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
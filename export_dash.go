@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dash export: the XML snippet list that `import --from dash` reads. Positional
+// parameters turn back into Dash __placeholders__, named from the notes'
+// "Arguments:" line when the item came from a snippet manager.
+
+var argumentsLine = regexp.MustCompile(`(?m)^Arguments: (.*)$`)
+
+func dashExport(items []Item) ([]byte, error) {
+	doc := struct {
+		XMLName  xml.Name      `xml:"snippets"`
+		Snippets []dashSnippet `xml:"snippet"`
+	}{}
+	taken := map[string]bool{}
+	for _, it := range items {
+		names, notes := argumentNames(it.Notes)
+
+		abbr := it.Slug
+		if abbr == "" {
+			abbr = slugify(it.Title)
+		}
+		if taken[abbr] {
+			abbr += "-" + strconv.Itoa(it.ID)
+		}
+		taken[abbr] = true
+
+		doc.Snippets = append(doc.Snippets, dashSnippet{
+			Title:        it.Title,
+			Body:         dashPlaceholders(it.Command, names),
+			Abbreviation: abbr,
+			Syntax:       "Shell",
+			Notes:        notes,
+			Tags:         it.Tags,
+		})
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// argumentNames reads the "Arguments: $1 = host, $2 = port" line an import
+// from a snippet manager leaves in the notes, and returns the notes without it.
+func argumentNames(notes string) (map[string]string, string) {
+	names := map[string]string{}
+	if m := argumentsLine.FindStringSubmatch(notes); m != nil {
+		for _, part := range strings.Split(m[1], ", ") {
+			if ref, name, ok := strings.Cut(part, " = "); ok {
+				names[strings.TrimPrefix(ref, "$")] = name
+			}
+		}
+		notes = strings.TrimSpace(argumentsLine.ReplaceAllString(notes, ""))
+	}
+	return names, notes
+}
+
+var positionalParam = regexp.MustCompile(`\$\{?([1-9@*])(?::-[^}]*)?\}?`)
+
+// paramRefs finds the positional parameters the shell would expand in
+// command, as positionalParam submatch indexes: not the ones in single
+// quotes or escaped (awk '{print $1}' is awk's), and with an "Arguments:"
+// line (names) only the numbers it declares.
+func paramRefs(command string, names map[string]string) [][]int {
+	literal := shellLiterals(command)
+	var out [][]int
+	for _, m := range positionalParam.FindAllStringSubmatchIndex(command, -1) {
+		ref := command[m[2]:m[3]]
+		if literal[m[0]] || len(names) > 0 && ref != "@" && ref != "*" && names[ref] == "" {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// shellLiterals marks the bytes of command the shell takes literally: in
+// single quotes, or right after a backslash outside them.
+func shellLiterals(command string) []bool {
+	literal := make([]bool, len(command))
+	single, double := false, false
+	for i := 0; i < len(command); i++ {
+		switch c := command[i]; {
+		case single:
+			literal[i] = c != '\''
+			single = c != '\''
+		case c == '\\' && i+1 < len(command):
+			i++
+			literal[i] = true
+		case c == '\'' && !double:
+			single = true
+		case c == '"':
+			double = !double
+		}
+	}
+	return literal
+}
+
+// replaceParams replaces the parameters paramRefs finds with what repl
+// makes of each ("1", "@"...).
+func replaceParams(command string, names map[string]string, repl func(ref string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range paramRefs(command, names) {
+		b.WriteString(command[last:m[0]])
+		b.WriteString(repl(command[m[2]:m[3]]))
+		last = m[1]
+	}
+	b.WriteString(command[last:])
+	return b.String()
+}
+
+// dashPlaceholders turns $1 / ${1} into __name__ (or __arg1__), and $@ / $*
+// into __args__.
+func dashPlaceholders(command string, names map[string]string) string {
+	return replaceParams(command, names, func(ref string) string {
+		if ref == "@" || ref == "*" {
+			return "__args__"
+		}
+		if n := names[ref]; n != "" {
+			return "__" + n + "__"
+		}
+		return "__arg" + ref + "__"
+	})
+}
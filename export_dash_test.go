@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestDashPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		names   map[string]string
+		want    string
+	}{
+		{"positional", `ssh $1 -p ${2}`, nil, `ssh __arg1__ -p __arg2__`},
+		{"named", `ssh $1`, map[string]string{"1": "host"}, `ssh __host__`},
+		{"default value", `ping -c ${1:-3} host`, nil, `ping -c __arg1__ host`},
+		{"all args", `grep -r "$@" .`, nil, `grep -r "__args__" .`},
+		{"awk program", `ps aux | awk '{print $1, $2}'`, nil, `ps aux | awk '{print $1, $2}'`},
+		{"awk program and a param", `cut -d: -f1 $1 | awk '{print $1}'`, nil, `cut -d: -f1 __arg1__ | awk '{print $1}'`},
+		{"double quotes expand", `echo "$1 is '$2'"`, nil, `echo "__arg1__ is '__arg2__'"`},
+		{"escaped", `echo \$1 costs $2`, nil, `echo \$1 costs __arg2__`},
+		{"undeclared number", `kubectl logs $1 | awk -v n=$3 '{print $2}'`, map[string]string{"1": "pod"}, `kubectl logs __pod__ | awk -v n=$3 '{print $2}'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dashPlaceholders(tt.command, tt.names); got != tt.want {
+				t.Errorf("dashPlaceholders(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Exports for the macOS launchers. Raycast gets a directory of script
+// commands that call `commandref run`, so secrets, trust and the run log
+// work as in the terminal; Alfred gets a snippet collection to paste from.
+// Both are meant to be regenerated (e.g. from hooks.post_sync).
+
+const raycastPrefix = "commandref-"
+
+// writeRaycastExport writes one script command per item into dir and
+// removes the ones earlier exports wrote for items that are gone.
+func writeRaycastExport(dir string, items []Item) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	written := map[string]bool{}
+	for _, it := range items {
+		name := it.Slug
+		if name == "" {
+			name = slugify(it.Title)
+		}
+		name = raycastPrefix + name
+		if written[name+".sh"] {
+			name += "-" + strconv.Itoa(it.ID)
+		}
+		name += ".sh"
+		written[name] = true
+		if err := writeFileAtomic(filepath.Join(dir, name), []byte(raycastScript(it, self)), 0755); err != nil {
+			return err
+		}
+	}
+	old, _ := filepath.Glob(filepath.Join(dir, raycastPrefix+"*.sh"))
+	for _, p := range old {
+		if !written[filepath.Base(p)] {
+			_ = os.Remove(p)
+		}
+	}
+	return nil
+}
+
+func raycastScript(it Item, self string) string {
+	names, notes := argumentNames(it.Notes)
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\n\n")
+	b.WriteString("# @raycast.schemaVersion 1\n")
+	b.WriteString("# @raycast.title " + oneLine(it.Title) + "\n")
+	b.WriteString("# @raycast.mode fullOutput\n")
+	b.WriteString("# @raycast.packageName " + oneLine(orDefault(it.Collection, "commandref")) + "\n")
+	if it.Icon != "" {
+		b.WriteString("# @raycast.icon " + it.Icon + "\n")
+	}
+	if first, _, _ := strings.Cut(notes, "\n"); first != "" {
+		b.WriteString("# @raycast.description " + oneLine(first) + "\n")
+	}
+
+	// Raycast asks for up to three arguments; $1..$3 map straight through,
+	// $@ becomes one that is split into words
+	var pass []string
+	args, all := 0, false
+	optional := map[string]bool{}
+	for _, m := range paramRefs(it.Command, names) {
+		ref := it.Command[m[2]:m[3]]
+		n, err := strconv.Atoi(ref)
+		if err != nil {
+			n, all = 1, true
+		}
+		args = max(args, min(n, 3))
+		if strings.Contains(it.Command[m[0]:m[1]], ":-") {
+			optional[ref] = true
+		}
+	}
+	for i := 1; i <= args; i++ {
+		ref := strconv.Itoa(i)
+		arg := map[string]any{"type": "text", "placeholder": orDefault(names[ref], "arg"+ref)}
+		if optional[ref] {
+			arg["optional"] = true
+		}
+		if all && i == args {
+			arg["placeholder"], arg["optional"] = "args", true
+		}
+		j, _ := json.Marshal(arg)
+		fmt.Fprintf(&b, "# @raycast.argument%d %s\n", i, j)
+		if all && i == args {
+			pass = append(pass, "$"+ref) // split into words on purpose
+		} else {
+			pass = append(pass, `"$`+ref+`"`)
+		}
+	}
+
+	ref := it.UUID // the same on every device, unlike the number
+	if ref == "" {
+		ref = strconv.Itoa(it.ID)
+	}
+	b.WriteString("\n# generated by commandref export --format raycast; changes are overwritten\n")
+	b.WriteString("exec " + shellQuote(self) + " run " + shellQuote(ref))
+	if len(pass) > 0 {
+		b.WriteString(" -- " + strings.Join(pass, " "))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// alfredExport builds an .alfredsnippets collection: a zip with one JSON
+// file per snippet. Parameters show as <name> to fill in after pasting,
+// and secrets as <NAME>, never their values.
+func alfredExport(items []Item) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	taken := map[string]bool{}
+	for _, it := range items {
+		names, _ := argumentNames(it.Notes)
+		keyword := it.Slug
+		if keyword == "" {
+			keyword = slugify(it.Title)
+		}
+		if taken[keyword] {
+			keyword += "-" + strconv.Itoa(it.ID)
+		}
+		taken[keyword] = true
+		uid := it.UUID
+		if uid == "" {
+			uid = newUUID()
+		}
+		snippet := map[string]any{"alfredsnippet": map[string]string{
+			"snippet": naviPlaceholders(it.Command, names),
+			"uid":     uid,
+			"name":    oneLine(it.Title),
+			"keyword": keyword,
+		}}
+		w, err := zw.Create(fmt.Sprintf("%s [%s].json", strings.ReplaceAll(oneLine(it.Title), "/", "-"), uid))
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false) // keep <name> readable
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(snippet); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRaycastScriptArguments(t *testing.T) {
+	it := Item{ID: 1, Title: "t", Command: `awk '{print $1, $2, $3}' ${1:-log.txt}`}
+	s := raycastScript(it, "commandref")
+	if !strings.Contains(s, `# @raycast.argument1 {"optional":true`) || strings.Contains(s, "argument2") {
+		t.Errorf("want one optional argument:\n%s", s)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// navi export: a .cheat file, one "% tags" section per set of tags, each
+// item a "# title" line followed by its command. Positional parameters
+// become navi <variables>, named like the Dash export names them, and
+// {{secret:NAME}} becomes <NAME> so navi asks for it instead.
+
+var naviUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+func naviExport(items []Item) []byte {
+	var groups []string
+	byTags := map[string][]Item{}
+	for _, it := range items {
+		tags := strings.Join(it.Tags, ", ")
+		if tags == "" {
+			tags = "commandref"
+		}
+		if _, ok := byTags[tags]; !ok {
+			groups = append(groups, tags)
+		}
+		byTags[tags] = append(byTags[tags], it)
+	}
+	sort.Strings(groups)
+
+	var b strings.Builder
+	for i, tags := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("% " + tags + "\n")
+		for _, it := range byTags[tags] {
+			names, notes := argumentNames(it.Notes)
+			b.WriteString("\n# " + strings.ReplaceAll(it.Title, "\n", " ") + "\n")
+			for _, l := range strings.Split(notes, "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					b.WriteString("; " + l + "\n")
+				}
+			}
+			// a blank line would end the command early
+			for _, l := range strings.Split(naviPlaceholders(it.Command, names), "\n") {
+				if strings.TrimSpace(l) != "" {
+					b.WriteString(l + "\n")
+				}
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// naviPlaceholders turns $1 / ${1} into <name> (or <arg1>), $@ / $* into
+// <args> and {{secret:NAME}} into <NAME>.
+func naviPlaceholders(command string, names map[string]string) string {
+	command = secretRef.ReplaceAllStringFunc(command, func(m string) string {
+		return "<" + naviUnsafe.ReplaceAllString(secretRef.FindStringSubmatch(m)[1], "_") + ">"
+	})
+	return replaceParams(command, names, func(ref string) string {
+		if ref == "@" || ref == "*" {
+			return "<args>"
+		}
+		if n := naviUnsafe.ReplaceAllString(names[ref], "_"); strings.Trim(n, "_") != "" {
+			return "<" + n + ">"
+		}
+		return "<arg" + ref + ">"
+	})
+}
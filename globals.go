@@ -0,0 +1,198 @@
+package main
+
+import (
+	"commandref/api"
+	"commandref/auth"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// options that apply to every subcommand
+type globalOptions struct {
+	Accessible bool
+	Workspace  string // --workspace, "" if not given
+	APIBase    string // --api-base
+	Profile    string // --profile
+	Reveal     bool   // --reveal: don't mask likely secrets (see redact.go)
+	Yes        bool   // --yes / -y: answer yes to every confirmation
+	// --error-format json (or COMMANDREF_ERROR_FORMAT): failures as JSON
+	ErrorFormat string
+	// COMMANDREF_NONINTERACTIVE: never prompt, as if there were no terminal
+	NonInteractive bool
+}
+
+var opts globalOptions
+
+// extractGlobalFlags removes global flags from args (wherever they appear
+// before a "--") and records them in opts, so subcommands never see them.
+func extractGlobalFlags(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		switch {
+		case a == "--accessible":
+			opts.Accessible = true
+		case a == "--reveal":
+			opts.Reveal = true
+		case a == "--yes" || a == "-y":
+			opts.Yes = true
+		case a == "--error-format" && i+1 < len(args):
+			opts.ErrorFormat = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--error-format="):
+			opts.ErrorFormat = strings.TrimPrefix(a, "--error-format=")
+		case a == "--workspace" && i+1 < len(args):
+			opts.Workspace = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--workspace="):
+			opts.Workspace = strings.TrimPrefix(a, "--workspace=")
+		case a == "--api-base" && i+1 < len(args):
+			opts.APIBase = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--api-base="):
+			opts.APIBase = strings.TrimPrefix(a, "--api-base=")
+		case a == "--profile" && i+1 < len(args):
+			opts.Profile = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--profile="):
+			opts.Profile = strings.TrimPrefix(a, "--profile=")
+		default:
+			out = append(out, a)
+		}
+	}
+
+	if cfg != nil && cfg.Accessible {
+		opts.Accessible = true
+	}
+	if os.Getenv("COMMANDREF_ACCESSIBLE") != "" {
+		opts.Accessible = true
+	}
+	if opts.ErrorFormat == "" {
+		opts.ErrorFormat = os.Getenv("COMMANDREF_ERROR_FORMAT")
+	}
+	for _, v := range []string{"COMMANDREF_NONINTERACTIVE", "CMDREF_NONINTERACTIVE"} {
+		if s := os.Getenv(v); s != "" && s != "0" && s != "false" {
+			opts.NonInteractive = true
+		}
+	}
+	return out
+}
+
+// globalArgs gives back the global flags this invocation was started with,
+// for when it starts another commandref (watch --detach, run --tmux).
+func globalArgs() []string {
+	var out []string
+	for _, f := range []struct{ name, v string }{
+		{"--workspace", opts.Workspace},
+		{"--profile", opts.Profile},
+		{"--api-base", opts.APIBase},
+		{"--error-format", opts.ErrorFormat},
+	} {
+		if f.v != "" {
+			out = append(out, f.name+"="+f.v)
+		}
+	}
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{
+		{"--accessible", opts.Accessible},
+		{"--reveal", opts.Reveal},
+		{"--yes", opts.Yes},
+	} {
+		if f.on {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+// applyBackendFlags points this invocation (and anything it starts) at the
+// backend chosen with --profile / COMMANDREF_PROFILE and --api-base, which
+// wins over the profile's. Both go through the environment, where the API
+// client and the session store look. Naming a backend implies API storage.
+func applyBackendFlags() error {
+	name := opts.Profile
+	if name == "" {
+		name = os.Getenv("COMMANDREF_PROFILE")
+	}
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("no profile %q in config.json (\"profiles\": {%q: {\"api_base\": \"https://...\"}})", name, name)
+		}
+		if strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+			return fmt.Errorf("profile name %q may only use letters, digits, - and _", name)
+		}
+		os.Setenv("COMMANDREF_PROFILE", name)
+		if p.APIBase != "" {
+			os.Setenv("COMMANDREF_API_BASE", p.APIBase)
+		}
+	}
+	if opts.APIBase != "" {
+		base := strings.TrimRight(opts.APIBase, "/")
+		if err := checkSessionOrigin(base, name); err != nil {
+			return err
+		}
+		os.Setenv("COMMANDREF_API_BASE", base)
+	}
+	if name != "" || opts.APIBase != "" {
+		cfg.Storage = "api"
+	}
+	return nil
+}
+
+// checkSessionOrigin keeps the active login's token (the profile's, or the
+// default one) from being sent to another host named with --api-base: that
+// takes a profile of its own, which has a login of its own.
+func checkSessionOrigin(base, profile string) error {
+	s, err := auth.LoadSession()
+	if err != nil || s == nil {
+		return nil // no token to give away
+	}
+	issuer := s.APIBase
+	if issuer == "" {
+		issuer = api.New().BaseURL // logged in before sessions recorded it
+	}
+	if sameOrigin(base, issuer) {
+		return nil
+	}
+	if profile != "" {
+		return fmt.Errorf("--api-base %s isn't the backend profile %s is logged in to (%s); give it a profile of its own in config.json", base, profile, issuer)
+	}
+	return fmt.Errorf("--api-base %s isn't the backend you're logged in to (%s); add a profile for it, which keeps its own login: \"profiles\": {\"name\": {\"api_base\": %q}}, then use --profile name", base, issuer, base)
+}
+
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// profileSuffix keeps a profile's caches apart from the default backend's.
+func profileSuffix() string {
+	if p := os.Getenv("COMMANDREF_PROFILE"); p != "" {
+		return "-" + p
+	}
+	return ""
+}
+
+// librarySuffix goes further and keeps each workspace's files apart too.
+func librarySuffix() string {
+	if ws := currentWorkspace(); ws.ID != "" {
+		return profileSuffix() + "-" + slugify(ws.ID)
+	}
+	return profileSuffix()
+}
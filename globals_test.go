@@ -0,0 +1,83 @@
+package main
+
+import (
+	"commandref/auth"
+	"commandref/config"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestApplyBackendFlagsSessionOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		session string // APIBase of the default login; "-" for none
+		profile string
+		apiBase string
+		wantErr bool
+		// the staging profile's login ("" for none), and COMMANDREF_PROFILE
+		stagingSession, envProfile string
+	}{
+		{"same backend", "https://api.example.com", "", "https://api.example.com/", false, "", ""},
+		{"other host", "https://api.example.com", "", "https://evil.example", true, "", ""},
+		{"other port", "http://127.0.0.1:8080", "", "http://127.0.0.1:9090", true, "", ""},
+		{"other host with a profile", "https://api.example.com", "staging", "https://staging.example.com", false, "", ""},
+		{"not logged in", "-", "", "https://evil.example", false, "", ""},
+		{"old session, default backend", "", "", "http://127.0.0.1:8080", false, "", ""},
+		{"profile, its own backend", "-", "staging", "https://staging.example.com", false, "https://staging.example.com", ""},
+		{"profile, other host", "-", "staging", "https://evil.example", true, "https://staging.example.com", ""},
+		{"profile from the env, other host", "-", "", "https://evil.example", true, "https://staging.example.com", "staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COMMANDREF_PROFILE", tt.envProfile)
+			t.Setenv("COMMANDREF_API_BASE", "")
+			home := t.TempDir()
+			t.Setenv("COMMANDREF_HOME", home)
+			if tt.session != "-" {
+				// written by hand: SaveSession would fill in a missing apiBase
+				b, _ := json.Marshal(auth.Session{Token: "t", APIBase: tt.session})
+				if err := os.WriteFile(filepath.Join(home, "session.json"), b, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.stagingSession != "" {
+				b, _ := json.Marshal(auth.Session{Token: "s", APIBase: tt.stagingSession})
+				if err := os.WriteFile(filepath.Join(home, "session-staging.json"), b, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg = &config.Config{Profiles: map[string]config.ProfileConfig{"staging": {APIBase: "https://staging.example.com"}}}
+			opts = globalOptions{Profile: tt.profile, APIBase: tt.apiBase}
+			t.Cleanup(func() { opts = globalOptions{} })
+
+			if err := applyBackendFlags(); (err != nil) != tt.wantErr {
+				t.Errorf("applyBackendFlags() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGlobalArgsRoundTrip(t *testing.T) {
+	tests := []globalOptions{
+		{},
+		{Workspace: "team a", Reveal: true},
+		{Profile: "work", APIBase: "https://api.example.com", ErrorFormat: "json", Accessible: true, Yes: true},
+	}
+	defer func(o globalOptions) { opts = o }(opts)
+	for _, want := range tests {
+		opts = want
+		args := globalArgs()
+		opts = globalOptions{}
+		rest := extractGlobalFlags(append(slices.Clone(args), "watch"))
+		if !slices.Equal(rest, []string{"watch"}) {
+			t.Errorf("%v left %v", args, rest)
+		}
+		opts.NonInteractive = want.NonInteractive
+		if os.Getenv("COMMANDREF_ACCESSIBLE") == "" && opts != want {
+			t.Errorf("%v parsed back as %+v, want %+v", args, opts, want)
+		}
+	}
+}
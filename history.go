@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"commandref/paths"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runRecord is one line of runs.jsonl: what commandref actually executed.
+type runRecord struct {
+	ItemID    int      `json:"itemId"`
+	Title     string   `json:"title"`
+	Command   string   `json:"command"` // as handed to the shell
+	Args      []string `json:"args,omitempty"`
+	StartedAt string   `json:"startedAt"`
+	EndedAt   string   `json:"endedAt"`
+	ExitCode  int      `json:"exitCode"`
+	Host      string   `json:"host"`
+	Dir       string   `json:"dir,omitempty"` // where it was run from
+}
+
+func (r runRecord) duration() time.Duration {
+	s, err1 := time.Parse(time.RFC3339Nano, r.StartedAt)
+	e, err2 := time.Parse(time.RFC3339Nano, r.EndedAt)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return e.Sub(s)
+}
+
+// runsPath is one file per library (profile and workspace), as the item
+// IDs are its own.
+func runsPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "runs"+librarySuffix()+".jsonl"), nil
+}
+
+// recordRun appends to the run log. Like usage tracking, it is best effort
+// and skipped entirely for noLog items and project commands (whose IDs
+// depend on the directory).
+func recordRun(it *Item, ro runOptions, started time.Time, exitCode int) {
+	if it.NoLog || isProjectItem(*it) {
+		return
+	}
+	argv := shellArgs(it.Command, ro.extra)
+	rec := runRecord{
+		ItemID:    it.ID,
+		Title:     it.Title,
+		Command:   redact(argv[1]),
+		StartedAt: started.UTC().Format(time.RFC3339Nano),
+		EndedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+		ExitCode:  exitCode,
+	}
+	if len(argv) > 3 {
+		for _, a := range argv[3:] { // passed as $@
+			rec.Args = append(rec.Args, redact(a))
+		}
+	}
+	rec.Host, _ = os.Hostname()
+	rec.Dir, _ = os.Getwd()
+	if ro.host != "" {
+		rec.Host = ro.host
+	}
+
+	p, err := runsPath()
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(b, '\n'))
+}
+
+func loadRunRecords() ([]runRecord, error) {
+	p, err := runsPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []runRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var r runRecord
+		if json.Unmarshal(sc.Bytes(), &r) == nil {
+			out = append(out, r)
+		}
+	}
+	return out, sc.Err()
+}
+
+func runRuns(args []string) {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	id := fs.Int("id", 0, "only runs of this item")
+	failed := fs.Bool("failed", false, "only runs with a non-zero exit code")
+	limit := fs.Int("limit", 20, "show at most this many (most recent first; 0 = all)")
+	_ = fs.Parse(args)
+
+	recs, err := loadRunRecords()
+	if err != nil {
+		exitErr(err)
+	}
+
+	shown := 0
+	for i := len(recs) - 1; i >= 0; i-- {
+		r := recs[i]
+		if (*id != 0 && r.ItemID != *id) || (*failed && r.ExitCode == 0) {
+			continue
+		}
+		if *limit > 0 && shown == *limit {
+			break
+		}
+		shown++
+
+		status := colorize("32", "ok")
+		if r.ExitCode != 0 {
+			status = colorize("31", fmt.Sprintf("exit %d", r.ExitCode))
+		}
+		fmt.Printf("%s  #%d %s  [%s, %s, %s]\n", formatTime(r.StartedAt), r.ItemID, r.Title, status, r.duration().Round(time.Millisecond), r.Host)
+		fmt.Printf("    %s\n", displayItem(Item{Command: r.Command}).Command)
+	}
+	if shown == 0 {
+		fmt.Println("(no runs recorded)")
+	}
+}
+
+// itemRunStats summarizes an item's run history for `show`.
+type itemRunStats struct {
+	count int
+	last  runRecord
+	avg   time.Duration
+}
+
+func runStatsFor(id int) itemRunStats {
+	var st itemRunStats
+	recs, _ := loadRunRecords()
+	var total time.Duration
+	for _, r := range recs {
+		if r.ItemID != id {
+			continue
+		}
+		st.count++
+		st.last = r
+		total += r.duration()
+	}
+	if st.count > 0 {
+		st.avg = total / time.Duration(st.count)
+	}
+	return st
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"commandref/auth"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runHook runs a configured hook command with sh. A failing hook prints a
+// warning and returns the error; only a pre_run hook's stops anything (the
+// run, see runWithHooks).
+func runHook(event, command string, env []string) error {
+	if command == "" {
+		return nil
+	}
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "COMMANDREF_EVENT="+event)
+	cmd.Env = append(cmd.Env, env...)
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s hook failed: %v\n", event, err)
+		return err
+	}
+	return nil
+}
+
+func sessionEnv(s *auth.Session, loggedIn bool) []string {
+	env := []string{"COMMANDREF_LOGGED_IN=0"}
+	if loggedIn {
+		env[0] = "COMMANDREF_LOGGED_IN=1"
+	}
+	if s != nil {
+		env = append(env, "COMMANDREF_EMAIL="+s.Email, "COMMANDREF_NAME="+s.Name, "COMMANDREF_SESSION_CREATED="+s.CreatedAt)
+	}
+	return env
+}
+
+// runLoginHook fires on_login, or on_token_refresh when prev was a session
+// for the same account with a different token.
+func runLoginHook(prev *auth.Session) {
+	s, err := auth.LoadSession()
+	if err != nil || s == nil {
+		return
+	}
+	if prev != nil && prev.Email == s.Email && prev.Token != s.Token {
+		_ = runHook("token_refresh", cfg.Hooks.OnTokenRefresh, sessionEnv(s, true))
+		return
+	}
+	_ = runHook("login", cfg.Hooks.OnLogin, sessionEnv(s, true))
+}
+
+// itemEnv describes the run to a hook. The global hooks get only the ID and
+// tags of a --no-log item (global): they tend to log, and its title,
+// command and args are what it keeps out of logs.
+func itemEnv(it *Item, ro runOptions, global bool) []string {
+	env := []string{
+		"COMMANDREF_ITEM_ID=" + strconv.Itoa(it.ID),
+		"COMMANDREF_ITEM_TAGS=" + strings.Join(it.Tags, ","),
+	}
+	if global && it.NoLog {
+		return env
+	}
+	args := make([]string, len(ro.extra))
+	for i, a := range ro.extra {
+		args[i] = shellQuote(a)
+	}
+	return append(env,
+		"COMMANDREF_ITEM_TITLE="+it.Title,
+		"COMMANDREF_ITEM_COMMAND="+it.Command,
+		"COMMANDREF_ARGS="+strings.Join(args, " "))
+}
+
+// runWithHooks wraps execItem with the global and per-item pre/post run
+// hooks. Pre hooks run global first, post hooks item first; a failing
+// pre_run hook stops the run.
+func runWithHooks(it *Item, ro runOptions) (int, error) {
+	env, globalEnv := itemEnv(it, ro, false), itemEnv(it, ro, true)
+	if err := runHook("pre_run", cfg.Hooks.PreRun, globalEnv); err != nil {
+		return 0, fmt.Errorf("pre_run hook failed, not running #%d", it.ID)
+	}
+	if err := runHook("pre_run", it.PreRun, env); err != nil {
+		return 0, fmt.Errorf("pre_run hook failed, not running #%d", it.ID)
+	}
+
+	started := time.Now()
+	code, err := execItem(it, ro)
+	if err != nil {
+		return code, err
+	}
+
+	result := []string{
+		"COMMANDREF_EXIT_CODE=" + strconv.Itoa(code),
+		"COMMANDREF_DURATION_MS=" + strconv.FormatInt(time.Since(started).Milliseconds(), 10),
+	}
+	_ = runHook("post_run", it.PostRun, append(env, result...))
+	_ = runHook("post_run", cfg.Hooks.PostRun, append(globalEnv, result...))
+	return code, nil
+}
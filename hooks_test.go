@@ -0,0 +1,37 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestItemEnv(t *testing.T) {
+	ro := runOptions{extra: []string{"--password", "s3cret"}}
+	tests := []struct {
+		name     string
+		noLog    bool
+		global   bool
+		wantText bool // title, command and args
+	}{
+		{"item hook", false, false, true},
+		{"global hook", false, true, true},
+		{"no-log, item hook", true, false, true},
+		{"no-log, global hook", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := &Item{ID: 7, Title: "db login", Command: "mysql -u root", Tags: []string{"db"}, NoLog: tt.noLog}
+			env := itemEnv(it, ro, tt.global)
+			if !slices.Contains(env, "COMMANDREF_ITEM_ID=7") || !slices.Contains(env, "COMMANDREF_ITEM_TAGS=db") {
+				t.Errorf("env = %q, want the ID and tags", env)
+			}
+			joined := strings.Join(env, "\n")
+			for _, s := range []string{"db login", "mysql -u root", "s3cret"} {
+				if strings.Contains(joined, s) != tt.wantText {
+					t.Errorf("env = %q; has %q: %v, want %v", env, s, !tt.wantText, tt.wantText)
+				}
+			}
+		})
+	}
+}
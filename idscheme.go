@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Numeric IDs are handed out by each store: the same command has another
+// number on the backend than in a synced local library, and a new one after
+// a migrate. "id_display" in the config names items by something that
+// travels with them instead: the slug, or a short UUID prefix. Whatever is
+// shown, every form is accepted where an <id> is.
+
+const shortUUIDLen = 8
+
+func schemeID(it Item) string {
+	switch cfg.IDDisplay {
+	case "slug":
+		if it.Slug != "" {
+			return it.Slug
+		}
+	case "uuid":
+		if u := shortUUID(it.UUID); u != "" {
+			return u
+		}
+	}
+	return strconv.Itoa(it.ID)
+}
+
+func shortUUID(u string) string {
+	u = strings.ReplaceAll(u, "-", "")
+	if len(u) < shortUUIDLen {
+		return ""
+	}
+	return strings.ToLower(u[:shortUUIDLen])
+}
+
+// lookupItemRef finds the item a slug or UUID prefix (at least 4 hex
+// digits) names. With cached, the cache may answer without a round trip;
+// commands that change the item ask the store, as a stale cache can still
+// have the slug on the item it was on before.
+func lookupItemRef(ref string, cached bool) (int, error) {
+	if ref == "" || strings.ContainsAny(ref, " \t\n") {
+		return 0, fmt.Errorf("invalid id: %q", ref)
+	}
+	var items []Item
+	if cached {
+		items = readCachedItems()
+	}
+	if usingLocalStore() || !hasRef(items, ref) {
+		var err error
+		if items, err = openStore().List(); err != nil {
+			return 0, err
+		}
+	}
+	var found []Item
+	for _, it := range items {
+		if refMatches(it, ref) {
+			found = append(found, it)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("invalid id: %s (no item has that number, slug or UUID)", ref)
+	case 1:
+		return found[0].ID, nil
+	}
+	ids := make([]string, len(found))
+	for i, it := range found {
+		ids[i] = "#" + strconv.Itoa(it.ID)
+	}
+	return 0, fmt.Errorf("%s could be %s; use more of the UUID or the number", ref, strings.Join(ids, ", "))
+}
+
+func hasRef(items []Item, ref string) bool {
+	for _, it := range items {
+		if refMatches(it, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func refMatches(it Item, ref string) bool {
+	if it.Slug != "" && strings.EqualFold(it.Slug, ref) {
+		return true
+	}
+	u := strings.ReplaceAll(strings.ToLower(it.UUID), "-", "")
+	r := strings.ReplaceAll(strings.ToLower(ref), "-", "")
+	return len(r) >= 4 && u != "" && strings.Trim(r, "0123456789abcdef") == "" && strings.HasPrefix(u, r)
+}
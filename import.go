@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	force := fs.Bool("force", false, "import even if the bundle signature does not verify")
+	yes := fs.Bool("yes", false, "save a shared command without asking")
+	from := fs.String("from", "", `import another tool's export: "dash" or "snippetslab", "pet", "navi" or "cheat" (a file or directory), or "history" (your shell's)`)
+	limit := fs.Int("limit", 20, "with --from history: how many of the most used commands to import")
+	_ = fs.Parse(args)
+
+	if *from == "history" && fs.NArg() <= 1 {
+		importHistory(fs.Arg(0), *limit)
+		return
+	}
+	if fs.NArg() != 1 {
+		exitErr(errors.New("usage: commandref import [--force] <bundle.json> | import [--yes] <share-url|slug>"))
+	}
+	ref := fs.Arg(0)
+	if *from != "" {
+		importExternal(*from, ref)
+		return
+	}
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		importShared(ref, *yes)
+		return
+	}
+	if _, err := os.Stat(ref); err != nil && shareSlug.MatchString(ref) {
+		importShared(ref, *yes)
+		return
+	}
+
+	raw, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		exitErr(err)
+	}
+
+	payload, trust, signer, err := openBundle(raw)
+	if err != nil {
+		exitErr(fmt.Errorf("not a commandref bundle: %w", err))
+	}
+	switch trust {
+	case bundleUnsigned:
+		fmt.Fprintln(os.Stderr, "warning: bundle is unsigned; only import it if you trust where it came from")
+	case bundleTampered:
+		if !*force {
+			exitErr(errors.New("bundle signature does not verify; it may have been tampered with (use --force to import anyway)"))
+		}
+		fmt.Fprintln(os.Stderr, "warning: importing bundle with an INVALID signature")
+	case bundleUntrusted:
+		fmt.Fprintf(os.Stderr, "warning: bundle signed by unknown key %s (trust it with: commandref keys trust %s)\n", keyFingerprint(signer), signer)
+	case bundleTrusted:
+		fmt.Fprintln(os.Stderr, "Signature OK:", keyFingerprint(signer))
+	}
+
+	var bundle exportBundle
+	if err := json.Unmarshal(payload, &bundle); err != nil {
+		exitErr(fmt.Errorf("not a commandref bundle: %w", err))
+	}
+
+	// checking a version runs the tool; only on a bundle from a trusted key,
+	// or when asked
+	probe := trust == bundleTrusted
+	if !probe && versionChecks(bundle.Requires) {
+		q := "The bundle needs minimum versions of: " + requirementList(bundle.Requires) + ". Run them with --version to check?"
+		probe = opts.Yes || (canPrompt() && askYes(q))
+	}
+	if missing := missingRequirements(bundle.Requires, probe); len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, "warning: this bundle's commands need tools you don't have:")
+		for _, m := range missing {
+			fmt.Fprintln(os.Stderr, "  -", m)
+		}
+	}
+
+	st := openStore()
+	imported := 0
+	for _, it := range bundle.Items {
+		it.Command = normalizeCommand(it.Command)
+		if _, err := st.Create(it); err != nil {
+			fmt.Fprintf(os.Stderr, "error importing %q: %v\n", it.Title, err)
+			continue
+		}
+		imported++
+	}
+	fmt.Printf("Imported %d of %d commands\n", imported, len(bundle.Items))
+	if imported < len(bundle.Items) {
+		exitErr(fmt.Errorf("%d of %d commands couldn't be imported", len(bundle.Items)-imported, len(bundle.Items)))
+	}
+}
+
+func importExternal(from, path string) {
+	var items []Item
+	switch from {
+	case "dash", "snippetslab":
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			exitErr(err)
+		}
+		if items, err = parseExternalImport(from, raw); err != nil {
+			exitErr(fmt.Errorf("not a %s export: %w", from, err))
+		}
+	case "pet", "navi", "cheat":
+		var err error
+		if items, err = parseCheatSource(from, path); err != nil {
+			exitErr(err)
+		}
+	default:
+		exitErr(fmt.Errorf("unknown --from %q (dash, snippetslab, pet, navi, cheat or history)", from))
+	}
+
+	st := openStore()
+	imported := 0
+	for _, it := range items {
+		if it.Command == "" {
+			fmt.Fprintf(os.Stderr, "skipping %q: empty snippet\n", it.Title)
+			continue
+		}
+		if _, err := st.Create(it); err != nil {
+			fmt.Fprintf(os.Stderr, "error importing %q: %v\n", it.Title, err)
+			continue
+		}
+		imported++
+	}
+	fmt.Printf("Imported %d of %d snippets from %s\n", imported, len(items), from)
+	if imported == 0 && len(items) > 0 {
+		exitErr(errors.New("none of the snippets could be imported"))
+	}
+}
+
+// importHistory saves the most used commands of a shell history file
+// (the shell's own if path is empty).
+func importHistory(path string, limit int) {
+	if path == "" {
+		if path = defaultHistoryFile(); path == "" {
+			exitErr(errors.New("no shell history found; give the file, e.g. commandref import --from history ~/.zsh_history"))
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		exitErr(err)
+	}
+	st := openStore()
+	existing, err := st.List()
+	if err != nil {
+		exitErr(err)
+	}
+	raw = decodeHistory(raw)
+	entries := parseShellHistory(raw)
+	if isPowerShellHistory(path) {
+		entries = parsePowerShellHistory(raw)
+	}
+	items := historyItems(entries, existing, limit)
+	imported := 0
+	for _, it := range items {
+		if _, err := st.Create(it); err != nil {
+			fmt.Fprintf(os.Stderr, "error importing %q: %v\n", it.Title, err)
+			continue
+		}
+		imported++
+	}
+	fmt.Printf("Imported %d commands from %s (tagged history)\n", imported, path)
+	if imported == 0 && len(items) > 0 {
+		exitErr(errors.New("none of the commands could be imported"))
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Importers for the terminal cheatsheet tools: pet's snippet.toml, navi's
+// .cheat files and cheat's cheatsheets (a file or a directory of them).
+// Their <placeholders> become positional parameters, like the snippet
+// managers' do; pet's <name=default> keeps its default as ${1:-default}.
+
+var (
+	petPlaceholder  = regexp.MustCompile(`<([^<>=\s][^<>=]*?)(?:=([^<>]*))?>`)
+	naviPlaceholder = regexp.MustCompile(`<([A-Za-z_][A-Za-z0-9_-]*)>`)
+	// a default that can go in ${1:-...} unquoted
+	plainDefault = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+// cheatItem is snippetItem for the cheatsheet tools, whose notes have no
+// language and whose placeholders may carry defaults.
+func cheatItem(title, body, notes string, tags []string, placeholder *regexp.Regexp) Item {
+	it := snippetItem(title, body, "", notes, "", tags, placeholder)
+	it.Slug = ""
+	return it
+}
+
+// readCheatFiles reads path, or every file under it that has one of exts
+// (any file when exts is empty), skipping hidden files and directories.
+func readCheatFiles(path string, exts ...string) (map[string][]byte, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	if !st.IsDir() {
+		b, err := os.ReadFile(path)
+		files[path] = b
+		return files, err
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != path {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || (len(exts) > 0 && !hasAnySuffix(d.Name(), exts)) {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[p] = b
+		return nil
+	})
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("no %s files in %s", strings.Join(exts, " or "), path)
+	}
+	return files, err
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, x := range suffixes {
+		if strings.HasSuffix(s, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderDefault is the default of a pet placeholder: the value after
+// "=", or the first of the choices in <name=|_a_||_b_|>.
+func placeholderDefault(v string) string {
+	v = strings.TrimSpace(v)
+	if rest, ok := strings.CutPrefix(v, "|_"); ok {
+		v, _, _ = strings.Cut(rest, "_|")
+	}
+	return v
+}
+
+// parsePetSnippets reads pet's snippet.toml:
+//
+//	[[snippets]]
+//	  description = "ping"
+//	  command = "ping <host=8.8.8.8>"
+//	  tag = ["network"]
+//	  output = ""
+func parsePetSnippets(raw []byte) ([]Item, error) {
+	tables, err := parseTOMLTables(string(raw), "snippets")
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, t := range tables {
+		notes := ""
+		if out := t.str("output"); strings.TrimSpace(out) != "" {
+			notes = "Output: " + strings.TrimSpace(out)
+		}
+		items = append(items, cheatItem(t.str("description"), t.str("command"), notes, t.list("tag"), petPlaceholder))
+	}
+	return items, nil
+}
+
+// parseNaviCheat reads a .cheat file: "% tags" starts a section, "#" lines
+// describe the command that follows, ";" lines are comments and "$ name:
+// command" suggests values for a variable.
+func parseNaviCheat(raw []byte) []Item {
+	var items []Item
+	var tags, notes []string
+	var title string
+	var body []string
+	// the section's commands as written, for its "$ name:" lines, which
+	// usually come last
+	var section []string
+	suggest := map[string]string{}
+	flush := func() {
+		if len(body) > 0 {
+			items = append(items, cheatItem(orDefault(title, body[0]), strings.Join(body, "\n"), strings.Join(notes, "\n"), tags, naviPlaceholder))
+			section = append(section, strings.Join(body, "\n"))
+		}
+		title, notes, body = "", nil, nil
+	}
+	endSection := func() {
+		flush()
+		var names []string
+		for name := range suggest {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		first := len(items) - len(section)
+		for i, cmd := range section {
+			for _, name := range names {
+				if strings.Contains(cmd, "<"+name+">") {
+					items[first+i].Notes = strings.TrimSpace(items[first+i].Notes + "\nValues for " + name + ": " + suggest[name])
+				}
+			}
+		}
+		section, suggest = nil, map[string]string{}
+	}
+	sc := bufio.NewScanner(strings.NewReader(string(raw)))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "%"):
+			endSection()
+			tags = nil
+			for _, t := range strings.Split(strings.TrimPrefix(trimmed, "%"), ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					tags = append(tags, t)
+				}
+			}
+		case strings.HasPrefix(trimmed, "#"):
+			if len(body) > 0 {
+				flush()
+			}
+			if d := strings.TrimSpace(strings.TrimPrefix(trimmed, "#")); title == "" {
+				title = d
+			} else if d != "" {
+				notes = append(notes, d)
+			}
+		case strings.HasPrefix(trimmed, ";"):
+			if d := strings.TrimSpace(strings.TrimPrefix(trimmed, ";")); d != "" {
+				notes = append(notes, d)
+			}
+		case strings.HasPrefix(trimmed, "$"):
+			flush()
+			if name, cmd, ok := strings.Cut(strings.TrimPrefix(trimmed, "$"), ":"); ok {
+				cmd, _, _ = strings.Cut(cmd, " --- ")
+				suggest[strings.TrimSpace(name)] = strings.TrimSpace(cmd)
+			}
+		case strings.HasPrefix(trimmed, "@"):
+			// "@ tags" pulls in another section's variables; nothing to keep
+		default:
+			body = append(body, line)
+		}
+	}
+	endSection()
+	return items
+}
+
+// parseCheatSheet reads one of cheat's cheatsheets: optional front matter
+// (syntax, tags), then "# To do something:" comments over their commands.
+// The sheet's name is a tag of each command in it.
+func parseCheatSheet(name string, raw []byte) []Item {
+	text := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	tags := []string{name}
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if front, body, ok := strings.Cut(rest, "\n---\n"); ok {
+			text = body
+			for _, l := range strings.Split(front, "\n") {
+				k, v, _ := strings.Cut(l, ":")
+				if strings.TrimSpace(k) == "tags" {
+					for _, t := range strings.Split(strings.Trim(strings.TrimSpace(v), "[]"), ",") {
+						if t = strings.Trim(strings.TrimSpace(t), `"'`); t != "" {
+							tags = append(tags, t)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var items []Item
+	var comments, body []string
+	flush := func() {
+		if len(body) > 0 {
+			title := body[0]
+			var notes []string
+			if len(comments) > 0 {
+				title = comments[0]
+				notes = comments[1:]
+			}
+			title = strings.TrimSuffix(strings.TrimPrefix(title, "To "), ":")
+			if title != "" {
+				title = strings.ToUpper(title[:1]) + title[1:]
+			}
+			items = append(items, cheatItem(title, strings.Join(body, "\n"), strings.Join(notes, "\n"), tags, naviPlaceholder))
+		}
+		comments, body = nil, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			if len(body) > 0 {
+				flush()
+			}
+			if c := strings.TrimSpace(strings.TrimPrefix(trimmed, "#")); c != "" {
+				comments = append(comments, c)
+			}
+		default:
+			body = append(body, line)
+		}
+	}
+	flush()
+	return items
+}
+
+// parseCheatSource reads what --from pet|navi|cheat points at.
+func parseCheatSource(from, path string) ([]Item, error) {
+	var exts []string
+	switch from {
+	case "pet":
+		exts = []string{".toml"}
+	case "navi":
+		exts = []string{".cheat"}
+	}
+	files, err := readCheatFiles(path, exts...)
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, p := range sortedKeys(files) {
+		switch from {
+		case "pet":
+			got, err := parsePetSnippets(files[p])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			items = append(items, got...)
+		case "navi":
+			items = append(items, parseNaviCheat(files[p])...)
+		case "cheat":
+			items = append(items, parseCheatSheet(strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)), files[p])...)
+		}
+	}
+	return items, nil
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tomlTable is one [[name]] table of a TOML document: strings and arrays
+// of strings, which is all pet writes.
+type tomlTable map[string]any
+
+func (t tomlTable) str(k string) string {
+	s, _ := t[k].(string)
+	return s
+}
+
+func (t tomlTable) list(k string) []string {
+	switch v := t[k].(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	}
+	return nil
+}
+
+// parseTOMLTables returns the [[name]] tables of a TOML document. It knows
+// basic, literal and multi-line strings and arrays of them; other values
+// are skipped.
+func parseTOMLTables(doc, name string) ([]tomlTable, error) {
+	var tables []tomlTable
+	var cur tomlTable
+	lines := strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			cur = nil
+			if line == "[["+name+"]]" {
+				cur = tomlTable{}
+				tables = append(tables, cur)
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		k = strings.Trim(strings.TrimSpace(k), `"`)
+		v = strings.TrimSpace(v)
+		// values can run over several lines
+		for open := tomlOpen(v); open != "" && i+1 < len(lines); open = tomlOpen(v) {
+			i++
+			v += "\n" + lines[i]
+		}
+		val, err := tomlValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if cur != nil && val != nil {
+			cur[k] = val
+		}
+	}
+	return tables, nil
+}
+
+// tomlOpen says what a value still waits for: closing triple quotes or ].
+func tomlOpen(v string) string {
+	for _, q := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(v, q) {
+			if strings.Count(v, q) < 2 {
+				return q
+			}
+			return ""
+		}
+	}
+	if strings.HasPrefix(v, "[") {
+		depth, inStr := 0, byte(0)
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			switch {
+			case inStr != 0:
+				if c == '\\' && inStr == '"' {
+					i++
+				} else if c == inStr {
+					inStr = 0
+				}
+			case c == '"' || c == '\'':
+				inStr = c
+			case c == '#':
+				for i < len(v) && v[i] != '\n' {
+					i++
+				}
+			case c == '[':
+				depth++
+			case c == ']':
+				depth--
+			}
+		}
+		if depth > 0 {
+			return "]"
+		}
+	}
+	return ""
+}
+
+func tomlValue(v string) (any, error) {
+	switch {
+	case strings.HasPrefix(v, `"""`):
+		s := strings.TrimPrefix(strings.TrimPrefix(v[3:], "\r"), "\n")
+		end := strings.Index(s, `"""`)
+		if end < 0 {
+			return nil, fmt.Errorf(`unterminated """`)
+		}
+		return unescapeTOML(s[:end])
+	case strings.HasPrefix(v, `'''`):
+		s := strings.TrimPrefix(v[3:], "\n")
+		end := strings.Index(s, `'''`)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated '''")
+		}
+		return s[:end], nil
+	case strings.HasPrefix(v, `"`), strings.HasPrefix(v, `'`):
+		s, _, err := tomlString(v)
+		return s, err
+	case strings.HasPrefix(v, "["):
+		var out []string
+		rest := strings.TrimSpace(v[1:])
+		for {
+			rest = strings.TrimLeft(rest, " \t\n,")
+			for strings.HasPrefix(rest, "#") {
+				_, rest, _ = strings.Cut(rest, "\n")
+				rest = strings.TrimLeft(rest, " \t\n,")
+			}
+			if rest == "" || rest[0] == ']' {
+				return out, nil
+			}
+			s, n, err := tomlString(rest)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, s)
+			rest = rest[n:]
+		}
+	}
+	return nil, nil // numbers, booleans, dates: nothing pet needs
+}
+
+// tomlString reads the quoted string at the start of v and how many bytes
+// it took.
+func tomlString(v string) (string, int, error) {
+	if strings.HasPrefix(v, "'") {
+		end := strings.IndexByte(v[1:], '\'')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated string")
+		}
+		return v[1 : end+1], end + 2, nil
+	}
+	if !strings.HasPrefix(v, `"`) {
+		return "", 0, fmt.Errorf("expected a string at %.20q", v)
+	}
+	for i := 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case '"':
+			s, err := unescapeTOML(v[1:i])
+			return s, i + 1, err
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func unescapeTOML(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+1+n > len(s) {
+				return "", fmt.Errorf(`bad \%c escape`, c)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf(`bad \%c escape`, c)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case '\n':
+			// line-ending backslash: skip the newline and leading space
+			for i+1 < len(s) && strings.ContainsRune(" \t\n", rune(s[i+1])) {
+				i++
+			}
+		default:
+			return "", fmt.Errorf(`bad escape \%c`, c)
+		}
+	}
+	return b.String(), nil
+}
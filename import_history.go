@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Importing from shell history: the most often used commands become items,
+// titled with the command itself, so a new library isn't empty.
+
+// commands too trivial to be worth saving
+var trivialCommands = map[string]bool{
+	"ls": true, "ll": true, "la": true, "cd": true, "pwd": true, "clear": true,
+	"exit": true, "history": true, "cat": true, "less": true, "man": true,
+	"vi": true, "vim": true, "nvim": true, "nano": true, "code": true,
+	"echo": true, "mkdir": true, "rm": true, "mv": true, "cp": true,
+	"commandref": true,
+	// PowerShell (compared in lower case, as it does)
+	"cls": true, "dir": true, "type": true, "gci": true, "sl": true, "ii": true,
+	"clear-host": true, "get-childitem": true, "set-location": true,
+	"get-location": true, "get-content": true, "get-history": true,
+	"notepad": true, "explorer": true,
+}
+
+// historyFiles are the shells' default history files, in the order they
+// are tried when no file is given.
+func historyFiles() []string {
+	var files []string
+	if h := os.Getenv("HISTFILE"); h != "" {
+		files = append(files, h)
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			files = append(files, filepath.Join(appData, "Microsoft", "Windows", "PowerShell", "PSReadLine", "ConsoleHost_history.txt"))
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files,
+			filepath.Join(home, ".zsh_history"),
+			filepath.Join(home, ".bash_history"),
+			filepath.Join(home, ".local", "share", "fish", "fish_history"),
+			// pwsh on Linux and macOS
+			filepath.Join(home, ".local", "share", "powershell", "PSReadLine", "ConsoleHost_history.txt"),
+		)
+	}
+	return files
+}
+
+// isPowerShellHistory tells PSReadLine's history (ConsoleHost_history.txt,
+// or <host>_history.txt for other hosts) by its name.
+func isPowerShellHistory(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	return strings.HasSuffix(base, "_history.txt")
+}
+
+// decodeHistory turns a history file into UTF-8: Windows PowerShell 5
+// may have written it as UTF-16 (with or without a BOM), and editors
+// like to add a UTF-8 BOM.
+func decodeHistory(raw []byte) []byte {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}):
+		return raw[3:]
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}):
+		order, raw = binary.LittleEndian, raw[2:]
+	case bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		order, raw = binary.BigEndian, raw[2:]
+	case len(raw) >= 4 && raw[1] == 0 && raw[3] == 0 && raw[0] != 0:
+		order = binary.LittleEndian // ASCII text as UTF-16LE, no BOM
+	default:
+		return raw
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = order.Uint16(raw[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// parsePowerShellHistory returns the commands of a PSReadLine history file,
+// oldest first. Lines of a multi-line command end in a backtick.
+func parsePowerShellHistory(raw []byte) []string {
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	var out []string
+	lines := strings.Split(string(raw), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(line, "`") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "`") + "\n" + lines[i]
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func defaultHistoryFile() string {
+	for _, f := range historyFiles() {
+		if st, err := os.Stat(f); err == nil && st.Size() > 0 {
+			return f
+		}
+	}
+	return ""
+}
+
+// parseShellHistory returns the commands of a zsh (plain or extended),
+// bash or fish history file, oldest first.
+func parseShellHistory(raw []byte) []string {
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	var out []string
+	lines := strings.Split(string(raw), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "- cmd: "): // fish
+			out = append(out, strings.ReplaceAll(strings.TrimPrefix(line, "- cmd: "), `\n`, "\n"))
+		case strings.HasPrefix(line, "  when: ") || strings.HasPrefix(line, "  paths:") || strings.HasPrefix(line, "    - "):
+			// fish metadata
+		case strings.HasPrefix(line, "#") && len(line) > 1 && strings.Trim(line[1:], "0123456789") == "":
+			// bash HISTTIMEFORMAT timestamp
+		default:
+			// zsh extended history: ": <start>:<elapsed>;<command>"
+			if strings.HasPrefix(line, ": ") {
+				if _, cmd, ok := strings.Cut(line, ";"); ok {
+					line = cmd
+				}
+			}
+			// zsh keeps multi-line commands as lines ending in a backslash
+			for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+				i++
+				line = strings.TrimSuffix(line, `\`) + "\n" + lines[i]
+			}
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// historyItems picks up to limit of the most used commands that are not
+// trivial and not in the library already.
+func historyItems(entries []string, existing []Item, limit int) []Item {
+	have := map[string]bool{}
+	for _, it := range existing {
+		have[strings.TrimSpace(it.Command)] = true
+	}
+	counts := map[string]int{}
+	var order []string
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		fields := strings.Fields(e)
+		if len(fields) < 2 || trivialCommands[strings.ToLower(fields[0])] || have[e] {
+			continue
+		}
+		if counts[e] == 0 {
+			order = append(order, e)
+		}
+		counts[e]++
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+
+	var items []Item
+	for _, cmd := range order[:min(len(order), limit)] {
+		title := strings.TrimSpace(strings.SplitN(cmd, "\n", 2)[0])
+		if r := []rune(title); len(r) > 60 {
+			title = strings.TrimSpace(string(r[:57])) + "..."
+		}
+		items = append(items, Item{Title: title, Command: cmd, Tags: []string{"history"}})
+	}
+	return items
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Importers for the macOS snippet managers. Both keep a snippet's
+// placeholders, which become positional parameters ($1, $2...) so the
+// command takes them as `run <id> -- a b`; the names are listed in the notes.
+
+var (
+	dashPlaceholder        = regexp.MustCompile(`__([A-Za-z0-9][A-Za-z0-9 _.-]*?)__`)
+	snippetsLabPlaceholder = regexp.MustCompile(`<#([^#]+)#>`)
+)
+
+// dashSnippet is one snippet of a Dash export, as JSON ([...] or
+// {"snippets": [...]}) or XML (<snippets><snippet>...</snippet></snippets>).
+type dashSnippet struct {
+	Title        string   `json:"title" xml:"title"`
+	Body         string   `json:"body" xml:"body"`
+	Abbreviation string   `json:"abbreviation" xml:"abbreviation"`
+	Syntax       string   `json:"syntax" xml:"syntax"`
+	Notes        string   `json:"notes" xml:"notes,omitempty"`
+	Tags         []string `json:"tags" xml:"tags>tag,omitempty"`
+}
+
+func parseDashExport(raw []byte) ([]Item, error) {
+	var snippets []dashSnippet
+	trimmed := bytes.TrimSpace(raw)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		var doc struct {
+			Snippets []dashSnippet `xml:"snippet"`
+		}
+		if err := xml.Unmarshal(trimmed, &doc); err != nil {
+			return nil, err
+		}
+		snippets = doc.Snippets
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &snippets); err != nil {
+			return nil, err
+		}
+	default:
+		var doc struct {
+			Snippets []dashSnippet `json:"snippets"`
+		}
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, err
+		}
+		snippets = doc.Snippets
+	}
+
+	var items []Item
+	for _, s := range snippets {
+		body := strings.ReplaceAll(s.Body, "@cursor", "")
+		it := snippetItem(s.Title, body, s.Abbreviation, s.Notes, s.Syntax, s.Tags, dashPlaceholder)
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// snippetsLabExport is SnippetsLab's JSON export: snippets made of one or
+// more fragments, with tags and folders referenced by UUID.
+type snippetsLabExport struct {
+	Contents struct {
+		Snippets []struct {
+			Title      string   `json:"title"`
+			FolderUUID string   `json:"folderUUID"`
+			TagsUUIDs  []string `json:"tagsUUIDs"`
+			Fragments  []struct {
+				Title    string `json:"title"`
+				Content  string `json:"content"`
+				Language string `json:"language"`
+				Note     string `json:"note"`
+			} `json:"fragments"`
+		} `json:"snippets"`
+		Tags []struct {
+			UUID  string `json:"uuid"`
+			Title string `json:"title"`
+		} `json:"tags"`
+		Folders []struct {
+			UUID  string `json:"uuid"`
+			Title string `json:"title"`
+		} `json:"folders"`
+	} `json:"contents"`
+}
+
+func parseSnippetsLabExport(raw []byte) ([]Item, error) {
+	var doc snippetsLabExport
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	tagNames := map[string]string{}
+	for _, t := range doc.Contents.Tags {
+		tagNames[t.UUID] = t.Title
+	}
+	folderNames := map[string]string{}
+	for _, f := range doc.Contents.Folders {
+		folderNames[f.UUID] = f.Title
+	}
+
+	var items []Item
+	for _, s := range doc.Contents.Snippets {
+		var tags []string
+		for _, u := range s.TagsUUIDs {
+			if n := tagNames[u]; n != "" {
+				tags = append(tags, n)
+			}
+		}
+		if f := folderNames[s.FolderUUID]; f != "" {
+			tags = append(tags, f)
+		}
+		// a multi-fragment snippet becomes one item per fragment
+		for _, f := range s.Fragments {
+			title := s.Title
+			if len(s.Fragments) > 1 && f.Title != "" {
+				title += " - " + f.Title
+			}
+			items = append(items, snippetItem(title, f.Content, "", f.Note, f.Language, tags, snippetsLabPlaceholder))
+		}
+	}
+	return items, nil
+}
+
+func snippetItem(title, body, abbreviation, notes, language string, tags []string, placeholder *regexp.Regexp) Item {
+	command, params := placeholderParams(body, placeholder)
+	var extra []string
+	if notes = strings.TrimSpace(notes); notes != "" {
+		extra = append(extra, notes)
+	}
+	if len(params) > 0 {
+		args := make([]string, len(params))
+		for i, p := range params {
+			args[i] = "$" + strconv.Itoa(i+1) + " = " + p
+		}
+		extra = append(extra, "Arguments: "+strings.Join(args, ", "))
+	}
+	if lang := strings.ToLower(strings.TrimSpace(language)); lang != "" && lang != "text" && lang != "plain text" {
+		tags = append(tags, lang)
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = abbreviation
+	}
+	return Item{
+		Title:   title,
+		Command: normalizeCommand(command),
+		Tags:    parseTags(strings.Join(tags, ",")),
+		Notes:   strings.Join(extra, "\n"),
+		Slug:    slugify(abbreviation),
+	}
+}
+
+// placeholderParams swaps named placeholders for positional parameters,
+// numbered by first appearance; a name used twice gets the same number.
+// A second submatch, when re has one, is a default: ${1:-default}. In single
+// quotes, where the shell wouldn't expand ${1}, the quotes are closed around
+// it ('Hello '"${1}"'!'); an escaped placeholder (\<name>) is left as it is.
+func placeholderParams(body string, re *regexp.Regexp) (string, []string) {
+	var names []string
+	index := map[string]int{}
+	literal := shellLiterals(body)
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(body, -1) {
+		escaped := m[0] > 0 && body[m[0]-1] == '\\'
+		if escaped {
+			continue
+		}
+		name := strings.TrimSpace(body[m[2]:m[3]])
+		n, ok := index[name]
+		if !ok {
+			names = append(names, name)
+			n = len(names)
+			index[name] = n
+		}
+		param := "${" + strconv.Itoa(n) + "}"
+		if len(m) > 5 && m[4] >= 0 {
+			if def := placeholderDefault(body[m[4]:m[5]]); def != "" && plainDefault.MatchString(def) {
+				param = "${" + strconv.Itoa(n) + ":-" + def + "}"
+			}
+		}
+		if literal[m[0]] {
+			param = `'"` + param + `"'`
+		}
+		b.WriteString(body[last:m[0]])
+		b.WriteString(param)
+		last = m[1]
+	}
+	b.WriteString(body[last:])
+	return b.String(), names
+}
+
+func parseExternalImport(from string, raw []byte) ([]Item, error) {
+	if from == "dash" {
+		return parseDashExport(raw)
+	}
+	return parseSnippetsLabExport(raw)
+}
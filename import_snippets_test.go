@@ -0,0 +1,35 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestPlaceholderParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		re        *regexp.Regexp
+		want      string
+		wantNames []string
+	}{
+		{"dash", "ssh __user__@__host__", dashPlaceholder, "ssh ${1}@${2}", []string{"user", "host"}},
+		{"used twice", "cp <f> <f>.bak", naviPlaceholder, "cp ${1} ${1}.bak", []string{"f"}},
+		{"double quotes", `echo "Hello <name>"`, naviPlaceholder, `echo "Hello ${1}"`, []string{"name"}},
+		{"single quotes", "echo 'Hello <name>!'", naviPlaceholder, `echo 'Hello '"${1}"'!'`, []string{"name"}},
+		{"awk", "awk '{print <col>}' <file>", naviPlaceholder, `awk '{print '"${1}"'}' ${2}`, []string{"col", "file"}},
+		{"escaped", `echo \<b> <name>`, naviPlaceholder, `echo \<b> ${1}`, []string{"name"}},
+		{"pet default", "ping <host=localhost>", petPlaceholder, "ping ${1:-localhost}", []string{"host"}},
+		{"pet default, single quotes", "curl '<url=http://localhost>'", petPlaceholder, `curl ''"${1:-http://localhost}"''`, []string{"url"}},
+		{"snippetslab", "git checkout <#branch#>", snippetsLabPlaceholder, "git checkout ${1}", []string{"branch"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, names := placeholderParams(tt.body, tt.re)
+			if got != tt.want || !slices.Equal(names, tt.wantNames) {
+				t.Errorf("got %s %q, want %s %q", got, names, tt.want, tt.wantNames)
+			}
+		})
+	}
+}
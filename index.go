@@ -0,0 +1,279 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// searchIndex is an inverted index over titles, commands, notes and tags:
+// every word maps to the IDs of the items containing it. Terms carry the
+// field they came from ("t:docker", "c:docker"...) so a search can be
+// limited to some fields. Search words match as prefixes ("kube" finds
+// "kubectl"), all words must match.
+//
+// It is rebuilt whenever its source changes: the item cache for the API
+// (every listing, watch event or edit of ours rewrites it) or commands.json
+// for a local library.
+type searchIndex struct {
+	Version  int      `json:"version"`
+	Stamp    string   `json:"stamp"` // identifies the source it was built from
+	Terms    []string `json:"terms"` // sorted
+	Postings [][]int  `json:"postings"`
+}
+
+// how long `search` trusts the item cache before asking the backend again
+const searchCacheTTL = 5 * time.Minute
+
+const indexVersion = 2
+
+// searchFields are the fields --in can name, with their term prefix.
+var searchFields = []struct{ name, prefix string }{
+	{"title", "t"}, {"command", "c"}, {"notes", "n"}, {"tags", "g"},
+}
+
+// parseSearchFields reads --in: a comma list of title, cmd/command, notes
+// and tag(s). Empty means every field.
+func parseSearchFields(s string) ([]string, error) {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		switch f = strings.ToLower(strings.TrimSpace(f)); f {
+		case "":
+			continue
+		case "cmd":
+			f = "command"
+		case "tag":
+			f = "tags"
+		case "title", "command", "notes", "tags":
+		default:
+			return nil, fmt.Errorf("unknown field %q for --in (title, cmd, notes, tags)", f)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func itemField(it Item, field string) string {
+	switch field {
+	case "title":
+		return it.Title
+	case "command":
+		return it.Command
+	case "notes":
+		return it.Notes
+	}
+	return strings.Join(it.Tags, " ")
+}
+
+func indexPath() (string, error) {
+	if usingLocalStore() {
+		dir, err := paths.CacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "local.index.json"), nil
+	}
+	p, err := cachePath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(p, ".json") + ".index.json", nil
+}
+
+func indexTerms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func buildIndex(items []Item, stamp string) *searchIndex {
+	post := map[string][]int{}
+	for _, it := range items {
+		seen := map[string]bool{}
+		for _, f := range searchFields {
+			for _, t := range indexTerms(itemField(it, f.name)) {
+				t = f.prefix + ":" + t
+				if !seen[t] {
+					seen[t] = true
+					post[t] = append(post[t], it.ID)
+				}
+			}
+		}
+	}
+	idx := &searchIndex{Version: indexVersion, Stamp: stamp}
+	for t := range post {
+		idx.Terms = append(idx.Terms, t)
+	}
+	sort.Strings(idx.Terms)
+	for _, t := range idx.Terms {
+		idx.Postings = append(idx.Postings, post[t])
+	}
+	return idx
+}
+
+func saveIndex(idx *searchIndex) {
+	p, err := indexPath()
+	if err != nil {
+		return
+	}
+	if b, err := json.Marshal(idx); err == nil {
+		_ = writeFileAtomic(p, b, 0600)
+	}
+}
+
+// loadIndex returns the stored index if it was built from stamp, else
+// builds (and stores) a new one.
+func loadIndex(items []Item, stamp string) *searchIndex {
+	if p, err := indexPath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			var idx searchIndex
+			if json.Unmarshal(b, &idx) == nil && idx.Version == indexVersion && idx.Stamp == stamp {
+				return &idx
+			}
+		}
+	}
+	idx := buildIndex(items, stamp)
+	saveIndex(idx)
+	return idx
+}
+
+// lookup returns the IDs of items matching every word of the query in one
+// of the fields (all of them if none are given).
+func (idx *searchIndex) lookup(query string, fields []string) map[int]bool {
+	var prefixes []string
+	for _, f := range searchFields {
+		if len(fields) == 0 || slices.Contains(fields, f.name) {
+			prefixes = append(prefixes, f.prefix+":")
+		}
+	}
+	var result map[int]bool
+	for _, w := range indexTerms(query) {
+		hits := map[int]bool{}
+		for _, p := range prefixes {
+			w := p + w
+			for i := sort.SearchStrings(idx.Terms, w); i < len(idx.Terms) && strings.HasPrefix(idx.Terms[i], w); i++ {
+				for _, id := range idx.Postings[i] {
+					if result == nil || result[id] {
+						hits[id] = true
+					}
+				}
+			}
+		}
+		result = hits
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+func localIndexStamp() string {
+	p, err := dbPath()
+	if err != nil {
+		return ""
+	}
+	st, err := os.Stat(p)
+	if err != nil {
+		return "empty"
+	}
+	return strconv.FormatInt(st.Size(), 10) + "@" + strconv.FormatInt(st.ModTime().UnixNano(), 10)
+}
+
+// indexSearch runs a query against items through the index built from
+// stamp, falling back to plain substring matching when no word starts a term.
+func indexSearch(all []Item, stamp, query string, fields []string) []Item {
+	if strings.TrimSpace(query) == "" {
+		return all
+	}
+	hits := loadIndex(all, stamp).lookup(query, fields)
+	var items []Item
+	for _, it := range all {
+		if hits[it.ID] {
+			items = append(items, it)
+		}
+	}
+	if len(items) == 0 {
+		// try the words anywhere ("ctl" in "kubectl")
+		items = filterItemsIn(all, query, fields)
+	}
+	return items
+}
+
+// filterItemsIn keeps items where every word of query appears in one of
+// the fields (all of them if none are given).
+func filterItemsIn(items []Item, query string, fields []string) []Item {
+	if len(fields) == 0 {
+		fields = []string{"title", "command", "notes", "tags"}
+	}
+	words := strings.Fields(strings.ToLower(query))
+	var out []Item
+	for _, it := range items {
+		var hay []string
+		for _, f := range fields {
+			hay = append(hay, strings.ToLower(itemField(it, f)))
+		}
+		all := strings.Join(hay, "\n")
+		ok := true
+		for _, w := range words {
+			if !strings.Contains(all, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// searchLibrary answers `search`. A local library goes through the index;
+// with the API, the item cache is searched the same way while it is younger
+// than searchCacheTTL, otherwise the backend is asked (which falls back to
+// the cache when it can't be reached). refresh skips the cache.
+func searchLibrary(query string, fields []string, refresh bool) ([]Item, error) {
+	if usingLocalStore() {
+		return localStore{}.Search(query, fields)
+	}
+	if c, err := loadItemCache(); err == nil && !refresh && len(loadQueue()) == 0 {
+		if t, err := time.Parse(time.RFC3339, c.FetchedAt); err == nil && time.Since(t) < searchCacheTTL {
+			return indexSearch(c.Items, c.Rev, query, fields), nil
+		}
+	}
+	return openStore().Search(query, fields)
+}
+
+// cacheMu keeps concurrent patches (bulk commands) from losing each other.
+var cacheMu sync.Mutex
+
+// patchItemCache keeps the cache (and so the index) in step with our own
+// writes, without making it look freshly fetched.
+func patchItemCache(it Item, deleted bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	c, err := loadItemCache()
+	if err != nil {
+		return
+	}
+	items := c.Items[:0]
+	for _, old := range c.Items {
+		if old.ID != it.ID {
+			items = append(items, old)
+		}
+	}
+	if !deleted {
+		items = append(items, it)
+	}
+	sortByID(items)
+	c.Items = items
+	writeItemCache(c)
+}
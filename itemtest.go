@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// `commandref test` checks what a command renders to for given parameters
+// against what was recorded with `test record`, without running it. The
+// expectations are kept on the item (Tests), so they sync with it.
+
+type itemTest struct {
+	Params []string `json:"params"`
+	Expect string   `json:"expect"`
+}
+
+// paramFlag collects --param values: positional in order, or name=value /
+// $N=value for an argument named in the notes' "Arguments:" line.
+type paramFlag []string
+
+func (p *paramFlag) String() string { return strings.Join(*p, " ") }
+
+func (p *paramFlag) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// positional puts the params in $1, $2... order for it.
+func (p paramFlag) positional(it Item) []string {
+	names, _ := argumentNames(it.Notes)
+	byName := map[string]int{}
+	for ref, name := range names {
+		if n, err := strconv.Atoi(ref); err == nil {
+			byName[name] = n
+		}
+	}
+	var out []string
+	next := 1
+	put := func(n int, v string) {
+		for len(out) < n {
+			out = append(out, "")
+		}
+		out[n-1] = v
+	}
+	for _, s := range p {
+		if k, v, ok := strings.Cut(s, "="); ok {
+			if n, err := strconv.Atoi(strings.TrimPrefix(k, "$")); err == nil && strings.HasPrefix(k, "$") && n > 0 {
+				put(n, v)
+				continue
+			}
+			if n, ok := byName[k]; ok {
+				put(n, v)
+				continue
+			}
+		}
+		for next <= len(out) && out[next-1] != "" {
+			next++
+		}
+		put(next, s)
+		next++
+	}
+	return out
+}
+
+// renderCommand is the command as the shell would get it with params as
+// $1, $2...: quoted in place, or appended. Secrets stay placeholders.
+func renderCommand(it Item, params []string) string {
+	return inlineArgs(it.Command, params, shellQuote)
+}
+
+func runTest(args []string) {
+	record := len(args) > 0 && args[0] == "record"
+	if record {
+		args = args[1:]
+	}
+	refs, rest := splitIDArgs(args)
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	var params paramFlag
+	fs.Var(&params, "param", "a parameter: a value for the next $N, or name=value (repeatable)")
+	expect := fs.String("expect", "", "with record: the expected text, instead of what it renders to now")
+	clearAll := fs.Bool("clear", false, "with record: remove the recorded tests")
+	_ = fs.Parse(rest)
+
+	st := openStore()
+	var items []Item
+	if len(refs) == 0 {
+		if record {
+			exitErr(errors.New("usage: commandref test record <id> [--param v]... [--expect text] [--clear]"))
+		}
+		all, err := st.List()
+		if err != nil {
+			exitErr(err)
+		}
+		sortByID(all)
+		for _, it := range all {
+			if len(it.Tests) > 0 {
+				items = append(items, it)
+			}
+		}
+		if len(items) == 0 {
+			fmt.Println("(no tests) record one with: commandref test record <id> --param ...")
+			return
+		}
+	} else {
+		ids, err := parseIDArgs(refs)
+		if err != nil {
+			exitErr(err)
+		}
+		for _, id := range ids {
+			items = append(items, *mustGetItem(st, id))
+		}
+	}
+
+	if record {
+		if len(items) != 1 {
+			exitErr(errors.New("record one item at a time"))
+		}
+		recordTest(st, items[0], params, *expect, *clearAll)
+		return
+	}
+
+	ran, failed := checkTests(items, params)
+	if ran > 1 || failed > 0 {
+		fmt.Printf("%d passed, %d failed\n", ran-failed, failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkTests runs the recorded tests of items (with params, just the one
+// recorded for them) and prints a line for each.
+func checkTests(items []Item, params paramFlag) (ran, failed int) {
+	for _, it := range items {
+		tests := it.Tests
+		if len(params) > 0 {
+			pos := params.positional(it)
+			i := slices.IndexFunc(tests, func(t itemTest) bool { return slices.Equal(t.Params, pos) })
+			if i < 0 {
+				// nothing recorded for these: show the rendering to check by eye
+				fmt.Println(renderCommand(it, pos))
+				fmt.Fprintf(os.Stderr, "(nothing recorded for these params; keep it with: commandref test record %s --param ...)\n", displayID(it))
+				continue
+			}
+			tests = tests[i : i+1]
+		}
+		if len(tests) == 0 {
+			fmt.Printf("#%s %s: no tests recorded\n", displayID(it), it.Title)
+			continue
+		}
+		for _, t := range tests {
+			ran++
+			got := renderCommand(it, t.Params)
+			label := fmt.Sprintf("#%s %s [%s]", displayID(it), it.Title, strings.Join(t.Params, " "))
+			if got == t.Expect {
+				fmt.Printf("%s %s\n", colorize("32", "ok  "), label)
+				continue
+			}
+			failed++
+			fmt.Printf("%s %s\n", colorize("31", "FAIL"), label)
+			fmt.Printf("    expected: %s\n    got:      %s\n", t.Expect, got)
+		}
+	}
+	return ran, failed
+}
+
+// testsSealable refuses recording while commands are encrypted: a test's
+// expected text is the rendered command, and tests aren't encrypted.
+func testsSealable() error {
+	if !e2eEnabled() {
+		return nil
+	}
+	fields, err := e2eFields()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(fields, "command") {
+		return errors.New("a test keeps the rendered command in plain text, and commands are encrypted here (encryption.fields), so it can't be recorded")
+	}
+	return nil
+}
+
+func recordTest(st Store, it Item, params paramFlag, expect string, clearAll bool) {
+	if it.ID < 0 {
+		exitErr(fmt.Errorf("p%d is a project command; tests are kept on saved items", -it.ID))
+	}
+	if !clearAll {
+		if err := testsSealable(); err != nil {
+			exitErr(err)
+		}
+	}
+	var tests []itemTest
+	var msg string
+	if clearAll {
+		tests, msg = nil, fmt.Sprintf("Cleared the tests of #%s", displayID(it))
+	} else {
+		pos := params.positional(it)
+		if expect == "" {
+			expect = renderCommand(it, pos)
+		}
+		if pos == nil {
+			pos = []string{}
+		}
+		tests = slices.DeleteFunc(slices.Clone(it.Tests), func(t itemTest) bool { return slices.Equal(t.Params, pos) })
+		tests = append(tests, itemTest{Params: pos, Expect: expect})
+		msg = fmt.Sprintf("Recorded test %d of #%s: %s", len(tests), displayID(it), expect)
+	}
+	if _, err := st.Update(it.ID, map[string]any{"tests": tests}); err != nil {
+		exitErr(err)
+	}
+	fmt.Println(msg)
+}
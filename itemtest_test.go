@@ -0,0 +1,65 @@
+package main
+
+import (
+	"commandref/config"
+	"testing"
+)
+
+func TestCheckTests(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	greet := Item{ID: 1, Title: "greet", Command: "echo hello $1",
+		Tests: []itemTest{{Params: []string{"bob"}, Expect: "echo hello 'bob'"}}}
+	other := Item{ID: 2, Title: "other", Command: "echo $1",
+		Tests: []itemTest{{Params: []string{"x"}, Expect: "echo 'x'"}}}
+	broken := Item{ID: 3, Title: "broken", Command: "echo bye $1",
+		Tests: []itemTest{{Params: []string{"bob"}, Expect: "echo hello 'bob'"}}}
+
+	tests := []struct {
+		name        string
+		items       []Item
+		params      paramFlag
+		ran, failed int
+	}{
+		{"all recorded", []Item{greet, other}, nil, 2, 0},
+		{"params match the first", []Item{greet, other}, paramFlag{"bob"}, 1, 0},
+		{"params match only a later one", []Item{other, greet}, paramFlag{"bob"}, 1, 0},
+		{"later one fails", []Item{other, broken}, paramFlag{"bob"}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran, failed := checkTests(tt.items, tt.params)
+			if ran != tt.ran || failed != tt.failed {
+				t.Errorf("ran, failed = %d, %d, want %d, %d", ran, failed, tt.ran, tt.failed)
+			}
+		})
+	}
+}
+
+func TestTestsSealable(t *testing.T) {
+	tests := []struct {
+		name      string
+		storage   string
+		enabled   bool
+		fields    []string
+		workspace string
+		wantErr   bool
+	}{
+		{"encryption off", "api", false, nil, "", false},
+		{"commands encrypted (default fields)", "api", true, nil, "", true},
+		{"only notes encrypted", "api", true, []string{"notes"}, "", false},
+		{"team workspace", "api", true, nil, "w1", false},
+		{"local library", "local", true, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &config.Config{Storage: tt.storage}
+			cfg.Encryption.Enabled, cfg.Encryption.Fields = tt.enabled, tt.fields
+			resolvedWorkspace = &workspace{ID: tt.workspace}
+			t.Cleanup(func() { resolvedWorkspace = nil })
+			if err := testsSealable(); (err != nil) != tt.wantErr {
+				t.Errorf("testsSealable() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
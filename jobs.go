@@ -0,0 +1,347 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// job is a detached run. The parent writes the record and starts a hidden
+// `commandref __job <id>` wrapper, which fills in PID and exit code itself.
+type job struct {
+	ID        int      `json:"id"`
+	Item      Item     `json:"item"`
+	Extra     []string `json:"extra,omitempty"`
+	Timeout   string   `json:"timeout,omitempty"`
+	Host      string   `json:"host,omitempty"`
+	Win       bool     `json:"win,omitempty"`
+	PID       int      `json:"pid"`            // the __job wrapper
+	PGID      int      `json:"pgid,omitempty"` // the command's own process group
+	StartedAt string   `json:"startedAt"`
+	EndedAt   string   `json:"endedAt,omitempty"`
+	ExitCode  *int     `json:"exitCode,omitempty"`
+}
+
+func jobsDir() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "jobs")
+	return dir, os.MkdirAll(dir, 0700)
+}
+
+func jobFile(id int, ext string) (string, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strconv.Itoa(id)+ext), nil
+}
+
+func saveJob(j *job) error {
+	p, err := jobFile(j.ID, ".json")
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+func loadJob(id int) (*job, error) {
+	p, err := jobFile(id, ".json")
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no job %d", id)
+		}
+		return nil, err
+	}
+	var j job
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func loadJobs() ([]*job, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []*job
+	for _, e := range entries {
+		idStr, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		if j, err := loadJob(id); err == nil {
+			out = append(out, j)
+		}
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].ID < out[k].ID })
+	return out, nil
+}
+
+func (j *job) status() string {
+	switch {
+	case j.ExitCode != nil:
+		if *j.ExitCode == 0 {
+			return "done"
+		}
+		return fmt.Sprintf("exit %d", *j.ExitCode)
+	case j.PID == 0:
+		return "starting"
+	case processAlive(j.PID):
+		return "running"
+	default:
+		return "died"
+	}
+}
+
+func (j *job) finished() bool {
+	s := j.status()
+	return s != "running" && s != "starting"
+}
+
+// reserveJobID claims the first free job ID from id on by creating its
+// record file, so two runs started at once can't both get it.
+func reserveJobID(id int) (int, error) {
+	for ; ; id++ {
+		p, err := jobFile(id, ".json")
+		if err != nil {
+			return 0, err
+		}
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			return id, f.Close()
+		}
+		if !os.IsExist(err) {
+			return 0, err
+		}
+	}
+}
+
+// startDetached launches the item in the background and returns the job.
+func startDetached(it *Item, ro runOptions) (*job, error) {
+	jobs, err := loadJobs()
+	if err != nil {
+		return nil, err
+	}
+	j := &job{ID: 1, Item: *it, Extra: ro.extra, Host: ro.host, Win: ro.win, StartedAt: time.Now().UTC().Format(time.RFC3339)}
+	if len(jobs) > 0 {
+		j.ID = jobs[len(jobs)-1].ID + 1
+	}
+	if j.ID, err = reserveJobID(j.ID); err != nil {
+		return nil, err
+	}
+	if ro.timeout > 0 {
+		j.Timeout = ro.timeout.String()
+	}
+	if err := saveJob(j); err != nil {
+		return nil, err
+	}
+
+	logPath, err := jobFile(j.ID, ".log")
+	if err != nil {
+		return nil, err
+	}
+	logf, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer logf.Close()
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(self, "__job", strconv.Itoa(j.ID))
+	cmd.Stdout = logf
+	cmd.Stderr = logf
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	_ = cmd.Process.Release()
+	return j, nil
+}
+
+// runJobWrapper is the body of the hidden `__job` command.
+func runJobWrapper(args []string) {
+	if len(args) != 1 {
+		exitErr(errors.New("usage: commandref __job <id>"))
+	}
+	id, _ := strconv.Atoi(args[0])
+	j, err := loadJob(id)
+	if err != nil {
+		exitErr(err)
+	}
+	j.PID = os.Getpid()
+	_ = saveJob(j)
+	childStarted = func(pid int) {
+		j.PGID = pid
+		_ = saveJob(j)
+	}
+
+	ro := runOptions{extra: j.Extra, host: j.Host, win: j.Win}
+	if j.Timeout != "" {
+		ro.timeout, _ = time.ParseDuration(j.Timeout)
+	}
+	started := time.Now()
+	code, err := runWithHooks(&j.Item, ro)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "commandref: run error:", err)
+		code = 5
+	}
+	recordRun(&j.Item, ro, started, code)
+	notifyIfLong(&j.Item, started, code)
+
+	j.EndedAt = time.Now().UTC().Format(time.RFC3339)
+	j.ExitCode = &code
+	_ = saveJob(j)
+}
+
+func runJobs(args []string) {
+	fs := flag.NewFlagSet("jobs", flag.ExitOnError)
+	clean := fs.Bool("clean", false, "forget finished jobs and delete their logs")
+	_ = fs.Parse(args)
+
+	jobs, err := loadJobs()
+	if err != nil {
+		exitErr(err)
+	}
+
+	if *clean {
+		n := 0
+		for _, j := range jobs {
+			if !j.finished() {
+				continue
+			}
+			for _, ext := range []string{".json", ".log"} {
+				if p, err := jobFile(j.ID, ext); err == nil {
+					_ = os.Remove(p)
+				}
+			}
+			n++
+		}
+		fmt.Printf("Removed %d finished jobs\n", n)
+		return
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("(no jobs)")
+		return
+	}
+	for _, j := range jobs {
+		st := j.status()
+		switch st {
+		case "running":
+			st = colorize("32", st)
+		case "done":
+		default:
+			st = colorize("31", st)
+		}
+		fmt.Printf("[%d] %-10s #%d %s  (started %s, pid %d)\n", j.ID, st, j.Item.ID, j.Item.Title, formatTime(j.StartedAt), j.PID)
+	}
+}
+
+func requireJob(args []string, name string) *job {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("%s requires a job id (see: commandref jobs)", name))
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "%"))
+	if err != nil {
+		exitErr(fmt.Errorf("invalid job id: %s", args[0]))
+	}
+	j, err := loadJob(id)
+	if err != nil {
+		exitWith(exitNotFound, err)
+	}
+	return j
+}
+
+func runLogs(args []string) {
+	j := requireJob(args, "logs")
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "keep printing output until the job ends")
+	_ = fs.Parse(args[1:])
+
+	p, err := jobFile(j.ID, ".log")
+	if err != nil {
+		exitErr(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		exitErr(err)
+	}
+	defer f.Close()
+
+	for {
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			exitErr(err)
+		}
+		if !*follow {
+			return
+		}
+		if cur, err := loadJob(j.ID); err != nil || cur.finished() {
+			_, _ = io.Copy(os.Stdout, f)
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func runKill(args []string) {
+	j := requireJob(args, "kill")
+	fs := flag.NewFlagSet("kill", flag.ExitOnError)
+	force := fs.Bool("9", false, "send SIGKILL instead of SIGTERM")
+	_ = fs.Parse(args[1:])
+
+	if j.finished() {
+		fmt.Printf("Job %d is not running (%s)\n", j.ID, j.status())
+		return
+	}
+	if j.PID == 0 {
+		exitErr(errors.New("job has not started yet; try again"))
+	}
+	sig := syscall.SIGTERM
+	if *force {
+		sig = syscall.SIGKILL
+	}
+	// the command has a process group of its own, and SIGKILL can't be
+	// forwarded: signal it directly, and the wrapper records how it ended
+	pid := j.PID
+	if j.PGID != 0 {
+		pid = j.PGID
+	}
+	if err := signalProcessGroup(pid, sig); err != nil {
+		exitErr(err)
+	}
+	fmt.Printf("Sent %s to job %d\n", sig, j.ID)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReserveJobID(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+
+	const n = 20
+	ids := make([]int, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := reserveJobID(1) // all think 1 is next
+			if err != nil {
+				t.Error(err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("job ID %d handed out twice: %v", id, ids)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// `list --long` prints a table. Which columns it has comes from --columns,
+// else the list_columns config setting, else defaultColumns.
+
+var defaultColumns = []string{"id", "title", "cmd", "tags"}
+
+// column name -> header and how to render an item's cell
+var listColumns = map[string]struct {
+	header string
+	cell   func(it Item, u usageEntry) string
+}{
+	"id":         {"ID", func(it Item, _ usageEntry) string { return displayID(it) }},
+	"title":      {"TITLE", func(it Item, _ usageEntry) string { return cellText(it.Title) }},
+	"cmd":        {"COMMAND", func(it Item, _ usageEntry) string { return cellText(it.Command) }},
+	"tags":       {"TAGS", func(it Item, _ usageEntry) string { return strings.Join(it.Tags, ",") }},
+	"collection": {"COLLECTION", func(it Item, _ usageEntry) string { return it.Collection }},
+	"notes":      {"NOTES", func(it Item, _ usageEntry) string { return cellText(it.Notes) }},
+	"created":    {"CREATED", func(it Item, _ usageEntry) string { return formatTime(it.CreatedAt) }},
+	"updated":    {"UPDATED", func(it Item, _ usageEntry) string { return formatTime(it.UpdatedAt) }},
+	"used":       {"USED", func(_ Item, u usageEntry) string { return strconv.Itoa(u.Runs + u.Copies) }},
+	"runs":       {"RUNS", func(_ Item, u usageEntry) string { return strconv.Itoa(u.Runs) }},
+	"copies":     {"COPIES", func(_ Item, u usageEntry) string { return strconv.Itoa(u.Copies) }},
+	"last-used": {"LAST USED", func(_ Item, u usageEntry) string {
+		if t, err := time.Parse(time.RFC3339, u.LastUsedAt); err == nil {
+			return formatSince(t, time.Now())
+		}
+		return "never"
+	}},
+}
+
+var columnOrder = []string{"id", "title", "cmd", "tags", "collection", "notes", "created", "updated", "used", "runs", "copies", "last-used"}
+
+// parseColumns checks a comma list of column names ("command" and
+// "last_used" are accepted too).
+func parseColumns(s string) ([]string, error) {
+	var cols []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(c)), "_", "-")
+		switch c {
+		case "":
+			continue
+		case "command":
+			c = "cmd"
+		}
+		if _, ok := listColumns[c]; !ok {
+			return nil, fmt.Errorf("unknown column %q (have: %s)", c, strings.Join(columnOrder, ", "))
+		}
+		cols = append(cols, c)
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return cols, nil
+}
+
+// listColumnsFor picks the columns: the flag, then the config, then the
+// defaults.
+func listColumnsFor(flagValue string) ([]string, error) {
+	if flagValue != "" {
+		return parseColumns(flagValue)
+	}
+	if cfg != nil && len(cfg.ListColumns) > 0 {
+		cols, err := parseColumns(strings.Join(cfg.ListColumns, ","))
+		if err != nil {
+			return nil, fmt.Errorf("list_columns in the config: %w", err)
+		}
+		return cols, nil
+	}
+	return defaultColumns, nil
+}
+
+// cellText keeps a cell on one line and reasonably narrow.
+func cellText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	return s
+}
+
+func printLongList(items []Item, cols []string) {
+	usage, _ := loadUsage()
+	if opts.Accessible {
+		for _, it := range items {
+			u := usage.get(it.ID)
+			fmt.Printf("Item %s\n", displayID(it))
+			for _, c := range cols {
+				if c == "id" {
+					continue
+				}
+				col := listColumns[c]
+				fmt.Printf("  %s: %s\n", strings.ToUpper(col.header[:1])+strings.ToLower(col.header[1:]), col.cell(it, u))
+			}
+			fmt.Println()
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var row []string
+	for _, c := range cols {
+		row = append(row, listColumns[c].header)
+	}
+	fmt.Fprintln(w, strings.Join(row, "\t"))
+	for _, it := range items {
+		u := usage.get(it.ID)
+		row = row[:0]
+		for _, c := range cols {
+			row = append(row, strings.ReplaceAll(listColumns[c].cell(it, u), "\t", " "))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"commandref/config"
+	"testing"
+	"time"
+)
+
+func TestLastUsedColumnTimeFormat(t *testing.T) {
+	defer func(c *config.Config) { cfg = c }(cfg)
+	at := time.Now().Add(-3 * 24 * time.Hour).UTC()
+	u := usageEntry{LastUsedAt: at.Format(time.RFC3339)}
+	tests := []struct {
+		format, zone, want string
+	}{
+		{"", "", "3d ago"},
+		{"relative", "", "3d ago"},
+		{"date", "utc", at.Format("2006-01-02")},
+		{"rfc3339", "utc", at.Format(time.RFC3339)},
+		{"Jan 2 15:04", "utc", at.Format("Jan 2 15:04")},
+	}
+	for _, tt := range tests {
+		cfg = &config.Config{TimeFormat: tt.format, TimeZone: tt.zone}
+		if got := listColumns["last-used"].cell(Item{}, u); got != tt.want {
+			t.Errorf("time_format %q: last-used = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+	if got := listColumns["last-used"].cell(Item{}, usageEntry{}); got != "never" {
+		t.Errorf("never used: %q", got)
+	}
+}
@@ -4,55 +4,94 @@ import (
 	"bytes"
 	"commandref/api"
 	"commandref/auth"
+	"commandref/config"
+	"commandref/paths"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Item struct {
 	ID        int      `json:"id"`
+	UUID      string   `json:"uuid"`
 	Title     string   `json:"title"`
+	Slug      string   `json:"slug"` // short name, e.g. a snippet abbreviation
 	Command   string   `json:"command"`
 	Tags      []string `json:"tags"`
 	Notes     string   `json:"notes"`
+	Icon      string   `json:"icon"`
 	CreatedAt string   `json:"createdAt"`
 	UpdatedAt string   `json:"updatedAt"`
+
+	// how `run` executes it
+	Workdir string            `json:"workdir"`
+	Env     map[string]string `json:"env"`
+	Timeout string            `json:"timeout"` // default run timeout, e.g. "10m"
+
+	// shell commands run before/after this item's command (see runHooks)
+	PreRun  string `json:"preRun"`
+	PostRun string `json:"postRun"`
+
+	// hosts `run --host` may target (globs like "prod-*"); empty means any
+	// host unless a tag restricts it via the run_hosts config
+	Hosts []string `json:"hosts"`
+
+	// "windows" makes `run` on WSL use Windows by default (like --win)
+	Context string `json:"context"`
+
+	// never record runs/copies of this item in usage counters or history
+	NoLog bool `json:"noLog"`
+
+	// name of the collection it's filed in, if any (see collections.go)
+	Collection string `json:"collection"`
+
+	// hidden from list, search and the picker (see archive.go)
+	Archived bool `json:"archived"`
+
+	// expected renderings for given parameters (see itemtest.go)
+	Tests []itemTest `json:"tests,omitempty"`
+
+	// in a workspace: who wrote it and who changed it last (set by the
+	// backend), and the author's signature over what `run` executes
+	// (see trust.go)
+	CreatedBy string `json:"createdBy,omitempty"`
+	UpdatedBy string `json:"updatedBy,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	SignedBy  string `json:"signedBy,omitempty"`
 }
 
+var cfg *config.Config
+
 type DB struct {
-	NextID int    `json:"nextId"`
-	Items  []Item `json:"items"`
+	NextID      int          `json:"nextId"`
+	Items       []Item       `json:"items"`
+	Collections []collection `json:"collections,omitempty"`
+	Tombstones  []tombstone  `json:"tombstones,omitempty"`
 }
 
 func dbPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+	if usingGitStore() {
+		dir, err := gitRepoDir()
+		return filepath.Join(dir, "commands.json"), err
 	}
-	return filepath.Join(home, ".commandref", "commands.json"), nil
-}
-
-func ensureDir() error {
-	p, err := dbPath()
+	dir, err := paths.DataDir()
 	if err != nil {
-		return err
+		return "", err
 	}
-	return os.MkdirAll(filepath.Dir(p), 0755)
+	return filepath.Join(dir, "commands.json"), nil
 }
 
 func loadDB() (DB, error) {
-	if err := ensureDir(); err != nil {
-		return DB{}, err
-	}
 	p, err := dbPath()
 	if err != nil {
 		return DB{}, err
@@ -133,55 +172,220 @@ func usage() {
 	fmt.Print(`commandref - save and recall important terminal commands
 
 Usage:
-  commandref add    --title "..." --cmd "..." [--tags t1,t2] [--notes "..."]
-  commandref list
-  commandref search <query>
-  commandref show <id>
-  commandref copy <id>     (macOS clipboard via pbcopy)
-  commandref run  <id>     (executes using: /bin/zsh -lc "<command>")
-  commandref rm   <id>
+  commandref [--accessible] [--workspace name|id] [--profile name] [--api-base url] [--reveal] [--yes] [--error-format json] <command> ...
+             (--reveal: show what looks like a password or token in list, show and search unmasked;
+              --yes/-y: answer yes to confirmations, which otherwise fail without a terminal;
+              COMMANDREF_NONINTERACTIVE=1 never prompts, even in a terminal;
+              --error-format json: report a failure as one JSON object on stderr)
+
+  commandref setup  (storage, login, history import and shell widget; offered on first run)
+  commandref login [--paste-token]
+  commandref whoami
+  commandref logout
+  commandref doctor  (check the setup, and what is kept in plain text)
+  commandref secure  (move the login, credentials and library off plain text, step by step)
+
+  commandref add    --title "..." --cmd "..." [--tags t1,t2] [--notes "..."] [--icon "🐳"] [--slug name]
+                    [--workdir dir] [--env KEY=VALUE ...] [--timeout 10m] [--no-log]
+                    [--pre-run "..."] [--post-run "..."] [--hosts h1,h2]
+                    [--context windows|linux]
+  commandref edit <id> [--title "..."] [--cmd "..."] [--tags t1,t2] [--notes "..."] [--icon "..."] [--slug name]
+                       [--workdir dir] [--env KEY=VALUE ...] [--timeout 10m] [--no-log]
+                       [--pre-run "..."] [--post-run "..."] [--hosts h1,h2]
+                       [--context windows|linux]
+  commandref list [--filter name] [--format name|ndjson|csv|tsv|'{{.Title}}'] [--collection name] [--archived] [--exclude-tag t]
+                  [--long [--columns id,title,cmd,tags,used,...]]
+                  [--created-after 7d] [--created-before date] [--updated-since 30d] [--updated-before 365d]
+  commandref archive <id>... | unarchive <id>...  (hide an item from list, search and the picker)
+  commandref collection list | create <name> | rm [--force] <name>
+  commandref mv <id> --collection <name>  (--collection "" takes it out)
+  commandref scripts  (list filter/format/transform templates in <config dir>/scripts)
+  commandref search [--in title,cmd,notes,tags] [--collection name] [--archived] [--refresh]
+                    [--exclude-tag t] [--created-after 7d] [--updated-before 365d]...
+                    <query>  (-tag:t, -title:x, -cmd:x, -notes:x leave items out)
+  commandref recent [-n 5]  (newest and last used items)
+  commandref suggest [-n 5] [--cached] [--ids | --commands]  (likely next commands: used often,
+                     lately and in this directory or repo, weighed by "picker" in the config)
+  commandref ai add "<what it should do>" [--yes] [--tags t1,t2] [--collection name]
+                 (propose a command with the model set up under "ai" in the config)
+  commandref ask "<question>" [-n 5] [--keyword]  (find saved commands by meaning)
+  commandref explain <id> | --cmd "..."  (what each program, flag and operator in a command does)
+  commandref show <id>... [--output]  (ids: 7, a slug, or a range like 9-12)
+  commandref copy <id>... [--stdout]  (pbcopy/wl-copy/xclip, or OSC 52 over SSH)
+  commandref copied [--id N] [--since 2h] [--limit 20] [--clear]
+                    (what copy put on the clipboard; needs "copy_history": true in the config)
+  commandref share <id> [--expires 7d] [--no-copy]  (public read-only link)
+  commandref unshare <id>
+  commandref publish --tag public [--prune] [--dry-run] | publish <id>... | publish list
+                     | publish remove <id>... | --all | publish handle [name]
+                     (read-only public profile page, /u/<handle>, with copies of the chosen items)
+  commandref run  <id> [--timeout 30s] [--capture] [--detach] [--tmux pane|window]
+                  [--host name] [--win|--linux] [--trust] [-- args...]  (executes using: /bin/zsh -lc "<command>")
+                  (--trust: needed once for a workspace item someone else wrote or changed, and again after any change)
+  commandref rm   <id>...  (e.g. rm 3 7 9-12) | rm -i [filter]  (mark them in a picker)
+  commandref runs [--id N] [--failed] [--limit 20]  (local history of run)
+  commandref workflow add [--force] <name> <step>...  (step: item id or a quoted command)
+  commandref workflow list | show <name> | rm <name> | run <name> [--keep-going]  (alias: playbook)
+  commandref workflow export <name> [--format markdown] [--out file.md]  (a checklist for a ticket)
+  commandref workspace [current] | list | switch <name|id|personal>
+  commandref project [show] | allow | forget  (the .commandref.yaml here: its commands show up
+                    as p1, p2... once allowed, and again after each change to it)
+  commandref watch [--quiet] [--detach | --stop]  (live updates from the backend)
+  commandref daemon [--addr 127.0.0.1:7878] [--idle-timeout 10m] [--refresh 5m]  (local API for the browser extension)
+  commandref daemon install [--idle-timeout 10m] | daemon uninstall
+                    (start it on demand through systemd socket activation or launchd)
+  commandref pair [--list | --revoke name]  (show a code to pair the browser extension)
+  commandref jobs [--clean] | logs <job> [-f] | kill <job> [-9]  (run --detach jobs)
+  commandref pick [--no-rank] [query]  (prints the chosen command; uses fzf when installed; best
+                  match, most recent, most used and used here first, weighed by "picker" in the config)
+  commandref widget zsh|bash|fish  (Ctrl-G inserts a saved command at the prompt)
+  commandref completion zsh|bash|fish  (TAB completion; reads only the local cache)
+  commandref export [--format json|ndjson|markdown|dash|navi|raycast|alfred|csv|tsv] [--columns id,title,...] [--out file.json|dir] [--since-last] [--post https://...] [--sign] [--require 'jq>=1.6' ...] [--collection name]
+  commandref import [--force] <bundle.json>
+  commandref import --from dash|snippetslab <export file>
+  commandref import --from pet|navi|cheat <file or dir>  (snippet.toml, .cheat files or cheatsheets;
+                      <placeholders> become $1, $2...)
+  commandref import --from history [--limit 20] [history file]  (most used shell commands;
+                      zsh, bash, fish or PowerShell's ConsoleHost_history.txt)
+  commandref import [--yes] <share-url|slug>  (save a copy of a shared command)
+  commandref keys generate|show|trust <public-key>
+  commandref secret list | secret set <NAME> | secret rm <NAME>
+                    ({{secret:NAME}} in a command is filled in by run and copy from the environment,
+                    secret_command or the OS keychain; only the placeholder is saved)
+  commandref encryption [status] | encryption rotate [--dry-run] | encryption keygen <file>
+                        (encrypt command and notes before they reach the backend; see "encryption" in the config)
+  commandref stats
+  commandref digest [--format markdown|slack|text] [--days 7] [--stale-days 90]  (weekly summary for a team channel)
+  commandref tags
+  commandref tag <id>... [--add t,u] [--remove v]
+  commandref slugs [fill]  (names to use instead of IDs: commandref run restart-nginx)
+  commandref tag add|remove [-i] <tag> [<id>...]  (-i: mark the items in a picker)
+  commandref test [<id>...] [--param v | --param name=v]...  (check renderings against the recorded ones)
+  commandref test record <id> [--param v]... [--expect text] [--clear]
+  commandref sync [--backend webdav|gist|git]  (encrypted sync of the local library)
+  commandref sync --peer user@host  (direct sync with another machine over ssh)
+  commandref sync obsidian --vault ~/Notes [--folder commandref] [--watch]  (two-way sync with notes)
+  commandref sync tombstones [list] | prune [--older-than 90d]  (deletions sync passes on)
+  commandref alias export [--tag t] [--shell zsh|bash|fish] > ~/.commandref_aliases
+  commandref migrate --to api|local|git [--grace 90d] [--force] | --forget
+                    (copy the library to another store; old IDs work as old:<n>, in show/run/copy as they are)
+  commandref merge-store [--prefer mine|theirs|both] [--dry-run] <other commands.json>
+                     (fold another machine's local library into this one)
+  commandref api <method> <path> [--data @file]  (raw authenticated request, prints the JSON response)
+  commandref version [--check]
 
 Examples:
   commandref add --title "List files" --cmd "ls -la" --tags shell,mac
   commandref search adb
   commandref copy 2
+  commandref run 5 -- --verbose /tmp/file
+  eval "$(commandref copy 2 --stdout)"
+  eval "$(commandref widget zsh)"   # in ~/.zshrc
+  commandref run p1   # first command in the project's .commandref.yaml
+
+Exit codes:
+  0 ok, 1 declined or cancelled, 2 usage or other error, 3 not found, 4 clipboard,
+  5 could not start the command, 6 not logged in or not allowed, 7 backend unreachable;
+  run exits with the command's own code once it has started (124: --timeout)
 `)
 }
 
 func main() {
+	var err error
+	cfg, err = config.Load()
+	if err != nil {
+		exitErr(fmt.Errorf("loading config: %w", err))
+	}
+	auth.UseKeychain = cfg.SessionStorage == "keychain"
+	api.UserAgent = fmt.Sprintf("commandref/%s (%s/%s)", version, runtime.GOOS, runtime.GOARCH)
+	os.Args = append(os.Args[:1], extractGlobalFlags(os.Args[1:])...)
+	if err := applyBackendFlags(); err != nil {
+		exitErr(err)
+	}
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
 	}
 
 	cmd := os.Args[1]
+	if cmd != "daemon" && cmd != "completion" {
+		api.Waiting = func(req string) func() { return waitFor("the API (" + req + ")") }
+	}
+	maybeOnboard(cmd)
 
 	switch cmd {
+	case "version", "--version":
+		fs := flag.NewFlagSet("version", flag.ExitOnError)
+		check := fs.Bool("check", false, "check for a newer release now")
+		_ = fs.Parse(os.Args[2:])
+		printVersion(*check)
+		if !*check {
+			maybeNotifyUpdate() // update_check goes through the daily cache
+		}
+		return
+
+	case "setup":
+		runSetup()
+
 	case "login":
+		fs := flag.NewFlagSet("login", flag.ExitOnError)
+		pasteToken := fs.Bool("paste-token", false, "paste a token from the web app instead of browser OAuth")
+		_ = fs.Parse(os.Args[2:])
+		prev, _ := auth.LoadSession()
+
+		if *pasteToken {
+			token, err := readSecret("Paste your commandref token: ")
+			if err != nil {
+				fmt.Println("Login failed:", err)
+				os.Exit(1)
+			}
+			s, err := auth.LoginWithToken(token)
+			if err != nil {
+				fmt.Println("Login failed:", err)
+				os.Exit(1)
+			}
+			fmt.Println("Logged in as:", s.Email)
+			runLoginHook(prev)
+			return
+		}
+
 		if err := auth.Login(); err != nil {
 			fmt.Println("Login failed:", err)
 			os.Exit(1)
 		}
 		fmt.Println("Login successful")
+		runLoginHook(prev)
 
 	case "whoami":
 		s, err := auth.LoadSession()
 		if err != nil {
-			fmt.Println("error:", err)
-			os.Exit(2)
+			exitErr(err)
 		}
 		if s == nil {
 			fmt.Println("Not logged in. Run: commandref login")
 			return
 		}
 		fmt.Println("Logged in as:", s.Email)
+		if ws := currentWorkspace(); ws.ID != "" {
+			fmt.Println("Workspace:", ws.label())
+		}
+		printSecurity(false)
+
+	case "doctor":
+		runDoctor()
+
+	case "secure":
+		runSecure()
 
 	case "logout":
+		prev, _ := auth.LoadSession()
 		if err := auth.ClearSession(); err != nil {
-			fmt.Println("error:", err)
-			os.Exit(2)
+			exitErr(err)
 		}
 		fmt.Println("Logged out")
+		if prev != nil {
+			_ = runHook("logout", cfg.Hooks.OnLogout, sessionEnv(prev, false))
+		}
 
 	case "add":
 		fs := flag.NewFlagSet("add", flag.ExitOnError)
@@ -189,209 +393,793 @@ func main() {
 		command := fs.String("cmd", "", "the command to save")
 		tags := fs.String("tags", "", "comma-separated tags")
 		notes := fs.String("notes", "", "optional notes")
+		icon := fs.String("icon", "", "optional icon/emoji shown next to the item")
+		workdir := fs.String("workdir", "", "directory to run the command in")
+		env := envFlag{}
+		fs.Var(env, "env", "KEY=VALUE environment variable for run (repeatable)")
+		timeout := fs.String("timeout", "", "default run timeout, e.g. 10m")
+		noLog := fs.Bool("no-log", false, "never record runs/copies of this item (for sensitive commands)")
+		preRun := fs.String("pre-run", "", "shell command to run before each run (non-zero exit aborts the run)")
+		postRun := fs.String("post-run", "", "shell command to run after each run")
+		hosts := fs.String("hosts", "", "comma-separated hosts allowed for run --host (globs ok)")
+		context := fs.String("context", "", `on WSL, run in "windows" or "linux" (default)`)
+		coll := fs.String("collection", "", "file it in this collection")
+		slug := fs.String("slug", "", "short name to use instead of the ID, e.g. restart-nginx (default: from the title)")
 		_ = fs.Parse(os.Args[2:])
 
+		if *timeout != "" {
+			if _, err := time.ParseDuration(*timeout); err != nil {
+				exitErr(fmt.Errorf("invalid --timeout: %w", err))
+			}
+		}
+		if c := strings.ToLower(strings.TrimSpace(*context)); c != "" && c != "windows" && c != "linux" {
+			exitErr(errors.New(`--context must be "windows" or "linux"`))
+		}
+
 		if strings.TrimSpace(*title) == "" || strings.TrimSpace(*command) == "" {
-			fmt.Fprintln(os.Stderr, "error: --title and --cmd are required")
-			os.Exit(2)
+			exitErr(errors.New("--title and --cmd are required"))
+		}
+
+		if *slug != "" {
+			if err := checkSlugFree(*slug, 0); err != nil {
+				exitErr(err)
+			}
+		}
+
+		it := Item{
+			Title:   strings.TrimSpace(*title),
+			Slug:    *slug,
+			Command: normalizeCommand(*command),
+			Tags:    parseTags(*tags),
+			Notes:   strings.TrimSpace(*notes),
+			Icon:    strings.TrimSpace(*icon),
+			Workdir: strings.TrimSpace(*workdir),
+			Env:     env,
+			Timeout: *timeout,
+			NoLog:   *noLog,
+			PreRun:  strings.TrimSpace(*preRun),
+			PostRun: strings.TrimSpace(*postRun),
+			Hosts:   parseTags(*hosts),
+			Context: strings.ToLower(strings.TrimSpace(*context)),
+		}
+		if strings.TrimSpace(*coll) != "" {
+			it.Collection = resolveCollection(*coll)
+		}
+		if err := applyTransforms(&it); err != nil {
+			exitErr(err)
+		}
+		note := guardSyncRules(it)
+		created, err := openStore().Create(it)
+		if err != nil {
+			exitErr(err)
+		}
+
+		if created.Slug != "" {
+			fmt.Printf("Saved #%d: %s (as %s)\n", created.ID, created.Title, created.Slug)
+		} else {
+			fmt.Printf("Saved #%d: %s\n", created.ID, created.Title)
+		}
+		if note != "" {
+			fmt.Println(note)
+		}
+
+	case "edit":
+		id, err := requireID(os.Args)
+		if err != nil {
+			exitErr(err)
 		}
+		refuseProjectID(id)
 
-		c := api.New()
+		fs := flag.NewFlagSet("edit", flag.ExitOnError)
+		fs.String("title", "", "new title")
+		fs.String("slug", "", "new slug (the title's, made unique, if empty)")
+		fs.String("cmd", "", "new command")
+		fs.String("tags", "", "comma-separated tags (replaces existing)")
+		fs.String("notes", "", "new notes")
+		fs.String("icon", "", "icon/emoji (empty to clear)")
+		fs.String("workdir", "", "directory to run in (empty to clear)")
+		env := envFlag{}
+		fs.Var(env, "env", "KEY=VALUE for run, repeatable (replaces existing env)")
+		fs.String("timeout", "", "default run timeout, e.g. 10m (empty to clear)")
+		fs.Bool("no-log", false, "never record runs/copies of this item (--no-log=false to undo)")
+		fs.String("pre-run", "", "shell command to run before each run (empty to clear)")
+		fs.String("post-run", "", "shell command to run after each run (empty to clear)")
+		fs.String("hosts", "", "comma-separated hosts allowed for run --host (empty to clear)")
+		fs.String("context", "", `on WSL, run in "windows" or "linux"`)
+		_ = fs.Parse(os.Args[3:])
 
-		var created Item
-		err := c.DoJSON("POST", "/v1/commands", map[string]any{
-			"title":   strings.TrimSpace(*title),
-			"command": strings.TrimSpace(*command),
-			"tags":    parseTags(*tags),
-			"notes":   strings.TrimSpace(*notes),
-		}, &created)
+		// only send what was explicitly passed
+		patch := map[string]any{}
+		fs.Visit(func(f *flag.Flag) {
+			v := strings.TrimSpace(f.Value.String())
+			switch f.Name {
+			case "cmd":
+				patch["command"] = normalizeCommand(v)
+			case "tags":
+				patch["tags"] = parseTags(v)
+			case "env":
+				patch["env"] = map[string]string(env)
+			case "no-log":
+				patch["noLog"] = v == "true"
+			case "pre-run":
+				patch["preRun"] = v
+			case "post-run":
+				patch["postRun"] = v
+			case "hosts":
+				patch["hosts"] = parseTags(v)
+			case "context":
+				patch["context"] = strings.ToLower(v)
+			default:
+				patch[f.Name] = v
+			}
+		})
+		if len(patch) == 0 {
+			exitErr(errors.New("nothing to change"))
+		}
+		if t, ok := patch["title"]; ok && t == "" {
+			exitErr(errors.New("--title cannot be empty"))
+		}
+		if s, ok := patch["slug"].(string); ok {
+			if s == "" {
+				items, _ := openStore().List()
+				title, _ := patch["title"].(string)
+				if title == "" {
+					title = mustGetItem(openStore(), id).Title
+				}
+				patch["slug"] = uniqueSlug(slugSource(title), items, id)
+			} else if err := checkSlugFree(s, id); err != nil {
+				exitErr(err)
+			}
+		}
+		if c, ok := patch["command"]; ok && c == "" {
+			exitErr(errors.New("--cmd cannot be empty"))
+		}
+		if t, ok := patch["timeout"].(string); ok && t != "" {
+			if _, err := time.ParseDuration(t); err != nil {
+				exitErr(fmt.Errorf("invalid --timeout: %w", err))
+			}
+		}
+		if c, ok := patch["context"].(string); ok && c != "" && c != "windows" && c != "linux" {
+			exitErr(errors.New(`--context must be "windows" or "linux"`))
+		}
 
+		st := openStore()
+		note := ""
+		if hasSyncRules() && usingLocalStore() {
+			next := *mustGetItem(st, id)
+			if err := applyPatch(&next, patch); err != nil {
+				exitErr(err)
+			}
+			note = guardSyncRules(next)
+		}
+		updated, err := st.Update(id, patch)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+			if errors.Is(err, errNotFound) {
+				exitWith(exitNotFound, errNotFound)
+			}
+			exitErr(err)
 		}
 
-		fmt.Printf("Saved #%d: %s\n", created.ID, created.Title)
+		fmt.Printf("Updated #%d: %s\n", updated.ID, updated.Title)
+		if note != "" {
+			fmt.Println(note)
+		}
 
 	case "list":
-		c := api.New()
-		var items []Item
-		if err := c.DoJSON("GET", "/v1/commands", nil, &items); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		filter := fs.String("filter", "", "only items a filter script accepts (see: commandref scripts)")
+		format := fs.String("format", "", `"ndjson", "csv" or "tsv" (with --columns), a format script, or a Go template like '{{.ID}}\t{{.Title}}'`)
+		coll := fs.String("collection", "", "only items in this collection")
+		archived := fs.Bool("archived", false, "list the archived items instead")
+		long := fs.Bool("long", false, "print a table (see --columns)")
+		columns := fs.String("columns", "", "columns for --long / --format csv, e.g. id,title,tags,used (default: the list_columns config)")
+		var exclude excludeTagFlag
+		fs.Var(&exclude, "exclude-tag", "leave out items with this tag (repeatable, comma lists ok)")
+		var dates dateFilter
+		dates.register(fs)
+		_ = fs.Parse(os.Args[2:])
+		comma, delimited := delimiter(*format)
+		var cols []string
+		if *long || *columns != "" || delimited {
+			var err error
+			if cols, err = listColumnsFor(*columns); err != nil {
+				exitErr(err)
+			}
 		}
-		if len(items) == 0 {
+
+		if *format == "ndjson" && *filter == "" {
+			// straight through as the items arrive; a --filter script needs
+			// them all first
+			collName := ""
+			if *coll != "" {
+				collName = resolveCollection(*coll)
+			}
+			var extra []Item
+			if *coll == "" && !*archived {
+				extra = projectItems()
+			}
+			_, err := streamNDJSON(os.Stdout, func(it Item) bool {
+				one := byArchived([]Item{it}, *archived)
+				if collName != "" {
+					one = inCollection(one, collName)
+				}
+				return len(dates.apply(excludeItems(one, exclude))) == 1
+			}, extra, false)
+			if err != nil {
+				exitErr(err)
+			}
+			return
+		}
+
+		items, err := openStore().List()
+		if err != nil {
+			exitErr(err)
+		}
+		items = byArchived(items, *archived)
+		if *coll != "" {
+			items = inCollection(items, resolveCollection(*coll))
+		}
+		// stable order by ID (backend already does it, but safe), then the
+		// project's own commands in file order
+		sortByID(items)
+		if *coll == "" && !*archived {
+			items = append(items, projectItems()...)
+		}
+		items = dates.apply(excludeItems(items, exclude))
+		if *filter != "" {
+			if items, err = filterWithScript(*filter, items); err != nil {
+				exitErr(err)
+			}
+		}
+		if len(items) == 0 && !delimited && *format != "ndjson" {
+			if *filter != "" || *coll != "" || len(exclude) > 0 || dates.active() {
+				fmt.Println("(no matches)")
+				return
+			}
+			if *archived {
+				fmt.Println("(no archived items)")
+				return
+			}
 			fmt.Println("(empty) add one with: commandref add --title ... --cmd ...")
 			return
 		}
-		// stable order by ID (backend already does it, but safe)
-		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
 
+		items = displayItems(items)
+		if *format == "ndjson" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetEscapeHTML(false)
+			for _, it := range items {
+				_ = enc.Encode(it)
+			}
+			return
+		}
+		if delimited {
+			if err := writeDelimited(os.Stdout, items, cols, comma); err != nil {
+				exitErr(err)
+			}
+			return
+		}
+		if *format != "" {
+			if err := printWithScript(*format, items); err != nil {
+				exitErr(err)
+			}
+			return
+		}
+		if cols != nil {
+			printLongList(items, cols)
+			return
+		}
 		for _, it := range items {
-			fmt.Printf("\033[32m%d)\033[0m \033[36m%s\033[0m      (\033[33m%s\033[0m)\n", it.ID, it.Command, it.Title)
+			printListItem(it)
 		}
 
+	case "api":
+		runAPI(os.Args[2:])
+
+	case "migrate":
+		runMigrate(os.Args[2:])
+
+	case "merge-store":
+		runMergeStore(os.Args[2:])
+
+	case "scripts":
+		runScripts(os.Args[2:])
+
+	case "collection":
+		runCollection(os.Args[2:])
+
+	case "digest":
+		runDigest(os.Args[2:])
+
+	case "recent":
+		runRecent(os.Args[2:])
+
+	case "suggest":
+		runSuggest(os.Args[2:])
+
+	case "explain":
+		runExplain(os.Args[2:])
+
+	case "encryption":
+		runEncryption(os.Args[2:])
+
+	case "secret":
+		runSecret(os.Args[2:])
+
+	case "copied":
+		runCopied(os.Args[2:])
+
+	case "ai":
+		runAI(os.Args[2:])
+
+	case "ask":
+		runAsk(os.Args[2:])
+
+	case "mv":
+		runMove(os.Args[2:])
+
+	case "archive":
+		runArchive(os.Args[2:], true)
+
+	case "unarchive":
+		runArchive(os.Args[2:], false)
+
+	case "workflow", "playbook":
+		runWorkflow(os.Args[2:])
+
+	case "watch":
+		runWatch(os.Args[2:])
+
+	case "project":
+		runProject(os.Args[2:])
+
+	case "workspace":
+		runWorkspace(os.Args[2:])
+
+	case "daemon":
+		runDaemon(os.Args[2:])
+
+	case "pair":
+		runPair(os.Args[2:])
+
+	case "share":
+		runShare(os.Args[2:])
+
+	case "unshare":
+		runUnshare(os.Args[2:])
+
+	case "publish":
+		runPublish(os.Args[2:])
+
 	case "search":
 		if len(os.Args) < 3 {
-			fmt.Fprintln(os.Stderr, "error: search requires a query")
-			os.Exit(2)
+			exitErr(errors.New("search requires a query"))
 		}
 
-		query := strings.TrimSpace(strings.Join(os.Args[2:], " "))
-		q := url.QueryEscape(query)
-
-		c := api.New()
+		coll, in, archived, refresh, words := "", "", false, false, []string(nil)
+		var exclude excludeTagFlag
+		var dates dateFilter
+		for i := 2; i < len(os.Args); i++ {
+			a := os.Args[i]
+			n, err := dates.parseArg(os.Args, i)
+			if err != nil {
+				exitErr(err)
+			}
+			if n > 0 {
+				i += n - 1
+				continue
+			}
+			switch {
+			case a == "--archived" || a == "-archived":
+				archived = true
+			case a == "--refresh" || a == "-refresh":
+				refresh = true
+			case (a == "--in" || a == "-in") && i+1 < len(os.Args):
+				in = os.Args[i+1]
+				i++
+			case strings.HasPrefix(a, "--in="):
+				in = strings.TrimPrefix(a, "--in=")
+			case (a == "--exclude-tag" || a == "-exclude-tag") && i+1 < len(os.Args):
+				_ = exclude.Set(os.Args[i+1])
+				i++
+			case strings.HasPrefix(a, "--exclude-tag="):
+				_ = exclude.Set(strings.TrimPrefix(a, "--exclude-tag="))
+			case (a == "--collection" || a == "-collection") && i+1 < len(os.Args):
+				coll = os.Args[i+1]
+				i++
+			case strings.HasPrefix(a, "--collection="):
+				coll = strings.TrimPrefix(a, "--collection=")
+			default:
+				words = append(words, a)
+			}
+		}
+		words, negated := splitNegations(words)
+		query := strings.TrimSpace(strings.Join(words, " "))
+		if query == "" && len(negated)+len(exclude) == 0 && !dates.active() {
+			exitErr(errors.New("search requires a query"))
+		}
 
-		var items []Item
-		if err := c.DoJSON("GET", "/v1/commands?q="+q, nil, &items); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+		fields, err := parseSearchFields(in)
+		if err != nil {
+			exitErr(err)
+		}
+		items, err := searchLibrary(query, fields, refresh)
+		if err != nil {
+			exitErr(err)
+		}
+		sortByID(items)
+		items = byArchived(items, archived)
+		if coll != "" {
+			items = inCollection(items, resolveCollection(coll))
+		} else if !archived {
+			items = append(items, filterItemsIn(projectItems(), query, fields)...)
 		}
+		items = dates.apply(excludeItems(items, append(exclude, negated...)))
 
 		if len(items) == 0 {
 			fmt.Println("(no matches)")
+			if query != "" {
+				pool := suggestionPool()
+				if coll != "" {
+					pool = inCollection(pool, resolveCollection(coll))
+				}
+				printDidYouMean(os.Stdout, similarItems(pool, query))
+			}
 			return
 		}
 
-		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
-
-		for _, it := range items {
-			tagStr := ""
-			if len(it.Tags) > 0 {
-				tagStr = " [" + strings.Join(it.Tags, ",") + "]"
-			}
-			fmt.Printf("%d) %s%s\n", it.ID, it.Title, tagStr)
+		for _, it := range displayItems(items) {
+			printSearchItem(it)
 		}
 
 	case "show":
-		id, err := requireID(os.Args)
+		refs, rest := splitIDArgs(os.Args[2:])
+		ids, err := parseViewIDs(refs)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
-		}
-
-		c := api.New()
-
-		var it Item
-
-		if err := c.DoJSON("GET", fmt.Sprintf("/v1/commands/%d", id), nil, &it); err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				fmt.Fprintln(os.Stderr, "not found")
-				os.Exit(3)
+			reportErr(exitError, err)
+			if len(os.Args) > 2 && !strings.HasPrefix(os.Args[2], "-") && opts.ErrorFormat != "json" {
+				// `show nginx`: maybe a title was meant
+				printDidYouMean(os.Stderr, similarItems(suggestionPool(), os.Args[2]))
 			}
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+			os.Exit(exitError)
 		}
+		fs := flag.NewFlagSet("show", flag.ExitOnError)
+		showOutput := fs.Bool("output", false, "print the last output captured with run --capture")
+		_ = fs.Parse(rest)
 
-		fmt.Printf("#%d %s\n", it.ID, it.Title)
-		if len(it.Tags) > 0 {
-			fmt.Printf("Tags: %s\n", strings.Join(it.Tags, ", "))
-		}
-		if it.Notes != "" {
-			fmt.Printf("Notes: %s\n", it.Notes)
+		if len(ids) > 1 {
+			st := openStore()
+			var mu sync.Mutex
+			got := map[int]*Item{}
+			code := forEachID(ids, func(id int) (string, error) {
+				it, err := getItem(st, id)
+				mu.Lock()
+				got[id] = it
+				mu.Unlock()
+				return "", err
+			})
+			first := true
+			for _, id := range ids {
+				if got[id] == nil {
+					continue
+				}
+				if !first {
+					fmt.Println()
+				}
+				first = false
+				printItem(got[id], *showOutput)
+			}
+			if code != 0 {
+				os.Exit(code)
+			}
+			return
 		}
-		fmt.Printf("Command:\n%s\n", it.Command)
+		printItem(mustGetItem(openStore(), ids[0]), *showOutput)
 
 	case "copy":
-		id, err := requireID(os.Args)
+		refs, rest := splitIDArgs(os.Args[2:])
+		ids, err := parseViewIDs(refs)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+			exitErr(err)
 		}
 
-		c := api.New()
-		var it Item
+		fs := flag.NewFlagSet("copy", flag.ExitOnError)
+		toStdout := fs.Bool("stdout", false, "print the raw command to stdout instead of the clipboard")
+		_ = fs.Parse(rest)
 
-		if err := c.DoJSON("GET", fmt.Sprintf("/v1/commands/%d", id), nil, &it); err != nil {
-			if strings.Contains(strings.ToLower(err.Error()), "not found") {
-				fmt.Fprintln(os.Stderr, "not found")
-				os.Exit(3)
+		// several items are copied as one text, a command per line
+		st := openStore()
+		var items []*Item
+		var texts []string
+		for _, id := range ids {
+			it := mustGetItem(st, id)
+			text, err := resolveSecrets(it.Command)
+			if err != nil {
+				exitErr(err)
 			}
-			fmt.Fprintf(os.Stderr, "error:", err)
-			os.Exit(2)
+			items = append(items, it)
+			texts = append(texts, text)
 		}
+		text := strings.Join(texts, "\n")
 
-		if err := pbcopy(it.Command); err != nil {
-			fmt.Fprintln(os.Stderr, "error copying:", err)
-			os.Exit(4)
+		where := "clipboard"
+		if *toStdout {
+			where = "stdout"
+			fmt.Print(text)
+		} else if err := copyToClipboard(text); err != nil {
+			exitWith(exitClipboard, fmt.Errorf("copying: %w", err))
+		}
+		for i, it := range items {
+			recordUsage(it, "copy")
+			recordCopy(it, texts[i], where)
+		}
+		switch {
+		case *toStdout:
+		case len(items) == 1:
+			fmt.Printf("Copied #%s to clipboard\n", displayID(*items[0]))
+		default:
+			fmt.Printf("Copied %d commands to clipboard\n", len(items))
 		}
-		fmt.Printf("Copied #%d to clipboard\n", it.ID)
 
 	case "run":
-		id, err := requireID(os.Args)
+		runItem(os.Args[2:])
+
+	case "rm":
+		refs, rest := splitIDArgs(os.Args[2:])
+		fs := flag.NewFlagSet("rm", flag.ExitOnError)
+		interactive := fs.Bool("i", false, "mark the items to remove in a picker (optionally filtered by the remaining words)")
+		_ = fs.Parse(rest)
+		var ids []int
+		var err error
+		if *interactive {
+			ids, err = pickIDs(strings.Join(fs.Args(), " "), nil)
+		} else {
+			ids, err = parseTargetIDs(refs)
+		}
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+			exitErr(err)
+		}
+		if len(ids) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing marked")
+			return
+		}
+		if len(ids) == 1 && !*interactive {
+			refuseProjectID(ids[0])
+		} else if !confirm(fmt.Sprintf("Remove %s?", plural(len(ids), "item"))) {
+			fmt.Fprintln(os.Stderr, "Nothing removed")
+			os.Exit(1)
 		}
 
-		c := api.New()
-		var it Item
-
-		if err := c.DoJSON("GET", fmt.Sprintf("/v1/commands/%d", id), nil, &it); err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				fmt.Fprintln(os.Stderr, "not found")
-				os.Exit(3)
+		st := openStore()
+		if code := forEachID(ids, func(id int) (string, error) {
+			if id < 0 {
+				return "", fmt.Errorf("a project command; edit it in %s", projectFileName)
+			}
+			if err := st.Delete(id); err != nil {
+				return "", err
 			}
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
+			return fmt.Sprintf("Removed #%d", id), nil
+		}); code != 0 {
+			os.Exit(code)
 		}
 
-		// Use login shell so user's PATH etc works.
-		cmdExec := exec.Command("/bin/zsh", "-lc", it.Command)
-		cmdExec.Stdout = os.Stdout
-		cmdExec.Stderr = os.Stderr
-		cmdExec.Stdin = os.Stdin
+	case "export":
+		runExport(os.Args[2:])
 
-		if err := cmdExec.Run(); err != nil {
-			// return underlying exit code if any
-			var ee *exec.ExitError
-			if errors.As(err, &ee) {
-				os.Exit(ee.ExitCode())
-			}
-			fmt.Fprintln(os.Stderr, "run error:", err)
-			os.Exit(5)
-		}
+	case "stats":
+		runStats(os.Args[2:])
 
-	case "rm":
-		id, err := requireID(os.Args)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
-		}
+	case "tags":
+		runTags(os.Args[2:])
 
-		c := api.New()
+	case "tag":
+		runTag(os.Args[2:])
 
-		if err := c.DoJSON("DELETE", fmt.Sprintf("/v1/commands/%d", id), nil, nil); err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				fmt.Fprintln(os.Stderr, "not found")
-				os.Exit(3)
-			}
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
-		}
+	case "slugs":
+		runSlugs(os.Args[2:])
+
+	case "test":
+		runTest(os.Args[2:])
+
+	case "runs":
+		runRuns(os.Args[2:])
+
+	case "jobs":
+		runJobs(os.Args[2:])
+
+	case "logs":
+		runLogs(os.Args[2:])
 
-		fmt.Printf("Removed #%d\n", id)
+	case "kill":
+		runKill(os.Args[2:])
+
+	case "__job":
+		runJobWrapper(os.Args[2:])
+		return
+
+	case "alias":
+		runAlias(os.Args[2:])
+
+	case "import":
+		runImport(os.Args[2:])
+
+	case "keys":
+		runKeys(os.Args[2:])
+
+	case "sync":
+		runSync(os.Args[2:])
+
+	case "pick":
+		runPick(os.Args[2:])
+
+	case "completion":
+		runCompletion(os.Args[2:])
+	case "__complete":
+		runComplete(os.Args[2:])
+	case "__refresh":
+		runRefresh()
+
+	case "widget":
+		runWidget(os.Args[2:])
 
 	default:
 		usage()
 		os.Exit(1)
 	}
+
+	maybeNotifyUpdate()
+}
+
+// printItem is `show`: the item's details and command, or with output its
+// last captured output.
+func printItem(it *Item, output bool) {
+	if output {
+		rec, err := loadCapturedOutput(it.ID)
+		if err != nil {
+			exitErr(err)
+		}
+		if rec == nil {
+			fmt.Printf("No captured output for #%s. Run: commandref run %s --capture\n", displayID(*it), displayID(*it))
+			return
+		}
+		note := ""
+		if rec.Truncated {
+			note = ", truncated to the last part"
+		}
+		fmt.Printf("Last output of #%s (%s, exit %d%s):\n", displayID(*it), formatTime(rec.CapturedAt), rec.ExitCode, note)
+		fmt.Print(rec.Output)
+		return
+	}
+
+	shown := displayItem(*it)
+	it = &shown
+	fmt.Printf("#%s %s%s\n", displayID(*it), iconPrefix(*it), it.Title)
+	if isProjectItem(*it) {
+		fmt.Printf("Project: %s\n", findProjectFile())
+	} else if displayID(*it) != strconv.Itoa(it.ID) {
+		fmt.Printf("Number: %d (in this store)\n", it.ID)
+	}
+	if it.Slug != "" {
+		fmt.Printf("Slug: %s\n", it.Slug)
+	}
+	if it.Archived {
+		fmt.Println("Archived: yes (commandref unarchive to restore it)")
+	}
+	if it.Collection != "" {
+		fmt.Printf("Collection: %s\n", it.Collection)
+	}
+	if len(it.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(it.Tags, ", "))
+	}
+	if it.Notes != "" {
+		fmt.Printf("Notes: %s\n", it.Notes)
+	}
+	if it.Workdir != "" {
+		fmt.Printf("Workdir: %s\n", it.Workdir)
+	}
+	if len(it.Env) > 0 {
+		fmt.Printf("Env: %s\n", envFlag(it.Env).String())
+	}
+	if it.Timeout != "" {
+		fmt.Printf("Timeout: %s\n", it.Timeout)
+	}
+	if it.PreRun != "" {
+		fmt.Printf("Pre-run: %s\n", it.PreRun)
+	}
+	if it.PostRun != "" {
+		fmt.Printf("Post-run: %s\n", it.PostRun)
+	}
+	if it.Context != "" {
+		fmt.Printf("Context: %s\n", it.Context)
+	}
+	if len(it.Hosts) > 0 {
+		fmt.Printf("Hosts: %s\n", strings.Join(it.Hosts, ", "))
+	}
+	if it.NoLog {
+		fmt.Println("Logging: off (runs and copies are not recorded)")
+	}
+	if it.CreatedAt != "" {
+		fmt.Printf("Created: %s\n", formatTime(it.CreatedAt))
+	}
+	if it.UpdatedAt != "" && it.UpdatedAt != it.CreatedAt {
+		fmt.Printf("Updated: %s\n", formatTime(it.UpdatedAt))
+	}
+	if rs := runStatsFor(it.ID); rs.count > 0 {
+		status := colorize("32", "ok")
+		if rs.last.ExitCode != 0 {
+			status = colorize("31", fmt.Sprintf("failed, exit %d", rs.last.ExitCode))
+		}
+		fmt.Printf("Runs: %d, last %s (%s), avg %s\n", rs.count, formatTime(rs.last.StartedAt), status, rs.avg.Round(time.Millisecond))
+	}
+	fmt.Printf("Command:\n%s\n", it.Command)
 }
 
 func requireID(args []string) (int, error) {
 	if len(args) < 3 {
 		return 0, fmt.Errorf("missing <id>")
 	}
-	id, err := strconv.Atoi(args[2])
-	if err != nil || id <= 0 {
-		return 0, fmt.Errorf("invalid id: %s", args[2])
-	}
-	return id, nil
+	return parseTargetID(args[2])
+}
+
+// parseID reads an item reference: a number, old:<n> for an ID from before
+// `migrate`, p<n> for a project command, a slug or a UUID prefix. Only show,
+// run and copy also take a bare old number (see parseViewID).
+func parseID(s string) (int, error) {
+	return parseItemRef(s, refCached)
+}
+
+// parseViewID is parseID for show, run and copy, which follow an old ID
+// from before `migrate` to the item's new one (resolveLegacyID).
+func parseViewID(s string) (int, error) {
+	return parseItemRef(s, refLegacy|refCached)
+}
+
+// parseTargetID is parseID for commands that change the item: a slug or
+// UUID prefix is looked up in the store, never only in the cache.
+func parseTargetID(s string) (int, error) {
+	return parseItemRef(s, 0)
 }
 
-func pbcopy(text string) error {
-	// macOS only; later we’ll make Linux fallback (xclip/wl-copy)
-	cmd := exec.Command("pbcopy")
-	cmd.Stdin = strings.NewReader(text)
-	return cmd.Run()
+// How parseItemRef reads a reference.
+const (
+	refLegacy = 1 << iota // a number may be an old ID from before `migrate`
+	refCached             // a slug or UUID prefix may be found in the cache
+)
+
+// parseItemRef is parseID, as the refLegacy and refCached bits in how say.
+func parseItemRef(s string, how int) (int, error) {
+	if n, ok := strings.CutPrefix(s, legacyIDPrefix); ok {
+		old, err := strconv.Atoi(n)
+		if err != nil || old <= 0 {
+			return 0, fmt.Errorf("invalid id: %s", s)
+		}
+		if id, ok := legacyID(old); ok {
+			return id, nil
+		}
+		return 0, fmt.Errorf("%w: no item had #%d before a migrate (or the grace period is over)", errNotFound, old)
+	}
+	if n, ok := strings.CutPrefix(s, "p"); ok && n != "" && strings.Trim(n, "0123456789") == "" {
+		// project command (see project.go), kept as a negative ID
+		id, err := strconv.Atoi(n)
+		if err != nil || id <= 0 {
+			return 0, fmt.Errorf("invalid id: %s", s)
+		}
+		return -id, nil
+	}
+	if cfg.IDDisplay == "uuid" && len(s) >= shortUUIDLen {
+		if id, err := lookupItemRef(s, how&refCached != 0); err == nil {
+			return id, nil
+		}
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return lookupItemRef(s, how&refCached != 0)
+	}
+	if id <= 0 {
+		return 0, fmt.Errorf("invalid id: %s", s)
+	}
+	if how&refLegacy != 0 {
+		id = resolveLegacyID(id)
+	}
+	return id, nil
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// itemFileName is the Markdown file name for an item: the title slug, with
+// the ID added when two titles slug the same.
+func itemFileName(it Item, taken map[string]bool) string {
+	slug := slugify(it.Title)
+	if slug == "" {
+		slug = "command"
+	}
+	name := slug
+	if taken[name] {
+		name = slug + "-" + strconv.Itoa(it.ID)
+	}
+	taken[name] = true
+	return name + ".md"
+}
+
+// yamlString quotes s for YAML front matter. JSON string syntax is valid
+// YAML, so strconv.Quote covers escaping.
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
+
+// itemMarkdown renders an item as a note: YAML front matter Obsidian and
+// Notion understand, the command in a fenced block, then the notes.
+func itemMarkdown(it Item, slug string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", yamlString(it.Title))
+	tags := make([]string, len(it.Tags))
+	for i, t := range it.Tags {
+		tags[i] = yamlString(t)
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(tags, ", "))
+	if it.CreatedAt != "" {
+		fmt.Fprintf(&b, "created: %s\n", it.CreatedAt)
+	}
+	if it.UpdatedAt != "" {
+		fmt.Fprintf(&b, "updated: %s\n", it.UpdatedAt)
+	}
+	fmt.Fprintf(&b, "slug: %s\n", slug)
+	fmt.Fprintf(&b, "commandref_id: %d\n", it.ID)
+	if it.UUID != "" {
+		fmt.Fprintf(&b, "commandref_uuid: %s\n", it.UUID)
+	}
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "# %s\n\n", it.Title)
+	fence := "```"
+	for strings.Contains(it.Command, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(&b, "%ssh\n%s\n%s\n", fence, it.Command, fence)
+	if it.Notes != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(it.Notes))
+	}
+	return b.String()
+}
+
+// writeMarkdownExport writes one .md file per item into dir, ready to drop
+// into an Obsidian vault or import into Notion.
+func writeMarkdownExport(dir string, items []Item) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	sortByID(items)
+	taken := map[string]bool{}
+	for _, it := range items {
+		name := itemFileName(it, taken)
+		p := filepath.Join(dir, name)
+		if err := writeFileAtomic(p, []byte(itemMarkdown(it, strings.TrimSuffix(name, ".md"))), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// `merge-store other/commands.json` folds another machine's local library
+// into this one. Items are matched by UUID, then by command, then by being
+// nearly the same; whatever differs is shown for a decision before
+// anything is written.
+
+type storeMatch struct {
+	theirs Item
+	mine   int    // index in the local items, -1 if none
+	kind   string // new, same, edited, duplicate or similar
+	choice string // mine, theirs or both
+}
+
+var matchKinds = map[string]string{
+	"edited":    "the same item, edited on both machines",
+	"duplicate": "the same command, saved separately",
+	"similar":   "a similar command",
+}
+
+func runMergeStore(args []string) {
+	fs := flag.NewFlagSet("merge-store", flag.ExitOnError)
+	prefer := fs.String("prefer", "", "settle every conflict without asking: mine, theirs or both")
+	dryRun := fs.Bool("dry-run", false, "only show what would be merged")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		exitErr(errors.New("usage: commandref merge-store [--prefer mine|theirs|both] [--dry-run] <other commands.json>"))
+	}
+	switch *prefer {
+	case "", "mine", "theirs", "both":
+	default:
+		exitErr(errors.New("--prefer must be mine, theirs or both"))
+	}
+	if !usingLocalStore() {
+		exitErr(errors.New(`merge-store merges into a library on this machine (storage "local" or "git")`))
+	}
+	path := fs.Arg(0)
+	other, err := readOtherStore(path)
+	if err != nil {
+		exitErr(err)
+	}
+	db, err := loadDB()
+	if err != nil {
+		exitErr(err)
+	}
+	if same, _ := dbPath(); sameFile(same, path) {
+		exitErr(errors.New("that is this library"))
+	}
+	mergeItems(&db, nil) // every local item needs a UUID
+
+	matches := matchStores(db.Items, other.Items)
+	counts := map[string]int{}
+	var conflicts []*storeMatch
+	for i := range matches {
+		m := &matches[i]
+		counts[m.kind]++
+		switch m.kind {
+		case "new":
+			m.choice = "both"
+		case "same":
+			m.choice = "mine"
+		default:
+			conflicts = append(conflicts, m)
+		}
+	}
+	fmt.Printf("%s: %d items; %d new, %d already here, %s to review\n",
+		path, len(other.Items), counts["new"], counts["same"], plural(len(conflicts), "conflict"))
+
+	if *dryRun {
+		for _, m := range conflicts {
+			fmt.Printf("  %s: %s\n", m.theirs.Title, matchKinds[m.kind])
+		}
+		return
+	}
+	if len(conflicts) > 0 && *prefer == "" && !canPrompt() {
+		exitErr(errors.New("conflicts need a review: run this in a terminal, or pass --prefer mine|theirs|both"))
+	}
+	all := *prefer
+	for n, m := range conflicts {
+		if all != "" {
+			m.choice = all
+			continue
+		}
+		fmt.Println()
+		fmt.Printf("Conflict %d of %d: %s\n", n+1, len(conflicts), matchKinds[m.kind])
+		printConflict(db.Items[m.mine], m.theirs)
+		for m.choice == "" {
+			answer, err := readLine("Keep [m]ine, take [t]heirs, keep [b]oth (M/T/B: this and all the rest, q: quit)? ")
+			if err != nil {
+				os.Exit(1)
+			}
+			a := strings.TrimSpace(answer)
+			switch strings.ToLower(a) {
+			case "m", "mine":
+				m.choice = "mine"
+			case "t", "theirs":
+				m.choice = "theirs"
+			case "b", "both":
+				m.choice = "both"
+			case "q", "quit":
+				fmt.Println("Nothing merged.")
+				os.Exit(1)
+			}
+			if a == "M" || a == "T" || a == "B" {
+				all = m.choice
+			}
+		}
+	}
+
+	added, updated := applyStoreMerge(&db, matches, other.Collections)
+	if err := saveDB(db); err != nil {
+		exitErr(err)
+	}
+	if usingGitStore() {
+		if err := gitCommit("Merge " + filepath.Base(path)); err != nil {
+			exitErr(err)
+		}
+	}
+	fmt.Printf("Merged: %d added, %d updated, %d unchanged\n", added, updated, len(matches)-added-updated)
+}
+
+// readOtherStore reads a commands.json, or a plain array of items.
+func readOtherStore(path string) (DB, error) {
+	var db DB
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return db, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "[") {
+		err = json.Unmarshal(b, &db.Items)
+	} else {
+		err = json.Unmarshal(b, &db)
+	}
+	if err != nil {
+		return db, fmt.Errorf("%s: not a commandref library: %w", path, err)
+	}
+	return db, nil
+}
+
+func sameFile(a, b string) bool {
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(sa, sb)
+}
+
+// matchStores pairs each of their items with one of mine.
+func matchStores(mine, theirs []Item) []storeMatch {
+	byUUID := map[string]int{}
+	byCommand := map[string]int{}
+	for i, it := range mine {
+		byUUID[it.UUID] = i
+		if _, ok := byCommand[commandKey(it.Command)]; !ok {
+			byCommand[commandKey(it.Command)] = i
+		}
+	}
+	var out []storeMatch
+	for _, t := range theirs {
+		m := storeMatch{theirs: t, mine: -1, kind: "new"}
+		if i, ok := byUUID[t.UUID]; ok && t.UUID != "" {
+			m.mine, m.kind = i, "edited"
+		} else if i, ok := byCommand[commandKey(t.Command)]; ok {
+			m.mine, m.kind = i, "duplicate"
+		} else if i := mostSimilar(mine, t); i >= 0 {
+			m.mine, m.kind = i, "similar"
+		}
+		if m.mine >= 0 && sameContent(mine[m.mine], t) {
+			m.kind = "same"
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func commandKey(cmd string) string {
+	return strings.Join(strings.Fields(cmd), " ")
+}
+
+// mostSimilar finds the item with the same title, or a command at most a
+// few edits away from t's (-1 if none).
+func mostSimilar(mine []Item, t Item) int {
+	best, bestDist := -1, 0
+	tc := commandKey(t.Command)
+	for i, it := range mine {
+		if strings.EqualFold(strings.TrimSpace(it.Title), strings.TrimSpace(t.Title)) {
+			return i
+		}
+		c := commandKey(it.Command)
+		if abs(len(c)-len(tc)) > 8 {
+			continue
+		}
+		d := editDistance(c, tc)
+		if d <= max(2, len(tc)/10) && (best < 0 || d < bestDist) {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// sameContent compares what a user edits, not IDs or timestamps.
+func sameContent(a, b Item) bool {
+	norm := func(it Item) map[string]any {
+		tags := append([]string(nil), it.Tags...)
+		sort.Strings(tags)
+		it.Tags, it.Command = tags, commandKey(it.Command)
+		p := itemPayload(it)
+		for k, v := range p {
+			if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.Len() == 0 {
+				p[k] = nil
+			}
+		}
+		return p
+	}
+	return reflect.DeepEqual(norm(a), norm(b))
+}
+
+func printConflict(mine, theirs Item) {
+	row := func(label, m, t string) {
+		if m == t {
+			return
+		}
+		fmt.Printf("  %s\n    mine:   %s\n    theirs: %s\n", label, m, t)
+	}
+	fmt.Printf("  mine #%d %s, updated %s; theirs updated %s\n", mine.ID, mine.Title, formatTime(mine.UpdatedAt), formatTime(theirs.UpdatedAt))
+	row("title", mine.Title, theirs.Title)
+	row("command", mine.Command, theirs.Command)
+	row("tags", strings.Join(mine.Tags, ","), strings.Join(theirs.Tags, ","))
+	row("notes", mine.Notes, theirs.Notes)
+	row("collection", mine.Collection, theirs.Collection)
+	m, t := mine, theirs
+	m.Title, m.Command, m.Tags, m.Notes, m.Collection = "", "", nil, "", ""
+	t.Title, t.Command, t.Tags, t.Notes, t.Collection = "", "", nil, "", ""
+	if !sameContent(m, t) {
+		fmt.Println("  (run settings differ too)")
+	}
+}
+
+// applyStoreMerge writes the decisions into db.
+func applyStoreMerge(db *DB, matches []storeMatch, cols []collection) (added, updated int) {
+	uuids := map[string]bool{}
+	for _, it := range db.Items {
+		uuids[it.UUID] = true
+	}
+	for _, m := range matches {
+		t := m.theirs
+		switch m.choice {
+		case "theirs":
+			mine := db.Items[m.mine]
+			t.ID, t.UUID = mine.ID, mine.UUID
+			if t.CreatedAt == "" || newerTimestamp(t.CreatedAt, mine.CreatedAt) {
+				t.CreatedAt = mine.CreatedAt
+			}
+			db.Items[m.mine] = t
+			updated++
+		case "both":
+			t.ID = db.NextID
+			db.NextID++
+			if t.UUID == "" || uuids[t.UUID] {
+				t.UUID = newUUID()
+			}
+			if t.Tags == nil {
+				t.Tags = []string{}
+			}
+			uuids[t.UUID] = true
+			db.Items = append(db.Items, t)
+			added++
+		}
+	}
+	for _, c := range cols {
+		if findCollection(db.Collections, c.Name) == nil {
+			c.ID = len(db.Collections) + 1
+			db.Collections = append(db.Collections, c)
+		}
+	}
+	return added, updated
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"commandref/config"
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// idMap records how item IDs changed when the library moved to another
+// store, so the old numbers keep working in show, run and copy for a while.
+type idMap struct {
+	From       string             `json:"from"`
+	To         string             `json:"to"`
+	MigratedAt string             `json:"migratedAt"`
+	Until      string             `json:"until"`
+	IDs        map[string]movedID `json:"ids"` // old ID -> new
+}
+
+type movedID struct {
+	ID   int    `json:"id"`
+	UUID string `json:"uuid,omitempty"`
+}
+
+func idMapPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "id-map.json"), nil
+}
+
+func storageName() string {
+	if cfg == nil || cfg.Storage == "" {
+		return "api"
+	}
+	return cfg.Storage
+}
+
+// legacyIDPrefix marks a number as an ID from before `migrate`: old:12.
+const legacyIDPrefix = "old:"
+
+// activeIDMap is id-map.json while it applies: we're using the store the
+// library moved to and the grace period lasts.
+func activeIDMap() *idMap {
+	p, err := idMapPath()
+	if err != nil {
+		return nil
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	var m idMap
+	if json.Unmarshal(b, &m) != nil || m.To != storageName() {
+		return nil
+	}
+	if until, err := time.Parse(time.RFC3339, m.Until); err != nil || time.Now().After(until) {
+		return nil
+	}
+	return &m
+}
+
+// legacyID is the new ID of the item that was id before `migrate`.
+func legacyID(id int) (int, bool) {
+	m := activeIDMap()
+	if m == nil {
+		return 0, false
+	}
+	moved, ok := m.IDs[strconv.Itoa(id)]
+	return moved.ID, ok
+}
+
+// resolveLegacyID maps an ID from before `migrate` onto the item's new ID.
+// A number that is also a current item's ID means that item, with a
+// warning; old:<n> always means the old one. Only show, run and copy call
+// it (parseViewID); the store, not the cache, says which numbers are taken.
+func resolveLegacyID(id int) int {
+	m := activeIDMap()
+	if id <= 0 || m == nil {
+		return id
+	}
+	moved, ok := m.IDs[strconv.Itoa(id)]
+	if !ok || moved.ID == id {
+		return id
+	}
+	if _, err := openStore().Get(id); err == nil {
+		fmt.Fprintf(os.Stderr, "warning: #%d is also the old ID of what is now #%d; taking the current #%d (for the other: %s%d)\n", id, moved.ID, id, legacyIDPrefix, id)
+		return id
+	} else if !errors.Is(err, errNotFound) {
+		return id // the store can't say; the command reports why
+	}
+	fmt.Fprintf(os.Stderr, "(#%d is #%d since the move to %s; old IDs work until %s)\n", id, moved.ID, m.To, formatTime(m.Until))
+	return moved.ID
+}
+
+// runMigrate is `commandref migrate --to api|local|git`: it copies the
+// library from the configured store into another one. File stores keep
+// IDs as they are; the backend assigns new ones, recorded in id-map.json.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := fs.String("to", "", `target store: "api", "local" or "git"`)
+	grace := fs.String("grace", "90d", "how long old IDs keep working")
+	force := fs.Bool("force", false, "copy even if the target already has items")
+	forget := fs.Bool("forget", false, "stop resolving old IDs now")
+	_ = fs.Parse(args)
+
+	if *forget {
+		p, err := idMapPath()
+		if err == nil {
+			err = os.Remove(p)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			exitErr(err)
+		}
+		fmt.Println("Old IDs are no longer resolved.")
+		return
+	}
+
+	from := storageName()
+	if *to != "api" && *to != "local" && *to != "git" {
+		exitErr(errors.New(`--to must be "api", "local" or "git"`))
+	}
+	if *to == from {
+		exitErr(fmt.Errorf("the library is already in %s storage", from))
+	}
+	graceFor, err := parseExpiry(*grace)
+	if err != nil {
+		exitErr(err)
+	}
+
+	items, err := openStore().List()
+	if err != nil {
+		exitErr(err)
+	}
+	sortByID(items)
+	cols, err := listCollections()
+	if err != nil {
+		exitErr(err)
+	}
+
+	// from here on the store helpers work on the target
+	cfg.Storage = *to
+	target := openStore()
+	existing, err := target.List()
+	if err != nil {
+		exitErr(err)
+	}
+	if len(existing) > 0 && !*force {
+		exitErr(fmt.Errorf("%s storage already has %d items; use --force to add to them", *to, len(existing)))
+	}
+
+	var moved map[string]movedID
+	var skipped int
+	if usingLocalStore() {
+		moved, err = migrateToFile(items, cols, from)
+	} else {
+		moved, skipped, err = migrateToAPI(items, cols)
+	}
+	if err != nil {
+		code := exitCodeOf(err)
+		reportErr(code, err)
+		if len(moved) > 0 {
+			fmt.Fprintf(os.Stderr, "%s were copied before that; remove them from %s storage before trying again\n", plural(len(moved), "item"), *to)
+		}
+		os.Exit(code)
+	}
+
+	fmt.Printf("Copied %d items from %s to %s storage.\n", len(moved), from, *to)
+	if skipped > 0 {
+		fmt.Printf("Left %s out: the sync rules keep them off the backend.\n", plural(skipped, "item"))
+	}
+	changed := 0
+	for old, m := range moved {
+		if strconv.Itoa(m.ID) != old {
+			changed++
+		}
+	}
+	if changed > 0 {
+		now := time.Now().UTC()
+		m := idMap{
+			From:       from,
+			To:         *to,
+			MigratedAt: now.Format(time.RFC3339),
+			Until:      now.Add(graceFor).Format(time.RFC3339),
+			IDs:        moved,
+		}
+		p, err := idMapPath()
+		if err == nil {
+			var b []byte
+			if b, err = json.MarshalIndent(m, "", "  "); err == nil {
+				err = writeFileAtomic(p, b, 0600)
+			}
+		}
+		if err != nil {
+			exitErr(fmt.Errorf("saving the ID map: %w", err))
+		}
+		fmt.Printf("%s got new IDs; show, run and copy accept the old ones until %s (see %s).\n", plural(changed, "item"), formatTime(m.Until), p)
+	}
+	if p, err := config.Path(); err == nil {
+		fmt.Printf("Switch over by setting \"storage\": %q in %s.\n", *to, p)
+	}
+}
+
+// migrateToFile writes the items into the local (or git) library, keeping
+// their IDs and UUIDs unless an ID is already taken there.
+func migrateToFile(items []Item, cols []collection, from string) (map[string]movedID, error) {
+	db, err := loadDB()
+	if err != nil {
+		return nil, err
+	}
+	taken := map[int]bool{}
+	for _, it := range db.Items {
+		taken[it.ID] = true
+		if it.ID >= db.NextID {
+			db.NextID = it.ID + 1
+		}
+	}
+	for _, it := range items {
+		if it.ID >= db.NextID {
+			db.NextID = it.ID + 1
+		}
+	}
+	moved := map[string]movedID{}
+	for _, it := range items {
+		old := it.ID
+		if taken[it.ID] {
+			it.ID = db.NextID
+			db.NextID++
+		}
+		if it.UUID == "" {
+			it.UUID = newUUID()
+		}
+		if it.Tags == nil {
+			it.Tags = []string{}
+		}
+		taken[it.ID] = true
+		db.Items = append(db.Items, it)
+		moved[strconv.Itoa(old)] = movedID{it.ID, it.UUID}
+	}
+	for _, c := range cols {
+		if findCollection(db.Collections, c.Name) == nil {
+			c.ID = len(db.Collections) + 1
+			db.Collections = append(db.Collections, c)
+		}
+	}
+	sortByID(db.Items)
+	if err := saveDB(db); err != nil {
+		return nil, err
+	}
+	if usingGitStore() {
+		return moved, gitCommit("Migrate from " + from)
+	}
+	return moved, nil
+}
+
+// migrateToAPI creates the items on the backend, which numbers them anew.
+// Items the sync rules keep local are not sent.
+func migrateToAPI(items []Item, cols []collection) (map[string]movedID, int, error) {
+	have, err := listCollections()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, c := range cols {
+		if findCollection(have, c.Name) == nil {
+			if _, err := createCollection(c.Name); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	st := openStore()
+	moved := map[string]movedID{}
+	skipped := 0
+	for _, it := range items {
+		if why, err := syncBlocked(it); err != nil {
+			return nil, 0, err
+		} else if why != "" {
+			skipped++
+			continue
+		}
+		created, err := st.Create(it)
+		if err != nil {
+			return moved, skipped, fmt.Errorf("#%d %s: %w", it.ID, it.Title, err)
+		}
+		moved[strconv.Itoa(it.ID)] = movedID{created.ID, created.UUID}
+	}
+	return moved, skipped, nil
+}
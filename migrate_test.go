@@ -0,0 +1,65 @@
+package main
+
+import (
+	"commandref/config"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResolveLegacyID(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COMMANDREF_HOME", home)
+	cfg = &config.Config{Storage: "local"}
+	for range 11 {
+		if _, err := (localStore{}).Create(Item{Title: "x", Command: "true"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeIDMap := func(until time.Time) {
+		m := idMap{From: "api", To: "local", Until: until.UTC().Format(time.RFC3339), IDs: map[string]movedID{
+			"3":  {ID: 10}, // 3 is a current item too
+			"20": {ID: 11},
+		}}
+		b, _ := json.Marshal(m)
+		if err := os.WriteFile(filepath.Join(home, "id-map.json"), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		refs    []string
+		how     int
+		expired bool
+		want    []int
+		wantErr bool
+	}{
+		{"old ID", []string{"20"}, refLegacy, false, []int{11}, false},
+		{"current ID wins", []string{"3"}, refLegacy, false, []int{3}, false},
+		{"old: prefix", []string{"old:3"}, refLegacy, false, []int{10}, false},
+		{"old: prefix, not an old ID", []string{"old:4"}, refLegacy, false, nil, true},
+		{"range", []string{"19-20"}, refLegacy, false, []int{19, 11}, false},
+		{"picker IDs", []string{"20"}, 0, false, []int{20}, false},
+		{"not show, run or copy", []string{"20"}, refCached, false, []int{20}, false},
+		{"not show, run or copy, old: prefix", []string{"old:20"}, refCached, false, []int{11}, false},
+		{"grace period over", []string{"20"}, refLegacy, true, []int{20}, false},
+		{"old: prefix after the grace period", []string{"old:20"}, refLegacy, true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until := time.Now().Add(time.Hour)
+			if tt.expired {
+				until = time.Now().Add(-time.Hour)
+			}
+			writeIDMap(until)
+			got, err := parseIDRefs(tt.refs, tt.how)
+			if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+				t.Errorf("parseIDRefs(%q) = %v, %v; want %v (error: %v)", tt.refs, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// NDJSON output: one item per line, written as the items come in rather
+// than after the whole library is loaded, so `list --format ndjson | grep`
+// starts at once and stays small however many items there are.
+
+// eachItem calls each with every item in the store. From the API they are
+// decoded one at a time off the response; offline, or with local storage,
+// it walks the usual list.
+func eachItem(each func(Item) error) error {
+	st := openStore()
+	s, ok := st.(apiStore)
+	if ok && s.reachable() == nil {
+		started := false
+		err := apiErr(s.c.EachJSON(s.c.Path("/v1/commands"), func(raw json.RawMessage) error {
+			var it Item
+			if err := json.Unmarshal(raw, &it); err != nil {
+				return err
+			}
+			started = true
+			decryptItem(&it)
+			return each(it)
+		}))
+		noteAPIResult(err)
+		if err == nil || started {
+			return err
+		}
+	}
+	items, err := st.List()
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if err := each(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamNDJSON writes the items keep accepts, then the extra ones, as
+// NDJSON; masked for display unless raw.
+func streamNDJSON(w io.Writer, keep func(Item) bool, extra []Item, raw bool) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	n := 0
+	emit := func(it Item) error {
+		if keep != nil && !keep(it) {
+			return nil
+		}
+		if !raw {
+			it = displayItem(it)
+		}
+		n++
+		return enc.Encode(it)
+	}
+	err := eachItem(emit)
+	for _, it := range extra {
+		if err != nil {
+			break
+		}
+		err = emit(it)
+	}
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
+
+// exportNDJSON streams the items, as stored (not masked), to stdout or to
+// out by way of a temp file, like writeFileAtomic.
+func exportNDJSON(out, coll string) {
+	var keep func(Item) bool
+	if coll != "" {
+		name := resolveCollection(coll)
+		keep = func(it Item) bool { return strings.EqualFold(it.Collection, name) }
+	}
+	if out == "" {
+		if _, err := streamNDJSON(os.Stdout, keep, nil, true); err != nil {
+			exitErr(err)
+		}
+		return
+	}
+	f, err := os.OpenFile(out+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		exitErr(err)
+	}
+	n, err := streamNDJSON(f, keep, nil, true)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(out+".tmp", out)
+	}
+	if err != nil {
+		_ = os.Remove(out + ".tmp")
+		exitErr(err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d items to %s\n", n, out)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// notifyIfLong pops up a desktop notification when a run took longer than
+// "notify_after" in the config, so a slow command can be left running in
+// another window. Like usage tracking it is best effort.
+func notifyIfLong(it *Item, started time.Time, code int) {
+	if cfg == nil || cfg.NotifyAfter == "" || cfg.NotifyAfter == "off" {
+		return
+	}
+	after, err := time.ParseDuration(cfg.NotifyAfter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: invalid notify_after %q in config\n", cfg.NotifyAfter)
+		return
+	}
+	took := time.Since(started)
+	if took < after {
+		return
+	}
+	status := "finished"
+	if code != 0 {
+		status = fmt.Sprintf("failed (exit %d)", code)
+	}
+	body := fmt.Sprintf("#%s %s %s after %s", displayID(*it), it.Title, status, took.Round(time.Second))
+	_ = desktopNotify("commandref", body)
+}
+
+func desktopNotify(title, body string) error {
+	var argv []string
+	switch runtime.GOOS {
+	case "darwin":
+		argv = []string{"osascript", "-e", fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))}
+	case "windows":
+		argv = []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToast(title, body)}
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return fmt.Errorf("no desktop session")
+		}
+		argv = []string{"notify-send", "--app-name=commandref", title, body}
+	}
+	if _, err := exec.LookPath(argv[0]); err != nil {
+		return err
+	}
+	return exec.Command(argv[0], argv[1:]...).Run()
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// windowsToast is a PowerShell script showing a toast through the WinRT
+// API, which needs no module installed.
+func windowsToast(title, body string) string {
+	esc := func(s string) string {
+		s = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+		return psQuotes.Replace(s) // inside '...', see psQuote
+	}
+	return `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('<toast><visual><binding template="ToastGeneric"><text>` + esc(title) + `</text><text>` + esc(body) + `</text></binding></visual></toast>')
+$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+}
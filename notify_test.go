@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// psLiteralEnd is where PowerShell ends the '...' string starting at s[0],
+// or -1: any single quote, straight or curly, ends it unless doubled.
+func psLiteralEnd(s string) int {
+	isQuote := func(r rune) bool { return strings.ContainsRune("'\u2018\u2019\u201a\u201b", r) }
+	rs := []rune(s)
+	for i := 1; i < len(rs); i++ {
+		if !isQuote(rs[i]) {
+			continue
+		}
+		if i+1 < len(rs) && isQuote(rs[i+1]) {
+			i++
+			continue
+		}
+		return len(string(rs[:i+1]))
+	}
+	return -1
+}
+
+func TestWindowsToastQuoting(t *testing.T) {
+	tests := []struct {
+		name, title, body string
+	}{
+		{"plain", "Backup done", "exit 0"},
+		{"straight quote", "Bob's job", "it's done"},
+		{"curly quotes", "Bob\u2019s job", "\u2018done\u2019 \u201alow\u201b"},
+		{"injection", "x\u2019); Remove-Item C:\\ -Recurse; (\u2019", "<b>&</b>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := windowsToast(tt.title, tt.body)
+			_, arg, ok := strings.Cut(script, "$xml.LoadXml(")
+			if !ok {
+				t.Fatal("no LoadXml call")
+			}
+			end := psLiteralEnd(arg)
+			if end < 0 || !strings.HasPrefix(arg[end:], ")\n") {
+				t.Errorf("the XML string ends early: %q", arg)
+			}
+		})
+	}
+}
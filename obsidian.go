@@ -0,0 +1,425 @@
+package main
+
+import (
+	"commandref/paths"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// `sync obsidian` keeps a vault folder of notes (the export --format markdown
+// layout) and the library in step. Each side's last synced state is kept in
+// obsidian-sync.json (one per profile and workspace) so we can tell which
+// one changed; when both did, the newer edit wins. A note whose item isn't
+// listed is only removed once the item is known to be deleted: it may
+// belong to another library synced into the same folder.
+
+type obsidianState struct {
+	Vault string                       `json:"vault"`
+	Notes map[string]obsidianStateNote `json:"notes"` // by noteKey
+}
+
+type obsidianStateNote struct {
+	File      string `json:"file"`
+	Hash      string `json:"hash"`      // of the note as last written or read
+	UpdatedAt string `json:"updatedAt"` // of the item at that time
+}
+
+// note is a command note parsed back from Markdown.
+type note struct {
+	File    string
+	Title   string
+	Tags    []string
+	UUID    string
+	ID      int
+	Command string
+	Notes   string
+	Hash    string
+	ModTime time.Time
+}
+
+func noteKey(uuid string, id int) string {
+	if uuid != "" {
+		return "uuid:" + uuid
+	}
+	return "id:" + strconv.Itoa(id)
+}
+
+func hashNote(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func unquoteYAML(v string) string {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, `"`) {
+		if s, err := strconv.Unquote(v); err == nil {
+			return s
+		}
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+	return v
+}
+
+// parseNote reads the front matter keys we write (plus the block-list tags
+// Obsidian's property editor produces), the first fenced block as the
+// command, and whatever follows it as notes.
+func parseNote(b []byte) (note, error) {
+	var n note
+	text := strings.ReplaceAll(string(b), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		fm, body, ok := strings.Cut(rest, "\n---")
+		if !ok {
+			return n, fmt.Errorf("front matter is not closed")
+		}
+		text = strings.TrimPrefix(body, "\n")
+		inTags := false
+		for _, line := range strings.Split(fm, "\n") {
+			if inTags {
+				if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok {
+					n.Tags = append(n.Tags, unquoteYAML(item))
+					continue
+				}
+				inTags = false
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "title":
+				n.Title = unquoteYAML(value)
+			case "tags":
+				if value == "" {
+					inTags = true
+					continue
+				}
+				for _, t := range strings.Split(strings.Trim(value, "[]"), ",") {
+					if t = unquoteYAML(t); t != "" {
+						n.Tags = append(n.Tags, t)
+					}
+				}
+			case "commandref_uuid":
+				n.UUID = unquoteYAML(value)
+			case "commandref_id":
+				n.ID, _ = strconv.Atoi(unquoteYAML(value))
+			}
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	fence := ""
+	var cmd, notes []string
+	done := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case done:
+			notes = append(notes, line)
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			// the fence is the run of backticks/tildes; the rest is the language
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		case fence != "" && len(trimmed) >= len(fence) && strings.TrimLeft(trimmed, fence[:1]) == "":
+			done = true
+		case fence != "":
+			cmd = append(cmd, line)
+		case n.Title == "" && strings.HasPrefix(trimmed, "# "):
+			n.Title = strings.TrimPrefix(trimmed, "# ")
+		}
+	}
+	if !done {
+		return n, fmt.Errorf("no fenced code block with the command")
+	}
+	n.Command = normalizeCommand(strings.Join(cmd, "\n"))
+	n.Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+	n.Tags = parseTags(strings.Join(n.Tags, ","))
+	return n, nil
+}
+
+func obsidianStatePath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "obsidian-sync"+librarySuffix()+".json"), nil
+}
+
+func loadObsidianState(vault string) (*obsidianState, error) {
+	st := &obsidianState{Vault: vault, Notes: map[string]obsidianStateNote{}}
+	p, err := obsidianStatePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, err
+	}
+	var prev obsidianState
+	if err := json.Unmarshal(b, &prev); err != nil {
+		return nil, err
+	}
+	// state from another vault says nothing about this one
+	if prev.Vault == vault && prev.Notes != nil {
+		st.Notes = prev.Notes
+	}
+	return st, nil
+}
+
+func saveObsidianState(st *obsidianState) error {
+	p, err := obsidianStatePath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+func readNotes(dir string) ([]note, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	var notes []note
+	for _, m := range matches {
+		b, err := os.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+		n, err := parseNote(b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", filepath.Base(m), err)
+			continue
+		}
+		if fi, err := os.Stat(m); err == nil {
+			n.ModTime = fi.ModTime()
+		}
+		n.File, n.Hash = filepath.Base(m), hashNote(b)
+		if n.Title == "" {
+			n.Title = strings.TrimSuffix(n.File, ".md")
+		}
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+// writeNote (re)writes an item's note and records it as in sync.
+func writeNote(dir, file string, it Item, state *obsidianState) error {
+	content := []byte(itemMarkdown(it, strings.TrimSuffix(file, ".md")))
+	if err := writeFileAtomic(filepath.Join(dir, file), content, 0644); err != nil {
+		return err
+	}
+	state.Notes[noteKey(it.UUID, it.ID)] = obsidianStateNote{File: file, Hash: hashNote(content), UpdatedAt: it.UpdatedAt}
+	return nil
+}
+
+func notePatch(n note) map[string]any {
+	return map[string]any{"title": n.Title, "command": n.Command, "tags": n.Tags, "notes": n.Notes}
+}
+
+// obsidianPass does one round of two-way sync and returns what it did.
+func obsidianPass(store Store, dir string, state *obsidianState) (changes []string, err error) {
+	items, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	notes, err := readNotes(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := map[string]*Item{}
+	taken := map[string]bool{}
+	for i := range items {
+		byKey[noteKey(items[i].UUID, items[i].ID)] = &items[i]
+	}
+	// every file counts, not just the notes that parsed
+	files, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	for _, f := range files {
+		taken[strings.TrimSuffix(filepath.Base(f), ".md")] = true
+	}
+	seen := map[string]bool{}
+
+	for _, n := range notes {
+		if n.UUID == "" && n.ID == 0 {
+			// a note written in the vault: becomes a new item
+			created, err := store.Create(Item{Title: n.Title, Command: n.Command, Tags: n.Tags, Notes: n.Notes})
+			if err != nil {
+				return changes, err
+			}
+			if err := writeNote(dir, n.File, *created, state); err != nil {
+				return changes, err
+			}
+			seen[noteKey(created.UUID, created.ID)] = true
+			changes = append(changes, fmt.Sprintf("added #%d from %s", created.ID, n.File))
+			continue
+		}
+
+		key := noteKey(n.UUID, n.ID)
+		seen[key] = true
+		prev, known := state.Notes[key]
+		it := byKey[key]
+		if it == nil {
+			if known && deletedUpstream(store, n) {
+				// deleted in the library since the last sync
+				if err := os.Remove(filepath.Join(dir, n.File)); err != nil {
+					return changes, err
+				}
+				delete(state.Notes, key)
+				changes = append(changes, "removed "+n.File+" (item was deleted)")
+			}
+			continue
+		}
+
+		noteChanged := !known || n.Hash != prev.Hash
+		itemChanged := !known || it.UpdatedAt != prev.UpdatedAt
+		if noteChanged && itemChanged {
+			// both sides moved (or first sync): keep the newer one
+			t, _ := time.Parse(time.RFC3339, it.UpdatedAt)
+			if n.ModTime.After(t) {
+				itemChanged = false
+			} else {
+				noteChanged = false
+			}
+		}
+		switch {
+		case noteChanged:
+			if n.Title == it.Title && n.Command == it.Command && n.Notes == it.Notes && strings.Join(n.Tags, ",") == strings.Join(it.Tags, ",") {
+				state.Notes[key] = obsidianStateNote{File: n.File, Hash: n.Hash, UpdatedAt: it.UpdatedAt}
+				continue
+			}
+			updated, err := store.Update(it.ID, notePatch(n))
+			if err != nil {
+				return changes, err
+			}
+			state.Notes[key] = obsidianStateNote{File: n.File, Hash: n.Hash, UpdatedAt: updated.UpdatedAt}
+			changes = append(changes, fmt.Sprintf("updated #%d from %s", it.ID, n.File))
+		case itemChanged:
+			if err := writeNote(dir, n.File, *it, state); err != nil {
+				return changes, err
+			}
+			changes = append(changes, fmt.Sprintf("updated %s from #%d", n.File, it.ID))
+		}
+	}
+
+	for key, prev := range state.Notes {
+		if seen[key] {
+			continue
+		}
+		it := byKey[key]
+		if it == nil {
+			delete(state.Notes, key)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, prev.File)); !os.IsNotExist(err) {
+			// still there, just unreadable (readNotes warned): leave both alone
+			seen[key] = true
+			continue
+		}
+		// the note was deleted in the vault since the last sync
+		if err := store.Delete(it.ID); err != nil && !errors.Is(err, errNotFound) {
+			return changes, err
+		}
+		delete(state.Notes, key)
+		delete(byKey, key)
+		changes = append(changes, fmt.Sprintf("deleted #%d (%s was removed)", it.ID, prev.File))
+	}
+
+	sortByID(items)
+	for _, it := range items {
+		key := noteKey(it.UUID, it.ID)
+		if seen[key] || byKey[key] == nil {
+			continue
+		}
+		file := itemFileName(it, taken)
+		if err := writeNote(dir, file, it, state); err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("wrote %s for #%d", file, it.ID))
+	}
+	return changes, nil
+}
+
+// deletedUpstream reports whether n's item is known to be deleted, not just
+// missing from the listing: a tombstone in the local library, or the
+// backend saying so for its ID.
+func deletedUpstream(store Store, n note) bool {
+	if _, ok := store.(localStore); ok && n.UUID != "" {
+		db, err := loadDB()
+		if err != nil {
+			return false
+		}
+		return slices.ContainsFunc(db.Tombstones, func(t tombstone) bool { return t.UUID == n.UUID })
+	}
+	if n.ID == 0 {
+		return false
+	}
+	_, err := store.Get(n.ID)
+	return errors.Is(err, errNotFound)
+}
+
+func runObsidianSync(args []string) {
+	fs := flag.NewFlagSet("sync obsidian", flag.ExitOnError)
+	vault := fs.String("vault", "", "path to the Obsidian vault")
+	folder := fs.String("folder", "commandref", "folder inside the vault for command notes")
+	watch := fs.Bool("watch", false, "keep syncing until interrupted")
+	interval := fs.Duration("interval", 5*time.Second, "how often to check for changes with --watch")
+	_ = fs.Parse(args)
+
+	if *vault == "" {
+		exitErr(errors.New("--vault is required"))
+	}
+	vaultDir, err := filepath.Abs(expandHome(*vault))
+	if err != nil {
+		exitErr(err)
+	}
+	dir := filepath.Join(vaultDir, *folder)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		exitErr(err)
+	}
+
+	state, err := loadObsidianState(dir)
+	if err != nil {
+		exitErr(fmt.Errorf("reading sync state: %w", err))
+	}
+	store := openStore()
+	for {
+		changes, err := obsidianPass(store, dir, state)
+		if serr := saveObsidianState(state); serr != nil && err == nil {
+			err = serr
+		}
+		for _, c := range changes {
+			fmt.Printf("%s  %s\n", formatTimeValue(time.Now()), c)
+		}
+		if err != nil {
+			if !*watch {
+				exitErr(err)
+			}
+			reportErr(exitCodeOf(err), err)
+		}
+		if !*watch {
+			if len(changes) == 0 {
+				fmt.Println("Vault and library are in sync")
+			}
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
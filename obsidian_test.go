@@ -0,0 +1,131 @@
+package main
+
+import (
+	"commandref/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestObsidianPassDeletes(t *testing.T) {
+	const uuid = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	tests := []struct {
+		name        string
+		note        string // content of the item's note; "" removes the file
+		wantDeleted bool
+	}{
+		{"note removed", "", true},
+		{"front matter not closed", "---\ntitle: Broken\ncommandref_uuid: " + uuid + "\n", false},
+		{"no fenced command", "---\ntitle: Broken\ncommandref_uuid: " + uuid + "\n---\njust text\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			it := Item{ID: 1, UUID: uuid, Title: "Broken", Command: "echo hi", UpdatedAt: "2026-01-01T00:00:00Z"}
+			st := newMemStore(it)
+			state := &obsidianState{Notes: map[string]obsidianStateNote{
+				noteKey(uuid, 1): {File: "broken.md", Hash: "old", UpdatedAt: it.UpdatedAt},
+			}}
+			if tt.note != "" {
+				if err := os.WriteFile(filepath.Join(dir, "broken.md"), []byte(tt.note), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := obsidianPass(st, dir, state); err != nil {
+				t.Fatal(err)
+			}
+			if deleted := len(st.deleted) > 0; deleted != tt.wantDeleted {
+				t.Errorf("item deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if tt.note != "" {
+				b, _ := os.ReadFile(filepath.Join(dir, "broken.md"))
+				if string(b) != tt.note {
+					t.Errorf("the unreadable note was rewritten:\n%s", b)
+				}
+				if files, _ := filepath.Glob(filepath.Join(dir, "*.md")); len(files) != 1 {
+					t.Errorf("notes in the vault = %v, want just broken.md", files)
+				}
+			}
+		})
+	}
+}
+
+// listless is a store whose listing has lost an item that still exists,
+// as with another library's notes in the same folder.
+type listless struct{ *memStore }
+
+func (s listless) List() ([]Item, error) { return nil, nil }
+
+func TestObsidianPassKeepsUnlistedNotes(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{Storage: "local"}
+	const uuid = "6a1f2a5e-3c33-4c0e-8f43-5d1b2a0e9b11"
+	it := Item{ID: 4, UUID: uuid, Title: "Deploy", Command: "make deploy", UpdatedAt: "2026-01-01T00:00:00Z"}
+
+	tests := []struct {
+		name        string
+		store       func(t *testing.T) Store
+		wantRemoved bool
+	}{
+		{"gone from the backend", func(*testing.T) Store { return newMemStore() }, true},
+		{"still there, just not listed", func(*testing.T) Store { return listless{newMemStore(it)} }, false},
+		{"local, no tombstone", func(*testing.T) Store { return localStore{} }, false},
+		{"local, deleted", func(t *testing.T) Store {
+			db, err := loadDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			buryItem(&db, it)
+			if err := saveDB(db); err != nil {
+				t.Fatal(err)
+			}
+			return localStore{}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COMMANDREF_HOME", t.TempDir())
+			st := tt.store(t)
+			dir := t.TempDir()
+			state := &obsidianState{Notes: map[string]obsidianStateNote{}}
+			if err := writeNote(dir, "deploy.md", it, state); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := obsidianPass(st, dir, state); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(filepath.Join(dir, "deploy.md"))
+			if removed := os.IsNotExist(err); removed != tt.wantRemoved {
+				t.Errorf("note removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestObsidianStatePathPerLibrary(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	defer func() { resolvedWorkspace = nil }()
+	seen := map[string]bool{}
+	for _, tt := range []struct {
+		profile string
+		ws      workspace
+	}{
+		{"", workspace{}},
+		{"", workspace{ID: "ws_1"}},
+		{"work", workspace{}},
+		{"work", workspace{ID: "ws_1"}},
+	} {
+		t.Setenv("COMMANDREF_PROFILE", tt.profile)
+		ws := tt.ws
+		resolvedWorkspace = &ws
+		p, err := obsidianStatePath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[p] {
+			t.Errorf("profile %q workspace %q share %s", tt.profile, tt.ws.ID, p)
+		}
+		seen[p] = true
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// When the backend can't be reached, apiStore keeps working from the item
+// cache: reads are answered from it (with a banner saying how old it is)
+// and writes are applied to it and queued, to be sent in order on the next
+// command that gets through. After a couple of failures in a row the
+// backend isn't even tried for a minute, so each command doesn't sit
+// through its own timeout.
+
+const (
+	downAfterFailures = 2
+	downCooldown      = time.Minute
+)
+
+type apiHealth struct {
+	Failures    int    `json:"failures"` // in a row
+	LastFailure string `json:"lastFailure,omitempty"`
+}
+
+func healthPath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "api-health"+profileSuffix()+".json"), nil
+}
+
+func loadHealth() apiHealth {
+	var h apiHealth
+	if p, err := healthPath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &h)
+		}
+	}
+	return h
+}
+
+func saveHealth(h apiHealth) {
+	p, err := healthPath()
+	if err != nil {
+		return
+	}
+	if h.Failures == 0 {
+		_ = os.Remove(p)
+		return
+	}
+	if b, err := json.Marshal(h); err == nil {
+		_ = writeFileAtomic(p, b, 0600)
+	}
+}
+
+// backendDown is true while recent failures say trying again is pointless.
+func backendDown() bool {
+	h := loadHealth()
+	if h.Failures < downAfterFailures {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, h.LastFailure)
+	return err == nil && time.Since(t) < downCooldown
+}
+
+// noteAPIResult records whether a request reached the backend.
+func noteAPIResult(err error) {
+	h := loadHealth()
+	switch {
+	case err == nil || !isNetworkErr(err):
+		if h.Failures == 0 {
+			return
+		}
+		h = apiHealth{}
+	default:
+		h.Failures++
+		h.LastFailure = time.Now().UTC().Format(time.RFC3339)
+	}
+	saveHealth(h)
+}
+
+var (
+	errBackendDown = errors.New("the backend is unreachable (retrying in a minute)")
+
+	// set by listWithFreshness, which reports the staleness itself
+	quietOffline bool
+	bannerShown  bool
+
+	// the cache a read was answered from, if any
+	servedOffline *itemCache
+)
+
+// offlineCache returns the cache to answer a read from when err says the
+// backend is out of reach (any other error is returned as is).
+func offlineCache(err error) (*itemCache, error) {
+	c, err := writeCache(err)
+	if err != nil {
+		return nil, err
+	}
+	servedOffline = c
+	if !quietOffline && !bannerShown {
+		bannerShown = true
+		fmt.Fprintf(os.Stderr, "(offline data from %s: the backend can't be reached)\n", cacheAge(c))
+	}
+	return c, nil
+}
+
+// writeCache is offlineCache for writes, which get their own message.
+func writeCache(err error) (*itemCache, error) {
+	if !isNetworkErr(err) && !errors.Is(err, errBackendDown) {
+		return nil, err
+	}
+	c, cerr := loadItemCache()
+	if cerr != nil {
+		return nil, fmt.Errorf("%w (and no offline cache yet)", err)
+	}
+	return c, nil
+}
+
+func cacheAge(c *itemCache) string {
+	if t, err := time.Parse(time.RFC3339, c.FetchedAt); err == nil {
+		return relativeTime(t, time.Now())
+	}
+	return "unknown age"
+}
+
+// queuedWrite is a write made while offline.
+type queuedWrite struct {
+	Op    string         `json:"op"`   // create, update or delete
+	Base  string         `json:"base"` // collection path, workspace included
+	ID    int            `json:"id"`   // provisional for a create
+	Body  map[string]any `json:"body,omitempty"`
+	Title string         `json:"title"`
+	At    string         `json:"at"`
+}
+
+// queueMu serializes changes to the queue: bulk commands write from several
+// goroutines, and two flushes at once would send it twice.
+var queueMu sync.Mutex
+
+func queuePath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "queued-writes"+profileSuffix()+".json"), nil
+}
+
+func loadQueue() []queuedWrite {
+	var q []queuedWrite
+	if p, err := queuePath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &q)
+		}
+	}
+	return q
+}
+
+func saveQueue(q []queuedWrite) error {
+	p, err := queuePath()
+	if err != nil {
+		return err
+	}
+	if len(q) == 0 {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+// queueWrite records w and patches the cache so later reads see it.
+func (s apiStore) queueWrite(w queuedWrite, it Item, deleted bool) error {
+	w.Base = s.c.Path("/v1/commands")
+	w.At = time.Now().UTC().Format(time.RFC3339)
+	queueMu.Lock()
+	err := saveQueue(append(loadQueue(), w))
+	queueMu.Unlock()
+	if err != nil {
+		return err
+	}
+	patchItemCache(it, deleted)
+	if !quietOffline {
+		fmt.Fprintln(os.Stderr, "(the backend can't be reached: the change is queued and will be sent by the next command that gets through)")
+	}
+	return nil
+}
+
+// provisionalID picks the ID an offline create shows until it is sent:
+// the next one after everything cached.
+func provisionalID(c *itemCache) int {
+	id := 0
+	for _, it := range c.Items {
+		id = max(id, it.ID)
+	}
+	return id + 1
+}
+
+// flushQueue sends queued writes in order. It stops at the first network
+// error (leaving the rest queued); a write the backend rejects is dropped
+// with a warning.
+func (s apiStore) flushQueue() error {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	q := loadQueue()
+	if len(q) == 0 {
+		return nil
+	}
+	current := s.c.Path("/v1/commands")
+	newIDs := map[int]int{} // provisional -> real
+	sent := 0
+	for i, w := range q {
+		id := w.ID
+		if real, ok := newIDs[id]; ok {
+			id = real
+		}
+		if id == 0 {
+			continue // its create was dropped
+		}
+		body, err := e2eSealFor(w.Base, w.Body)
+		if err != nil { // keep it queued until there is a key
+			if serr := saveQueue(remapQueue(q[i:], newIDs)); serr != nil {
+				return serr
+			}
+			return err
+		}
+		var got Item
+		switch w.Op {
+		case "create":
+			err = s.c.DoJSON("POST", w.Base, body, &got)
+		case "update":
+			err = s.c.DoJSON("PATCH", w.Base+"/"+strconv.Itoa(id), body, &got)
+		case "delete":
+			err = s.c.DoJSON("DELETE", w.Base+"/"+strconv.Itoa(id), nil, nil)
+		}
+		if isNetworkErr(err) {
+			noteAPIResult(err)
+			if serr := saveQueue(remapQueue(q[i:], newIDs)); serr != nil {
+				return serr
+			}
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: dropped the queued %s of %q from %s: %v\n", w.Op, w.Title, formatTime(w.At), strings.TrimSpace(err.Error()))
+			if w.Op == "create" {
+				newIDs[w.ID] = 0
+				if w.Base == current {
+					patchItemCache(Item{ID: w.ID}, true)
+				}
+			}
+			continue
+		}
+		sent++
+		decryptItem(&got)
+		if w.Op == "create" {
+			newIDs[w.ID] = got.ID
+		}
+		if w.Base != current {
+			continue // another workspace; its cache catches up on its next listing
+		}
+		switch w.Op {
+		case "create":
+			patchItemCache(Item{ID: w.ID}, true)
+			patchItemCache(got, false)
+			if got.ID != w.ID {
+				fmt.Fprintf(os.Stderr, "(%q, saved offline as #%d, is #%d)\n", w.Title, w.ID, got.ID)
+			}
+		case "update":
+			patchItemCache(got, false)
+		}
+	}
+	noteAPIResult(nil)
+	if sent > 0 {
+		fmt.Fprintf(os.Stderr, "(sent %s queued while offline)\n", plural(sent, "change"))
+	}
+	return saveQueue(nil)
+}
+
+// remapQueue gives the writes still queued after a partial flush the real
+// IDs of the creates that went through, and drops the ones whose create was
+// rejected.
+func remapQueue(q []queuedWrite, newIDs map[int]int) []queuedWrite {
+	out := make([]queuedWrite, 0, len(q))
+	for _, w := range q {
+		if real, ok := newIDs[w.ID]; ok {
+			if real == 0 {
+				continue
+			}
+			w.ID = real
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+// reachable flushes queued writes first, so a read never returns data that
+// is missing them; it reports errBackendDown during the cooldown.
+func (s apiStore) reachable() error {
+	if backendDown() {
+		return errBackendDown
+	}
+	return s.flushQueue()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"commandref/api"
+	"commandref/auth"
+	"commandref/config"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// offlineTestEnv points the data and cache dirs at a temp dir with an
+// empty item cache.
+func offlineTestEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	t.Setenv("COMMANDREF_PROFILE", "")
+	cfg = &config.Config{}
+	quietOffline = true
+	t.Cleanup(func() { quietOffline = false })
+	writeItemCache(&itemCache{FetchedAt: "2026-01-01T00:00:00Z"})
+}
+
+func TestQueueWriteConcurrent(t *testing.T) {
+	offlineTestEnv(t)
+	s := apiStore{c: &api.Client{}}
+
+	const n = 30
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.queueWrite(queuedWrite{Op: "update", ID: i}, Item{ID: i}, false); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if q := loadQueue(); len(q) != n {
+		t.Errorf("queued %d writes, want %d", len(q), n)
+	}
+	c, err := loadItemCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Items) != n {
+		t.Errorf("cache has %d items, want %d", len(c.Items), n)
+	}
+}
+
+func TestRemapQueue(t *testing.T) {
+	tests := []struct {
+		name   string
+		q      []queuedWrite
+		newIDs map[int]int
+		want   []int
+	}{
+		{"nothing sent", []queuedWrite{{Op: "update", ID: 7}}, map[int]int{}, []int{7}},
+		{"create sent", []queuedWrite{{Op: "update", ID: 7}, {Op: "delete", ID: 3}}, map[int]int{7: 42}, []int{42, 3}},
+		{"create rejected", []queuedWrite{{Op: "update", ID: 7}, {Op: "update", ID: 3}}, map[int]int{7: 0}, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, w := range remapQueue(tt.q, tt.newIDs) {
+				got = append(got, w.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A create goes through, then the connection drops: the update to the same
+// item stays queued under the ID the server gave it.
+func TestFlushQueueRemapsAfterPartialFlush(t *testing.T) {
+	offlineTestEnv(t)
+	if err := auth.SaveSession(auth.Session{Token: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			fmt.Fprint(w, `{"id": 42, "title": "new"}`)
+			return
+		}
+		// anything after the create: the network goes away
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	s := apiStore{c: &api.Client{BaseURL: srv.URL}}
+	q := []queuedWrite{
+		{Op: "create", Base: "/v1/commands", ID: 7, Body: map[string]any{"title": "new"}, Title: "new"},
+		{Op: "update", Base: "/v1/commands", ID: 7, Body: map[string]any{"title": "renamed"}, Title: "renamed"},
+	}
+	if err := saveQueue(q); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.flushQueue(); !isNetworkErr(err) {
+		t.Fatalf("flushQueue = %v, want a network error", err)
+	}
+	left := loadQueue()
+	if len(left) != 1 || left[0].Op != "update" || left[0].ID != 42 {
+		t.Errorf("left queued: %+v, want the update to #42", left)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"commandref/auth"
+	"commandref/config"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commands that never start the first-run setup
+var noOnboarding = map[string]bool{
+	"version": true, "--version": true, "help": true, "-h": true, "--help": true,
+	"login": true, "setup": true, "widget": true, "__job": true, "api": true,
+	"completion": true, "__complete": true, "__refresh": true, "doctor": true,
+}
+
+// firstRun is true before anything was ever set up here: no config file,
+// no login and no local library.
+func firstRun() bool {
+	p, err := config.Path()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		return false
+	}
+	if s, err := auth.LoadSession(); err != nil || s != nil {
+		return false
+	}
+	if p, err := dbPath(); err != nil {
+		return false
+	} else if _, err := os.Stat(p); !os.IsNotExist(err) {
+		return false
+	}
+	return true
+}
+
+// maybeOnboard runs the setup on the very first interactive invocation,
+// then lets the command carry on.
+func maybeOnboard(cmd string) {
+	if noOnboarding[cmd] || !canPrompt() || !isTerminal(os.Stdout) || !firstRun() {
+		return
+	}
+	runSetup()
+	fmt.Println()
+}
+
+func askYes(prompt string) bool {
+	answer, _ := readLine(prompt + " [y/N] ")
+	a := strings.ToLower(strings.TrimSpace(answer))
+	return a == "y" || a == "yes"
+}
+
+// runSetup is `commandref setup`: where the library lives (logging in if
+// it's hosted), seeding it from shell history and the Ctrl-G widget.
+func runSetup() {
+	fmt.Println("Welcome to commandref! A few questions to get you set up.")
+	fmt.Println()
+	fmt.Println("Where should your commands live?")
+	fmt.Println("  1) on this machine only (no account needed)")
+	fmt.Println("  2) hosted, the same library on all your machines (needs a login)")
+	storage := ""
+	for storage == "" {
+		answer, err := readLine("Choice [1]: ")
+		if err != nil {
+			return
+		}
+		switch strings.TrimSpace(answer) {
+		case "", "1":
+			storage = "local"
+		case "2":
+			storage = "api"
+		}
+	}
+	if err := writeSetupConfig(storage); err != nil {
+		exitErr(fmt.Errorf("saving the config: %w", err))
+	}
+	cfg.Storage = storage
+
+	if storage == "api" {
+		if s, _ := auth.LoadSession(); s == nil {
+			if err := auth.Login(); err != nil {
+				fmt.Println("Login failed:", err)
+				fmt.Println("Try again later with: commandref login")
+				return
+			}
+			runLoginHook(nil)
+		}
+	}
+
+	if f := defaultHistoryFile(); f != "" {
+		fmt.Println()
+		if askYes(fmt.Sprintf("Import your 20 most used commands from %s?", tildePath(f))) {
+			importHistory(f, 20)
+		}
+	}
+
+	if shell, rc, line := widgetInstall(); rc != "" {
+		fmt.Println()
+		if hasLine(rc, line) {
+			fmt.Printf("The %s widget is already in %s.\n", shell, tildePath(rc))
+		} else if askYes(fmt.Sprintf("Add the Ctrl-G picker to %s?", tildePath(rc))) {
+			if err := appendLine(rc, line); err != nil {
+				reportErr(exitCodeOf(err), err) // the rest of the setup goes on
+			} else {
+				fmt.Println("Added; open a new shell to use it.")
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("All set. Save a command with:")
+	fmt.Println(`  commandref add --title "List files" --cmd "ls -la"`)
+	fmt.Println("Change your answers any time with: commandref setup")
+}
+
+// writeSetupConfig sets "storage" in config.json, keeping the rest.
+func writeSetupConfig(storage string) error {
+	return setConfig("storage", storage)
+}
+
+// setConfig sets (or, for nil, removes) a dotted key like "ai.api_key" in
+// config.json, keeping the rest as written.
+func setConfig(key string, value any) error {
+	p, err := config.Path()
+	if err != nil {
+		return err
+	}
+	conf := map[string]any{}
+	if b, err := os.ReadFile(p); err == nil {
+		if err := json.Unmarshal(b, &conf); err != nil {
+			return err
+		}
+	}
+	parts := strings.Split(key, ".")
+	m := conf
+	for _, k := range parts[:len(parts)-1] {
+		sub, ok := m[k].(map[string]any)
+		if !ok {
+			if value == nil {
+				return nil
+			}
+			sub = map[string]any{}
+			m[k] = sub
+		}
+		m = sub
+	}
+	if value == nil {
+		delete(m, parts[len(parts)-1])
+	} else {
+		m[parts[len(parts)-1]] = value
+	}
+	b, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, append(b, '\n'), 0600)
+}
+
+// widgetInstall returns the user's shell, its rc file and the line that
+// loads the widget there ("" if the shell isn't supported).
+func widgetInstall() (shell, rc, line string) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", ""
+	}
+	switch shell = filepath.Base(os.Getenv("SHELL")); shell {
+	case "zsh":
+		return shell, filepath.Join(home, ".zshrc"), `eval "$(commandref widget zsh)"`
+	case "bash":
+		return shell, filepath.Join(home, ".bashrc"), `eval "$(commandref widget bash)"`
+	case "fish":
+		return shell, filepath.Join(home, ".config", "fish", "config.fish"), "commandref widget fish | source"
+	}
+	return "", "", ""
+}
+
+func hasLine(rc, line string) bool {
+	b, err := os.ReadFile(rc)
+	return err == nil && strings.Contains(string(b), line)
+}
+
+func appendLine(path, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "\n# commandref: Ctrl-G inserts a saved command\n%s\n", line)
+	return err
+}
+
+func tildePath(p string) string {
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(p, home+string(filepath.Separator)) {
+		return "~" + p[len(home):]
+	}
+	return p
+}
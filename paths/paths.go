@@ -0,0 +1,143 @@
+package paths
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const appName = "commandref"
+
+// files that used to live in ~/.commandref, and which dir they belong in now
+var legacyFiles = map[string]func() (string, error){
+	"config.json":   configDir,
+	"commands.json": dataDir,
+	"session.json":  dataDir,
+}
+
+var migrateOnce sync.Once
+
+// ConfigDir returns the directory for user-edited configuration, creating it if needed.
+// Resolution: $COMMANDREF_HOME, then $XDG_CONFIG_HOME/commandref, then ~/.config/commandref.
+func ConfigDir() (string, error) {
+	migrateOnce.Do(migrateLegacy)
+	return ensure(configDir())
+}
+
+// DataDir returns the directory for the local store, session and other state.
+// Resolution: $COMMANDREF_HOME, then $XDG_DATA_HOME/commandref, then ~/.local/share/commandref.
+func DataDir() (string, error) {
+	migrateOnce.Do(migrateLegacy)
+	return ensure(dataDir())
+}
+
+// CacheDir returns the directory for disposable cached data.
+// Resolution: $COMMANDREF_HOME/cache, then $XDG_CACHE_HOME/commandref, then ~/.cache/commandref.
+func CacheDir() (string, error) {
+	migrateOnce.Do(migrateLegacy)
+	return ensure(cacheDir())
+}
+
+func configDir() (string, error) {
+	return resolve("XDG_CONFIG_HOME", ".config", "")
+}
+
+func dataDir() (string, error) {
+	return resolve("XDG_DATA_HOME", filepath.Join(".local", "share"), "")
+}
+
+func cacheDir() (string, error) {
+	return resolve("XDG_CACHE_HOME", ".cache", "cache")
+}
+
+func resolve(xdgVar, homeRel, portableSub string) (string, error) {
+	if h := os.Getenv("COMMANDREF_HOME"); h != "" {
+		return filepath.Join(h, portableSub), nil
+	}
+	if x := os.Getenv(xdgVar); x != "" && filepath.IsAbs(x) {
+		return filepath.Join(x, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, homeRel, appName), nil
+}
+
+func ensure(dir string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// migrateLegacy moves files out of the old ~/.commandref directory.
+// Errors are ignored: worst case the user keeps working from fresh files.
+// Portable installs (COMMANDREF_HOME) are left alone.
+func migrateLegacy() {
+	if os.Getenv("COMMANDREF_HOME") != "" {
+		return
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	legacy := filepath.Join(home, ".commandref")
+	if _, err := os.Stat(legacy); err != nil {
+		return
+	}
+
+	for name, dirFn := range legacyFiles {
+		src := filepath.Join(legacy, name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		dir, err := ensure(dirFn())
+		if err != nil || dir == legacy {
+			continue
+		}
+		dst := filepath.Join(dir, name)
+		if _, err := os.Stat(dst); err == nil {
+			continue // never clobber newer files
+		}
+		_ = moveFile(src, dst)
+	}
+
+	// only removes it if nothing else is left inside
+	_ = os.Remove(legacy)
+}
+
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// rename fails across filesystems; fall back to copy + remove
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, st.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errPickCancelled = errors.New("cancelled")
+
+// runPick lets the user choose an item and prints its command on stdout,
+// undecorated, so shell widgets can insert it into the prompt buffer.
+// Items come most likely first (see rank.go) unless --no-rank asks for
+// ID order.
+func runPick(args []string) {
+	// the widgets pass --cached: no network on Ctrl-G (see completion.go)
+	cached, rank := false, true
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		switch args[0] {
+		case "--cached":
+			cached = true
+		case "--no-rank":
+			rank = false
+		default:
+			exitErr(errors.New("usage: commandref pick [--cached] [--no-rank] [query]"))
+		}
+		args = args[1:]
+	}
+	query := strings.TrimSpace(strings.Join(args, " "))
+
+	var items []Item
+	if cached {
+		if items = cachedItems(); len(items) == 0 && !usingLocalStore() {
+			fmt.Fprintln(os.Stderr, "no commands cached yet; fetching them in the background, try again in a moment")
+			os.Exit(1)
+		}
+	} else {
+		var err error
+		if items, err = openStore().List(); err != nil {
+			exitErr(err)
+		}
+	}
+	items = byArchived(items, false)
+	sortByID(items)
+
+	it, err := pickItem(items, query, rank)
+	if err != nil {
+		if errors.Is(err, errPickCancelled) {
+			os.Exit(1)
+		}
+		exitErr(err)
+	}
+	recordUsage(it, "pick")
+	fmt.Print(it.Command)
+}
+
+func pickItem(items []Item, query string, rank bool) (*Item, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no saved commands")
+	}
+	if _, err := exec.LookPath("fzf"); err == nil && !opts.Accessible {
+		if rank {
+			items = rankItems(items, query)
+		}
+		return pickWithFzf(items, query, rank)
+	}
+	return pickLinear(items, query, rank)
+}
+
+// pickWithFzf hands items to fzf in order; ranked, fzf's own match score
+// still comes first and the order breaks its ties. See fzfArgs.
+func pickWithFzf(items []Item, query string, ranked bool) (*Item, error) {
+	var in bytes.Buffer
+	for _, it := range items {
+		tags := ""
+		if len(it.Tags) > 0 {
+			tags = "  [" + strings.Join(it.Tags, ",") + "]"
+		}
+		cmd := strings.ReplaceAll(it.Command, "\n", " ⏎ ")
+		fmt.Fprintf(&in, "%d\t%s%s\t%s\n", it.ID, it.Title, tags, cmd)
+	}
+
+	fzf := exec.Command("fzf", fzfArgs(query, ranked)...)
+	fzf.Stdin = &in
+	fzf.Stderr = os.Stderr // fzf draws its UI on /dev/tty
+	out, err := fzf.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && (ee.ExitCode() == 1 || ee.ExitCode() == 130) {
+			return nil, errPickCancelled
+		}
+		return nil, err
+	}
+
+	idStr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	id, _ := strconv.Atoi(idStr)
+	for i := range items {
+		if items[i].ID == id {
+			return &items[i], nil
+		}
+	}
+	return nil, errPickCancelled
+}
+
+// fzfArgs: ranked, ties keep our order, and with a query of a couple of
+// letters or none fzf doesn't sort at all. Its score on so little is mostly
+// noise and would bury what was used lately.
+func fzfArgs(query string, ranked bool) []string {
+	args := []string{"--delimiter=\t", "--with-nth=2..",
+		"--height=40%", "--reverse",
+		"--prompt=commandref> ",
+		"--query=" + query}
+	if ranked {
+		args = append(args, "--tiebreak=index")
+		if len([]rune(strings.TrimSpace(query))) < 3 {
+			args = append(args, "--no-sort")
+		}
+	}
+	return args
+}
+
+// pickLinear is the no-dependency fallback: a filtered, numbered list and a
+// prompt, both on the terminal so stdout stays clean for the result.
+func pickLinear(items []Item, query string, rank bool) (*Item, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal available for picking")
+	}
+	defer tty.Close()
+	r := bufio.NewReader(tty)
+
+	for {
+		matches := filterItems(items, query)
+		if rank {
+			matches = rankItems(matches, query)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(tty, "No matches for %q.\n", query)
+		}
+		for _, it := range matches {
+			fmt.Fprintf(tty, "%s) %s%s\n", displayID(it), it.Title, renderTags(it.Tags))
+		}
+		fmt.Fprint(tty, "Enter an ID, or text to filter (empty to cancel): ")
+
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" || err != nil {
+			return nil, errPickCancelled
+		}
+		for i := range items {
+			if displayID(items[i]) == line {
+				return &items[i], nil
+			}
+		}
+		if id, err := strconv.Atoi(line); err == nil {
+			for i := range items {
+				if items[i].ID == id {
+					return &items[i], nil
+				}
+			}
+			fmt.Fprintf(tty, "No item #%d.\n", id)
+			continue
+		}
+		query = line
+	}
+}
+
+// filterItems keeps items whose title, command or tags contain every word of query.
+func filterItems(items []Item, query string) []Item {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		hay := strings.ToLower(it.Title + " " + it.Command + " " + strings.Join(it.Tags, " "))
+		ok := true
+		for _, w := range words {
+			if !strings.Contains(hay, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// pickItems lets the user mark any number of items: fzf --multi (Tab marks,
+// Ctrl-A marks every match), or a checklist on the terminal.
+func pickItems(items []Item, query string) ([]Item, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no saved commands")
+	}
+	if _, err := exec.LookPath("fzf"); err == nil && !opts.Accessible {
+		return pickManyWithFzf(items, query)
+	}
+	return pickManyLinear(items, query)
+}
+
+func pickManyWithFzf(items []Item, query string) ([]Item, error) {
+	var in bytes.Buffer
+	for _, it := range items {
+		cmd := strings.ReplaceAll(it.Command, "\n", " ⏎ ")
+		fmt.Fprintf(&in, "%d\t%s%s\t%s\n", it.ID, it.Title, renderTags(it.Tags), cmd)
+	}
+	fzf := exec.Command("fzf", "--multi", "--delimiter=\t", "--with-nth=2..",
+		"--height=60%", "--reverse", "--bind=ctrl-a:select-all",
+		"--header=Tab marks, Ctrl-A marks all matches, Enter is done",
+		"--prompt=commandref> ", "--query="+query)
+	fzf.Stdin = &in
+	fzf.Stderr = os.Stderr
+	out, err := fzf.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && (ee.ExitCode() == 1 || ee.ExitCode() == 130) {
+			return nil, errPickCancelled
+		}
+		return nil, err
+	}
+	marked := map[int]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		idStr, _, _ := strings.Cut(line, "\t")
+		if id, err := strconv.Atoi(idStr); err == nil {
+			marked[id] = true
+		}
+	}
+	var picked []Item
+	for _, it := range items {
+		if marked[it.ID] {
+			picked = append(picked, it)
+		}
+	}
+	return picked, nil
+}
+
+// pickManyLinear shows the matches with a box each; IDs or ranges toggle
+// them, other text filters, "all" marks every match and an empty line is
+// done.
+func pickManyLinear(items []Item, query string) ([]Item, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal available for picking")
+	}
+	defer tty.Close()
+	r := bufio.NewReader(tty)
+
+	marked := map[int]bool{}
+	for {
+		matches := filterItems(items, query)
+		if len(matches) == 0 {
+			fmt.Fprintf(tty, "No matches for %q.\n", query)
+		}
+		for _, it := range matches {
+			box := "[ ]"
+			if marked[it.ID] {
+				box = "[x]"
+			}
+			fmt.Fprintf(tty, "%s %s) %s%s\n", box, displayID(it), it.Title, renderTags(it.Tags))
+		}
+		fmt.Fprintf(tty, "%d marked. IDs or ranges to toggle, text to filter, \"all\", \"q\" to cancel, empty when done: ", len(marked))
+
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if err != nil || line == "q" {
+			return nil, errPickCancelled
+		}
+		switch {
+		case line == "":
+			var picked []Item
+			for _, it := range items {
+				if marked[it.ID] {
+					picked = append(picked, it)
+				}
+			}
+			return picked, nil
+		case line == "all":
+			for _, it := range matches {
+				marked[it.ID] = true
+			}
+		default:
+			// the IDs on screen, never old ones
+			ids, err := parseIDRefs(strings.Fields(line), refCached)
+			if err != nil {
+				query = line
+				continue
+			}
+			for _, id := range ids {
+				if marked[id] {
+					delete(marked, id)
+				} else if slices.ContainsFunc(items, func(it Item) bool { return it.ID == id }) {
+					marked[id] = true
+				}
+			}
+		}
+	}
+}
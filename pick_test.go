@@ -0,0 +1,33 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFzfArgs(t *testing.T) {
+	tests := []struct {
+		query    string
+		ranked   bool
+		tiebreak bool
+		noSort   bool
+	}{
+		{"", true, true, true},
+		{"dk", true, true, true},
+		{"docker", true, true, false},
+		{"", false, false, false},
+		{"docker", false, false, false},
+	}
+	for _, tt := range tests {
+		args := fzfArgs(tt.query, tt.ranked)
+		if got := slices.Contains(args, "--tiebreak=index"); got != tt.tiebreak {
+			t.Errorf("fzfArgs(%q, %v): --tiebreak=index = %v, want %v", tt.query, tt.ranked, got, tt.tiebreak)
+		}
+		if got := slices.Contains(args, "--no-sort"); got != tt.noSort {
+			t.Errorf("fzfArgs(%q, %v): --no-sort = %v, want %v", tt.query, tt.ranked, got, tt.noSort)
+		}
+		if !slices.Contains(args, "--query="+tt.query) {
+			t.Errorf("fzfArgs(%q, %v) lost the query: %v", tt.query, tt.ranked, args)
+		}
+	}
+}
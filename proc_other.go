@@ -0,0 +1,56 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func prepareChild(cmd *exec.Cmd, interactive bool) {}
+
+// signalChild can only reach the direct child here; on Windows anything but
+// Kill is unsupported, so fall back to that.
+func signalChild(cmd *exec.Cmd, sig os.Signal) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := cmd.Process.Signal(sig); err != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+func killChild(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+func reclaimTerminal() {}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+func detachProcess(cmd *exec.Cmd) {}
+
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := p.Signal(sig); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
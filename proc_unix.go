@@ -0,0 +1,65 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// prepareChild puts the child in its own process group so signals reach
+// everything it spawns. When attached to a terminal the new group is made
+// the foreground group, so interactive commands can still read the tty.
+func prepareChild(cmd *exec.Cmd, interactive bool) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if interactive {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = 0 // child's stdin
+	}
+}
+
+func signalChild(cmd *exec.Cmd, sig os.Signal) {
+	if cmd.Process == nil {
+		return
+	}
+	if s, ok := sig.(syscall.Signal); ok {
+		_ = syscall.Kill(-cmd.Process.Pid, s)
+		return
+	}
+	_ = cmd.Process.Signal(sig)
+}
+
+func killChild(cmd *exec.Cmd) {
+	signalChild(cmd, syscall.SIGKILL)
+}
+
+// reclaimTerminal makes our own process group the foreground one again
+// after an interactive child finishes.
+func reclaimTerminal() {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	pgrp := syscall.Getpgrp()
+	_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgrp)))
+}
+
+func isTerminal(f *os.File) bool {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlGetTermios, uintptr(unsafe.Pointer(&t)))
+	return errno == 0
+}
+
+// detachProcess starts cmd in its own session so it outlives the terminal.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
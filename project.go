@@ -0,0 +1,557 @@
+package main
+
+import (
+	"commandref/paths"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Project commands live in a .commandref.yaml committed to a repo:
+//
+//	commands:
+//	  - title: Build
+//	    command: go build ./...
+//	    tags: [build, go]
+//	  - title: Reset dev db
+//	    command: |
+//	      dropdb app_dev
+//	      createdb app_dev
+//	    workdir: db
+//	    env:
+//	      PGHOST: localhost
+//
+// They're found from the current directory upward, show up in list, search
+// and run as p1, p2... (in file order) and run from the file's directory
+// unless they set a workdir (relative to that directory).
+//
+// The file comes with whatever was cloned or unpacked, so its commands stay
+// out of list, suggest and run until `project allow` has recorded its path
+// and content; any change to the file asks again.
+
+const projectFileName = ".commandref.yaml"
+
+// findProjectFile walks up from the working directory; "" if there is none.
+func findProjectFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range []string{projectFileName, ".commandref.yml"} {
+			p := filepath.Join(dir, name)
+			if st, err := os.Stat(p); err == nil && !st.IsDir() {
+				return p
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+var projectWarned bool
+
+// projectItems loads the project commands, if any and allowed. A broken or
+// unallowed file is reported once instead of breaking the rest of the
+// library.
+func projectItems() []Item {
+	items, err := allowedProjectItems()
+	if err != nil {
+		if !projectWarned {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+			projectWarned = true
+		}
+		return nil
+	}
+	return items
+}
+
+// allowedProjectItems is projectItems with the reason there are none.
+func allowedProjectItems() ([]Item, error) {
+	p := findProjectFile()
+	if p == "" {
+		return nil, nil
+	}
+	items, hash, err := loadProjectFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if ok, seen := projectAllowed(p, hash); !ok {
+		if seen {
+			return nil, fmt.Errorf("%s changed since you allowed it; look it over, then: commandref project allow", p)
+		}
+		return nil, fmt.Errorf("%s isn't allowed to add commands yet; look it over, then: commandref project allow", p)
+	}
+	return items, nil
+}
+
+// loadProjectFile parses p and returns its items and the hash of its
+// content.
+func loadProjectFile(p string) ([]Item, string, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:])
+	items, err := parseProjectYAML(string(b))
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", p, err)
+	}
+	dir := filepath.Dir(p)
+	for i := range items {
+		it := &items[i]
+		it.ID = -(i + 1)
+		if it.Tags == nil {
+			it.Tags = []string{}
+		}
+		switch {
+		case it.Workdir == "":
+			it.Workdir = dir
+		case !filepath.IsAbs(expandHome(it.Workdir)):
+			it.Workdir = filepath.Join(dir, it.Workdir)
+		}
+		if it.Title == "" || it.Command == "" {
+			return nil, "", fmt.Errorf("%s: command %d needs a title and a command", p, i+1)
+		}
+	}
+	return items, hash, nil
+}
+
+func isProjectItem(it Item) bool { return it.ID < 0 }
+
+// displayID is how an item is referred to on the command line: 12 (or its
+// slug or UUID prefix, see idscheme.go), or p3 for a project command.
+func displayID(it Item) string {
+	if isProjectItem(it) {
+		return "p" + strconv.Itoa(-it.ID)
+	}
+	return schemeID(it)
+}
+
+func getProjectItem(id int) (*Item, error) {
+	items, err := allowedProjectItems()
+	if err != nil {
+		return nil, err
+	}
+	if -id > len(items) {
+		return nil, errNotFound
+	}
+	return &items[-id-1], nil
+}
+
+// refuseProjectID stops edit/rm from touching project commands; those are
+// changed in the file itself.
+func refuseProjectID(id int) {
+	if id < 0 {
+		where := findProjectFile()
+		if where == "" {
+			where = projectFileName
+		}
+		exitErr(fmt.Errorf("p%d is a project command; edit it in %s", -id, where))
+	}
+}
+
+type allowedProject struct {
+	Hash string `json:"hash"`
+	At   string `json:"at"`
+}
+
+func allowedProjectsPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "allowed-projects.json"), nil
+}
+
+// loadAllowedProjects maps a project file's path to what was allowed.
+func loadAllowedProjects() map[string]allowedProject {
+	m := map[string]allowedProject{}
+	if p, err := allowedProjectsPath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &m)
+		}
+	}
+	return m
+}
+
+func saveAllowedProjects(m map[string]allowedProject) error {
+	p, err := allowedProjectsPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+// projectAllowed is true when the file at p was allowed with exactly this
+// content; seen says it was allowed before some change.
+func projectAllowed(p, hash string) (ok, seen bool) {
+	a, seen := loadAllowedProjects()[p]
+	return seen && a.Hash == hash, seen
+}
+
+// runProject is `project [show]`, `project allow` and `project forget`.
+func runProject(args []string) {
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+	p := findProjectFile()
+	if p == "" {
+		exitErr(fmt.Errorf("%w: no %s here or in a directory above", errNotFound, projectFileName))
+	}
+	switch args[0] {
+	case "show":
+		items, hash, err := loadProjectFile(p)
+		if err != nil {
+			exitErr(err)
+		}
+		status := "allowed"
+		if ok, seen := projectAllowed(p, hash); !ok && seen {
+			status = colorize("33", "changed since you allowed it (commandref project allow)")
+		} else if !ok {
+			status = colorize("33", "not allowed (commandref project allow)")
+		}
+		fmt.Printf("%s: %s\n", p, status)
+		printProjectCommands(items)
+
+	case "allow":
+		items, hash, err := loadProjectFile(p)
+		if err != nil {
+			exitErr(err)
+		}
+		if ok, _ := projectAllowed(p, hash); ok {
+			fmt.Println("Already allowed:", p)
+			return
+		}
+		fmt.Fprintln(os.Stderr, p+":")
+		printProjectCommands(items)
+		if !confirm("Allow these commands in list, suggest and run?") {
+			fmt.Fprintln(os.Stderr, "Not allowed")
+			os.Exit(exitDeclined)
+		}
+		m := loadAllowedProjects()
+		m[p] = allowedProject{Hash: hash, At: time.Now().UTC().Format(time.RFC3339)}
+		if err := saveAllowedProjects(m); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Allowed", p)
+
+	case "forget":
+		m := loadAllowedProjects()
+		delete(m, p)
+		if err := saveAllowedProjects(m); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Forgot", p)
+
+	default:
+		exitErr(errors.New("usage: commandref project [show] | allow | forget"))
+	}
+}
+
+func printProjectCommands(items []Item) {
+	for _, it := range items {
+		fmt.Fprintf(os.Stderr, "  %s) %s\n", displayID(it), it.Title)
+		for _, l := range strings.Split(it.Command, "\n") {
+			fmt.Fprintf(os.Stderr, "      %s\n", l)
+		}
+		fmt.Fprintf(os.Stderr, "      (in %s)\n", it.Workdir)
+		keys := make([]string, 0, len(it.Env))
+		for k := range it.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(os.Stderr, "      (env %s=%s)\n", k, it.Env[k])
+		}
+	}
+}
+
+// parseProjectYAML understands the small part of YAML the file needs: a
+// top-level "commands" list of maps with plain, quoted or block (| and >)
+// scalars, [a, b] or "- a" lists, and a nested map for env.
+func parseProjectYAML(src string) ([]Item, error) {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var items []Item
+	inCommands := false
+	itemIndent, keyIndent := -1, -1
+
+	for i := 0; i < len(lines); i++ {
+		raw := lines[i]
+		if isBlankYAML(raw) {
+			continue
+		}
+		ind := indentOf(raw)
+		text := strings.TrimSpace(raw)
+
+		if ind == 0 && !(inCommands && strings.HasPrefix(text, "-")) {
+			key, val, ok := strings.Cut(text, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\"", i+1)
+			}
+			inCommands = strings.TrimSpace(key) == "commands" && strings.TrimSpace(val) == ""
+			continue
+		}
+		if !inCommands {
+			continue // other top-level keys are ignored
+		}
+
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			if itemIndent >= 0 && ind != itemIndent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+			}
+			itemIndent = ind
+			items = append(items, Item{})
+			text = strings.TrimSpace(strings.TrimPrefix(text, "-"))
+			if text == "" {
+				keyIndent = -1 // keys start on the next line
+				continue
+			}
+			// the first key sits where the dash would be if it were a space
+			ind = indentOf(raw[:ind] + " " + raw[ind+1:])
+			keyIndent = ind
+		} else if len(items) == 0 {
+			return nil, fmt.Errorf("line %d: expected a \"- title: ...\" entry", i+1)
+		} else if keyIndent < 0 {
+			keyIndent = ind
+		}
+		if ind != keyIndent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+		}
+
+		key, val, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		it := &items[len(items)-1]
+
+		// nested values (block scalars, lists, maps) are the lines below
+		// that are indented deeper than the key
+		end := i + 1
+		for end < len(lines) && (strings.TrimSpace(lines[end]) == "" || indentOf(lines[end]) > keyIndent) {
+			end++
+		}
+		nested := lines[i+1 : end]
+		line := i + 1
+		i = end - 1
+
+		switch key {
+		case "title", "command", "notes", "workdir", "timeout", "icon", "context":
+			s, err := yamlScalar(val, nested, line)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "title":
+				it.Title = s
+			case "command":
+				it.Command = normalizeCommand(s)
+			case "notes":
+				it.Notes = strings.TrimSpace(s)
+			case "workdir":
+				it.Workdir = s
+			case "timeout":
+				it.Timeout = s
+			case "icon":
+				it.Icon = s
+			case "context":
+				it.Context = s
+			}
+		case "tags", "hosts":
+			l, err := yamlList(val, nested, line)
+			if err != nil {
+				return nil, err
+			}
+			if key == "tags" {
+				it.Tags = parseTags(strings.Join(l, ","))
+			} else {
+				it.Hosts = l
+			}
+		case "env":
+			m, err := yamlMap(val, nested, line)
+			if err != nil {
+				return nil, err
+			}
+			it.Env = m
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", line, key)
+		}
+	}
+	return items, nil
+}
+
+func isBlankYAML(s string) bool {
+	t := strings.TrimSpace(s)
+	return t == "" || strings.HasPrefix(t, "#")
+}
+
+func indentOf(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " "))
+}
+
+func yamlScalar(val string, nested []string, line int) (string, error) {
+	if val == "|" || val == "|-" || val == ">" || val == ">-" {
+		return yamlBlock(val, nested), nil
+	}
+	if len(nested) > 0 && !allBlankYAML(nested) {
+		return "", fmt.Errorf("line %d: expected a single value (use | for multiple lines)", line)
+	}
+	return yamlValue(val, line)
+}
+
+// yamlBlock joins the lines of a | (literal) or > (folded) block scalar.
+func yamlBlock(style string, nested []string) string {
+	strip := -1
+	for _, l := range nested {
+		if strings.TrimSpace(l) != "" {
+			strip = indentOf(l)
+			break
+		}
+	}
+	var out []string
+	for _, l := range nested {
+		if len(l) >= strip && strip >= 0 {
+			l = l[strip:]
+		} else {
+			l = strings.TrimSpace(l)
+		}
+		out = append(out, strings.TrimRight(l, " "))
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	s := strings.Join(out, "\n")
+	if strings.HasPrefix(style, ">") {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, "\n\n", "\x00"), "\n", " ")
+		s = strings.ReplaceAll(s, "\x00", "\n")
+	}
+	if !strings.HasSuffix(style, "-") {
+		s += "\n"
+	}
+	return s
+}
+
+func allBlankYAML(lines []string) bool {
+	for _, l := range lines {
+		if !isBlankYAML(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlValue unquotes a single-line scalar and drops a trailing " # comment".
+func yamlValue(s string, line int) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for j := 1; j < len(s); j++ {
+			if s[j] == '\\' {
+				j++
+				continue
+			}
+			if s[j] == '"' {
+				v, err := strconv.Unquote(s[:j+1])
+				if err != nil {
+					return "", fmt.Errorf("line %d: bad quoted string", line)
+				}
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("line %d: unterminated quoted string", line)
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		for j := 1; j < len(s); j++ {
+			if s[j] == '\'' {
+				if j+1 < len(s) && s[j+1] == '\'' {
+					b.WriteByte('\'')
+					j++
+					continue
+				}
+				return b.String(), nil
+			}
+			b.WriteByte(s[j])
+		}
+		return "", fmt.Errorf("line %d: unterminated quoted string", line)
+	}
+	if k := strings.Index(s, " #"); k >= 0 {
+		s = s[:k]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+func yamlList(val string, nested []string, line int) ([]string, error) {
+	if strings.HasPrefix(val, "[") {
+		if !strings.HasSuffix(val, "]") {
+			return nil, fmt.Errorf("line %d: unterminated [list]", line)
+		}
+		var out []string
+		for _, part := range strings.Split(val[1:len(val)-1], ",") {
+			v, err := yamlValue(strings.TrimSpace(part), line)
+			if err != nil {
+				return nil, err
+			}
+			if v != "" {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	}
+	if val != "" {
+		return nil, fmt.Errorf("line %d: expected a list", line)
+	}
+	var out []string
+	for k, l := range nested {
+		if isBlankYAML(l) {
+			continue
+		}
+		t := strings.TrimSpace(l)
+		if !strings.HasPrefix(t, "- ") {
+			return nil, fmt.Errorf("line %d: expected a \"- item\"", line+1+k)
+		}
+		v, err := yamlValue(strings.TrimSpace(t[2:]), line+1+k)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func yamlMap(val string, nested []string, line int) (map[string]string, error) {
+	if val != "" && val != "{}" {
+		return nil, fmt.Errorf("line %d: expected KEY: value lines below", line)
+	}
+	m := map[string]string{}
+	for k, l := range nested {
+		if isBlankYAML(l) {
+			continue
+		}
+		key, v, ok := strings.Cut(strings.TrimSpace(l), ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"KEY: value\"", line+1+k)
+		}
+		s, err := yamlValue(strings.TrimSpace(v), line+1+k)
+		if err != nil {
+			return nil, err
+		}
+		m[strings.TrimSpace(key)] = s
+	}
+	return m, nil
+}
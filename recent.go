@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// runRecent lists the newest items and the ones used last, the quickest
+// way back to "that thing from yesterday".
+func runRecent(args []string) {
+	fs := flag.NewFlagSet("recent", flag.ExitOnError)
+	n := fs.Int("n", 5, "items per section")
+	_ = fs.Parse(args)
+
+	items, freshness, err := listWithFreshness()
+	if err != nil {
+		exitErr(err)
+	}
+	if freshness != "live" && freshness != "local library" {
+		fmt.Printf("(%s)\n", freshness)
+	}
+	usage, _ := loadUsage()
+	items = byArchived(items, false)
+
+	type dated struct {
+		it Item
+		at time.Time
+	}
+	var added, used []dated
+	for _, it := range items {
+		if t, err := time.Parse(time.RFC3339, it.CreatedAt); err == nil {
+			added = append(added, dated{it, t})
+		}
+		if t, err := time.Parse(time.RFC3339, usage.get(it.ID).LastUsedAt); err == nil {
+			used = append(used, dated{it, t})
+		}
+	}
+	newest := func(l []dated) {
+		sort.SliceStable(l, func(i, j int) bool {
+			if !l[i].at.Equal(l[j].at) {
+				return l[i].at.After(l[j].at)
+			}
+			return l[i].it.ID > l[j].it.ID
+		})
+	}
+	newest(added)
+	newest(used)
+
+	now := time.Now()
+	section := func(title string, l []dated, empty string) {
+		fmt.Println(title + ":")
+		if len(l) == 0 {
+			fmt.Println("  " + empty)
+		}
+		for _, d := range l[:min(len(l), *n)] {
+			if opts.Accessible {
+				fmt.Printf("  Item %s, %s, %s\n", displayID(d.it), d.it.Title, relativeTime(d.at, now))
+				continue
+			}
+			fmt.Printf("  %s) %s%s%s  %s\n", displayID(d.it), iconPrefix(d.it), d.it.Title, renderTags(d.it.Tags), colorize("90", relativeTime(d.at, now)))
+		}
+	}
+	section("Recently added", added, "(nothing yet) add one with: commandref add --title ... --cmd ...")
+	fmt.Println()
+	section("Recently used", used, "(nothing run or copied yet)")
+}
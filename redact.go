@@ -0,0 +1,104 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Commands sometimes carry a credential typed in literally (a token, an
+// AWS key, mysql -pPassword). list, show and search mask what looks like
+// one unless --reveal is given, and the run and copy logs never keep it.
+// {{secret:NAME}} placeholders and $VAR references are left alone: they
+// aren't the secret.
+
+type secretPattern struct {
+	re    *regexp.Regexp
+	group int // the submatch holding the secret; 0 is the whole match
+}
+
+var secretPatterns = []secretPattern{
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), 0},
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{30,}\b|\bgithub_pat_[A-Za-z0-9_]{20,}`), 0},
+	{regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`), 0},
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), 0},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`), 0},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), 0}, // JWT
+	{regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{12,}=*)`), 1},
+	// NAME_PASSWORD=..., export API_TOKEN=..., aws_secret_access_key = ...
+	{regexp.MustCompile(`(?i)\b[A-Za-z0-9_]*(?:passw(?:or)?d|secret|token|api_?key|access_key)[A-Za-z0-9_]*\s*[=:]\s*("[^"]*"|'[^']*'|[^\s"';&|]+)`), 1},
+	// --password x, --password=x, --token x, --api-key x ...
+	{regexp.MustCompile(`(?i)--(?:password|passwd|pass|token|secret|api-?key|access-key)(?:=|\s+)("[^"]*"|'[^']*'|[^\s"';&|]+)`), 1},
+	// mysql -pPassword (attached), sshpass -p x, redis-cli -a x
+	{regexp.MustCompile(`\b(?:mysql|mysqldump|mysqladmin|mariadb|mariadb-dump)\b[^|;&\n]*?\s-p([^\s"';&|]+)`), 1},
+	{regexp.MustCompile(`\bsshpass\s+-p\s*([^\s"';&|]+)`), 1},
+	{regexp.MustCompile(`\bredis-cli\b[^|;&\n]*?\s-a\s+([^\s"';&|]+)`), 1},
+	// user:password@ in URLs, curl -u user:password
+	{regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^\s/:@]+:([^\s/@]+)@`), 1},
+	{regexp.MustCompile(`\bcurl\b[^|;&\n]*?\s(?:-u|--user)\s+[^\s:]+:([^\s"';&|]+)`), 1},
+}
+
+// redact masks the likely secrets in s.
+func redact(s string) string {
+	// set the placeholders aside so secret:NAME isn't taken for a value
+	var refs []string
+	s = secretRef.ReplaceAllStringFunc(s, func(m string) string {
+		refs = append(refs, m)
+		return "\x00" + strconv.Itoa(len(refs)-1) + "\x00"
+	})
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
+			if p.group == 0 {
+				return maskSecret(m)
+			}
+			loc := p.re.FindStringSubmatchIndex(m)
+			if loc == nil || loc[2*p.group] < 0 {
+				return m
+			}
+			start, end := loc[2*p.group], loc[2*p.group+1]
+			return m[:start] + maskSecret(m[start:end]) + m[end:]
+		})
+	}
+	for i, m := range refs {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", m, 1)
+	}
+	return s
+}
+
+func maskSecret(v string) string {
+	inner := strings.Trim(v, `"'`)
+	if inner == "" || strings.HasPrefix(inner, "$") || strings.Contains(inner, "\x00") || strings.Contains(inner, "****") {
+		return v
+	}
+	q := v[:len(v)-len(strings.TrimLeft(v, `"'`))]
+	keep := ""
+	if len(inner) >= 16 {
+		keep = inner[:4] // enough to tell keys apart
+	}
+	return q + keep + "****" + q
+}
+
+// displayItem is it as list, show and search print it.
+func displayItem(it Item) Item {
+	if opts.Reveal {
+		return it
+	}
+	it.Command, it.Notes = redact(it.Command), redact(it.Notes)
+	it.PreRun, it.PostRun = redact(it.PreRun), redact(it.PostRun)
+	if len(it.Env) > 0 {
+		env := make(map[string]string, len(it.Env))
+		for k, v := range it.Env {
+			env[k] = redact(k + "=" + v)[len(k)+1:]
+		}
+		it.Env = env
+	}
+	return it
+}
+
+func displayItems(items []Item) []Item {
+	out := make([]Item, len(items))
+	for i, it := range items {
+		out[i] = displayItem(it)
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+)
+
+// checkHostAllowed applies the allow-lists for run --host: the item's own
+// Hosts, and run_hosts entries for any of its tags. With neither, any host
+// is allowed.
+func checkHostAllowed(it *Item, host string) error {
+	var lists [][]string
+	if len(it.Hosts) > 0 {
+		lists = append(lists, it.Hosts)
+	}
+	for _, t := range it.Tags {
+		if hs, ok := cfg.RunHosts[t]; ok {
+			lists = append(lists, hs)
+		}
+	}
+	// every list that applies must allow the host
+	for _, hs := range lists {
+		if !matchHost(hs, host) {
+			return fmt.Errorf("#%d may not run on %s (allowed: %s)", it.ID, host, strings.Join(hs, ", "))
+		}
+	}
+	return nil
+}
+
+func matchHost(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:] // user@host
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sshCommand runs the item on ro.host with the system ssh, so ~/.ssh/config,
+// agents and jump hosts all work as usual.
+func sshCommand(it *Item, ro runOptions) *exec.Cmd {
+	args := []string{"-T"}
+	if isTerminal(os.Stdin) {
+		args[0] = "-t" // so Ctrl-C and full-screen tools work remotely
+	}
+	args = append(args, "--", ro.host, remoteScript(it, ro.extra))
+	return exec.Command("ssh", args...)
+}
+
+// remoteScript turns the item into one line for the remote login shell.
+// Workdir and env are applied there, not locally.
+func remoteScript(it *Item, extra []string) string {
+	var parts []string
+	if dir := it.Workdir; dir != "" {
+		if dir == "~" || strings.HasPrefix(dir, "~/") {
+			parts = append(parts, `cd "$HOME"`+shellQuote(dir[1:])+" || exit 1")
+		} else {
+			parts = append(parts, "cd "+shellQuote(dir)+" || exit 1")
+		}
+	}
+	if len(it.Env) > 0 {
+		keys := make([]string, 0, len(it.Env))
+		for k := range it.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = append(parts, "export "+k+"="+shellQuote(it.Env[k]))
+		}
+	}
+
+	quoted := make([]string, len(extra))
+	for i, a := range extra {
+		quoted[i] = shellQuote(a)
+	}
+	cmd := it.Command
+	if len(extra) > 0 {
+		if positionalRef.MatchString(cmd) {
+			parts = append(parts, "set -- "+strings.Join(quoted, " "))
+		} else {
+			cmd += " " + strings.Join(quoted, " ")
+		}
+	}
+	parts = append(parts, cmd)
+	return strings.Join(parts, "; ")
+}
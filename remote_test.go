@@ -0,0 +1,37 @@
+package main
+
+import (
+	"commandref/config"
+	"strings"
+	"testing"
+)
+
+func TestItemCommandHostSecrets(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	t.Setenv("DB_PASSWORD", "hunter2")
+	cfg = &config.Config{}
+
+	tests := []struct {
+		name    string
+		it      Item
+		wantErr bool
+	}{
+		{"no secrets", Item{ID: 1, Command: "uptime", Env: map[string]string{"LANG": "C"}}, false},
+		{"secret in the command", Item{ID: 2, Command: "mysql -p{{secret:DB_PASSWORD}}"}, true},
+		{"secret in the env", Item{ID: 3, Command: "mysql", Env: map[string]string{"MYSQL_PWD": "{{ secret:DB_PASSWORD }}"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := itemCommand(&tt.it, runOptions{host: "db1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if args := strings.Join(cmd.Args, " "); cmd.Args[0] != "ssh" || strings.Contains(args, "hunter2") {
+				t.Errorf("args = %q", args)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	exitTimedOut    = 124 // same as timeout(1)
+	killGracePeriod = 5 * time.Second
+)
+
+type runOptions struct {
+	extra   []string
+	timeout time.Duration
+	capture *tailBuffer // nil unless --capture
+	host    string      // run over ssh on this host instead of locally
+	win     bool        // on WSL: run in Windows via interop
+}
+
+func runItem(args []string) {
+	if len(args) == 0 {
+		exitErr(errors.New("missing <id>"))
+	}
+	id, err := parseViewID(args[0])
+	if err != nil {
+		exitErr(err)
+	}
+
+	// everything after "--" is handed to the saved command
+	flagArgs, extra := args[1:], []string(nil)
+	for i, a := range flagArgs {
+		if a == "--" {
+			flagArgs, extra = flagArgs[:i], flagArgs[i+1:]
+			break
+		}
+	}
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 0, "stop the command after this long (SIGTERM, then SIGKILL)")
+	capture := fs.Bool("capture", false, "also save the output as the item's last output (see: show --output)")
+	detach := fs.Bool("detach", false, "run in the background as a job (see: jobs, logs, kill)")
+	tmux := fs.String("tmux", "", `run in a new tmux "pane" or "window" named after the item`)
+	host := fs.String("host", "", "run on this host over ssh (uses ~/.ssh/config)")
+	win := fs.Bool("win", false, "on WSL: run in Windows (PowerShell) instead of Linux")
+	linux := fs.Bool("linux", false, "on WSL: run in Linux even if the item defaults to Windows")
+	trust := fs.Bool("trust", false, "run an item someone else in the workspace wrote or changed (asks first in a terminal)")
+	_ = fs.Parse(flagArgs)
+
+	it := mustGetItem(openStore(), id)
+	if f := sealedField(*it); f != "" {
+		exitErr(fmt.Errorf("the %s of #%d is encrypted with a key that isn't here (see: commandref encryption status)", f, it.ID))
+	}
+	mustTrust(*it, *trust)
+
+	ro := runOptions{extra: extra, timeout: *timeout, host: *host}
+	if ro.timeout == 0 && it.Timeout != "" {
+		d, err := time.ParseDuration(it.Timeout)
+		if err != nil {
+			exitErr(fmt.Errorf("item #%d has an invalid timeout %q", it.ID, it.Timeout))
+		}
+		ro.timeout = d
+	}
+
+	if *win || *linux || it.Context == "windows" {
+		if err := applyWSLContext(it, &ro, *win, *linux); err != nil {
+			exitErr(err)
+		}
+	}
+
+	if ro.host != "" {
+		if err := checkHostAllowed(it, ro.host); err != nil {
+			exitErr(err)
+		}
+	}
+
+	if *tmux != "" {
+		if *detach {
+			exitErr(errors.New("--tmux and --detach cannot be combined"))
+		}
+		if err := runInTmux(it, ro, *tmux, *capture); err != nil {
+			exitWith(exitRun, err)
+		}
+		return
+	}
+
+	if *detach {
+		if *capture {
+			exitErr(errors.New("--capture and --detach cannot be combined (use: commandref logs)"))
+		}
+		recordUsage(it, "run")
+		j, err := startDetached(it, ro)
+		if err != nil {
+			exitWith(exitRun, err)
+		}
+		fmt.Printf("Started job %d: %s\n", j.ID, it.Title)
+		fmt.Printf("  commandref logs %d -f    commandref kill %d\n", j.ID, j.ID)
+		return
+	}
+
+	if *capture {
+		if it.NoLog {
+			fmt.Fprintf(os.Stderr, "warning: #%d is marked no-log; output will not be captured\n", it.ID)
+		} else if isProjectItem(*it) {
+			fmt.Fprintf(os.Stderr, "warning: %s is a project command; output will not be captured\n", displayID(*it))
+		} else {
+			ro.capture = newTailBuffer(captureLimit())
+		}
+	}
+
+	recordUsage(it, "run")
+	started := time.Now()
+	code, err := runWithHooks(it, ro)
+	if err != nil {
+		exitWith(exitRun, err)
+	}
+	recordRun(it, ro, started, code)
+	notifyIfLong(it, started, code)
+	if ro.capture != nil {
+		if err := saveCapturedOutput(it.ID, code, ro.capture); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: could not save captured output:", err)
+		}
+	}
+	if code == exitTimedOut {
+		fmt.Fprintf(os.Stderr, "commandref: #%d timed out after %s\n", it.ID, ro.timeout)
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// childStarted, when set, is told the PID of each command execItem starts,
+// which is also its process group: the job wrapper records it for kill.
+var childStarted func(pid int)
+
+// execItem runs the item's command to completion and returns its exit code.
+// SIGINT/SIGTERM sent to commandref are forwarded to the command's process
+// group; on timeout the group gets SIGTERM, then SIGKILL after a grace period.
+func execItem(it *Item, ro runOptions) (int, error) {
+	cmd, err := itemCommand(it, ro)
+	if err != nil {
+		return 0, err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if ro.capture != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, ro.capture)
+		cmd.Stderr = io.MultiWriter(os.Stderr, ro.capture)
+	}
+
+	interactive := isTerminal(os.Stdin)
+	prepareChild(cmd, interactive)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	if childStarted != nil {
+		childStarted(cmd.Process.Pid)
+	}
+	markRunStart(it)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var timeoutC, killC <-chan time.Time
+	if ro.timeout > 0 {
+		timeoutC = time.After(ro.timeout)
+	}
+	timedOut := false
+
+wait:
+	for {
+		select {
+		case err = <-done:
+			break wait
+		case s := <-sigs:
+			signalChild(cmd, s)
+		case <-timeoutC:
+			timedOut = true
+			signalChild(cmd, syscall.SIGTERM)
+			killC = time.After(killGracePeriod)
+		case <-killC:
+			killChild(cmd)
+		}
+	}
+	if interactive {
+		reclaimTerminal()
+	}
+
+	code, err := exitCode(err, timedOut)
+	if err == nil {
+		markRunEnd(code)
+	}
+	return code, err
+}
+
+func exitCode(err error, timedOut bool) (int, error) {
+	if timedOut {
+		return exitTimedOut, nil
+	}
+	if err != nil {
+		// return underlying exit code if any
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				return 128 + int(ws.Signal()), nil // shell convention
+			}
+			return ee.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+// itemCommand builds the shell invocation for an item, with its workdir and env.
+func itemCommand(it *Item, ro runOptions) (*exec.Cmd, error) {
+	if ro.host != "" {
+		// resolved, they'd be in the ssh argv here and in the remote
+		// process list
+		if secretRef.MatchString(it.Command) || envHasSecrets(it.Env) {
+			return nil, fmt.Errorf("#%d uses {{secret:...}}, which isn't sent over ssh; run it on %s with the secret set there", it.ID, ro.host)
+		}
+		return sshCommand(it, ro), nil
+	}
+	it, err := resolveItemSecrets(it)
+	if err != nil {
+		return nil, err
+	}
+	sh := runShell()
+	if ro.win {
+		sh = wslWindowsShell()
+	}
+	cmd := shellExec(sh, it.Command, ro.extra)
+	if err := applyRunContext(cmd, it); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// shellArgs builds the `-lc` invocation. Commands that reference $@, $1...
+// get the extra args as positional parameters; otherwise they're appended,
+// quoted, to the end of the command.
+func shellArgs(command string, extra []string) []string {
+	if len(extra) == 0 {
+		return []string{"-lc", command}
+	}
+	if positionalRef.MatchString(command) {
+		return append([]string{"-lc", command, "commandref"}, extra...)
+	}
+	quoted := make([]string, len(extra))
+	for i, a := range extra {
+		quoted[i] = shellQuote(a)
+	}
+	return []string{"-lc", command + " " + strings.Join(quoted, " ")}
+}
+
+// applyRunContext sets the item's working directory and extra environment:
+// the workspace's and profile's defaults, then the item's own.
+func applyRunContext(cmd *exec.Cmd, it *Item) error {
+	if it.Workdir != "" {
+		dir := expandHome(it.Workdir)
+		if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+			return fmt.Errorf("workdir %s does not exist", it.Workdir)
+		}
+		cmd.Dir = dir
+	}
+	defaults := runDefaults().Env
+	if len(it.Env) > 0 || len(defaults) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range defaults {
+			if _, own := it.Env[k]; own {
+				continue
+			}
+			v, err := resolveSecrets(v)
+			if err != nil {
+				return err
+			}
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		for k, v := range it.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	return nil
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// envFlag collects repeated --env KEY=VALUE flags.
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + e[k]
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e envFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", s)
+	}
+	e[strings.TrimSpace(k)] = v
+	return nil
+}
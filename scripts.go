@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"commandref/paths"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// User scripts are Go text/template files under <config dir>/scripts:
+//
+//	filters/<name>.tmpl     list --filter <name>; keep items rendering "true"
+//	formats/<name>.tmpl     list --format <name>; rendered once per item
+//	transforms/<name>.tmpl  run on every add, in name order; each output
+//	                        line "field: value" overrides that field
+//
+// Templates only get the item and the functions below, so they can't touch
+// files, the network or the environment. That isn't a full sandbox: a
+// template can still loop, so each run is cut off after scriptTimeout or
+// scriptMaxOutput bytes (see execScript). Treat a script like any other
+// code you install and read it first.
+var scriptKinds = []string{"filters", "formats", "transforms"}
+
+var scriptTimeout = 2 * time.Second
+
+const scriptMaxOutput = 64 << 10
+
+func scriptsDir(kind string) (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "scripts", kind), nil
+}
+
+func scriptFuncs() template.FuncMap {
+	return template.FuncMap{
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"trim":      strings.TrimSpace,
+		"contains":  strings.Contains,
+		"hasPrefix": strings.HasPrefix,
+		"hasSuffix": strings.HasSuffix,
+		"replace":   strings.ReplaceAll,
+		"split":     strings.Split,
+		"join":      strings.Join,
+		"append":    func(list []string, s ...string) []string { return append(append([]string(nil), list...), s...) },
+		"hasTag":    func(it Item, tag string) bool { return hasTag(it, tag) },
+		"matches": func(pattern, s string) (bool, error) {
+			return regexp.MatchString(pattern, s)
+		},
+		// the builtin would call any func value reachable from the data
+		"call": func(any, ...any) (any, error) { return nil, errors.New("call isn't available in scripts") },
+		// days since an RFC3339 timestamp, e.g. {{ if gt (ageDays .UpdatedAt) 90 }}
+		"ageDays": func(ts string) int {
+			t, err := time.Parse(time.RFC3339, ts)
+			if err != nil {
+				return 0
+			}
+			return int(time.Since(t).Hours() / 24)
+		},
+	}
+}
+
+func loadScript(kind, name string) (*template.Template, error) {
+	dir, err := scriptsDir(kind)
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.Join(dir, name+".tmpl")
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no %s script %q (expected %s)", strings.TrimSuffix(kind, "s"), name, p)
+		}
+		return nil, err
+	}
+	return template.New(name).Funcs(scriptFuncs()).Option("missingkey=error").Parse(string(b))
+}
+
+// execScript runs t on data, giving up after scriptTimeout or once it has
+// written scriptMaxOutput bytes. A template stuck in a loop keeps its
+// goroutine until the process exits, which for a command is soon.
+func execScript(t *template.Template, data any) (string, error) {
+	w := &cappedBuffer{max: scriptMaxOutput}
+	done := make(chan error, 1)
+	go func() { done <- t.Execute(w, data) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		return w.buf.String(), nil
+	case <-time.After(scriptTimeout):
+		return "", fmt.Errorf("still running after %s", scriptTimeout)
+	}
+}
+
+type cappedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if c.buf.Len()+len(p) > c.max {
+		return 0, fmt.Errorf("output over %d KiB", c.max>>10)
+	}
+	return c.buf.Write(p)
+}
+
+func scriptNames(kind string) []string {
+	dir, err := scriptsDir(kind)
+	if err != nil {
+		return nil
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(m), ".tmpl"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func filterWithScript(name string, items []Item) ([]Item, error) {
+	t, err := loadScript("filters", name)
+	if err != nil {
+		return nil, err
+	}
+	var out []Item
+	for _, it := range items {
+		s, err := execScript(t, it)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s: %w", name, err)
+		}
+		if strings.TrimSpace(s) == "true" {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+// printWithScript renders each item with the named format script, or with
+// the template itself when it is given inline ("{{.ID}}\t{{.Title}}", or
+// "go-template=..."); \t and \n in an inline one are a tab and a newline.
+func printWithScript(name string, items []Item) error {
+	var t *template.Template
+	var err error
+	if inline, ok := strings.CutPrefix(name, "go-template="); ok || strings.Contains(name, "{{") {
+		if !ok {
+			inline = name
+		}
+		inline = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(inline)
+		t, err = template.New("inline").Funcs(scriptFuncs()).Option("missingkey=error").Parse(inline)
+		name = "template"
+	} else {
+		t, err = loadScript("formats", name)
+	}
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, it := range items {
+		s, err := execScript(t, it)
+		if err != nil {
+			return fmt.Errorf("format %s: %w", name, err)
+		}
+		if s != "" && !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		w.WriteString(s)
+	}
+	return nil
+}
+
+// applyTransforms runs every transform script over a new item.
+func applyTransforms(it *Item) error {
+	for _, name := range scriptNames("transforms") {
+		t, err := loadScript("transforms", name)
+		if err != nil {
+			return err
+		}
+		s, err := execScript(t, *it)
+		if err != nil {
+			return fmt.Errorf("transform %s: %w", name, err)
+		}
+		for _, line := range strings.Split(s, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			field, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return fmt.Errorf("transform %s: expected \"field: value\", got %q", name, line)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(field)) {
+			case "title":
+				it.Title = value
+			case "command":
+				it.Command = value
+			case "tags":
+				it.Tags = parseTags(value)
+			case "notes":
+				it.Notes = value
+			case "icon":
+				it.Icon = value
+			case "workdir":
+				it.Workdir = value
+			case "timeout":
+				it.Timeout = value
+			default:
+				return fmt.Errorf("transform %s: unknown field %q", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+func runScripts(args []string) {
+	dir, err := scriptsDir("")
+	if err != nil {
+		exitErr(err)
+	}
+	fmt.Println("Scripts in", dir)
+	for _, kind := range scriptKinds {
+		names := scriptNames(kind)
+		if len(names) == 0 {
+			fmt.Printf("  %-11s (none)\n", kind+":")
+			continue
+		}
+		fmt.Printf("  %-11s %s\n", kind+":", strings.Join(names, ", "))
+	}
+}
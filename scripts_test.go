@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestExecScript(t *testing.T) {
+	defer func(d time.Duration) { scriptTimeout = d }(scriptTimeout)
+	scriptTimeout = 50 * time.Millisecond
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{"plain", `{{.ID}} {{upper .Title}}`, "7 DEPLOY", ""},
+		{"filter", `{{hasTag . "ops"}}`, "true", ""},
+		{"call is off", `{{call .Title}}`, "", "call isn't available"},
+		{"too much output", `{{range 100000}}{{$.Command}}{{end}}`, "", "output over"},
+		{"endless loop", `{{range 20000000}}{{end}}`, "", "still running"},
+	}
+	it := Item{ID: 7, Title: "deploy", Command: "make deploy", Tags: []string{"ops"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(scriptFuncs()).Option("missingkey=error").Parse(tt.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := execScript(tmpl, it)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"commandref/keychain"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A command can say {{secret:DB_PASSWORD}} where a credential goes. Only
+// the placeholder is saved (and synced); `run` and `copy` fill it in from
+// the environment, the secret_command in the config, or the OS keychain
+// (service "commandref": macOS Keychain, or the Secret Service through
+// secret-tool on Linux), in that order.
+
+var secretRef = regexp.MustCompile(`\{\{\s*secret:([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+var resolvedSecrets = map[string]string{}
+
+// resolveSecrets fills in every {{secret:NAME}} in s.
+func resolveSecrets(s string) (string, error) {
+	var missing []string
+	out := secretRef.ReplaceAllStringFunc(s, func(m string) string {
+		name := secretRef.FindStringSubmatch(m)[1]
+		v, _, err := lookupSecret(name)
+		if err != nil {
+			missing = append(missing, name)
+			return m
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("secret %s is not set: export it, or store it with: commandref secret set %s", strings.Join(missing, ", "), missing[0])
+	}
+	return out, nil
+}
+
+// resolveItemSecrets returns a copy of it with the secrets in its command
+// and env filled in, for running; it itself keeps the placeholders.
+func resolveItemSecrets(it *Item) (*Item, error) {
+	if !secretRef.MatchString(it.Command) && !envHasSecrets(it.Env) {
+		return it, nil
+	}
+	r := *it
+	var err error
+	if r.Command, err = resolveSecrets(it.Command); err != nil {
+		return nil, err
+	}
+	if len(it.Env) > 0 {
+		r.Env = make(map[string]string, len(it.Env))
+		for k, v := range it.Env {
+			if r.Env[k], err = resolveSecrets(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &r, nil
+}
+
+func envHasSecrets(env map[string]string) bool {
+	for _, v := range env {
+		if secretRef.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupSecret finds a secret's value and says where it came from.
+func lookupSecret(name string) (value, source string, err error) {
+	if v, ok := resolvedSecrets[name]; ok {
+		return v, "", nil
+	}
+	defer func() {
+		if err == nil {
+			resolvedSecrets[name] = value
+		}
+	}()
+	if v, ok := os.LookupEnv(name); ok {
+		return v, "environment", nil
+	}
+	if cfg.SecretCommand != "" {
+		cmd := exec.Command("/bin/sh", "-c", cfg.SecretCommand)
+		cmd.Env = append(os.Environ(), "COMMANDREF_SECRET="+name)
+		cmd.Stderr = os.Stderr
+		if out, err := cmd.Output(); err == nil && len(out) > 0 {
+			return strings.TrimRight(string(out), "\r\n"), "secret_command", nil
+		}
+	}
+	if v, err := keychain.Get(name); err == nil {
+		return v, "keychain", nil
+	}
+	return "", "", fmt.Errorf("secret %s is not set", name)
+}
+
+func runSecret(args []string) {
+	usage := "usage: commandref secret list | secret set <NAME> | secret rm <NAME>"
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list", "ls":
+		runSecretList()
+	case "set":
+		fs := flag.NewFlagSet("secret set", flag.ExitOnError)
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 1 || !secretRef.MatchString("{{secret:"+fs.Arg(0)+"}}") {
+			exitErr(errors.New(usage))
+		}
+		value, err := readSecret(fmt.Sprintf("Value for %s: ", fs.Arg(0)))
+		if err != nil || value == "" {
+			exitErr(errors.New("no value given"))
+		}
+		if err := keychain.Set(fs.Arg(0), value); err != nil {
+			exitErr(err)
+		}
+		fmt.Printf("Stored %s in the keychain; use it as {{secret:%s}}\n", fs.Arg(0), fs.Arg(0))
+	case "rm":
+		if len(args) != 2 {
+			exitErr(errors.New(usage))
+		}
+		if err := keychain.Delete(args[1]); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Removed", args[1], "from the keychain")
+	default:
+		exitErr(errors.New(usage))
+	}
+}
+
+// runSecretList shows the secrets the library refers to and where each
+// would come from, never the values.
+func runSecretList() {
+	items, err := openStore().List()
+	if err != nil {
+		exitErr(err)
+	}
+	usedBy := map[string][]string{}
+	for _, it := range append(items, projectItems()...) {
+		texts := []string{it.Command}
+		for _, v := range it.Env {
+			texts = append(texts, v)
+		}
+		seen := map[string]bool{}
+		for _, t := range texts {
+			for _, m := range secretRef.FindAllStringSubmatch(t, -1) {
+				if !seen[m[1]] {
+					seen[m[1]] = true
+					usedBy[m[1]] = append(usedBy[m[1]], "#"+displayID(it))
+				}
+			}
+		}
+	}
+	if len(usedBy) == 0 {
+		fmt.Println("(no {{secret:NAME}} placeholders in the library)")
+		return
+	}
+	var names []string
+	for n := range usedBy {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	missing := 0
+	for _, n := range names {
+		_, source, err := lookupSecret(n)
+		if err != nil {
+			source = colorize("31", "not set")
+			missing++
+		}
+		fmt.Printf("%s  %s  (used by %s)\n", n, source, strings.Join(usedBy[n], ", "))
+	}
+	if missing > 0 {
+		fmt.Println("Store a missing one with: commandref secret set <NAME>")
+	}
+}
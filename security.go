@@ -0,0 +1,298 @@
+package main
+
+import (
+	"commandref/auth"
+	"commandref/config"
+	"commandref/keychain"
+	"commandref/paths"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// `doctor` and `whoami` say what commandref keeps in plain text on this
+// machine (the login, the library, credentials in the config, files other
+// users can read) and `secure` moves what it can to the safer option: the
+// OS keychain, field encryption, and files only the user can read.
+
+type configCredential struct {
+	key, env, value string
+}
+
+// configCredentials are the config settings holding a credential.
+func configCredentials() []configCredential {
+	return []configCredential{
+		{"ai.api_key", "COMMANDREF_AI_KEY", cfg.AI.APIKey},
+		{"sync.webdav.password", "COMMANDREF_WEBDAV_PASSWORD", cfg.Sync.WebDAV.Password},
+		{"sync.gist.token", "COMMANDREF_GIST_TOKEN", cfg.Sync.Gist.Token},
+		{"export_webhook_secret", "COMMANDREF_WEBHOOK_SECRET", cfg.ExportWebhookSecret},
+	}
+}
+
+// credential is a credential from its environment variable, else the
+// config, else the keychain, where `secure` moves it.
+func credential(env, fromConfig, key string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	if fromConfig != "" {
+		return fromConfig
+	}
+	v, _ := keychain.Get("config:" + key)
+	return v
+}
+
+// sessionStorage says where the login is kept; plain is true when the
+// token sits in a file.
+func sessionStorage() (desc string, plain bool) {
+	s, err := auth.LoadSession()
+	switch {
+	case err != nil:
+		return colorize("31", err.Error()), false
+	case s == nil:
+		return "not logged in", false
+	case s.Keychain:
+		return "token in the OS keychain", false
+	}
+	p, _ := auth.SessionFile()
+	return "token in plain text in " + tildePath(p), true
+}
+
+// libraryStorage says how the library is kept; plain is true when a
+// hosted library could be encrypted and isn't.
+func libraryStorage() (desc string, plain bool) {
+	if usingLocalStore() {
+		p, _ := dbPath()
+		return "plain text in " + tildePath(p) + " (never leaves this machine; not encrypted)", false
+	}
+	if ws := currentWorkspace(); ws.ID != "" {
+		// a personal key can't protect what the team shares
+		return "team workspace " + ws.label() + ", in plain text on the backend", false
+	}
+	if !e2eEnabled() {
+		return "hosted, in plain text on the backend", true
+	}
+	fields, _ := e2eFields()
+	how := "a passphrase"
+	if cfg.Encryption.KeyFile != "" {
+		how = "key file " + cfg.Encryption.KeyFile
+	}
+	desc = fmt.Sprintf("hosted, %s encrypted before upload with %s", strings.Join(fields, ", "), how)
+	if _, err := currentE2EKey(); err != nil {
+		desc += colorize("31", " (but: "+err.Error()+")")
+	}
+	return desc, false
+}
+
+// loosePermissions lists the files and directories of commandref's own
+// that users other than this one can read or write.
+func loosePermissions() []string {
+	if runtime.GOOS == "windows" {
+		return nil // ACLs, not mode bits
+	}
+	var out []string
+	seen := map[string]bool{}
+	check := func(p string, d fs.DirEntry) {
+		info, err := d.Info()
+		if err != nil || seen[p] || info.Mode()&os.ModeSymlink != 0 {
+			return
+		}
+		seen[p] = true
+		if info.Mode().Perm()&0077 != 0 {
+			out = append(out, p)
+		}
+	}
+	var roots []string
+	for _, dir := range []func() (string, error){paths.ConfigDir, paths.DataDir, paths.CacheDir} {
+		if d, err := dir(); err == nil {
+			roots = append(roots, d)
+		}
+	}
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			// a git library's objects are shared read-only by design
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			check(p, d)
+			return nil
+		})
+	}
+	return out
+}
+
+// printSecurity is the security part of `whoami` and `doctor`; it
+// returns how many things `secure` could improve.
+func printSecurity(full bool) int {
+	fixable := 0
+	session, plain := sessionStorage()
+	if plain && keychain.Available() {
+		fixable++
+		session = colorize("33", session)
+	}
+	fmt.Println("Session:", session)
+	library, plain := libraryStorage()
+	if plain {
+		fixable++
+		library = colorize("33", library)
+	}
+	fmt.Println("Library:", library)
+	if !full {
+		if fixable > 0 {
+			fmt.Println("Secure these with: commandref secure")
+		}
+		return fixable
+	}
+
+	if !usingLocalStore() {
+		if dir, err := paths.CacheDir(); err == nil {
+			fmt.Println("Offline cache: plain text in " + tildePath(dir) + " (not encrypted)")
+		}
+	}
+	var inConfig []string
+	for _, c := range configCredentials() {
+		if c.value != "" {
+			inConfig = append(inConfig, c.key)
+		}
+	}
+	if len(inConfig) > 0 {
+		fixable++
+		fmt.Println("Credentials in config.json:", colorize("33", strings.Join(inConfig, ", ")+" in plain text"))
+	} else {
+		fmt.Println("Credentials in config.json: none")
+	}
+	if loose := loosePermissions(); len(loose) > 0 {
+		fixable++
+		fmt.Println("Permissions:", colorize("33", fmt.Sprintf("%d files or directories other users can read", len(loose))))
+	} else {
+		fmt.Println("Permissions: only you can read commandref's files")
+	}
+	if fixable > 0 {
+		fmt.Printf("%s to secure; run: commandref secure\n", plural(fixable, "thing"))
+	}
+	return fixable
+}
+
+// runSecure walks through moving plain-text state to the safer options,
+// asking before each step. Outside a terminal it only says what it would do.
+func runSecure() {
+	interactive := canPrompt()
+	step := func(question string) bool {
+		if opts.Yes {
+			fmt.Println(question, "yes")
+			return true
+		}
+		if !interactive {
+			fmt.Println("Would:", question)
+			return false
+		}
+		return askYes(question)
+	}
+	did, offered := 0, 0
+
+	if loose := loosePermissions(); len(loose) > 0 {
+		offered++
+		fmt.Printf("These can be read by other users on this machine:\n")
+		for _, p := range loose {
+			fmt.Println("  " + tildePath(p))
+		}
+		if step("Make them readable only by you (files 600, directories 700)?") {
+			for _, p := range loose {
+				mode := os.FileMode(0600)
+				if info, err := os.Stat(p); err == nil && info.IsDir() {
+					mode = 0700
+				}
+				if err := os.Chmod(p, mode); err != nil {
+					exitErr(err)
+				}
+			}
+			fmt.Println("Done.")
+			did++
+		}
+	}
+
+	if _, plain := sessionStorage(); plain {
+		offered++
+		switch {
+		case !keychain.Available():
+			fmt.Println("The login token is in a plain file and there is no keychain here (macOS Keychain, or secret-tool on Linux).")
+		case step("Move the login token into the OS keychain?"):
+			s, err := auth.LoadSession()
+			if err == nil {
+				auth.UseKeychain = true
+				err = auth.SaveSession(*s)
+			}
+			if err == nil {
+				err = setConfig("session_storage", "keychain")
+			}
+			if err != nil {
+				exitErr(err)
+			}
+			fmt.Println("Moved; later logins keep it there too.")
+			did++
+		}
+	}
+
+	for _, c := range configCredentials() {
+		if c.value == "" {
+			continue
+		}
+		offered++
+		if !keychain.Available() {
+			fmt.Printf("%s is in config.json in plain text; with no keychain here, set %s instead.\n", c.key, c.env)
+			continue
+		}
+		if !step(fmt.Sprintf("Move %s from config.json into the OS keychain?", c.key)) {
+			continue
+		}
+		err := keychain.Set("config:"+c.key, c.value)
+		if err == nil {
+			err = setConfig(c.key, nil)
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Moved.")
+		did++
+	}
+
+	if _, plain := libraryStorage(); plain {
+		offered++
+		dir, err := paths.DataDir()
+		if err != nil {
+			exitErr(err)
+		}
+		key := filepath.Join(dir, "e2e.key")
+		fmt.Println("Commands and notes are stored on the backend in plain text.")
+		if step(fmt.Sprintf("Encrypt them before upload, with a new key in %s?", tildePath(key))) {
+			if err := writeE2EKey(key); err != nil {
+				exitErr(err)
+			}
+			for k, v := range map[string]any{"encryption.enabled": true, "encryption.key_file": key} {
+				if err := setConfig(k, v); err != nil {
+					exitErr(err)
+				}
+			}
+			cfg.Encryption.Enabled, cfg.Encryption.KeyFile = true, key
+			runEncryptionRotate(nil)
+			fmt.Printf("Keep a copy of %s somewhere safe (and on your other machines): without it the encrypted fields are lost.\n", tildePath(key))
+			did++
+		}
+	}
+
+	switch {
+	case offered == 0:
+		fmt.Println("Nothing to secure: no plain-text login, credentials or readable files.")
+	case !interactive:
+		fmt.Println("Run commandref secure in a terminal to choose.")
+	default:
+		p, _ := config.Path()
+		fmt.Printf("Secured %d of %d (settings in %s).\n", did, offered, tildePath(p))
+	}
+}
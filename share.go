@@ -0,0 +1,191 @@
+package main
+
+import (
+	"commandref/api"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shareLink is the backend's answer to POST /v1/commands/<id>/share.
+type shareLink struct {
+	URL       string `json:"url"`
+	Slug      string `json:"slug"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// parseExpiry accepts Go durations plus a "d" suffix for days ("7d").
+func parseExpiry(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q (try 24h or 7d)", s)
+	}
+	return d, nil
+}
+
+func runShare(args []string) {
+	if usingLocalStore() {
+		exitErr(errors.New("share links are created by the backend; the local library can't share (try: commandref export)"))
+	}
+	if e2eEnabled() {
+		exitErr(errors.New("with encryption on, the backend only has ciphertext to share (try: commandref export, or publish)"))
+	}
+	if len(args) == 0 {
+		exitErr(errors.New("missing <id>"))
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		exitErr(err)
+	}
+	fs := flag.NewFlagSet("share", flag.ExitOnError)
+	expires := fs.String("expires", "", "make the link stop working after this long, e.g. 24h or 7d")
+	noCopy := fs.Bool("no-copy", false, "only print the URL, don't copy it")
+	_ = fs.Parse(args[1:])
+
+	body := map[string]any{}
+	if *expires != "" {
+		d, err := parseExpiry(*expires)
+		if err != nil {
+			exitErr(err)
+		}
+		body["expiresAt"] = time.Now().Add(d).UTC().Format(time.RFC3339)
+	}
+
+	var link shareLink
+	c := newAPIClient()
+	if err := c.DoJSON("POST", c.Path(fmt.Sprintf("/v1/commands/%d/share", id)), body, &link); err != nil {
+		exitShareErr(err)
+	}
+
+	fmt.Println(link.URL)
+	if link.ExpiresAt != "" {
+		fmt.Fprintf(os.Stderr, "Read-only link, expires %s\n", formatTime(link.ExpiresAt))
+	} else {
+		fmt.Fprintf(os.Stderr, "Read-only link, no expiry (revoke with: commandref unshare %d)\n", id)
+	}
+	if !*noCopy {
+		if err := copyToClipboard(link.URL); err == nil {
+			fmt.Fprintln(os.Stderr, "Copied link to clipboard")
+		}
+	}
+}
+
+func runUnshare(args []string) {
+	if usingLocalStore() {
+		exitErr(errors.New("the local library has no share links"))
+	}
+	if len(args) == 0 {
+		exitErr(errors.New("missing <id>"))
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		exitErr(err)
+	}
+	c := newAPIClient()
+	if err := c.DoJSON("DELETE", c.Path(fmt.Sprintf("/v1/commands/%d/share", id)), nil, nil); err != nil {
+		exitShareErr(err)
+	}
+	fmt.Printf("Revoked share link for #%d\n", id)
+}
+
+func exitShareErr(err error) {
+	if err = apiErr(err); errors.Is(err, errNotFound) {
+		exitWith(exitNotFound, errNotFound)
+	}
+	exitErr(err)
+}
+
+var (
+	shareSlug    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	errShareGone = errors.New("share link not found or expired")
+
+	shareClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// fetchShared gets a publicly shared command by URL or slug. Shared links
+// need no login.
+func fetchShared(ref string) (*Item, error) {
+	u := ref
+	if !strings.HasPrefix(ref, "http://") && !strings.HasPrefix(ref, "https://") {
+		u = strings.TrimRight(api.New().BaseURL, "/") + "/v1/shared/" + url.PathEscape(ref)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	id := api.PrepareRequest(req)
+	res, err := shareClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode == 404 || res.StatusCode == 410 {
+		return nil, errShareGone
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetching %s: %s (request %s)", u, res.Status, id)
+	}
+	var it Item
+	if err := json.Unmarshal(b, &it); err != nil || it.Command == "" {
+		return nil, fmt.Errorf("%s is not a shared command", u)
+	}
+	return &it, nil
+}
+
+// importShared previews a shared command and saves a copy after confirmation.
+func importShared(ref string, yes bool) {
+	it, err := fetchShared(ref)
+	if err != nil {
+		if errors.Is(err, errShareGone) {
+			exitWith(exitNotFound, err)
+		}
+		exitErr(err)
+	}
+
+	// someone else wrote it: no escape sequences hiding part of the command
+	fmt.Printf("Title: %s\n", terminalSafe(it.Title))
+	if len(it.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", terminalSafe(strings.Join(it.Tags, ", ")))
+	}
+	if it.Notes != "" {
+		fmt.Printf("Notes: %s\n", terminalSafe(it.Notes))
+	}
+	fmt.Printf("Command:\n%s\n\n", terminalSafe(it.Command))
+
+	if !yes && !confirm("Save to your library?") {
+		fmt.Println("Not saved")
+		return
+	}
+
+	// only the content comes along; run settings (workdir, env, hooks,
+	// hosts) from someone else's machine are dropped
+	created, err := openStore().Create(Item{
+		Title:   it.Title,
+		Command: normalizeCommand(it.Command),
+		Tags:    parseTags(strings.Join(it.Tags, ",")),
+		Notes:   it.Notes,
+		Icon:    it.Icon,
+	})
+	if err != nil {
+		exitErr(err)
+	}
+	fmt.Printf("Saved #%d: %s\n", created.ID, created.Title)
+}
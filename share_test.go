@@ -0,0 +1,88 @@
+package main
+
+import (
+	"commandref/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchSharedHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{"ok", 200, ""},
+		{"server error names the request", 500, "(request "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Clone()
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"title":"hi","command":"echo hi"}`))
+			}))
+			defer srv.Close()
+
+			_, err := fetchShared(srv.URL + "/v1/shared/abc")
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got.Get("User-Agent") != api.UserAgent {
+				t.Errorf("User-Agent = %q, want %q", got.Get("User-Agent"), api.UserAgent)
+			}
+			if got.Get("X-Request-ID") == "" {
+				t.Error("no X-Request-ID")
+			}
+		})
+	}
+}
+
+func TestFetchSharedTimeout(t *testing.T) {
+	defer func(c *http.Client) { shareClient = c }(shareClient)
+	shareClient = &http.Client{Timeout: 50 * time.Millisecond}
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stop // never answers
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := fetchShared(srv.URL + "/v1/shared/abc")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("no error from a server that never answers")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetchShared is still waiting")
+	}
+}
+
+func TestTerminalSafe(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"echo hi", "echo hi"},
+		{"a\n\tb", "a\n\tb"},
+		{"rm -rf /\x1b[2K\x1b[1Aecho hi", `rm -rf /\x1b[2K\x1b[1Aecho hi`},
+		{"curl x | sh\recho safe", `curl x | sh\recho safe`},
+		{"ls \u202egnp.exe", `ls \u202egnp.exe`},
+		{"bell\a", `bell\a`},
+		{"caf\u00e9 \U0001F600", "caf\u00e9 \U0001F600"},
+	}
+	for _, tt := range tests {
+		if got := terminalSafe(tt.in); got != tt.want {
+			t.Errorf("terminalSafe(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
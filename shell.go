@@ -0,0 +1,193 @@
+package main
+
+import (
+	"commandref/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// runShell is the shell `run` uses: the workspace's or profile's, else the
+// "shell" config setting, else zsh (PowerShell on Windows).
+func runShell() string {
+	if sh := runDefaults().Shell; sh != "" {
+		return sh
+	}
+	if cfg != nil && cfg.Shell != "" {
+		return cfg.Shell
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "/bin/zsh"
+}
+
+// runDefaults merges the run settings of the active profile and of the
+// current workspace, the workspace's winning.
+func runDefaults() config.RunDefaults {
+	var d config.RunDefaults
+	if cfg == nil {
+		return d
+	}
+	layers := []config.RunDefaults{}
+	if p, ok := cfg.Profiles[os.Getenv("COMMANDREF_PROFILE")]; ok {
+		layers = append(layers, p.RunDefaults)
+	}
+	if len(cfg.Workspaces) > 0 {
+		ws := currentWorkspace()
+		for key, w := range cfg.Workspaces {
+			if (ws.ID != "" && key == ws.ID) || strings.EqualFold(key, ws.label()) {
+				layers = append(layers, w)
+				break
+			}
+		}
+	}
+	for _, l := range layers {
+		if l.Shell != "" {
+			d.Shell = l.Shell
+		}
+		for k, v := range l.Env {
+			if d.Env == nil {
+				d.Env = map[string]string{}
+			}
+			d.Env[k] = v
+		}
+	}
+	return d
+}
+
+// shellFlavor groups shells by quoting rules: "cmd", "powershell" or "posix".
+func shellFlavor(sh string) string {
+	base := strings.ToLower(filepath.Base(strings.ReplaceAll(sh, `\`, "/")))
+	switch strings.TrimSuffix(base, ".exe") {
+	case "cmd":
+		return "cmd"
+	case "powershell", "pwsh":
+		return "powershell"
+	default:
+		return "posix"
+	}
+}
+
+// normalizeCommand turns CRLF (pasted from Windows editors and terminals)
+// into LF, so stored commands look the same everywhere.
+func normalizeCommand(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r", "\n"))
+}
+
+// shellExec builds the exec.Cmd for a command and pass-through args in sh.
+func shellExec(sh, command string, extra []string) *exec.Cmd {
+	command = normalizeCommand(command)
+	switch shellFlavor(sh) {
+	case "cmd":
+		line := inlineArgs(translateEnvRefs(command, "cmd"), extra, cmdQuote)
+		cmd := exec.Command(sh, "/d", "/s", "/c", line)
+		// cmd.exe does its own parsing; Go's argv escaping would mangle it
+		setRawCmdLine(cmd, sh+` /d /s /c "`+line+`"`)
+		return cmd
+	case "powershell":
+		line := inlineArgs(translateEnvRefs(command, "powershell"), extra, psQuote)
+		return exec.Command(sh, "-NoProfile", "-NonInteractive", "-Command", line)
+	default:
+		// Use login shell so user's PATH etc works.
+		return exec.Command(sh, shellArgs(command, extra)...)
+	}
+}
+
+// cmd.exe: double quotes, with embedded quotes doubled. Nothing can stop %VAR%
+// expansion inside cmd /c, so args containing % are passed as-is.
+func cmdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"&|<>^()") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// PowerShell: single quotes are literal, with embedded quotes doubled. The
+// curly ones (U+2018 to U+201B) end a '...' string too.
+func psQuote(s string) string {
+	return "'" + psQuotes.Replace(s) + "'"
+}
+
+var psQuotes = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+var positionalArg = regexp.MustCompile(`\$(?:\{([1-9@*])(?::-([^}]*))?\}|([1-9@*]))`)
+
+// inlineArgs is shellArgs for shells without $1/$@: positional references are
+// replaced with the quoted args, otherwise the args are appended.
+func inlineArgs(command string, extra []string, quote func(string) string) string {
+	quoted := make([]string, len(extra))
+	for i, a := range extra {
+		quoted[i] = quote(a)
+	}
+	if !positionalRef.MatchString(command) {
+		if len(quoted) == 0 {
+			return command
+		}
+		return command + " " + strings.Join(quoted, " ")
+	}
+	return positionalArg.ReplaceAllStringFunc(command, func(m string) string {
+		ref := positionalArg.FindStringSubmatch(m)
+		p := ref[1] + ref[3]
+		if p == "@" || p == "*" {
+			return strings.Join(quoted, " ")
+		}
+		n, _ := strconv.Atoi(p)
+		if n > len(quoted) {
+			if ref[2] != "" {
+				return quote(ref[2]) // ${1:-default}
+			}
+			return ""
+		}
+		return quoted[n-1]
+	})
+}
+
+var envRef = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// psAutomatic are PowerShell's own variables, left as they are rather than
+// read from the environment.
+var psAutomatic = map[string]bool{
+	"_": true, "true": true, "false": true, "null": true, "psitem": true, "args": true,
+	"input": true, "this": true, "matches": true, "error": true, "lastexitcode": true,
+}
+
+// translateEnvRefs rewrites $VAR and ${VAR} into %VAR% or $env:VAR when the
+// translate_env_refs setting is on, so one saved command works in sh and on
+// Windows. PowerShell scoped variables like $env:X, and automatic ones like
+// $_ or $true, are left alone.
+func translateEnvRefs(command, flavor string) string {
+	if cfg == nil || !cfg.TranslateEnvRefs {
+		return command
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range envRef.FindAllStringSubmatchIndex(command, -1) {
+		if m[1] < len(command) && command[m[1]] == ':' {
+			continue
+		}
+		name := ""
+		if m[2] >= 0 {
+			name = command[m[2]:m[3]]
+		} else {
+			name = command[m[4]:m[5]]
+		}
+		if flavor == "powershell" && psAutomatic[strings.ToLower(name)] {
+			continue
+		}
+		b.WriteString(command[last:m[0]])
+		if flavor == "cmd" {
+			b.WriteString("%" + name + "%")
+		} else {
+			b.WriteString("$env:" + name)
+		}
+		last = m[1]
+	}
+	b.WriteString(command[last:])
+	return b.String()
+}
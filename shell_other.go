@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "os/exec"
+
+// setRawCmdLine only matters on Windows, where the child parses its own
+// command line.
+func setRawCmdLine(cmd *exec.Cmd, line string) {}
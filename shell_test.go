@@ -0,0 +1,105 @@
+package main
+
+import (
+	"commandref/config"
+	"testing"
+)
+
+func TestCmdQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`say "hi"`, `"say ""hi"""`},
+		{"a&b", `"a&b"`},
+		{"%PATH%", "%PATH%"},
+	}
+	for _, tt := range tests {
+		if got := cmdQuote(tt.in); got != tt.want {
+			t.Errorf("cmdQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPSQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "'plain'"},
+		{"", "''"},
+		{"it's", "'it''s'"},
+		{"$env:HOME", "'$env:HOME'"},
+		{"it\u2019s", "'it\u2019\u2019s'"},
+		{"\u2018x\u201b\u201a", "'\u2018\u2018x\u201b\u201b\u201a\u201a'"},
+		{"x\u2019; rm -r C:\\", "'x\u2019\u2019; rm -r C:\\'"},
+	}
+	for _, tt := range tests {
+		if got := psQuote(tt.in); got != tt.want {
+			t.Errorf("psQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInlineArgs(t *testing.T) {
+	tests := []struct {
+		name, command string
+		extra         []string
+		want          string
+	}{
+		{"no args", "dir", nil, "dir"},
+		{"appended", "dir", []string{"C:\\My Files"}, `dir "C:\My Files"`},
+		{"positional", "copy $1 $2", []string{"a", "b c"}, `copy a "b c"`},
+		{"braced", "ping ${1}", []string{"host"}, "ping host"},
+		{"default", "ping ${1:-localhost}", nil, "ping localhost"},
+		{"missing", "echo $2", []string{"a"}, "echo "},
+		{"all", "echo $@", []string{"a", "b c"}, `echo a "b c"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inlineArgs(tt.command, tt.extra, cmdQuote); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCommand(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"ls -la", "ls -la"},
+		{"a\r\nb\r\n", "a\nb"},
+		{"a\rb", "a\nb"},
+		{"  echo hi \n", "echo hi"},
+	}
+	for _, tt := range tests {
+		if got := normalizeCommand(tt.in); got != tt.want {
+			t.Errorf("normalizeCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateEnvRefs(t *testing.T) {
+	cfg = &config.Config{TranslateEnvRefs: true}
+	tests := []struct {
+		command, flavor, want string
+	}{
+		{"echo $HOME", "cmd", "echo %HOME%"},
+		{"echo ${USER}-x", "cmd", "echo %USER%-x"},
+		{"echo $HOME", "powershell", "echo $env:HOME"},
+		{"echo $env:PATH", "powershell", "echo $env:PATH"},
+		{"ls | % { $_.Name }", "powershell", "ls | % { $_.Name }"},
+		{"ls | ? { $PSItem.Length -gt 0 }", "powershell", "ls | ? { $PSItem.Length -gt 0 }"},
+		{"if ($True -and $x) { $null }", "powershell", "if ($True -and $env:x) { $null }"},
+		{"$false; echo $args $LASTEXITCODE", "powershell", "$false; echo $args $LASTEXITCODE"},
+		{"echo $1", "powershell", "echo $1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flavor+" "+tt.command, func(t *testing.T) {
+			if got := translateEnvRefs(tt.command, tt.flavor); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	cfg = &config.Config{}
+	if got := translateEnvRefs("echo $HOME", "cmd"); got != "echo $HOME" {
+		t.Errorf("with translate_env_refs off: %s", got)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"commandref/paths"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const signedBundleFormat = "commandref-signed-bundle"
+
+type signingKey struct {
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// signedBundle wraps an export. The signature covers the compacted JSON of
+// payload, so re-indenting the file doesn't invalidate it.
+type signedBundle struct {
+	Format    string          `json:"format"`
+	PublicKey string          `json:"publicKey"`
+	Signature string          `json:"signature"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type bundleTrust int
+
+const (
+	bundleUnsigned bundleTrust = iota
+	bundleTampered
+	bundleUntrusted // valid signature from a key we don't know
+	bundleTrusted
+)
+
+func runKeys(args []string) {
+	if len(args) == 0 {
+		exitErr(errors.New("usage: commandref keys generate|show|trust <public-key>"))
+	}
+	switch args[0] {
+	case "generate":
+		force := len(args) > 1 && args[1] == "--force"
+		if k, _ := loadSigningKey(); k != nil && !force {
+			exitErr(errors.New("a signing key already exists (use --force to replace it)"))
+		}
+		k, err := generateSigningKey()
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Generated signing key", keyFingerprint(k.PublicKey))
+		fmt.Println("Public key (share with people who import your bundles):")
+		fmt.Println(k.PublicKey)
+
+	case "show":
+		k, err := loadSigningKey()
+		if err != nil {
+			exitErr(err)
+		}
+		if k == nil {
+			fmt.Println("No signing key. Run: commandref keys generate")
+			return
+		}
+		fmt.Println(k.PublicKey)
+
+	case "trust":
+		if len(args) < 2 {
+			exitErr(errors.New("keys trust requires a public key"))
+		}
+		pub := args[1]
+		if b, err := base64.StdEncoding.DecodeString(pub); err != nil || len(b) != ed25519.PublicKeySize {
+			exitErr(errors.New("not a valid public key"))
+		}
+		if err := trustKey(pub); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Trusted", keyFingerprint(pub))
+
+	default:
+		exitErr(fmt.Errorf("unknown keys subcommand: %s", args[0]))
+	}
+}
+
+func signingKeyPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "signing-key.json"), nil
+}
+
+func trustedKeysPath() (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trusted-keys.json"), nil
+}
+
+func generateSigningKey() (*signingKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k := &signingKey{
+		PublicKey:  base64.StdEncoding.EncodeToString(pub),
+		PrivateKey: base64.StdEncoding.EncodeToString(priv),
+	}
+	p, err := signingKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return k, writeFileAtomic(p, b, 0600)
+}
+
+func loadSigningKey() (*signingKey, error) {
+	p, err := signingKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var k signingKey
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func loadTrustedKeys() ([]string, error) {
+	p, err := trustedKeysPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var keys []string
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func trustKey(pub string) error {
+	keys, err := loadTrustedKeys()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(keys, pub) {
+		return nil
+	}
+	keys = append(keys, pub)
+	p, err := trustedKeysPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0644)
+}
+
+func keyFingerprint(pub string) string {
+	sum := sha256.Sum256([]byte(pub))
+	return "SHA256:" + hex.EncodeToString(sum[:8])
+}
+
+func signBundle(payload []byte, k *signingKey) ([]byte, error) {
+	priv, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key is corrupt")
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, payload); err != nil {
+		return nil, err
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(priv), compact.Bytes())
+
+	b, err := json.MarshalIndent(signedBundle{
+		Format:    signedBundleFormat,
+		PublicKey: k.PublicKey,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+		Payload:   compact.Bytes(),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// openBundle unwraps a possibly signed bundle, returning the payload and how
+// far it can be trusted. Plain exports come back as bundleUnsigned.
+func openBundle(b []byte) (payload []byte, trust bundleTrust, signer string, err error) {
+	var env signedBundle
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, 0, "", err
+	}
+	if env.Format != signedBundleFormat {
+		return b, bundleUnsigned, "", nil
+	}
+
+	pub, err1 := base64.StdEncoding.DecodeString(env.PublicKey)
+	sig, err2 := base64.StdEncoding.DecodeString(env.Signature)
+	var compact bytes.Buffer
+	err3 := json.Compact(&compact, env.Payload)
+	if err1 != nil || err2 != nil || err3 != nil || len(pub) != ed25519.PublicKeySize ||
+		!ed25519.Verify(ed25519.PublicKey(pub), compact.Bytes(), sig) {
+		return env.Payload, bundleTampered, env.PublicKey, nil
+	}
+
+	trusted, err := loadTrustedKeys()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if own, _ := loadSigningKey(); own != nil {
+		trusted = append(trusted, own.PublicKey)
+	}
+	if slices.Contains(trusted, env.PublicKey) {
+		return env.Payload, bundleTrusted, env.PublicKey, nil
+	}
+	return env.Payload, bundleUntrusted, env.PublicKey, nil
+}
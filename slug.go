@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// slugify turns a title into a lowercase, dash-separated ASCII name
+// ("Restart nginx (prod)" -> "restart-nginx-prod").
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// Every item gets a slug when it's saved, from its title unless one is
+// given, kept unique by a -2, -3... suffix (by the backend, for a hosted
+// library). It doesn't follow later title edits, so scripts and muscle
+// memory keep working; `edit --slug` renames. With titles encrypted the
+// slug is random instead, as one made from the title would give it away.
+
+const maxSlugLen = 48
+
+var notSlug = regexp.MustCompile(`^(\d+|p\d+|\d+-\d+)$`)
+
+// checkSlug rejects what couldn't be told apart from an ID, a project
+// command or a range.
+func checkSlug(s string) error {
+	switch {
+	case s == "":
+		return fmt.Errorf("slug is empty")
+	case len(s) > maxSlugLen:
+		return fmt.Errorf("slug %q is over %d characters", s, maxSlugLen)
+	case slugify(s) != s:
+		return fmt.Errorf("slug %q may only use lowercase letters, digits and single dashes", s)
+	case notSlug.MatchString(s):
+		return fmt.Errorf("slug %q looks like an ID or a range", s)
+	}
+	return nil
+}
+
+func slugTaken(slug string, items []Item, except int) bool {
+	for _, it := range items {
+		if it.ID != except && strings.EqualFold(it.Slug, slug) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueSlug turns want (a slug, or a title) into a valid slug no other
+// item in items has.
+func uniqueSlug(want string, items []Item, except int) string {
+	base := slugify(want)
+	if len(base) > maxSlugLen {
+		base = strings.TrimRight(base[:maxSlugLen], "-")
+	}
+	if base == "" {
+		base = "cmd"
+	} else if notSlug.MatchString(base) {
+		base = "cmd-" + base
+	}
+	slug := base
+	for n := 2; slugTaken(slug, items, except); n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+// slugSource is what a slug is made from when none is given: the title,
+// or something random if the title is encrypted.
+func slugSource(title string) string {
+	if fields, err := e2eFields(); err == nil && e2eEnabled() && slices.Contains(fields, "title") {
+		return "cmd-" + newUUID()[:8]
+	}
+	return title
+}
+
+// withSlug gives a new item its slug: the one it came with if that's free,
+// else one made from it or the title.
+func withSlug(it Item, items []Item) Item {
+	if it.Slug != "" && checkSlug(it.Slug) == nil && !slugTaken(it.Slug, items, it.ID) {
+		return it
+	}
+	want := it.Slug
+	if want == "" {
+		want = slugSource(it.Title)
+	}
+	it.Slug = uniqueSlug(want, items, it.ID)
+	return it
+}
+
+// checkSlugFree is checkSlug plus: no other item (than except) has it.
+func checkSlugFree(slug string, except int) error {
+	if err := checkSlug(slug); err != nil {
+		return err
+	}
+	items, err := openStore().List()
+	if err != nil {
+		return err
+	}
+	if slugTaken(slug, items, except) {
+		return fmt.Errorf("slug %q is taken", slug)
+	}
+	return nil
+}
+
+// runSlugs is `slugs`, listing them, and `slugs fill`, which names the
+// items saved before every item had a slug.
+func runSlugs(args []string) {
+	st := openStore()
+	items, err := st.List()
+	if err != nil {
+		exitErr(err)
+	}
+	sortByID(items)
+
+	if len(args) > 0 && args[0] == "fill" {
+		n := 0
+		for i, it := range items {
+			if it.Slug != "" {
+				continue
+			}
+			slug := uniqueSlug(slugSource(it.Title), items, it.ID)
+			if _, err := st.Update(it.ID, map[string]any{"slug": slug}); err != nil {
+				exitErr(fmt.Errorf("#%d: %w", it.ID, err))
+			}
+			items[i].Slug = slug
+			fmt.Printf("#%d %s\n", it.ID, slug)
+			n++
+		}
+		fmt.Printf("Named %s\n", plural(n, "item"))
+		return
+	}
+	if len(args) > 0 {
+		exitErr(errors.New("usage: commandref slugs [fill]"))
+	}
+
+	missing := 0
+	for _, it := range items {
+		if it.Slug == "" {
+			missing++
+			continue
+		}
+		fmt.Printf("%-24s #%d %s\n", it.Slug, it.ID, it.Title)
+	}
+	if missing > 0 {
+		fmt.Printf("(%s without a slug; name them with: commandref slugs fill)\n", plural(missing, "item"))
+	}
+}
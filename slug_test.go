@@ -0,0 +1,137 @@
+package main
+
+import (
+	"commandref/auth"
+	"commandref/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseID(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{Storage: "local"}
+	for _, it := range []Item{
+		{Title: "Ping google", Command: "ping google.com", Slug: "ping-google"},
+		{Title: "Dump the db", Command: "pg_dump app", Slug: "pg-dump"},
+		{Title: "Disk usage", Command: "du -sh ."},
+	} {
+		if _, err := (localStore{}).Create(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{"2", 2, false},
+		{"p3", -3, false},
+		{"p12", -12, false},
+		{"ping-google", 1, false},
+		{"pg-dump", 2, false},
+		{"disk-usage", 3, false},
+		{"p0", 0, true},
+		{"0", 0, true},
+		{"p", 0, true},
+		{"nope", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := parseID(tt.ref)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseID(%q) = %d, %v; want %d (error: %v)", tt.ref, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The slug moved from #1 to #2 since the cache was written: show may go by
+// the cache, rm must not.
+func TestParseIDStaleCache(t *testing.T) {
+	offlineTestEnv(t)
+	if err := auth.SaveSession(auth.Session{Token: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	resolvedWorkspace = &workspace{}
+	t.Cleanup(func() { resolvedWorkspace = nil })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]Item{
+			{ID: 1, UUID: "aaaaaaaa-1111", Title: "old deploy", Slug: "deploy-old"},
+			{ID: 2, UUID: "bbbbbbbb-2222", Title: "deploy", Slug: "deploy"},
+		})
+	}))
+	defer srv.Close()
+	t.Setenv("COMMANDREF_API_BASE", srv.URL)
+
+	stale := []Item{{ID: 1, UUID: "aaaaaaaa-1111", Title: "deploy", Slug: "deploy"}}
+	tests := []struct {
+		name  string
+		parse func(string) (int, error)
+		want  int
+	}{
+		{"show", parseID, 1},
+		{"rm", parseTargetID, 2},
+		{"tag", func(s string) (int, error) {
+			ids, err := parseTargetIDs([]string{s})
+			if err != nil {
+				return 0, err
+			}
+			return ids[0], nil
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saveItemCache(stale)
+			got, err := tt.parse("deploy")
+			if err != nil || got != tt.want {
+				t.Errorf("deploy = %d, %v; want #%d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSlug(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("COMMANDREF_HOME", dir)
+	key := filepath.Join(dir, "e2e.key")
+	if err := writeE2EKey(key); err != nil {
+		t.Fatal(err)
+	}
+	resolvedWorkspace = &workspace{}
+	t.Cleanup(func() { resolvedWorkspace = nil })
+	taken := []Item{{ID: 1, Slug: "restart-nginx"}}
+
+	tests := []struct {
+		name      string
+		it        Item
+		encrypted []string // encryption.fields; nil is off
+		want      string   // "" for a random one
+	}{
+		{"from the title", Item{Title: "Restart nginx (prod)"}, nil, "restart-nginx-prod"},
+		{"taken", Item{Title: "Restart nginx"}, nil, "restart-nginx-2"},
+		{"given", Item{Title: "Restart nginx", Slug: "rn"}, nil, "rn"},
+		{"commands encrypted", Item{Title: "Restart nginx"}, []string{"command"}, "restart-nginx-2"},
+		{"titles encrypted", Item{Title: "Restart nginx"}, []string{"title", "command"}, ""},
+		{"titles encrypted, slug given", Item{Title: "Restart nginx", Slug: "rn"}, []string{"title"}, "rn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &config.Config{}
+			if tt.encrypted != nil {
+				cfg.Encryption.Enabled, cfg.Encryption.KeyFile, cfg.Encryption.Fields = true, key, tt.encrypted
+			}
+			got := withSlug(tt.it, taken).Slug
+			switch {
+			case tt.want != "" && got != tt.want:
+				t.Errorf("slug = %q, want %q", got, tt.want)
+			case tt.want == "" && (strings.Contains(got, "nginx") || checkSlug(got) != nil):
+				t.Errorf("slug = %q, want a random one", got)
+			}
+		})
+	}
+}
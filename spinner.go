@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// While a network call is outstanding, a spinner with the elapsed time
+// shows on stderr once it has taken spinnerDelay (quick calls stay quiet).
+// When stderr isn't a terminal, or in accessible mode, a plain "still
+// waiting" line is printed now and then instead. Overlapping calls (bulk
+// commands) share one spinner.
+
+const (
+	spinnerDelay  = 400 * time.Millisecond
+	waitNoteAfter = 5 * time.Second
+	waitNoteEvery = 30 * time.Second
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+var waiting struct {
+	sync.Mutex
+	n    int
+	stop chan struct{}
+	done chan struct{}
+}
+
+// waitFor shows that what is in progress until the returned func is called.
+func waitFor(what string) (done func()) {
+	waiting.Lock()
+	defer waiting.Unlock()
+	waiting.n++
+	if waiting.n == 1 {
+		waiting.stop, waiting.done = make(chan struct{}), make(chan struct{})
+		go spin(what, time.Now(), waiting.stop, waiting.done)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			waiting.Lock()
+			defer waiting.Unlock()
+			waiting.n--
+			if waiting.n == 0 {
+				close(waiting.stop)
+				<-waiting.done
+			}
+		})
+	}
+}
+
+func spin(what string, start time.Time, stop, done chan struct{}) {
+	defer close(done)
+	if !isTerminal(os.Stderr) || opts.Accessible {
+		next := waitNoteAfter
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Until(start.Add(next))):
+				fmt.Fprintf(os.Stderr, "still waiting for %s, %s...\n", what, time.Since(start).Round(time.Second))
+				next += waitNoteEvery
+			}
+		}
+	}
+
+	select {
+	case <-stop:
+		return
+	case <-time.After(spinnerDelay):
+	}
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for i := 0; ; i++ {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s waiting for %s %.1fs", spinnerFrames[i%len(spinnerFrames)], what, time.Since(start).Seconds())
+		select {
+		case <-stop:
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		case <-tick.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const statsTopN = 5
+
+func runStats(args []string) {
+	items, freshness, err := listWithFreshness()
+	if err != nil {
+		exitErr(err)
+	}
+	usage, err := loadUsage()
+	if err != nil {
+		exitErr(fmt.Errorf("reading usage data: %w", err))
+	}
+
+	fmt.Printf("Data: %s\n", freshness)
+	fmt.Printf("Total commands: %d\n", len(items))
+	if len(items) == 0 {
+		return
+	}
+
+	// per tag
+	tagCounts := map[string]int{}
+	untagged := 0
+	for _, it := range items {
+		if len(it.Tags) == 0 {
+			untagged++
+		}
+		for _, t := range it.Tags {
+			tagCounts[t]++
+		}
+	}
+	fmt.Println()
+	fmt.Println("By tag:")
+	for _, kv := range sortedCounts(tagCounts) {
+		fmt.Printf("  %-20s %d\n", kv.key, kv.n)
+	}
+	if untagged > 0 {
+		fmt.Printf("  %-20s %d\n", "(untagged)", untagged)
+	}
+
+	// additions per month
+	months := map[string]int{}
+	for _, it := range items {
+		if len(it.CreatedAt) >= 7 {
+			months[it.CreatedAt[:7]]++
+		}
+	}
+	if len(months) > 0 {
+		keys := make([]string, 0, len(months))
+		for k := range months {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Println()
+		fmt.Println("Added per month:")
+		for _, k := range keys {
+			fmt.Printf("  %s  %s %d\n", k, strings.Repeat("#", min(months[k], 40)), months[k])
+		}
+	}
+
+	// top run
+	byRuns := append([]Item{}, items...)
+	sort.SliceStable(byRuns, func(i, j int) bool { return usage.get(byRuns[i].ID).Runs > usage.get(byRuns[j].ID).Runs })
+	fmt.Println()
+	fmt.Println("Most run:")
+	shown := 0
+	for _, it := range byRuns {
+		if shown == statsTopN || usage.get(it.ID).Runs == 0 {
+			break
+		}
+		fmt.Printf("  %s) %s  (%d runs)\n", displayID(it), it.Title, usage.get(it.ID).Runs)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println("  (no runs recorded yet)")
+	}
+
+	// largest
+	byLen := append([]Item{}, items...)
+	sort.SliceStable(byLen, func(i, j int) bool { return len(byLen[i].Command) > len(byLen[j].Command) })
+	fmt.Println()
+	fmt.Println("Largest:")
+	for _, it := range byLen[:min(statsTopN, len(byLen))] {
+		fmt.Printf("  %s) %s  (%d chars)\n", displayID(it), it.Title, len(it.Command))
+	}
+
+	// oldest
+	byAge := append([]Item{}, items...)
+	sort.SliceStable(byAge, func(i, j int) bool { return byAge[i].CreatedAt < byAge[j].CreatedAt })
+	fmt.Println()
+	fmt.Println("Oldest:")
+	for _, it := range byAge[:min(statsTopN, len(byAge))] {
+		fmt.Printf("  %s) %s  (added %s)\n", displayID(it), it.Title, formatTime(it.CreatedAt))
+	}
+
+	// never used
+	var never []Item
+	for _, it := range byAge {
+		u := usage.get(it.ID)
+		if u.Runs == 0 && u.Copies == 0 {
+			never = append(never, it)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("Never used: %d\n", len(never))
+	for _, it := range never[:min(statsTopN, len(never))] {
+		fmt.Printf("  %s) %s\n", displayID(it), it.Title)
+	}
+}
+
+type keyCount struct {
+	key string
+	n   int
+}
+
+// sortedCounts orders a histogram by count desc, then key.
+func sortedCounts(m map[string]int) []keyCount {
+	out := make([]keyCount, 0, len(m))
+	for k, n := range m {
+		out = append(out, keyCount{k, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].n != out[j].n {
+			return out[i].n > out[j].n
+		}
+		return out[i].key < out[j].key
+	})
+	return out
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"commandref/api"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+// Store is where the library lives: the hosted API (default) or, with
+// "storage": "local" in config, the commands.json file in the data dir.
+type Store interface {
+	List() ([]Item, error)
+	Search(query string, fields []string) ([]Item, error) // fields: see parseSearchFields
+	Get(id int) (*Item, error)
+	Create(it Item) (*Item, error)
+	Update(id int, patch map[string]any) (*Item, error)
+	Delete(id int) error
+}
+
+func openStore() Store {
+	if usingGitStore() {
+		if err := ensureGitRepo(); err != nil {
+			exitErr(err)
+		}
+		return gitStore{}
+	}
+	if usingLocalStore() {
+		return localStore{}
+	}
+	return apiStore{c: newAPIClient()}
+}
+
+// usingLocalStore is true for both file-based libraries, "local" and "git".
+func usingLocalStore() bool {
+	return cfg != nil && (cfg.Storage == "local" || cfg.Storage == "git")
+}
+
+// getItem fetches a saved item, or a project command for a negative ID.
+func getItem(st Store, id int) (*Item, error) {
+	if id < 0 {
+		return getProjectItem(id)
+	}
+	return st.Get(id)
+}
+
+// mustGetItem fetches an item or exits with the usual not-found/error codes.
+func mustGetItem(st Store, id int) *Item {
+	it, err := getItem(st, id)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			reportErr(exitNotFound, err)
+			if id > 0 && opts.ErrorFormat != "json" {
+				printDidYouMean(os.Stderr, nearIDs(suggestionPool(), id))
+			}
+			os.Exit(exitNotFound)
+		}
+		exitErr(err)
+	}
+	return it
+}
+
+type apiStore struct {
+	c *api.Client
+}
+
+func (s apiStore) List() ([]Item, error) {
+	err := s.reachable()
+	var items []Item
+	if err == nil {
+		err = apiErr(s.c.DoJSON("GET", s.c.Path("/v1/commands"), nil, &items))
+		noteAPIResult(err)
+	}
+	if err != nil {
+		c, err := offlineCache(err)
+		if err != nil {
+			return nil, err
+		}
+		return c.Items, nil
+	}
+	decryptItems(items)
+	saveItemCache(items)
+	return items, nil
+}
+
+func (s apiStore) Search(query string, fields []string) ([]Item, error) {
+	if e2eEnabled() {
+		// the backend can't search what it can't read
+		items, err := s.List()
+		if err != nil {
+			return nil, err
+		}
+		stamp := ""
+		if c, err := loadItemCache(); err == nil {
+			items, stamp = c.Items, c.Rev
+		}
+		return indexSearch(items, stamp, query, fields), nil
+	}
+	q := "q=" + url.QueryEscape(query)
+	if len(fields) > 0 {
+		q += "&in=" + url.QueryEscape(strings.Join(fields, ","))
+	}
+	err := s.reachable()
+	var items []Item
+	if err == nil {
+		err = apiErr(s.c.DoJSON("GET", s.c.Path("/v1/commands?"+q), nil, &items))
+		noteAPIResult(err)
+	}
+	if err != nil {
+		c, err := offlineCache(err)
+		if err != nil {
+			return nil, err
+		}
+		return indexSearch(c.Items, c.Rev, query, fields), nil
+	}
+	decryptItems(items)
+	return items, nil
+}
+
+func (s apiStore) Get(id int) (*Item, error) {
+	err := s.reachable()
+	var it Item
+	if err == nil {
+		err = apiErr(s.c.DoJSON("GET", s.c.Path(fmt.Sprintf("/v1/commands/%d", id)), nil, &it))
+		noteAPIResult(err)
+	}
+	if err != nil {
+		c, cerr := offlineCache(err)
+		if cerr != nil {
+			return nil, cerr
+		}
+		for i := range c.Items {
+			if c.Items[i].ID == id {
+				return &c.Items[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w; #%d is not in the offline cache", err, id)
+	}
+	decryptItem(&it)
+	return &it, nil
+}
+
+// Writes made while the backend is unreachable are queued (see offline.go),
+// unencrypted: they are encrypted when sent.
+
+func (s apiStore) Create(it Item) (*Item, error) {
+	if err := checkKeptLocal(it); err != nil {
+		return nil, err
+	}
+	// the cache may be behind; the backend makes the slug unique
+	it = withSlug(it, nil)
+	body := itemPayload(it)
+	signWorkspaceItem(it, body)
+	body, err := e2eSeal(body)
+	if err != nil {
+		return nil, err
+	}
+	err = s.reachable()
+	var created Item
+	if err == nil {
+		err = apiErr(s.c.DoJSON("POST", s.c.Path("/v1/commands"), body, &created))
+		noteAPIResult(err)
+	}
+	if err != nil {
+		c, cerr := writeCache(err)
+		if cerr != nil {
+			return nil, cerr
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		it.ID = provisionalID(c)
+		it.CreatedAt, it.UpdatedAt = now, now
+		w := queuedWrite{Op: "create", ID: it.ID, Body: itemPayload(it), Title: it.Title}
+		if err := s.queueWrite(w, it, false); err != nil {
+			return nil, err
+		}
+		return &it, nil
+	}
+	decryptItem(&created)
+	patchItemCache(created, false)
+	return &created, nil
+}
+
+func (s apiStore) Update(id int, patch map[string]any) (*Item, error) {
+	if hasSyncRules() {
+		// the rules look at the item as it will be
+		next, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyPatch(next, patch); err != nil {
+			return nil, err
+		}
+		if err := checkKeptLocal(*next); err != nil {
+			return nil, err
+		}
+	}
+	body, err := e2eSeal(patch)
+	if err != nil {
+		return nil, err
+	}
+	err = s.reachable()
+	var updated Item
+	if err == nil {
+		err = apiErr(s.c.DoJSON("PATCH", s.c.Path(fmt.Sprintf("/v1/commands/%d", id)), body, &updated))
+		noteAPIResult(err)
+	}
+	if err != nil {
+		c, cerr := writeCache(err)
+		if cerr != nil {
+			return nil, cerr
+		}
+		for _, it := range c.Items {
+			if it.ID != id {
+				continue
+			}
+			if err := applyPatch(&it, patch); err != nil {
+				return nil, err
+			}
+			it.ID = id
+			it.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			if err := s.queueWrite(queuedWrite{Op: "update", ID: id, Body: patch, Title: it.Title}, it, false); err != nil {
+				return nil, err
+			}
+			return &it, nil
+		}
+		return nil, fmt.Errorf("%w; #%d is not in the offline cache", err, id)
+	}
+	decryptItem(&updated)
+	if runFieldsChanged(patch) {
+		s.resign(&updated)
+	}
+	patchItemCache(updated, false)
+	return &updated, nil
+}
+
+// resign renews the signature of an item whose run fields just changed;
+// without a signing key the old one is dropped rather than left to fail.
+func (s apiStore) resign(it *Item) {
+	body := map[string]any{}
+	signWorkspaceItem(*it, body)
+	if len(body) == 0 && it.Signature == "" {
+		return
+	}
+	if len(body) == 0 {
+		body = map[string]any{"signature": "", "signedBy": ""}
+	}
+	var signed Item
+	if err := s.c.DoJSON("PATCH", s.c.Path(fmt.Sprintf("/v1/commands/%d", it.ID)), body, &signed); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not sign the change:", strings.TrimSpace(err.Error()))
+		return
+	}
+	it.Signature, it.SignedBy = signed.Signature, signed.SignedBy
+}
+
+func (s apiStore) Delete(id int) error {
+	err := s.reachable()
+	if err == nil {
+		err = apiErr(s.c.DoJSON("DELETE", s.c.Path(fmt.Sprintf("/v1/commands/%d", id)), nil, nil))
+		noteAPIResult(err)
+	}
+	if err != nil {
+		c, cerr := writeCache(err)
+		if cerr != nil {
+			return cerr
+		}
+		for _, it := range c.Items {
+			if it.ID == id {
+				return s.queueWrite(queuedWrite{Op: "delete", ID: id, Title: it.Title}, it, true)
+			}
+		}
+		return fmt.Errorf("%w; #%d is not in the offline cache", err, id)
+	}
+	patchItemCache(Item{ID: id}, true)
+	return nil
+}
+
+// apiErr maps the backend's not-found responses onto errNotFound.
+func apiErr(err error) error {
+	var he *api.HTTPError
+	if errors.As(err, &he) && (he.StatusCode == 404 || strings.Contains(strings.ToLower(he.Body), "not found")) {
+		return errNotFound
+	}
+	return err
+}
+
+// itemPayload is the create body for an item; server-owned fields are left out.
+func itemPayload(it Item) map[string]any {
+	return map[string]any{
+		"title":      it.Title,
+		"slug":       it.Slug,
+		"command":    it.Command,
+		"tags":       it.Tags,
+		"notes":      it.Notes,
+		"icon":       it.Icon,
+		"workdir":    it.Workdir,
+		"env":        it.Env,
+		"timeout":    it.Timeout,
+		"noLog":      it.NoLog,
+		"preRun":     it.PreRun,
+		"postRun":    it.PostRun,
+		"hosts":      it.Hosts,
+		"context":    it.Context,
+		"collection": it.Collection,
+		"archived":   it.Archived,
+		"tests":      it.Tests,
+	}
+}
+
+type localStore struct{}
+
+func (localStore) List() ([]Item, error) {
+	db, err := loadDB()
+	return db.Items, err
+}
+
+func (s localStore) Search(query string, fields []string) ([]Item, error) {
+	items, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return indexSearch(items, localIndexStamp(), query, fields), nil
+}
+
+func (localStore) Get(id int) (*Item, error) {
+	db, err := loadDB()
+	if err != nil {
+		return nil, err
+	}
+	it, _ := findByID(&db, id)
+	if it == nil {
+		return nil, errNotFound
+	}
+	return it, nil
+}
+
+func (localStore) Create(it Item) (*Item, error) {
+	db, err := loadDB()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	it.ID = db.NextID
+	it.UUID = newUUID()
+	it.CreatedAt, it.UpdatedAt = now, now
+	it = withSlug(it, db.Items)
+	if it.Tags == nil {
+		it.Tags = []string{}
+	}
+	db.NextID++
+	db.Items = append(db.Items, it)
+	if err := saveDB(db); err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+func (localStore) Update(id int, patch map[string]any) (*Item, error) {
+	db, err := loadDB()
+	if err != nil {
+		return nil, err
+	}
+	it, _ := findByID(&db, id)
+	if it == nil {
+		return nil, errNotFound
+	}
+	if err := applyPatch(it, patch); err != nil {
+		return nil, err
+	}
+	it.ID = id
+	it.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := saveDB(db); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+func (localStore) Delete(id int) error {
+	db, err := loadDB()
+	if err != nil {
+		return err
+	}
+	_, idx := findByID(&db, id)
+	if idx < 0 {
+		return errNotFound
+	}
+	buryItem(&db, db.Items[idx])
+	db.Items = append(db.Items[:idx], db.Items[idx+1:]...)
+	return saveDB(db)
+}
+
+// applyPatch overlays JSON-named fields onto an item, the same way the API
+// applies a PATCH body. A field in the patch is replaced, not merged:
+// json.Unmarshal would keep the env variables the patch leaves out.
+func applyPatch(it *Item, patch map[string]any) error {
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(it).Elem()
+	for i := range v.NumField() {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if _, ok := patch[name]; ok {
+			v.Field(i).SetZero()
+		}
+	}
+	return json.Unmarshal(b, it)
+}
+
+func sortByID(items []Item) {
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
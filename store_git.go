@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gitStore is the local library kept in a git repository ("storage": "git"):
+// the same commands.json, but every change is committed, so history and
+// blame work, and `sync --backend git` pulls and pushes it.
+type gitStore struct {
+	localStore
+}
+
+func usingGitStore() bool {
+	return cfg != nil && cfg.Storage == "git"
+}
+
+// gitRepoDir is sync.git.dir, or a "library" repo in the data dir.
+func gitRepoDir() (string, error) {
+	if cfg.Sync.Git.Dir != "" {
+		return filepath.Abs(expandHome(cfg.Sync.Git.Dir))
+	}
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "library"), nil
+}
+
+func gitBranch() string {
+	if cfg.Sync.Git.Branch != "" {
+		return cfg.Sync.Git.Branch
+	}
+	return "main"
+}
+
+// git runs git in the library repo and returns trimmed stdout.
+func git(args ...string) (string, error) {
+	dir, err := gitRepoDir()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// commits must work even on machines without a git identity
+	if out, _ := exec.Command("git", "-C", dir, "config", "user.email").Output(); len(bytes.TrimSpace(out)) == 0 {
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=commandref", "GIT_AUTHOR_EMAIL=commandref@localhost",
+			"GIT_COMMITTER_NAME=commandref", "GIT_COMMITTER_EMAIL=commandref@localhost")
+	}
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ensureGitRepo clones sync.git.remote, or initializes an empty repo, the
+// first time the library is used.
+func ensureGitRepo() error {
+	dir, err := gitRepoDir()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return nil
+	}
+	if remote := cfg.Sync.Git.Remote; remote != "" {
+		out, err := exec.Command("git", "clone", remote, dir).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git clone %s: %s", remote, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	_, err = git("init", "-q", "-b", gitBranch())
+	return err
+}
+
+// gitCommit commits commands.json if it changed.
+func gitCommit(msg string) error {
+	if _, err := git("add", "commands.json"); err != nil {
+		return err
+	}
+	if _, err := git("diff", "--cached", "--quiet"); err == nil {
+		return nil // nothing staged
+	}
+	_, err := git("commit", "-q", "-m", msg)
+	return err
+}
+
+// saveSyncedDB saves a library merged by sync, committing it when the
+// library is a git repo.
+func saveSyncedDB(db DB, source string) error {
+	if err := saveDB(db); err != nil {
+		return err
+	}
+	if usingGitStore() {
+		return gitCommit("Sync with " + source)
+	}
+	return nil
+}
+
+func (s gitStore) Create(it Item) (*Item, error) {
+	created, err := s.localStore.Create(it)
+	if err != nil {
+		return nil, err
+	}
+	return created, gitCommit(fmt.Sprintf("Add #%d: %s", created.ID, created.Title))
+}
+
+func (s gitStore) Update(id int, patch map[string]any) (*Item, error) {
+	updated, err := s.localStore.Update(id, patch)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(patch))
+	for k := range patch {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return updated, gitCommit(fmt.Sprintf("Edit #%d: %s (%s)", id, updated.Title, strings.Join(fields, ", ")))
+}
+
+func (s gitStore) Delete(id int) error {
+	it, err := s.localStore.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := s.localStore.Delete(id); err != nil {
+		return err
+	}
+	return gitCommit(fmt.Sprintf("Remove #%d: %s", id, it.Title))
+}
+
+// runGitSync pulls, three-way merges the library file with the remote one
+// (the merge base is the common commit), commits the result as a merge and
+// pushes.
+func runGitSync() {
+	if !usingGitStore() {
+		exitErr(errors.New(`the git backend needs "storage": "git" in config`))
+	}
+	fail := func(err error) {
+		exitErr(err)
+	}
+	if err := ensureGitRepo(); err != nil {
+		fail(err)
+	}
+	if hasSyncRules() {
+		fmt.Fprintln(os.Stderr, "warning: sync.include/exclude don't apply to git storage; the whole repo is pushed")
+	}
+	if _, err := git("remote", "get-url", "origin"); err != nil {
+		fail(fmt.Errorf("the library repo has no origin remote; set sync.git.remote or run: git -C <dir> remote add origin <url>"))
+	}
+	branch := gitBranch()
+	done := waitFor("the git remote")
+	_, err := git("fetch", "-q", "origin")
+	done()
+	if err != nil {
+		fail(err)
+	}
+
+	stats := mergeStats{}
+	remoteRef := "origin/" + branch
+	if _, err := git("rev-parse", "--verify", "-q", remoteRef); err == nil {
+		if _, err := git("rev-parse", "--verify", "-q", "HEAD"); err != nil {
+			// nothing local yet: just take the remote branch
+			if _, err := git("checkout", "-q", "-B", branch, remoteRef); err != nil {
+				fail(err)
+			}
+		} else if _, err := git("merge-base", "--is-ancestor", "HEAD", remoteRef); err == nil {
+			if _, err := git("merge", "-q", "--ff-only", remoteRef); err != nil {
+				fail(err)
+			}
+		} else if _, err := git("merge-base", "--is-ancestor", remoteRef, "HEAD"); err != nil {
+			db, err := loadDB()
+			if err != nil {
+				fail(err)
+			}
+			remote, err := dbAtRevision(remoteRef)
+			if err != nil {
+				fail(err)
+			}
+			var base []Item
+			if mb, err := git("merge-base", "HEAD", remoteRef); err == nil {
+				if b, err := dbAtRevision(mb); err == nil {
+					base = b.Items
+				}
+			}
+			stats = merge3(&db, base, remote.Items, remote.Tombstones)
+			if remote.NextID > db.NextID {
+				db.NextID = remote.NextID
+			}
+			// record a real merge, with our merged file as its content
+			if _, err := git("merge", "-q", "--no-commit", "-s", "ours", remoteRef); err != nil {
+				fail(err)
+			}
+			if err := saveDB(db); err != nil {
+				fail(err)
+			}
+			if _, err := git("add", "commands.json"); err != nil {
+				fail(err)
+			}
+			if _, err := git("commit", "-q", "-m", "Merge "+remoteRef); err != nil {
+				fail(err)
+			}
+		}
+	}
+
+	// an empty library has nothing to push yet
+	if _, err := git("rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		done := waitFor("the git remote")
+		_, err := git("push", "-q", "origin", "HEAD:"+branch)
+		done()
+		if err != nil {
+			fail(err)
+		}
+	}
+	db, _ := loadDB()
+	fmt.Printf("Synced with git: %d added, %d updated, %d deleted, %d total\n", stats.added, stats.updated, stats.deleted, len(db.Items))
+}
+
+func dbAtRevision(rev string) (DB, error) {
+	out, err := git("show", rev+":commands.json")
+	if err != nil {
+		return DB{}, err
+	}
+	var db DB
+	if err := json.Unmarshal([]byte(out), &db); err != nil {
+		return DB{}, err
+	}
+	return db, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// memStore is a Store kept in memory for tests.
+type memStore struct {
+	items   map[int]*Item
+	nextID  int
+	deleted []int
+}
+
+func newMemStore(items ...Item) *memStore {
+	s := &memStore{items: map[int]*Item{}, nextID: 1}
+	for _, it := range items {
+		it := it
+		s.items[it.ID] = &it
+		s.nextID = max(s.nextID, it.ID+1)
+	}
+	return s
+}
+
+func (s *memStore) List() ([]Item, error) {
+	var out []Item
+	for _, it := range s.items {
+		out = append(out, *it)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *memStore) Search(query string, fields []string) ([]Item, error) {
+	var out []Item
+	for _, it := range s.items {
+		if strings.Contains(strings.ToLower(it.Title), strings.ToLower(query)) {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) Get(id int) (*Item, error) {
+	it, ok := s.items[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	c := *it
+	return &c, nil
+}
+
+func (s *memStore) Create(it Item) (*Item, error) {
+	it.ID = s.nextID
+	s.nextID++
+	s.items[it.ID] = &it
+	c := it
+	return &c, nil
+}
+
+func (s *memStore) Update(id int, patch map[string]any) (*Item, error) {
+	it, ok := s.items[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	if err := applyPatch(it, patch); err != nil {
+		return nil, err
+	}
+	c := *it
+	return &c, nil
+}
+
+func (s *memStore) Delete(id int) error {
+	if _, ok := s.items[id]; !ok {
+		return errNotFound
+	}
+	delete(s.items, id)
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func TestApplyPatch(t *testing.T) {
+	base := func() Item {
+		return Item{
+			ID: 1, Title: "deploy", Command: "make deploy", Notes: "prod only",
+			Tags:  []string{"ops", "prod"},
+			Env:   map[string]string{"STAGE": "prod", "DEBUG": "1"},
+			Hosts: []string{"prod-*"},
+			Tests: []itemTest{{Params: []string{"a"}, Expect: "make deploy a"}},
+		}
+	}
+	tests := []struct {
+		name  string
+		patch map[string]any
+		check func(Item) bool
+	}{
+		{"env is replaced, not merged", map[string]any{"env": map[string]string{"STAGE": "dev"}},
+			func(it Item) bool { return reflect.DeepEqual(it.Env, map[string]string{"STAGE": "dev"}) }},
+		{"env cleared", map[string]any{"env": map[string]string{}},
+			func(it Item) bool { return len(it.Env) == 0 }},
+		{"hosts replaced", map[string]any{"hosts": []string{"staging"}},
+			func(it Item) bool { return slices.Equal(it.Hosts, []string{"staging"}) }},
+		{"tags shortened", map[string]any{"tags": []string{"ops"}},
+			func(it Item) bool { return slices.Equal(it.Tags, []string{"ops"}) }},
+		{"test without params", map[string]any{"tests": []itemTest{{Expect: "make deploy"}}},
+			func(it Item) bool { return len(it.Tests) == 1 && it.Tests[0].Params == nil }},
+		{"other fields kept", map[string]any{"title": "ship"},
+			func(it Item) bool {
+				return it.Title == "ship" && it.Notes == "prod only" && it.Env["DEBUG"] == "1" && len(it.Hosts) == 1
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := base()
+			if err := applyPatch(&it, tt.patch); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(it) {
+				t.Errorf("after %v: %+v", tt.patch, it)
+			}
+		})
+	}
+}
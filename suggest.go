@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runSuggest prints the few commands most likely wanted right now, in the
+// picker's order with no query: used often, used lately, used here. --ids
+// and --commands print bare lines for shell keybindings and scripts.
+func runSuggest(args []string) {
+	fs := flag.NewFlagSet("suggest", flag.ExitOnError)
+	n := fs.Int("n", 5, "how many to suggest")
+	cached := fs.Bool("cached", false, "use only the local cache (no network; for keybindings)")
+	ids := fs.Bool("ids", false, "print only the IDs, one per line")
+	commands := fs.Bool("commands", false, "print only the commands, one per line")
+	_ = fs.Parse(args)
+
+	var items []Item
+	if *cached {
+		items = cachedItems()
+	} else {
+		var err error
+		if items, err = openStore().List(); err != nil {
+			exitErr(err)
+		}
+	}
+	items = append(byArchived(items, false), projectItems()...)
+	sortByID(items)
+
+	r := newRanker(items)
+	type scored struct {
+		it    Item
+		score float64
+	}
+	var picks []scored
+	for _, it := range items {
+		if s := r.score(it, ""); s > 0 {
+			picks = append(picks, scored{it, s})
+		}
+	}
+	sort.SliceStable(picks, func(i, j int) bool { return picks[i].score > picks[j].score })
+	picks = picks[:min(len(picks), max(*n, 0))]
+
+	switch {
+	case *ids:
+		for _, p := range picks {
+			fmt.Println(displayID(p.it))
+		}
+		return
+	case *commands:
+		for _, p := range picks {
+			fmt.Println(p.it.Command)
+		}
+		return
+	}
+	if len(picks) == 0 {
+		fmt.Println("(nothing to suggest yet) run or copy a few commands first")
+		return
+	}
+	for _, p := range picks {
+		why := strings.Join(r.reasons(p.it), ", ")
+		if opts.Accessible {
+			fmt.Printf("Item %s, %s, %s\n", displayID(p.it), p.it.Title, why)
+			continue
+		}
+		fmt.Printf("%s) %s%s%s  %s\n", displayID(p.it), iconPrefix(p.it), p.it.Title, renderTags(p.it.Tags), colorize("90", why))
+	}
+}
+
+// reasons says in a few words why it was suggested.
+func (r *ranker) reasons(it Item) []string {
+	var out []string
+	if r.ctx != nil {
+		switch s := r.ctx.score(it); {
+		case isProjectItem(it):
+			out = append(out, "project command")
+		case s >= 0.5:
+			out = append(out, "used here")
+		case s > 0:
+			out = append(out, "used in this repo")
+		}
+	}
+	u := r.usage.get(it.ID)
+	if n := uses(u); n > 0 {
+		out = append(out, plural(n, "use"))
+	}
+	if t, err := time.Parse(time.RFC3339, u.LastUsedAt); err == nil {
+		out = append(out, "last "+relativeTime(t, r.now))
+	}
+	return out
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// syncBackend stores one snapshot of the library somewhere else.
+type syncBackend interface {
+	// Pull returns the remote snapshot, or nil if nothing has been pushed yet.
+	Pull() ([]byte, error)
+	Push(data []byte) error
+}
+
+func runSync(args []string) {
+	if len(args) == 2 && args[0] == "peer-serve" {
+		servePeer(args[1])
+		return
+	}
+	if len(args) > 0 && args[0] == "obsidian" {
+		runObsidianSync(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "tombstones" {
+		runTombstones(args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	backendName := fs.String("backend", cfg.Sync.Backend, "sync backend: webdav or gist")
+	peer := fs.String("peer", "", "sync directly with [user@]host over ssh")
+	remoteCmd := fs.String("remote-cmd", "commandref", "commandref binary on the peer")
+	_ = fs.Parse(args)
+
+	if !usingLocalStore() {
+		exitErr(errors.New(`sync works on the local library; set "storage": "local" in config`))
+	}
+
+	if err := checkSyncRules(); err != nil {
+		exitErr(err)
+	}
+
+	if *backendName == "git" {
+		runGitSync()
+		return
+	}
+
+	if *peer != "" {
+		runPeerSync(*peer, *remoteCmd)
+		return
+	}
+
+	backend, err := newSyncBackend(*backendName)
+	if err != nil {
+		exitErr(err)
+	}
+	pass, err := syncPassphrase()
+	if err != nil {
+		exitErr(err)
+	}
+
+	// a 412 means someone pushed since our pull: merge their push too
+	var st mergeStats
+	var db DB
+	for round := 1; ; round++ {
+		got, err := syncRound(backend, *backendName, pass, &db)
+		st.added, st.updated, st.deleted = st.added+got.added, st.updated+got.updated, st.deleted+got.deleted
+		if errors.Is(err, errSyncConflict) && round < syncRounds {
+			continue
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		break
+	}
+
+	fmt.Printf("Synced with %s: %d added, %d updated, %d deleted, %d total\n", *backendName, st.added, st.updated, st.deleted, len(db.Items))
+	_ = runHook("sync", cfg.Hooks.PostSync, []string{"COMMANDREF_SYNC_WITH=" + *backendName})
+}
+
+// errSyncConflict is what Push returns when the remote changed since Pull.
+var errSyncConflict = errors.New("the remote library changed while syncing")
+
+const syncRounds = 3
+
+// syncRound pulls, merges into the local library (saved to db) and pushes
+// the result.
+func syncRound(backend syncBackend, name, pass string, db *DB) (mergeStats, error) {
+	var err error
+	if *db, err = loadDB(); err != nil {
+		return mergeStats{}, err
+	}
+
+	done := waitFor("the " + name + " backend")
+	blob, err := backend.Pull()
+	done()
+	if err != nil {
+		return mergeStats{}, fmt.Errorf("pulling: %w", err)
+	}
+	var remoteItems []Item
+	var remoteGone []tombstone
+	if blob != nil {
+		plain, err := unseal(pass, blob)
+		if err != nil {
+			return mergeStats{}, err
+		}
+		var remote DB
+		if err := json.Unmarshal(plain, &remote); err != nil {
+			return mergeStats{}, fmt.Errorf("remote library is corrupt: %w", err)
+		}
+		remoteItems, remoteGone = remote.Items, remote.Tombstones
+	}
+	var base []Item
+	if blob != nil {
+		base = loadSyncBase(name) // no remote yet: nothing was deleted there
+	}
+	// remote items our rules don't sync (another machine's rules differ) are
+	// passed through untouched rather than pulled
+	remoteItems, passed := splitSyncable(remoteItems)
+	base = withoutUUIDs(base, passed)
+	st := merge3(db, base, remoteItems, remoteGone)
+
+	if err := saveSyncedDB(*db, name); err != nil {
+		return st, err
+	}
+
+	push := DB{NextID: db.NextID, Items: append(withoutUUIDs(syncable(db.Items), passed), passed...), Tombstones: db.Tombstones}
+	plain, err := json.Marshal(push)
+	if err != nil {
+		return st, err
+	}
+	sealed, err := seal(pass, plain)
+	if err != nil {
+		return st, err
+	}
+	done = waitFor("the " + name + " backend")
+	err = backend.Push(sealed)
+	done()
+	if err != nil {
+		return st, fmt.Errorf("pushing: %w", err)
+	}
+
+	if err := saveSyncBase(name, push.Items); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save sync state:", err)
+	}
+	return st, nil
+}
+
+func newSyncBackend(name string) (syncBackend, error) {
+	switch name {
+	case "webdav":
+		return newWebDAVBackend(cfg.Sync.WebDAV)
+	case "gist":
+		return newGistBackend(cfg.Sync.Gist)
+	case "":
+		return nil, fmt.Errorf("no sync backend; pass --backend or set sync.backend in config")
+	default:
+		return nil, fmt.Errorf("unknown sync backend: %s", name)
+	}
+}
+
+// mergeItems folds remote items into db, matching on UUID. New remote items
+// get a fresh local ID; for items on both sides the newer updatedAt wins.
+func mergeItems(db *DB, remote []Item) (added, updated int) {
+	byUUID := map[string]int{}
+	for i := range db.Items {
+		if db.Items[i].UUID == "" {
+			db.Items[i].UUID = newUUID()
+		}
+		byUUID[db.Items[i].UUID] = i
+	}
+
+	for _, r := range remote {
+		if r.UUID == "" {
+			continue
+		}
+		i, ok := byUUID[r.UUID]
+		if !ok {
+			if buried(db, r) {
+				continue
+			}
+			r.ID = db.NextID
+			db.NextID++
+			db.Items = append(db.Items, r)
+			byUUID[r.UUID] = len(db.Items) - 1
+			added++
+			continue
+		}
+		if newerTimestamp(r.UpdatedAt, db.Items[i].UpdatedAt) {
+			r.ID = db.Items[i].ID
+			db.Items[i] = r
+			updated++
+		}
+	}
+	return added, updated
+}
+
+func newerTimestamp(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a > b
+	}
+	return ta.After(tb)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"commandref/config"
+	"commandref/paths"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const gistFileName = "commandref-library.json"
+
+// gistBackend keeps the encrypted library as a file in a secret GitHub Gist.
+// When no gist ID is configured, the first push creates one and remembers
+// its ID in gist-sync.json in the data dir.
+type gistBackend struct {
+	api    string
+	id     string
+	token  string
+	client *http.Client
+}
+
+func gistStatePath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gist-sync.json"), nil
+}
+
+func newGistBackend(c config.GistConfig) (*gistBackend, error) {
+	token := credential("COMMANDREF_GIST_TOKEN", c.Token, "sync.gist.token")
+	if token == "" {
+		return nil, fmt.Errorf("no GitHub token for gist sync; set COMMANDREF_GIST_TOKEN or sync.gist.token (needs the gist scope)")
+	}
+	api := os.Getenv("GITHUB_API_URL") // GitHub Enterprise
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	g := &gistBackend{api: strings.TrimRight(api, "/"), id: c.ID, token: token, client: &http.Client{Timeout: 60 * time.Second}}
+	if g.id == "" {
+		if p, err := gistStatePath(); err == nil {
+			if b, err := os.ReadFile(p); err == nil {
+				var st struct{ ID string }
+				_ = json.Unmarshal(b, &st)
+				g.id = st.ID
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *gistBackend) do(method, path string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, g.api+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	res, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("github %s %s: %s", method, path, res.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
+
+type gistFile struct {
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated,omitempty"`
+	RawURL    string `json:"raw_url,omitempty"`
+}
+
+type gist struct {
+	ID    string              `json:"id"`
+	Files map[string]gistFile `json:"files"`
+}
+
+func (g *gistBackend) Pull() ([]byte, error) {
+	if g.id == "" {
+		return nil, nil
+	}
+	var gs gist
+	if err := g.do("GET", "/gists/"+g.id, nil, &gs); err != nil {
+		return nil, err
+	}
+	f, ok := gs.Files[gistFileName]
+	if !ok {
+		return nil, nil
+	}
+	if !f.Truncated {
+		return []byte(f.Content), nil
+	}
+	// large files are only partly inlined
+	res, err := g.client.Get(f.RawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+func (g *gistBackend) Push(data []byte) error {
+	files := map[string]gistFile{gistFileName: {Content: string(data)}}
+	if g.id != "" {
+		return g.do("PATCH", "/gists/"+g.id, map[string]any{"files": files}, nil)
+	}
+
+	var created gist
+	err := g.do("POST", "/gists", map[string]any{
+		"description": "commandref library (encrypted)",
+		"public":      false,
+		"files":       files,
+	}, &created)
+	if err != nil {
+		return err
+	}
+	g.id = created.ID
+	p, err := gistStatePath()
+	if err != nil {
+		return err
+	}
+	b, _ := json.Marshal(map[string]string{"id": g.id})
+	if err := writeFileAtomic(p, b, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created secret gist %s (set sync.gist.id to it on your other machines)\n", g.id)
+	return nil
+}
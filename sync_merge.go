@@ -0,0 +1,166 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// The base is the library as it was after the last successful sync with a
+// backend. With it, sync can tell who changed what: an item changed on one
+// side only is simply taken, edits to different fields on both sides are
+// combined, and deletions stick instead of coming back.
+
+func syncBasePath(backend string) (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sync-base-"+slugify(backend)+".json"), nil
+}
+
+func loadSyncBase(backend string) []Item {
+	p, err := syncBasePath(backend)
+	if err != nil {
+		return nil
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	var items []Item
+	if json.Unmarshal(b, &items) != nil {
+		return nil
+	}
+	return items
+}
+
+func saveSyncBase(backend string, items []Item) error {
+	p, err := syncBasePath(backend)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+type mergeStats struct {
+	added, updated, deleted int
+}
+
+// merge3 merges remote into db against base (nil on the first sync, which
+// makes it a plain newer-wins merge) and the remote's tombstones. Without a
+// base, deletions only come through tombstones.
+func merge3(db *DB, base, remote []Item, gone []tombstone) mergeStats {
+	var st mergeStats
+	mergeItems(db, nil) // every local item needs a UUID
+	st.deleted = applyTombstones(db, gone)
+
+	baseBy := map[string]Item{}
+	for _, it := range base {
+		baseBy[it.UUID] = it
+	}
+	remoteBy := map[string]bool{}
+	localBy := map[string]int{}
+	for i, it := range db.Items {
+		localBy[it.UUID] = i
+	}
+
+	for _, r := range remote {
+		if r.UUID == "" {
+			continue
+		}
+		remoteBy[r.UUID] = true
+		if buried(db, r) {
+			continue // a stale copy of something deleted since
+		}
+		b, inBase := baseBy[r.UUID]
+		i, inLocal := localBy[r.UUID]
+		remoteChanged := !inBase || r.UpdatedAt != b.UpdatedAt
+
+		if !inLocal {
+			if inBase && !remoteChanged {
+				continue // deleted here since the last sync
+			}
+			r.ID = db.NextID
+			db.NextID++
+			db.Items = append(db.Items, r)
+			localBy[r.UUID] = len(db.Items) - 1
+			st.added++
+			continue
+		}
+
+		l := db.Items[i]
+		localChanged := !inBase || l.UpdatedAt != b.UpdatedAt
+		switch {
+		case !remoteChanged:
+			continue
+		case !localChanged:
+			r.ID = l.ID
+			db.Items[i] = r
+			st.updated++
+		case inBase:
+			merged := mergeFields(b, l, r)
+			merged.ID = l.ID
+			if !reflect.DeepEqual(merged, l) {
+				db.Items[i] = merged
+				st.updated++
+			}
+		case newerTimestamp(r.UpdatedAt, l.UpdatedAt):
+			r.ID = l.ID
+			db.Items[i] = r
+			st.updated++
+		}
+	}
+
+	// items the remote no longer has: deleted there, unless edited here since
+	kept := db.Items[:0]
+	for _, l := range db.Items {
+		b, inBase := baseBy[l.UUID]
+		if inBase && !remoteBy[l.UUID] && l.UpdatedAt == b.UpdatedAt {
+			buryItem(db, l) // a remote from before tombstones
+			st.deleted++
+			continue
+		}
+		kept = append(kept, l)
+	}
+	db.Items = kept
+	return st
+}
+
+// mergeFields combines an item edited on both sides: fields only one side
+// touched are taken from that side; fields both changed go to the newer edit.
+func mergeFields(base, local, remote Item) Item {
+	var b, l, r map[string]any
+	toMap := func(it Item, m *map[string]any) {
+		raw, _ := json.Marshal(it)
+		_ = json.Unmarshal(raw, m)
+	}
+	toMap(base, &b)
+	toMap(local, &l)
+	toMap(remote, &r)
+
+	remoteNewer := newerTimestamp(remote.UpdatedAt, local.UpdatedAt)
+	for k, rv := range r {
+		lv := l[k]
+		if reflect.DeepEqual(rv, b[k]) || reflect.DeepEqual(rv, lv) {
+			continue // remote didn't change it, or both agree
+		}
+		if reflect.DeepEqual(lv, b[k]) || remoteNewer {
+			l[k] = rv
+		}
+	}
+	if remoteNewer {
+		l["updatedAt"] = remote.UpdatedAt
+	}
+
+	var out Item
+	raw, _ := json.Marshal(l)
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// manifest entry exchanged between peers; enough to compute the delta.
+// Deleted items are listed with deletedAt instead.
+type peerEntry struct {
+	UUID      string `json:"uuid"`
+	UpdatedAt string `json:"updatedAt"`
+	DeletedAt string `json:"deletedAt,omitempty"`
+}
+
+// runPeerSync syncs the local library with the one on host, over ssh
+// (so ~/.ssh/config, agents and jump hosts all apply).
+func runPeerSync(host, remoteCmd string) {
+	db, err := loadDB()
+	if err != nil {
+		exitErr(err)
+	}
+	mergeItems(&db, nil) // make sure every local item has a UUID
+
+	var remote []peerEntry
+	if err := peerCall(host, remoteCmd, "manifest", nil, &remote); err != nil {
+		exitErr(err)
+	}
+
+	var gone []tombstone
+	remoteGone := map[string]string{}
+	for _, e := range remote {
+		if e.DeletedAt != "" {
+			gone = append(gone, tombstone{UUID: e.UUID, DeletedAt: e.DeletedAt})
+			remoteGone[e.UUID] = e.DeletedAt
+		}
+	}
+	deleted := applyTombstones(&db, gone)
+
+	local := map[string]Item{}
+	for _, it := range db.Items {
+		local[it.UUID] = it
+	}
+	remoteAt := map[string]string{}
+	var want []string
+	for _, e := range remote {
+		if e.DeletedAt != "" {
+			continue
+		}
+		remoteAt[e.UUID] = e.UpdatedAt
+		if buried(&db, Item{UUID: e.UUID, UpdatedAt: e.UpdatedAt}) {
+			continue
+		}
+		if it, ok := local[e.UUID]; !ok || newerTimestamp(e.UpdatedAt, it.UpdatedAt) {
+			want = append(want, e.UUID)
+		}
+	}
+	var bury []tombstone
+	for _, t := range db.Tombstones {
+		if at, ok := remoteGone[t.UUID]; !ok || newerTimestamp(t.DeletedAt, at) {
+			bury = append(bury, t)
+		}
+	}
+	var give []Item
+	for _, it := range syncable(db.Items) {
+		at, ok := remoteAt[it.UUID]
+		if !ok || newerTimestamp(it.UpdatedAt, at) {
+			give = append(give, it)
+		}
+	}
+
+	var fetched []Item
+	if len(want) > 0 {
+		if err := peerCall(host, remoteCmd, "get", want, &fetched); err != nil {
+			exitErr(err)
+		}
+	}
+	added, updated := mergeItems(&db, fetched)
+	if err := saveSyncedDB(db, host); err != nil {
+		exitErr(err)
+	}
+
+	if len(give) > 0 {
+		if err := peerCall(host, remoteCmd, "apply", give, nil); err != nil {
+			exitErr(err)
+		}
+	}
+	if len(bury) > 0 {
+		if err := peerCall(host, remoteCmd, "bury", bury, nil); err != nil {
+			exitErr(err)
+		}
+	}
+
+	fmt.Printf("Synced with %s: pulled %d (%d added, %d updated, %d deleted), pushed %d\n", host, len(fetched), added, updated, deleted, len(give))
+	_ = runHook("sync", cfg.Hooks.PostSync, []string{"COMMANDREF_SYNC_WITH=" + host})
+}
+
+// peerArgs is the ssh argv for a peer call: "--" first, so a host like
+// -oProxyCommand=... is taken for a host, not an option.
+func peerArgs(host, remoteCmd, op string) []string {
+	return []string{"--", host, remoteCmd, "sync", "peer-serve", op}
+}
+
+func peerCall(host, remoteCmd, op string, in, out any) error {
+	var stdin []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		stdin = b
+	}
+
+	cmd := exec.Command("ssh", peerArgs(host, remoteCmd, op)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	done := waitFor(host)
+	b, err := cmd.Output()
+	done()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("peer %s (%s): %s", host, op, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("peer %s (%s): bad response: %w", host, op, err)
+	}
+	return nil
+}
+
+// servePeer is the remote half of runPeerSync, speaking JSON on stdin/stdout.
+func servePeer(op string) {
+	if !usingLocalStore() {
+		exitErr(errors.New(`remote is not using "storage": "local"`))
+	}
+	db, err := loadDB()
+	if err != nil {
+		exitErr(err)
+	}
+	mergeItems(&db, nil)
+
+	switch op {
+	case "manifest":
+		entries := make([]peerEntry, 0, len(db.Items))
+		for _, it := range syncable(db.Items) {
+			entries = append(entries, peerEntry{UUID: it.UUID, UpdatedAt: it.UpdatedAt})
+		}
+		for _, t := range db.Tombstones {
+			entries = append(entries, peerEntry{UUID: t.UUID, DeletedAt: t.DeletedAt})
+		}
+		writePeerJSON(entries)
+
+	case "get":
+		var uuids []string
+		readPeerJSON(&uuids)
+		want := map[string]bool{}
+		for _, u := range uuids {
+			want[u] = true
+		}
+		out := []Item{}
+		for _, it := range syncable(db.Items) {
+			if want[it.UUID] {
+				out = append(out, it)
+			}
+		}
+		writePeerJSON(out)
+
+	case "apply":
+		var items []Item
+		readPeerJSON(&items)
+		mergeItems(&db, items)
+
+	case "bury":
+		var gone []tombstone
+		readPeerJSON(&gone)
+		applyTombstones(&db, gone)
+
+	default:
+		exitErr(fmt.Errorf("unknown peer op: %s", op))
+	}
+
+	if err := saveSyncedDB(db, "peer"); err != nil {
+		exitErr(err)
+	}
+}
+
+func readPeerJSON(v any) {
+	b, err := io.ReadAll(os.Stdin)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+	if err != nil {
+		exitErr(fmt.Errorf("bad request: %w", err))
+	}
+}
+
+func writePeerJSON(v any) {
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		exitErr(err)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPeerArgs(t *testing.T) {
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"laptop", []string{"--", "laptop", "commandref", "sync", "peer-serve", "list"}},
+		{"me@laptop", []string{"--", "me@laptop", "commandref", "sync", "peer-serve", "list"}},
+		{"-oProxyCommand=touch /tmp/x", []string{"--", "-oProxyCommand=touch /tmp/x", "commandref", "sync", "peer-serve", "list"}},
+	}
+	for _, tt := range tests {
+		if got := peerArgs(tt.host, "commandref", "list"); !slices.Equal(got, tt.want) {
+			t.Errorf("peerArgs(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
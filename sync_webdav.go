@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"commandref/config"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const webdavDefaultFile = "commandref-library.json"
+
+type webdavBackend struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+
+	// what Pull saw, the file's ETag or that there was none: Push only
+	// replaces that, so another machine's push in between isn't lost
+	etag    string
+	missing bool
+}
+
+func newWebDAVBackend(c config.WebDAVConfig) (*webdavBackend, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("sync.webdav.url is not configured")
+	}
+	u := c.URL
+	if strings.HasSuffix(u, "/") {
+		u += webdavDefaultFile
+	}
+	pass := credential("COMMANDREF_WEBDAV_PASSWORD", c.Password, "sync.webdav.password")
+	return &webdavBackend{
+		url:      u,
+		username: c.Username,
+		password: pass,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (w *webdavBackend) do(method, url string, body []byte, header http.Header) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return w.client.Do(req)
+}
+
+func (w *webdavBackend) Pull() ([]byte, error) {
+	res, err := w.do("GET", w.url, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		w.etag, w.missing = "", true
+		return nil, nil
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("webdav GET: %s", res.Status)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	w.etag, w.missing = res.Header.Get("ETag"), false
+	return b, nil
+}
+
+// precondition is the If-Match (or If-None-Match) header for a PUT over
+// what Pull saw. A server that sends no ETag gets a plain PUT.
+func (w *webdavBackend) precondition() http.Header {
+	switch {
+	case w.etag != "":
+		return http.Header{"If-Match": {w.etag}}
+	case w.missing:
+		return http.Header{"If-None-Match": {"*"}}
+	}
+	return nil
+}
+
+func (w *webdavBackend) Push(data []byte) error {
+	res, err := w.do("PUT", w.url, data, w.precondition())
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	// 409: the parent collection doesn't exist yet; create it and retry once
+	if res.StatusCode == http.StatusConflict {
+		parent := w.url[:strings.LastIndex(w.url, "/")+1]
+		mk, err := w.do("MKCOL", parent, nil, nil)
+		if err != nil {
+			return err
+		}
+		mk.Body.Close()
+		if res, err = w.do("PUT", w.url, data, w.precondition()); err != nil {
+			return err
+		}
+		res.Body.Close()
+	}
+	if res.StatusCode == http.StatusPreconditionFailed {
+		return errSyncConflict
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webdav PUT: %s", res.Status)
+	}
+	return nil
+}
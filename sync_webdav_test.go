@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// davFile is a WebDAV server holding one file, with ETags and If-Match /
+// If-None-Match as the RFC has them (noETag: a server that sends none).
+type davFile struct {
+	mu     sync.Mutex
+	data   []byte
+	exists bool
+	rev    int
+	noETag bool
+}
+
+func (f *davFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	etag := `"` + strconv.Itoa(f.rev) + `"`
+	switch r.Method {
+	case "GET":
+		if !f.exists {
+			http.NotFound(w, r)
+			return
+		}
+		if !f.noETag {
+			w.Header().Set("ETag", etag)
+		}
+		_, _ = w.Write(f.data)
+	case "PUT":
+		if m := r.Header.Get("If-Match"); m != "" && (!f.exists || m != etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && f.exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.data, _ = io.ReadAll(r.Body)
+		f.exists = true
+		f.rev++
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (f *davFile) write(data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data, f.exists = []byte(data), true
+	f.rev++
+}
+
+func TestWebDAVPushAfterPull(t *testing.T) {
+	tests := []struct {
+		name            string
+		exists          bool
+		noETag          bool
+		pushedMeanwhile bool
+		wantConflict    bool
+	}{
+		{"unchanged", true, false, false, false},
+		{"changed since the pull", true, false, true, true},
+		{"first push", false, false, false, false},
+		{"first push, someone else was first", false, false, true, true},
+		{"server without ETags", true, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &davFile{noETag: tt.noETag}
+			if tt.exists {
+				f.write("v1")
+			}
+			srv := httptest.NewServer(f)
+			defer srv.Close()
+			w := &webdavBackend{url: srv.URL + "/lib.json", client: srv.Client()}
+
+			if _, err := w.Pull(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.pushedMeanwhile {
+				f.write("theirs")
+			}
+			err := w.Push([]byte("ours"))
+			if got := errors.Is(err, errSyncConflict); got != tt.wantConflict || (!tt.wantConflict && err != nil) {
+				t.Fatalf("Push = %v, want a conflict: %v", err, tt.wantConflict)
+			}
+			if want := map[bool]string{false: "ours", true: "theirs"}[tt.wantConflict]; string(f.data) != want {
+				t.Errorf("the file has %q, want %q", f.data, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sync rules pick which items leave this machine, e.g.
+//
+//	"sync": {"exclude": ["tag:secret", "title:~password"]}
+//
+// A rule is field:value (case-insensitive equality) or field:~value
+// (contains), on tag, title, command, notes or collection. With include
+// rules only matching items are synced; exclude rules always win.
+
+func parseSyncRule(rule string) (field, value string, contains bool, err error) {
+	field, value, ok := strings.Cut(rule, ":")
+	field = strings.ToLower(strings.TrimSpace(field))
+	switch field {
+	case "tag", "title", "command", "notes", "collection":
+	default:
+		ok = false
+	}
+	if !ok || strings.TrimSpace(value) == "" {
+		return "", "", false, fmt.Errorf("bad sync rule %q (want e.g. tag:secret or title:~password)", rule)
+	}
+	value, contains = strings.CutPrefix(value, "~")
+	return field, strings.ToLower(value), contains, nil
+}
+
+func ruleMatches(rule string, it Item) (bool, error) {
+	field, value, contains, err := parseSyncRule(rule)
+	if err != nil {
+		return false, err
+	}
+	var vals []string
+	switch field {
+	case "tag":
+		vals = it.Tags
+	case "title":
+		vals = []string{it.Title}
+	case "command":
+		vals = []string{it.Command}
+	case "notes":
+		vals = []string{it.Notes}
+	case "collection":
+		vals = []string{it.Collection}
+	}
+	for _, v := range vals {
+		v = strings.ToLower(v)
+		if v == value || (contains && strings.Contains(v, value)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// syncBlocked reports why the sync rules keep an item local, or "" if it
+// syncs.
+func syncBlocked(it Item) (string, error) {
+	for _, r := range cfg.Sync.Exclude {
+		if ok, err := ruleMatches(r, it); err != nil || ok {
+			return "sync.exclude " + r, err
+		}
+	}
+	if len(cfg.Sync.Include) == 0 {
+		return "", nil
+	}
+	for _, r := range cfg.Sync.Include {
+		if ok, err := ruleMatches(r, it); err != nil || ok {
+			return "", err
+		}
+	}
+	return "no sync.include rule", nil
+}
+
+func hasSyncRules() bool {
+	return len(cfg.Sync.Include) > 0 || len(cfg.Sync.Exclude) > 0
+}
+
+// checkSyncRules validates the configured rules up front, so a typo fails
+// the sync instead of quietly syncing everything.
+func checkSyncRules() error {
+	for _, r := range append(append([]string{}, cfg.Sync.Include...), cfg.Sync.Exclude...) {
+		if _, _, _, err := parseSyncRule(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncable(items []Item) []Item {
+	out, _ := splitSyncable(items)
+	return out
+}
+
+// splitSyncable separates the items our rules sync from the rest.
+func splitSyncable(items []Item) (synced, other []Item) {
+	for _, it := range items {
+		if why, _ := syncBlocked(it); why == "" {
+			synced = append(synced, it)
+		} else {
+			other = append(other, it)
+		}
+	}
+	return synced, other
+}
+
+// withoutUUIDs drops the items that share a UUID with one in drop.
+func withoutUUIDs(items, drop []Item) []Item {
+	if len(drop) == 0 {
+		return items
+	}
+	skip := map[string]bool{}
+	for _, it := range drop {
+		skip[it.UUID] = true
+	}
+	var out []Item
+	for _, it := range items {
+		if !skip[it.UUID] {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// errKeptLocal: with the API as storage, an item the rules keep local must
+// not be sent at all. apiStore.Create and Update check, so every command
+// that saves (add, edit, import, share, the daemon, ...) is covered.
+var errKeptLocal = errors.New("not saved")
+
+func checkKeptLocal(it Item) error {
+	why, err := syncBlocked(it)
+	if err != nil {
+		return err
+	}
+	if why != "" {
+		return fmt.Errorf("%w: the item matches %s and would be stored on the backend", errKeptLocal, why)
+	}
+	return nil
+}
+
+// guardSyncRules is run by add and edit before saving to a local library:
+// it saves an item the rules keep local as usual, and the returned note
+// says it won't sync.
+func guardSyncRules(it Item) (note string) {
+	if !usingLocalStore() || usingGitStore() { // the git repo holds (and pushes) everything
+		return ""
+	}
+	why, err := syncBlocked(it)
+	if err != nil {
+		exitErr(err)
+	}
+	if why == "" {
+		return ""
+	}
+	return fmt.Sprintf("(kept on this machine: matches %s)", why)
+}
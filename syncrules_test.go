@@ -0,0 +1,67 @@
+package main
+
+import (
+	"commandref/api"
+	"commandref/auth"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Whatever saves the item, the backend never sees one the rules keep local.
+func TestAPIStoreKeepsLocal(t *testing.T) {
+	offlineTestEnv(t)
+	cfg.Sync.Exclude = []string{"tag:secret"}
+	if err := auth.SaveSession(auth.Session{Token: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	var writes int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writes++
+		}
+		_ = json.NewEncoder(w).Encode(Item{ID: 4, Title: "vpn", Command: "openvpn corp.ovpn"})
+	}))
+	defer srv.Close()
+	s := apiStore{c: &api.Client{BaseURL: srv.URL}}
+
+	tests := []struct {
+		name string
+		save func() error
+		kept bool
+	}{
+		{"create", func() error {
+			_, err := s.Create(Item{Title: "vpn", Command: "openvpn corp.ovpn"})
+			return err
+		}, false},
+		{"create, excluded", func() error {
+			_, err := s.Create(Item{Title: "vpn", Command: "openvpn corp.ovpn", Tags: []string{"secret"}})
+			return err
+		}, true},
+		{"update", func() error {
+			_, err := s.Update(4, map[string]any{"title": "corp vpn"})
+			return err
+		}, false},
+		{"update, tagged excluded", func() error {
+			_, err := s.Update(4, map[string]any{"tags": []string{"secret"}})
+			return err
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writes = 0
+			err := tt.save()
+			if tt.kept {
+				if !errors.Is(err, errKeptLocal) || writes != 0 {
+					t.Errorf("err = %v, %d writes sent; want errKeptLocal and none", err, writes)
+				}
+				return
+			}
+			if err != nil || writes != 1 {
+				t.Errorf("err = %v, %d writes sent; want it saved", err, writes)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+func runTags(args []string) {
+	items, freshness, err := listWithFreshness()
+	if err != nil {
+		exitErr(err)
+	}
+	if freshness != "live" {
+		fmt.Printf("(%s)\n", freshness)
+	}
+
+	counts := map[string]int{}
+	for _, it := range items {
+		for _, t := range it.Tags {
+			counts[t]++
+		}
+	}
+	if len(counts) == 0 {
+		fmt.Println("(no tags)")
+		return
+	}
+	for _, kv := range sortedCounts(counts) {
+		fmt.Printf("%s %d\n", tagChip(kv.key), kv.n)
+	}
+}
+
+// runTag is `tag <id>... --add t,u --remove v`, or `tag add|remove <tag>
+// <id>...` where -i marks the items in a picker instead: edit the tags of
+// several items at once.
+func runTag(args []string) {
+	if len(args) > 0 && (args[0] == "add" || args[0] == "remove" || args[0] == "rm") {
+		fs := flag.NewFlagSet("tag "+args[0], flag.ExitOnError)
+		interactive := fs.Bool("i", false, "mark the items in a picker")
+		_ = fs.Parse(args[1:])
+		rest := fs.Args()
+		if len(rest) == 0 || (len(rest) == 1 && !*interactive) {
+			exitErr(fmt.Errorf("usage: commandref tag %s <tag> <id>... | tag %s -i <tag> [filter]", args[0], args[0]))
+		}
+		tags := parseTags(rest[0])
+		var adding, removing []string
+		if args[0] == "add" {
+			adding = tags
+		} else {
+			removing = tags
+		}
+		var ids []int
+		var err error
+		if *interactive {
+			// offer only the items the change would touch
+			ids, err = pickIDs(strings.Join(rest[1:], " "), func(it Item) bool {
+				for _, t := range tags {
+					if hasTag(it, t) == (args[0] != "add") {
+						return true
+					}
+				}
+				return false
+			})
+			if err == nil && len(ids) == 0 {
+				fmt.Fprintln(os.Stderr, "Nothing marked")
+				return
+			}
+		} else {
+			ids, err = parseTargetIDs(rest[1:])
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		retag(ids, adding, removing)
+		return
+	}
+
+	refs, rest := splitIDArgs(args)
+	fs := flag.NewFlagSet("tag", flag.ExitOnError)
+	add := fs.String("add", "", "tags to add (comma list)")
+	remove := fs.String("remove", "", "tags to take off (comma list)")
+	_ = fs.Parse(rest)
+	ids, err := parseTargetIDs(refs)
+	if err == nil && *add == "" && *remove == "" {
+		err = fmt.Errorf("nothing to do; pass --add and/or --remove")
+	}
+	if err != nil {
+		exitErr(fmt.Errorf("%w (usage: commandref tag <id>... [--add t,u] [--remove v])", err))
+	}
+	retag(ids, parseTags(*add), parseTags(*remove))
+}
+
+func retag(ids []int, adding, removing []string) {
+
+	st := openStore()
+	if code := forEachID(ids, func(id int) (string, error) {
+		if id < 0 {
+			return "", fmt.Errorf("a project command; edit it in %s", projectFileName)
+		}
+		it, err := st.Get(id)
+		if err != nil {
+			return "", err
+		}
+		tags := []string{}
+		for _, t := range it.Tags {
+			if !slices.Contains(removing, t) {
+				tags = append(tags, t)
+			}
+		}
+		for _, t := range adding {
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		if slices.Equal(tags, it.Tags) {
+			return fmt.Sprintf("#%d unchanged", it.ID), nil
+		}
+		if _, err := st.Update(id, map[string]any{"tags": tags}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Tagged #%d: %s", it.ID, strings.Join(tags, ", ")), nil
+	}); code != 0 {
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"strconv"
+)
+
+// Terminal integration: OSC 133 semantic marks let terminals (iTerm2,
+// WezTerm, kitty, VS Code...) jump between command outputs, and the user vars
+// (commandref_item, commandref_title, commandref_exit) can drive badges,
+// titles or status bars, e.g. \(user.commandref_item) in an iTerm2 badge.
+
+func terminalMarksEnabled() bool {
+	return cfg.TerminalMarks != "off" && isTerminal(os.Stdout)
+}
+
+func userVar(name, value string) string {
+	return passthrough("\033]1337;SetUserVar=" + name + "=" + base64.StdEncoding.EncodeToString([]byte(value)) + "\a")
+}
+
+// markRunStart is written just before the item's command starts.
+func markRunStart(it *Item) {
+	if !terminalMarksEnabled() {
+		return
+	}
+	os.Stdout.WriteString(userVar("commandref_item", strconv.Itoa(it.ID)) +
+		userVar("commandref_title", it.Title) +
+		userVar("commandref_exit", "") +
+		passthrough("\033]133;A\a") +
+		passthrough("\033]133;C\a"))
+}
+
+// markRunEnd closes the output block with the exit code and clears the item.
+func markRunEnd(code int) {
+	if !terminalMarksEnabled() {
+		return
+	}
+	os.Stdout.WriteString(passthrough("\033]133;D;"+strconv.Itoa(code)+"\a") +
+		userVar("commandref_exit", strconv.Itoa(code)) +
+		userVar("commandref_item", "") +
+		userVar("commandref_title", ""))
+}
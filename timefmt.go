@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// formatTime renders an RFC3339 timestamp according to the time_format and
+// time_zone config settings. Unparseable input is returned unchanged.
+func formatTime(ts string) string {
+	if ts == "" {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ts
+	}
+	return formatTimeValue(t)
+}
+
+func formatTimeValue(t time.Time) string {
+	format, zone := "", ""
+	if cfg != nil {
+		format, zone = cfg.TimeFormat, cfg.TimeZone
+	}
+
+	if strings.EqualFold(zone, "utc") {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+
+	switch strings.ToLower(format) {
+	case "", "datetime":
+		return t.Format("2006-01-02 15:04")
+	case "relative":
+		return relativeTime(t, time.Now())
+	case "rfc3339":
+		return t.Format(time.RFC3339)
+	case "date":
+		return t.Format("2006-01-02")
+	default:
+		// anything else is a Go reference layout, e.g. "Jan 2 15:04"
+		return t.Format(format)
+	}
+}
+
+// formatSince is for "when was it last..." columns: relative ("3d ago")
+// unless time_format asks for something else.
+func formatSince(t, now time.Time) string {
+	if cfg == nil || cfg.TimeFormat == "" {
+		return relativeTime(t, now)
+	}
+	return formatTimeValue(t)
+}
+
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var s string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		s = fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		s = fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		s = fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		s = fmt.Sprintf("%dmo", int(d.Hours()/24/30))
+	default:
+		s = fmt.Sprintf("%dy", int(d.Hours()/24/365))
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// runInTmux opens a new tmux pane or window that runs `commandref run <id>`
+// again, so hooks, timeout and history behave exactly like a normal run.
+func runInTmux(it *Item, ro runOptions, where string, capture bool) error {
+	if os.Getenv("TMUX") == "" {
+		return fmt.Errorf("--tmux only works inside a tmux session")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	switch where {
+	case "pane":
+		args = []string{"split-window"}
+	case "window":
+		args = []string{"new-window", "-n", it.Title}
+	default:
+		return fmt.Errorf(`--tmux must be "pane" or "window", not %q`, where)
+	}
+	if dir := expandHome(it.Workdir); dir != "" {
+		args = append(args, "-c", dir)
+	} else if wd, err := os.Getwd(); err == nil {
+		args = append(args, "-c", wd)
+	}
+	// the tmux server has its own environment; pass ours through so the new
+	// pane sees the same config, data dir and session
+	for _, kv := range tmuxEnv(os.Environ()) {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, tmuxCommand(self, it, ro, capture))
+
+	out, err := exec.Command("tmux", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tmux: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// tmuxEnvVars are what the pane gets of our environment: daemonEnvVars and
+// the output settings. `tmux -e` shows up in ps and in the server's
+// environment, so a sync passphrase or such stays out.
+var tmuxEnvVars = append([]string{"COMMANDREF_ACCESSIBLE", "COMMANDREF_ERROR_FORMAT"}, daemonEnvVars...)
+
+func tmuxEnv(environ []string) []string {
+	var env []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(tmuxEnvVars, name) || strings.HasPrefix(name, "XDG_") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// tmuxCommand is the shell line the new pane runs, with the global flags
+// (--workspace and the rest) this run was given.
+func tmuxCommand(self string, it *Item, ro runOptions, capture bool) string {
+	inner := []string{shellQuote(self)}
+	for _, a := range globalArgs() {
+		inner = append(inner, shellQuote(a))
+	}
+	inner = append(inner, "run", strconv.Itoa(it.ID))
+	if ro.timeout > 0 {
+		inner = append(inner, "--timeout", ro.timeout.String())
+	}
+	if ro.host != "" {
+		inner = append(inner, "--host", shellQuote(ro.host))
+	}
+	if ro.win {
+		inner = append(inner, "--win")
+	} else if it.Context == "windows" {
+		inner = append(inner, "--linux")
+	}
+	if capture {
+		inner = append(inner, "--capture")
+	}
+	if len(ro.extra) > 0 {
+		inner = append(inner, "--")
+		for _, a := range ro.extra {
+			inner = append(inner, shellQuote(a))
+		}
+	}
+	return strings.Join(inner, " ")
+}
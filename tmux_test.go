@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTmuxCommand(t *testing.T) {
+	defer func(o globalOptions) { opts = o }(opts)
+	it := &Item{ID: 7, Title: "deploy", Command: "make deploy"}
+	tests := []struct {
+		name string
+		opts globalOptions
+		ro   runOptions
+		want string
+	}{
+		{"plain", globalOptions{}, runOptions{}, `'/bin/cr' run 7`},
+		{"workspace", globalOptions{Workspace: "team a"}, runOptions{}, `'/bin/cr' '--workspace=team a' run 7`},
+		{"profile and reveal", globalOptions{Profile: "work", Reveal: true}, runOptions{extra: []string{"x y"}},
+			`'/bin/cr' '--profile=work' '--reveal' run 7 -- 'x y'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts = tt.opts
+			if got := tmuxCommand("/bin/cr", it, tt.ro, false); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTmuxEnv(t *testing.T) {
+	tests := []struct {
+		kv   string
+		want bool
+	}{
+		{"COMMANDREF_PROFILE=work", true},
+		{"COMMANDREF_HOME=/tmp/cr", true},
+		{"COMMANDREF_ACCESSIBLE=1", true},
+		{"XDG_CONFIG_HOME=/home/a/.config", true},
+		{"COMMANDREF_SYNC_PASSPHRASE=hunter2", false},
+		{"COMMANDREF_GOOGLE_CLIENT_ID=abc", false},
+		{"COMMANDREF_PROFILE_X=1", false},
+		{"PATH=/usr/bin", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kv, func(t *testing.T) {
+			if got := slices.Contains(tmuxEnv([]string{tt.kv}), tt.kv); got != tt.want {
+				t.Errorf("passed on: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
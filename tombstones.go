@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A tombstone records that an item was deleted, so sync can pass the
+// deletion on instead of guessing it from the item's absence: a device
+// with a stale copy (or no sync base) would otherwise bring it back.
+// An edit made after the deletion wins over it.
+type tombstone struct {
+	UUID      string `json:"uuid"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// tombstoneKeep is how long `sync tombstones prune` keeps a deletion by
+// default; a device that hasn't synced for longer may bring items back.
+const tombstoneKeep = "90d"
+
+func buryItem(db *DB, it Item) {
+	if it.UUID == "" {
+		return
+	}
+	addTombstones(db, []tombstone{{UUID: it.UUID, DeletedAt: time.Now().UTC().Format(time.RFC3339)}})
+}
+
+// addTombstones merges gone into db's tombstones, keeping the later time
+// for a UUID both have.
+func addTombstones(db *DB, gone []tombstone) {
+	at := map[string]int{}
+	for i, t := range db.Tombstones {
+		at[t.UUID] = i
+	}
+	for _, t := range gone {
+		if t.UUID == "" {
+			continue
+		}
+		if i, ok := at[t.UUID]; ok {
+			if newerTimestamp(t.DeletedAt, db.Tombstones[i].DeletedAt) {
+				db.Tombstones[i].DeletedAt = t.DeletedAt
+			}
+			continue
+		}
+		at[t.UUID] = len(db.Tombstones)
+		db.Tombstones = append(db.Tombstones, t)
+	}
+}
+
+// buried reports whether it was deleted after its last edit.
+func buried(db *DB, it Item) bool {
+	for _, t := range db.Tombstones {
+		if t.UUID == it.UUID {
+			return !newerTimestamp(it.UpdatedAt, t.DeletedAt)
+		}
+	}
+	return false
+}
+
+// applyTombstones takes in deletions from elsewhere and drops the local
+// items they cover. It returns how many items went.
+func applyTombstones(db *DB, gone []tombstone) int {
+	addTombstones(db, gone)
+	if len(db.Tombstones) == 0 {
+		return 0
+	}
+	n := 0
+	kept := db.Items[:0]
+	for _, it := range db.Items {
+		if buried(db, it) {
+			n++
+			continue
+		}
+		kept = append(kept, it)
+	}
+	db.Items = kept
+	return n
+}
+
+// runTombstones is `sync tombstones [list]` and `sync tombstones prune`.
+func runTombstones(args []string) {
+	if !usingLocalStore() {
+		exitErr(errors.New(`sync works on the local library; set "storage": "local" in config`))
+	}
+	db, err := loadDB()
+	if err != nil {
+		exitErr(err)
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		if len(db.Tombstones) == 0 {
+			fmt.Println("(no tombstones)")
+			return
+		}
+		ts := append([]tombstone(nil), db.Tombstones...)
+		sort.Slice(ts, func(i, j int) bool { return ts[i].DeletedAt < ts[j].DeletedAt })
+		for _, t := range ts {
+			fmt.Printf("%s  deleted %s\n", t.UUID, formatTime(t.DeletedAt))
+		}
+
+	case "prune":
+		fs := flag.NewFlagSet("sync tombstones prune", flag.ExitOnError)
+		olderThan := fs.String("older-than", tombstoneKeep, "drop tombstones of deletions before this (e.g. 30d, 2026-01-31); every device should have synced since")
+		_ = fs.Parse(args[1:])
+		cutoff, err := parseTimeArg(*olderThan)
+		if err != nil {
+			exitErr(err)
+		}
+		kept := db.Tombstones[:0]
+		for _, t := range db.Tombstones {
+			if d, err := time.Parse(time.RFC3339, t.DeletedAt); err == nil && d.Before(cutoff) {
+				continue
+			}
+			kept = append(kept, t)
+		}
+		pruned := len(db.Tombstones) - len(kept)
+		db.Tombstones = kept
+		if pruned > 0 {
+			if err := saveSyncedDB(db, "tombstone prune"); err != nil {
+				exitErr(err)
+			}
+		}
+		fmt.Printf("Pruned %s, %d left\n", plural(pruned, "tombstone"), len(kept))
+
+	default:
+		exitErr(errors.New("usage: commandref sync tombstones [list] | prune [--older-than 90d]"))
+	}
+}
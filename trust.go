@@ -0,0 +1,231 @@
+package main
+
+import (
+	"commandref/auth"
+	"commandref/paths"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Items in a team workspace can be written or changed by anyone in it, so
+// `run` won't execute one that someone else wrote or last edited until it
+// has been looked at: it shows the author, the last editor and whether the
+// command carries a valid signature, and needs --trust. Trust is kept per
+// item and for exactly what it runs; any later change asks again.
+
+// runContent is what a signature and a trust decision cover: everything
+// that decides what `run` executes, and nothing cosmetic.
+type runContent struct {
+	Command string            `json:"command"`
+	Workdir string            `json:"workdir"`
+	Env     map[string]string `json:"env"`
+	PreRun  string            `json:"preRun"`
+	PostRun string            `json:"postRun"`
+	Hosts   []string          `json:"hosts"`
+	Context string            `json:"context"`
+}
+
+func runContentOf(it Item) []byte {
+	rc := runContent{it.Command, it.Workdir, it.Env, it.PreRun, it.PostRun, it.Hosts, it.Context}
+	if len(rc.Env) == 0 {
+		rc.Env = nil
+	}
+	if len(rc.Hosts) == 0 {
+		rc.Hosts = nil
+	}
+	b, _ := json.Marshal(rc)
+	return b
+}
+
+func runContentHash(it Item) string {
+	sum := sha256.Sum256(runContentOf(it))
+	return hex.EncodeToString(sum[:])
+}
+
+// signWorkspaceItem adds the signature of it to an item payload when the
+// library is a workspace and there is a signing key (commandref keys generate).
+func signWorkspaceItem(it Item, body map[string]any) {
+	if currentWorkspace().ID == "" {
+		return
+	}
+	k, err := loadSigningKey()
+	if err != nil || k == nil {
+		return
+	}
+	priv, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return
+	}
+	body["signature"] = base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(priv), runContentOf(it)))
+	body["signedBy"] = k.PublicKey
+}
+
+// runFieldsChanged reports whether a patch touches what a signature covers.
+func runFieldsChanged(patch map[string]any) bool {
+	for _, f := range []string{"command", "workdir", "env", "preRun", "postRun", "hosts", "context"} {
+		if _, ok := patch[f]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// verification describes the signature of it, and whether it is one of
+// the keys trusted here.
+func verification(it Item) (string, bool) {
+	if it.Signature == "" {
+		return "unsigned", false
+	}
+	pub, err1 := base64.StdEncoding.DecodeString(it.SignedBy)
+	sig, err2 := base64.StdEncoding.DecodeString(it.Signature)
+	if err1 != nil || err2 != nil || len(pub) != ed25519.PublicKeySize ||
+		!ed25519.Verify(ed25519.PublicKey(pub), runContentOf(it), sig) {
+		return "SIGNATURE DOES NOT MATCH: changed since it was signed", false
+	}
+	trusted, _ := loadTrustedKeys()
+	if own, _ := loadSigningKey(); own != nil {
+		trusted = append(trusted, own.PublicKey)
+	}
+	if slices.Contains(trusted, it.SignedBy) {
+		return "verified, signed by " + keyFingerprint(it.SignedBy), true
+	}
+	return "signed by " + keyFingerprint(it.SignedBy) + ", a key not trusted here (commandref keys trust <key>)", false
+}
+
+// fromSomeoneElse is true for items another member wrote or last changed.
+func fromSomeoneElse(it Item) bool {
+	if usingLocalStore() || it.ID < 0 {
+		return false
+	}
+	me := ""
+	if s, _ := auth.LoadSession(); s != nil {
+		me = s.Email
+	}
+	return (it.CreatedBy != "" && it.CreatedBy != me) || (it.UpdatedBy != "" && it.UpdatedBy != me)
+}
+
+type trustedItem struct {
+	Hash string `json:"hash"`
+	At   string `json:"at"`
+}
+
+func trustedItemsPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trusted-items"+profileSuffix()+".json"), nil
+}
+
+func loadTrustedItems() map[string]trustedItem {
+	m := map[string]trustedItem{}
+	if p, err := trustedItemsPath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &m)
+		}
+	}
+	return m
+}
+
+func trustKeyOf(it Item) string {
+	k := it.UUID
+	if k == "" {
+		k = strconv.Itoa(it.ID)
+	}
+	return currentWorkspace().ID + "/" + k
+}
+
+// isTrusted is true for items that need no trust, and for those trusted
+// exactly as they are now.
+func isTrusted(it Item) (trusted, seenBefore bool) {
+	if !fromSomeoneElse(it) {
+		return true, false
+	}
+	t, ok := loadTrustedItems()[trustKeyOf(it)]
+	return ok && t.Hash == runContentHash(it), ok
+}
+
+// printProvenance shows who wrote the item and everything a run would do,
+// env values through redact.
+func printProvenance(w io.Writer, it Item) {
+	who := func(email string) string {
+		if email == "" {
+			return "(unknown)"
+		}
+		return email
+	}
+	v, ok := verification(it)
+	if !ok {
+		v = colorize("33", v)
+	}
+	fmt.Fprintf(w, "#%s %s, from workspace %s\n", displayID(it), it.Title, currentWorkspace().label())
+	fmt.Fprintf(w, "  Author:      %s, %s\n", who(it.CreatedBy), formatTime(it.CreatedAt))
+	fmt.Fprintf(w, "  Last edited: %s, %s\n", who(orDefault(it.UpdatedBy, it.CreatedBy)), formatTime(it.UpdatedAt))
+	fmt.Fprintf(w, "  Signature:   %s\n", v)
+	fmt.Fprintf(w, "  Runs:        %s\n", colorize("36", it.Command))
+	for _, x := range []struct{ label, v string }{{"Workdir", it.Workdir}, {"Pre-run", it.PreRun}, {"Post-run", it.PostRun}} {
+		if x.v != "" {
+			fmt.Fprintf(w, "  %-12s %s\n", x.label+":", x.v)
+		}
+	}
+	if len(it.Hosts) > 0 {
+		fmt.Fprintf(w, "  Hosts:       %s\n", strings.Join(it.Hosts, ", "))
+	}
+	keys := make([]string, 0, len(it.Env))
+	for k := range it.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		label := ""
+		if i == 0 {
+			label = "Env:"
+		}
+		fmt.Fprintf(w, "  %-12s %s\n", label, redact(k+"="+it.Env[k]))
+	}
+}
+
+// mustTrust stops `run` of an item from someone else until it's trusted:
+// --trust shows who wrote it and, in a terminal, asks before remembering it.
+func mustTrust(it Item, trust bool) {
+	trusted, seen := isTrusted(it)
+	if trusted {
+		return
+	}
+	printProvenance(os.Stderr, it)
+	if !trust {
+		why := "was written or changed by someone else"
+		if seen {
+			why = "changed since you trusted it"
+		}
+		exitErr(fmt.Errorf("#%s %s; check it above, then run again with --trust", displayID(it), why))
+	}
+	if !confirm("Trust and run it?") {
+		fmt.Fprintln(os.Stderr, "Not run")
+		os.Exit(1)
+	}
+	m := loadTrustedItems()
+	m[trustKeyOf(it)] = trustedItem{Hash: runContentHash(it), At: time.Now().UTC().Format(time.RFC3339)}
+	p, err := trustedItemsPath()
+	if err == nil {
+		var b []byte
+		if b, err = json.MarshalIndent(m, "", "  "); err == nil {
+			err = writeFileAtomic(p, b, 0600)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not remember the trust:", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"commandref/config"
+	"strings"
+	"testing"
+)
+
+func TestPrintProvenance(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	resolvedWorkspace = &workspace{}
+	t.Cleanup(func() { resolvedWorkspace = nil })
+	defer func(o globalOptions) { opts = o }(opts)
+	opts.Accessible = true // no colors
+
+	tests := []struct {
+		name    string
+		it      Item
+		want    []string
+		notWant []string
+	}{
+		{"plain", Item{ID: 1, Title: "uptime", Command: "uptime"}, []string{"Runs:        uptime"}, []string{"Env:", "Hosts:"}},
+		{"env", Item{ID: 2, Title: "deploy", Command: "make deploy", Env: map[string]string{"STAGE": "prod", "API_TOKEN": "hunter2hunter2"}},
+			[]string{"Env:         API_TOKEN=****", "STAGE=prod"}, []string{"hunter2"}},
+		{"hosts", Item{ID: 3, Title: "restart", Command: "systemctl restart app", Hosts: []string{"web-*", "db1"}},
+			[]string{"Hosts:       web-*, db1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			printProvenance(&b, tt.it)
+			for _, s := range tt.want {
+				if !strings.Contains(b.String(), s) {
+					t.Errorf("missing %q in:\n%s", s, b.String())
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(b.String(), s) {
+					t.Errorf("has %q in:\n%s", s, b.String())
+				}
+			}
+		})
+	}
+}
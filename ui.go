@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ansiColors = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"gray":    "90",
+	"grey":    "90",
+}
+
+func colorize(code, s string) string {
+	if code == "" || opts.Accessible {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+// colorCode turns a config color ("red", "bright-red", "208") into an SGR code.
+func colorCode(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n <= 255 {
+		return "38;5;" + name
+	}
+	if base, ok := strings.CutPrefix(name, "bright-"); ok {
+		if c, ok := ansiColors[base]; ok && c != "90" {
+			n, _ := strconv.Atoi(c)
+			return strconv.Itoa(n + 60)
+		}
+	}
+	return ansiColors[name]
+}
+
+func tagChip(tag string) string {
+	code := ""
+	if cfg != nil {
+		code = colorCode(cfg.TagColors[tag])
+	}
+	return colorize(code, "["+tag+"]")
+}
+
+func renderTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	if opts.Accessible {
+		return " (tags: " + strings.Join(tags, ", ") + ")"
+	}
+	chips := make([]string, 0, len(tags))
+	for _, t := range tags {
+		chips = append(chips, tagChip(t))
+	}
+	return " " + strings.Join(chips, " ")
+}
+
+func iconPrefix(it Item) string {
+	if it.Icon == "" {
+		return ""
+	}
+	return it.Icon + " "
+}
+
+func projectBadge(it Item) string {
+	if !isProjectItem(it) {
+		return ""
+	}
+	return " " + colorize("35", "[project]")
+}
+
+func printListItem(it Item) {
+	if opts.Accessible {
+		printLabeled(it)
+		return
+	}
+	fmt.Printf("%s %s%s      (%s)%s%s\n",
+		colorize("32", displayID(it)+")"),
+		iconPrefix(it),
+		colorize("36", it.Command),
+		colorize("33", it.Title),
+		renderTags(it.Tags),
+		projectBadge(it))
+}
+
+func printSearchItem(it Item) {
+	if opts.Accessible {
+		printLabeled(it)
+		return
+	}
+	fmt.Printf("%s) %s%s%s%s\n", displayID(it), iconPrefix(it), it.Title, renderTags(it.Tags), projectBadge(it))
+}
+
+// printLabeled is the screen-reader friendly form: one labeled field per line,
+// blank line between items, no symbols that only carry meaning visually.
+func printLabeled(it Item) {
+	fmt.Printf("Item %s\n", displayID(it))
+	if isProjectItem(it) {
+		fmt.Println("  Source: project")
+	}
+	fmt.Printf("  Title: %s\n", it.Title)
+	fmt.Printf("  Command: %s\n", it.Command)
+	if len(it.Tags) > 0 {
+		fmt.Printf("  Tags: %s\n", strings.Join(it.Tags, ", "))
+	}
+	if it.UpdatedAt != "" {
+		fmt.Printf("  Updated: %s\n", formatTime(it.UpdatedAt))
+	}
+	fmt.Println()
+}
+
+// terminalSafe shows the control characters in text from elsewhere (escape
+// sequences, carriage returns, bidi overrides) as \x1b and the like instead
+// of letting the terminal act on them. Newlines and tabs stay.
+func terminalSafe(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r != '\n' && r != '\t' && (unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r)) {
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
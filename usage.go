@@ -0,0 +1,93 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// local per-item usage counters, keyed by item ID: one file per library
+// (profile and workspace), whose IDs they are
+type usageEntry struct {
+	Runs       int    `json:"runs"`
+	Copies     int    `json:"copies"`
+	Picks      int    `json:"picks,omitempty"`
+	LastUsedAt string `json:"lastUsedAt"`
+}
+
+type usageDB map[string]*usageEntry
+
+func usagePath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "usage"+librarySuffix()+".json"), nil
+}
+
+func loadUsage() (usageDB, error) {
+	p, err := usagePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return usageDB{}, nil
+		}
+		return nil, err
+	}
+	u := usageDB{}
+	if err := json.Unmarshal(b, &u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (u usageDB) get(id int) usageEntry {
+	if e := u[strconv.Itoa(id)]; e != nil {
+		return *e
+	}
+	return usageEntry{}
+}
+
+// recordUsage bumps the run, copy or pick counter for an item. Failures are
+// ignored: usage tracking must never break the command itself.
+// Items marked noLog and project commands are never recorded.
+func recordUsage(it *Item, kind string) {
+	if it.NoLog || isProjectItem(*it) {
+		return
+	}
+	u, err := loadUsage()
+	if err != nil {
+		return
+	}
+	key := strconv.Itoa(it.ID)
+	e := u[key]
+	if e == nil {
+		e = &usageEntry{}
+		u[key] = e
+	}
+	switch kind {
+	case "run":
+		e.Runs++
+	case "copy":
+		e.Copies++
+	case "pick":
+		e.Picks++
+	}
+	e.LastUsedAt = time.Now().Format(time.RFC3339)
+
+	p, err := usagePath()
+	if err != nil {
+		return
+	}
+	b, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = writeFileAtomic(p, b, 0644)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"commandref/config"
+	"testing"
+)
+
+// #1 is another item in every library: runs and workflows of one don't show
+// in the others.
+func TestLocalStatePerLibrary(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	defer func() { resolvedWorkspace = nil }()
+	libraries := []struct {
+		profile string
+		ws      workspace
+	}{
+		{"", workspace{}},
+		{"", workspace{ID: "ws_1"}},
+		{"work", workspace{}},
+		{"work", workspace{ID: "ws_1"}},
+	}
+	use := func(i int) {
+		t.Setenv("COMMANDREF_PROFILE", libraries[i].profile)
+		ws := libraries[i].ws
+		resolvedWorkspace = &ws
+	}
+	for i := range libraries {
+		use(i)
+		for range i + 1 {
+			recordUsage(&Item{ID: 1, Title: "x"}, "run")
+		}
+		if err := saveWorkflows(map[string]*workflow{"wf": {Name: "wf", Steps: make([]workflowStep, i+1)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, lib := range libraries {
+		use(i)
+		u, err := loadUsage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wfs, err := loadWorkflows()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs, steps := u.get(1).Runs, len(wfs["wf"].Steps); runs != i+1 || steps != i+1 {
+			t.Errorf("profile %q workspace %q: %d runs, %d steps; want %d", lib.profile, lib.ws.ID, runs, steps, i+1)
+		}
+	}
+}
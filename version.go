@@ -0,0 +1,160 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// set at build time:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+const (
+	latestReleaseURL   = "https://api.github.com/repos/geekconvert/cmdref/releases/latest"
+	updateCheckEvery   = 24 * time.Hour
+	updateCheckTimeout = 2 * time.Second
+)
+
+type updateCheckState struct {
+	CheckedAt string `json:"checkedAt"`
+	Latest    string `json:"latest"`
+}
+
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	// fall back to what `go build` records from the VCS checkout
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+				return s.Value[:7]
+			}
+		}
+	}
+	return "unknown"
+}
+
+func printVersion(check bool) {
+	fmt.Printf("commandref %s (commit %s, %s %s/%s)\n", version, buildCommit(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if check {
+		if latest, err := latestVersion(true); err != nil {
+			fmt.Fprintln(os.Stderr, "update check failed:", err)
+		} else if newerVersion(latest, version) {
+			printUpdateNotice(latest)
+		} else {
+			fmt.Println("You are on the latest version.")
+		}
+	}
+}
+
+// maybeNotifyUpdate prints a one-line notice on stderr when the user opted in
+// and a newer release exists. It hits the network at most once a day.
+func maybeNotifyUpdate() {
+	if cfg == nil || !cfg.UpdateCheck || version == "dev" {
+		return
+	}
+	latest, err := latestVersion(false)
+	if err != nil || !newerVersion(latest, version) {
+		return
+	}
+	printUpdateNotice(latest)
+}
+
+func printUpdateNotice(latest string) {
+	fmt.Fprintf(os.Stderr, "A new version of commandref is available: %s (you have %s)\n", latest, version)
+}
+
+func updateStatePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "update-check.json"), nil
+}
+
+func latestVersion(force bool) (string, error) {
+	p, err := updateStatePath()
+	if err != nil {
+		return "", err
+	}
+
+	var st updateCheckState
+	if b, err := os.ReadFile(p); err == nil {
+		_ = json.Unmarshal(b, &st)
+	}
+	if !force && st.Latest != "" {
+		if t, err := time.Parse(time.RFC3339, st.CheckedAt); err == nil && time.Since(t) < updateCheckEvery {
+			return st.Latest, nil
+		}
+	}
+
+	client := &http.Client{Timeout: updateCheckTimeout}
+	res, err := client.Get(latestReleaseURL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return "", fmt.Errorf("release lookup: %s", res.Status)
+	}
+
+	var rel struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&rel); err != nil {
+		return "", err
+	}
+
+	st = updateCheckState{CheckedAt: time.Now().Format(time.RFC3339), Latest: rel.TagName}
+	if b, err := json.MarshalIndent(st, "", "  "); err == nil {
+		_ = os.WriteFile(p, b, 0644)
+	}
+	return rel.TagName, nil
+}
+
+// newerVersion reports whether a > b, comparing dotted numeric versions ("v1.10.2").
+func newerVersion(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "-") // ignore pre-release suffix
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"commandref/api"
+	"commandref/auth"
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// changeEvent is one message from the backend's /v1/events stream. The SSE
+// event name ("created", "updated", "deleted") wins over Type when both are set.
+type changeEvent struct {
+	Type string `json:"type"`
+	Item Item   `json:"item"`
+	By   string `json:"by"` // email of whoever made the change
+}
+
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	quiet := fs.Bool("quiet", false, "only keep the cache updated; print nothing")
+	detach := fs.Bool("detach", false, "keep watching in the background (log in watch.log)")
+	stop := fs.Bool("stop", false, "stop the background watcher")
+	_ = fs.Parse(args)
+
+	if usingLocalStore() {
+		exitErr(errors.New("watch needs the backend; the local library has no change stream"))
+	}
+
+	switch {
+	case *stop:
+		stopBackgroundWatch()
+		return
+	case *detach:
+		startBackgroundWatch()
+		return
+	}
+
+	// start from a fresh listing so the cache is complete before deltas arrive
+	if _, err := openStore().List(); err != nil {
+		exitErr(err)
+	}
+	me := ""
+	if s, _ := auth.LoadSession(); s != nil {
+		me = s.Email
+	}
+	if !*quiet {
+		fmt.Fprintln(os.Stderr, "Watching for changes (Ctrl-C to stop)...")
+	}
+
+	c := newAPIClient()
+	lastID := ""
+	var rc reconnect
+	for {
+		body, err := c.Stream(c.Path("/v1/events"), lastID)
+		if err != nil {
+			var he *api.HTTPError
+			if errors.As(err, &he) && (he.StatusCode == 401 || he.StatusCode == 404) {
+				exitErr(apiErr(err))
+			}
+			wait := rc.after(0)
+			if !*quiet {
+				fmt.Fprintf(os.Stderr, "watch: %v; retrying in %s\n", err, wait)
+			}
+			time.Sleep(wait)
+			continue
+		}
+		started := time.Now()
+		lastID = readEvents(body, lastID, func(ev changeEvent) {
+			applyToCache(ev)
+			if !*quiet && ev.By != me {
+				printChange(ev)
+			}
+		})
+		body.Close()
+		time.Sleep(rc.after(time.Since(started)))
+	}
+}
+
+// reconnect paces watch's reconnects: the wait doubles from a second up to
+// 30s while connecting fails or the stream drops straight away, and a
+// stream that stayed up a while reconnects at once.
+type reconnect struct {
+	delay time.Duration
+}
+
+func (r *reconnect) after(lasted time.Duration) time.Duration {
+	if lasted >= time.Minute {
+		r.delay = 0
+		return 0
+	}
+	d := max(r.delay, time.Second)
+	r.delay = min(d*2, 30*time.Second)
+	return d
+}
+
+// readEvents parses a text/event-stream until it ends, calling fn for each
+// change. It returns the last event id seen, for resuming.
+func readEvents(r io.Reader, lastID string, fn func(changeEvent)) string {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var name string
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if len(data) > 0 {
+				var ev changeEvent
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &ev); err == nil {
+					if name != "" && name != "message" {
+						ev.Type = name
+					}
+					decryptItem(&ev.Item)
+					fn(ev)
+				}
+			}
+			name, data = "", nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			lastID = value
+		}
+	}
+	return lastID
+}
+
+func applyToCache(ev changeEvent) {
+	c, err := loadItemCache()
+	if err != nil {
+		c = &itemCache{}
+	}
+	items := c.Items[:0]
+	for _, it := range c.Items {
+		if it.ID != ev.Item.ID {
+			items = append(items, it)
+		}
+	}
+	if ev.Type != "deleted" {
+		items = append(items, ev.Item)
+	}
+	sortByID(items)
+	saveItemCache(items)
+}
+
+func printChange(ev changeEvent) {
+	who := ev.By
+	if who == "" {
+		who = "someone"
+	}
+	verb := map[string]string{"created": "added", "updated": "edited", "deleted": "deleted"}[ev.Type]
+	if verb == "" {
+		verb = ev.Type
+	}
+	fmt.Printf("%s  %s %s #%d %s\n", formatTimeValue(time.Now()), who, verb, ev.Item.ID, colorize("33", redact(ev.Item.Title)))
+}
+
+func watchFile(name string) (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func startBackgroundWatch() {
+	pidPath, err := watchFile("watch.pid")
+	if err != nil {
+		exitErr(err)
+	}
+	if pid := readWatchPID(pidPath); pid != 0 && processAlive(pid) {
+		fmt.Printf("Already watching (pid %d)\n", pid)
+		return
+	}
+	logPath, _ := watchFile("watch.log")
+	logf, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		exitErr(err)
+	}
+	defer logf.Close()
+
+	self, err := os.Executable()
+	if err != nil {
+		exitErr(err)
+	}
+	cmd := exec.Command(self, append(globalArgs(), "watch")...)
+	cmd.Stdout = logf
+	cmd.Stderr = logf
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		exitErr(err)
+	}
+	pid := cmd.Process.Pid
+	_ = os.WriteFile(pidPath, []byte(strconv.Itoa(pid)), 0600)
+	_ = cmd.Process.Release()
+	fmt.Printf("Watching in the background (pid %d), log: %s\n", pid, logPath)
+}
+
+func stopBackgroundWatch() {
+	pidPath, err := watchFile("watch.pid")
+	if err != nil {
+		exitErr(err)
+	}
+	pid := readWatchPID(pidPath)
+	if pid == 0 || !processAlive(pid) {
+		_ = os.Remove(pidPath)
+		fmt.Println("No background watcher running")
+		return
+	}
+	if err := signalProcessGroup(pid, syscall.SIGTERM); err != nil {
+		exitErr(err)
+	}
+	_ = os.Remove(pidPath)
+	fmt.Println("Stopped background watcher")
+}
+
+func readWatchPID(p string) int {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnect(t *testing.T) {
+	var rc reconnect
+	steps := []struct {
+		lasted, want time.Duration
+	}{
+		{0, time.Second}, // couldn't connect
+		{0, 2 * time.Second},
+		{time.Second, 4 * time.Second}, // connected, dropped at once
+		{0, 8 * time.Second},
+		{0, 16 * time.Second},
+		{0, 30 * time.Second},
+		{0, 30 * time.Second},
+		{5 * time.Minute, 0}, // a healthy stream ended
+		{0, time.Second},
+	}
+	for i, s := range steps {
+		if got := rc.after(s.lasted); got != s.want {
+			t.Errorf("step %d: after(%s) = %s, want %s", i, s.lasted, got, s.want)
+		}
+	}
+}
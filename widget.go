@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Each widget binds Ctrl-G to `commandref pick` and inserts the chosen
+// command at the cursor without executing it.
+const zshWidget = `# commandref widget: eval "$(commandref widget zsh)"
+_commandref_widget() {
+  local selected
+  selected="$(commandref pick --cached </dev/tty)"
+  if [[ -n "$selected" ]]; then
+    LBUFFER="${LBUFFER}${selected}"
+  fi
+  zle reset-prompt
+}
+zle -N _commandref_widget
+bindkey '^G' _commandref_widget
+`
+
+const bashWidget = `# commandref widget: eval "$(commandref widget bash)"
+_commandref_widget() {
+  local selected
+  selected="$(commandref pick --cached </dev/tty)"
+  if [[ -n "$selected" ]]; then
+    READLINE_LINE="${READLINE_LINE:0:$READLINE_POINT}${selected}${READLINE_LINE:$READLINE_POINT}"
+    READLINE_POINT=$((READLINE_POINT + ${#selected}))
+  fi
+}
+bind -x '"\C-g": _commandref_widget'
+`
+
+const fishWidget = `# commandref widget: commandref widget fish | source
+function _commandref_widget
+    set -l selected (commandref pick --cached </dev/tty | string collect)
+    if test -n "$selected"
+        commandline -i -- $selected
+    end
+    commandline -f repaint
+end
+bind \cg _commandref_widget
+`
+
+func runWidget(args []string) {
+	if len(args) != 1 {
+		exitErr(errors.New("usage: commandref widget zsh|bash|fish"))
+	}
+	switch args[0] {
+	case "zsh":
+		fmt.Print(zshWidget)
+	case "bash":
+		fmt.Print(bashWidget)
+	case "fish":
+		fmt.Print(fishWidget)
+	default:
+		exitErr(fmt.Errorf("unsupported shell: %s", args[0]))
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A workflow is an ordered list of steps, each either a saved item or an
+// inline command. Workflows live in workflows.json in the data dir, next to
+// the rest of the local state, whichever storage the items use; with a
+// profile or workspace, in a file of its own, as the steps are its IDs.
+type workflow struct {
+	Name      string         `json:"name"`
+	Steps     []workflowStep `json:"steps"`
+	CreatedAt string         `json:"createdAt"`
+}
+
+type workflowStep struct {
+	ItemID  int    `json:"itemId,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+func (s workflowStep) String() string {
+	if s.ItemID != 0 {
+		return "#" + strconv.Itoa(s.ItemID)
+	}
+	return displayItem(Item{Command: s.Command}).Command
+}
+
+func workflowsPath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workflows"+librarySuffix()+".json"), nil
+}
+
+func loadWorkflows() (map[string]*workflow, error) {
+	p, err := workflowsPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]*workflow{}, nil
+		}
+		return nil, err
+	}
+	wfs := map[string]*workflow{}
+	if err := json.Unmarshal(b, &wfs); err != nil {
+		return nil, err
+	}
+	return wfs, nil
+}
+
+func saveWorkflows(wfs map[string]*workflow) error {
+	p, err := workflowsPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(wfs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0600)
+}
+
+func runWorkflow(args []string) {
+	if len(args) == 0 {
+		exitErr(errors.New("usage: commandref workflow add|list|show|export|run|rm ..."))
+	}
+	wfs, err := loadWorkflows()
+	if err != nil {
+		exitErr(err)
+	}
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("workflow add", flag.ExitOnError)
+		force := fs.Bool("force", false, "replace an existing workflow with the same name")
+		_ = fs.Parse(args[1:])
+		rest := fs.Args()
+		if len(rest) < 2 {
+			exitErr(errors.New(`usage: commandref workflow add <name> <step>...  (step: item id like 7, #7 or #restart-nginx, or a quoted command)`))
+		}
+		name := rest[0]
+		if _, ok := wfs[name]; ok && !*force {
+			exitErr(fmt.Errorf("workflow %q exists (use --force to replace it)", name))
+		}
+
+		st := openStore()
+		wf := &workflow{Name: name, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+		for _, a := range rest[1:] {
+			ref, isRef := strings.CutPrefix(a, "#")
+			id, err := strconv.Atoi(ref)
+			if err != nil && isRef && !strings.ContainsAny(ref, " \t") {
+				id, err = lookupItemRef(ref, true) // #restart-nginx
+			}
+			if err == nil {
+				mustGetItem(st, id) // fail now rather than halfway through a run
+				wf.Steps = append(wf.Steps, workflowStep{ItemID: id})
+				continue
+			}
+			if strings.TrimSpace(a) == "" {
+				continue
+			}
+			wf.Steps = append(wf.Steps, workflowStep{Command: a})
+		}
+		wfs[name] = wf
+		if err := saveWorkflows(wfs); err != nil {
+			exitErr(err)
+		}
+		fmt.Printf("Saved workflow %s (%d steps)\n", name, len(wf.Steps))
+
+	case "list":
+		if len(wfs) == 0 {
+			fmt.Println("(no workflows) add one with: commandref workflow add <name> <step>...")
+			return
+		}
+		names := make([]string, 0, len(wfs))
+		for n := range wfs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			steps := make([]string, len(wfs[n].Steps))
+			for i, s := range wfs[n].Steps {
+				steps[i] = s.String()
+			}
+			fmt.Printf("%s  (%d steps: %s)\n", colorize("36", n), len(steps), strings.Join(steps, ", "))
+		}
+
+	case "show":
+		wf := mustGetWorkflow(wfs, args[1:])
+		st := openStore()
+		fmt.Printf("Workflow: %s\n", wf.Name)
+		for i, s := range wf.Steps {
+			if s.ItemID != 0 {
+				title := "(missing item)"
+				if it, err := st.Get(s.ItemID); err == nil {
+					title = it.Title
+				}
+				fmt.Printf("  %d. #%d %s\n", i+1, s.ItemID, title)
+				continue
+			}
+			fmt.Printf("  %d. $ %s\n", i+1, s)
+		}
+
+	case "export":
+		wf := mustGetWorkflow(wfs, args[1:])
+		fs := flag.NewFlagSet("workflow export", flag.ExitOnError)
+		format := fs.String("format", "markdown", `"markdown": a checklist to paste into a ticket`)
+		out := fs.String("out", "", "write to file instead of stdout")
+		_ = fs.Parse(args[2:])
+		if *format != "markdown" && *format != "md" {
+			exitErr(fmt.Errorf("unknown --format %q (markdown)", *format))
+		}
+		md, err := workflowMarkdown(wf, openStore())
+		if err != nil {
+			exitErr(err)
+		}
+		if *out == "" {
+			fmt.Print(md)
+			return
+		}
+		if err := writeFileAtomic(*out, []byte(md), 0644); err != nil {
+			exitErr(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported workflow %s to %s\n", wf.Name, *out)
+
+	case "rm":
+		wf := mustGetWorkflow(wfs, args[1:])
+		delete(wfs, wf.Name)
+		if err := saveWorkflows(wfs); err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Deleted workflow", wf.Name)
+
+	case "run":
+		wf := mustGetWorkflow(wfs, args[1:])
+		fs := flag.NewFlagSet("workflow run", flag.ExitOnError)
+		keepGoing := fs.Bool("keep-going", false, "run the remaining steps even if one fails")
+		_ = fs.Parse(args[2:])
+		if code := runWorkflowSteps(wf, *keepGoing); code != 0 {
+			os.Exit(code)
+		}
+
+	default:
+		exitErr(fmt.Errorf("unknown workflow command: %s", args[0]))
+	}
+}
+
+func mustGetWorkflow(wfs map[string]*workflow, args []string) *workflow {
+	if len(args) == 0 {
+		exitErr(errors.New("missing workflow name"))
+	}
+	wf, ok := wfs[args[0]]
+	if !ok {
+		exitWith(exitNotFound, errNotFound)
+	}
+	return wf
+}
+
+// workflowMarkdown renders a workflow as a checklist: one box per step with
+// the step's command (and notes) under it, read from the same items
+// `workflow run` runs, so the ticket shows what would actually execute.
+// Secrets stay masked unless --reveal.
+func workflowMarkdown(wf *workflow, st Store) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", wf.Name)
+	fmt.Fprintf(&b, "Run it with `commandref workflow run %s`, or step by step:\n\n", shellQuote(wf.Name))
+	for i, s := range wf.Steps {
+		it := displayItem(Item{Command: s.Command})
+		it.Title = it.Command
+		if s.ItemID != 0 {
+			got, err := st.Get(s.ItemID)
+			if err != nil {
+				if errors.Is(err, errNotFound) {
+					err = fmt.Errorf("step %d: #%d no longer exists", i+1, s.ItemID)
+				}
+				return "", err
+			}
+			it = displayItem(*got)
+		}
+		title := strings.Join(strings.Fields(it.Title), " ")
+		if s.ItemID != 0 {
+			title += " (#" + strconv.Itoa(s.ItemID) + ")"
+		}
+		fmt.Fprintf(&b, "- [ ] **%d. %s**\n", i+1, title)
+		if s.ItemID != 0 && it.Notes != "" {
+			_, notes := argumentNames(it.Notes)
+			for _, l := range strings.Split(notes, "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					fmt.Fprintf(&b, "\n  %s\n", l)
+				}
+			}
+		}
+		// indented under the box so it stays part of the step
+		fence := "```"
+		for strings.Contains(it.Command, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "\n  %ssh\n", fence)
+		for _, l := range strings.Split(it.Command, "\n") {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+		fmt.Fprintf(&b, "  %s\n\n", fence)
+	}
+	return b.String(), nil
+}
+
+// runWorkflowSteps runs the steps in order and stops at the first failure
+// unless keepGoing. It returns the exit code of the (first) failing step.
+func runWorkflowSteps(wf *workflow, keepGoing bool) int {
+	items, err := workflowItems(openStore(), wf)
+	if err != nil {
+		exitErr(err)
+	}
+	failed := 0
+	for i, it := range items {
+		fmt.Fprintln(os.Stderr, colorize("1", fmt.Sprintf("==> [%d/%d] %s", i+1, len(items), it.Title)))
+		ro := runOptions{}
+		if it.Timeout != "" {
+			ro.timeout, _ = time.ParseDuration(it.Timeout)
+		}
+
+		var code int
+		var err error
+		if wf.Steps[i].ItemID != 0 {
+			recordUsage(it, "run")
+			started := time.Now()
+			code, err = runWithHooks(it, ro)
+			if err == nil {
+				recordRun(it, ro, started, code)
+			}
+		} else {
+			code, err = execItem(it, ro)
+		}
+		if err != nil {
+			reportErr(exitRun, fmt.Errorf("step %d: %w", i+1, err))
+			code = exitRun
+		}
+		if code != 0 {
+			fmt.Fprintf(os.Stderr, "commandref: step %d failed with exit code %d\n", i+1, code)
+			if failed == 0 {
+				failed = code
+			}
+			if !keepGoing {
+				return code
+			}
+		}
+	}
+	return failed
+}
+
+// workflowItems fetches what every step runs before the first one starts:
+// a missing, encrypted or untrusted item stops the workflow before it has
+// done anything, not halfway through.
+func workflowItems(st Store, wf *workflow) ([]*Item, error) {
+	items := make([]*Item, len(wf.Steps))
+	for i, s := range wf.Steps {
+		if s.ItemID == 0 {
+			// inline steps run like an unsaved item: no hooks, usage or history
+			items[i] = &Item{Title: s.Command, Command: s.Command}
+			continue
+		}
+		it, err := st.Get(s.ItemID)
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("step %d: #%d no longer exists (%w)", i+1, s.ItemID, errNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if f := sealedField(*it); f != "" {
+			return nil, fmt.Errorf("step %d: the %s of #%d is encrypted with a key that isn't here (see: commandref encryption status)", i+1, f, it.ID)
+		}
+		if ok, _ := isTrusted(*it); !ok {
+			return nil, fmt.Errorf("step %d: #%d was written or changed by someone else; check it with: commandref run %d --trust", i+1, it.ID, it.ID)
+		}
+		items[i] = it
+	}
+	return items, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"commandref/config"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWorkflowMarkdownMasksSecrets(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	st := newMemStore(Item{ID: 1, Title: "login", Command: "docker login --password hunter2secret"})
+
+	tests := []struct {
+		name string
+		step workflowStep
+	}{
+		{"item step", workflowStep{ItemID: 1}},
+		{"inline step", workflowStep{Command: "curl -u admin:hunter2secret https://example.com"}},
+		{"inline env", workflowStep{Command: "API_TOKEN=hunter2secret ./deploy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := &workflow{Name: "ship", Steps: []workflowStep{tt.step}}
+			md, err := workflowMarkdown(wf, st)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(md, "hunter2secret") {
+				t.Errorf("the secret is in the markdown:\n%s", md)
+			}
+			if s := tt.step.String(); strings.Contains(s, "hunter2secret") {
+				t.Errorf("the secret is in the step: %s", s)
+			}
+		})
+	}
+}
+
+// A step that can't run stops the workflow before the first one does.
+func TestWorkflowItems(t *testing.T) {
+	t.Setenv("COMMANDREF_HOME", t.TempDir())
+	cfg = &config.Config{}
+	st := newMemStore(
+		Item{ID: 1, Title: "build", Command: "make"},
+		Item{ID: 2, Title: "sealed", Command: e2ePrefix + "abc"},
+		Item{ID: 3, Title: "theirs", Command: "make deploy", CreatedBy: "mallory@example.com"},
+	)
+	tests := []struct {
+		name     string
+		second   workflowStep
+		wantErr  string
+		notFound bool
+	}{
+		{"all fine", workflowStep{Command: "echo done"}, "", false},
+		{"missing", workflowStep{ItemID: 9}, "step 2: #9 no longer exists", true},
+		{"encrypted", workflowStep{ItemID: 2}, "step 2: the command of #2 is encrypted", false},
+		{"untrusted", workflowStep{ItemID: 3}, "step 2: #3 was written or changed by someone else", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := &workflow{Name: "ship", Steps: []workflowStep{{ItemID: 1}, tt.second}}
+			items, err := workflowItems(st, wf)
+			if tt.wantErr == "" {
+				if err != nil || len(items) != 2 || items[1].Command != "echo done" {
+					t.Errorf("items = %v, %v", items, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || errors.Is(err, errNotFound) != tt.notFound {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"commandref/api"
+	"commandref/paths"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspace is a team library on the backend. The zero value is the
+// personal library.
+type workspace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"`
+}
+
+func (w workspace) label() string {
+	if w.ID == "" {
+		return "personal"
+	}
+	if w.Name != "" {
+		return w.Name
+	}
+	return w.ID
+}
+
+func workspaceStatePath() (string, error) {
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workspace"+profileSuffix()+".json"), nil
+}
+
+func workspaceListCachePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workspaces"+profileSuffix()+".json"), nil
+}
+
+var resolvedWorkspace *workspace
+
+// currentWorkspace is --workspace, else COMMANDREF_WORKSPACE, else the one
+// picked with `workspace switch`. Names are resolved through the list cached
+// by the last `workspace list`; anything else is taken as an ID.
+func currentWorkspace() workspace {
+	if resolvedWorkspace != nil {
+		return *resolvedWorkspace
+	}
+	var ws workspace
+	ref := opts.Workspace
+	if ref == "" {
+		ref = os.Getenv("COMMANDREF_WORKSPACE")
+	}
+	if ref != "" {
+		ws = lookupWorkspace(ref)
+	} else if p, err := workspaceStatePath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &ws)
+		}
+	}
+	resolvedWorkspace = &ws
+	return ws
+}
+
+func lookupWorkspace(ref string) workspace {
+	if strings.EqualFold(ref, "personal") {
+		return workspace{}
+	}
+	var known []workspace
+	if p, err := workspaceListCachePath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			_ = json.Unmarshal(b, &known)
+		}
+	}
+	for _, w := range known {
+		if w.ID == ref || strings.EqualFold(w.Name, ref) {
+			return w
+		}
+	}
+	return workspace{ID: ref}
+}
+
+func newAPIClient() *api.Client {
+	c := api.New()
+	c.Workspace = currentWorkspace().ID
+	return c
+}
+
+func fetchWorkspaces() ([]workspace, error) {
+	var list []workspace
+	if err := api.New().DoJSON("GET", "/v1/workspaces", nil, &list); err != nil {
+		return nil, apiErr(err)
+	}
+	if p, err := workspaceListCachePath(); err == nil {
+		if b, err := json.Marshal(list); err == nil {
+			_ = writeFileAtomic(p, b, 0600)
+		}
+	}
+	return list, nil
+}
+
+func runWorkspace(args []string) {
+	if usingLocalStore() {
+		exitErr(errors.New("workspaces live on the backend; the local library has none"))
+	}
+	if len(args) == 0 {
+		args = []string{"current"}
+	}
+
+	switch args[0] {
+	case "current":
+		fmt.Println(currentWorkspace().label())
+
+	case "list":
+		list, err := fetchWorkspaces()
+		if err != nil {
+			exitErr(err)
+		}
+		cur := currentWorkspace().ID
+		mark := func(id string) string {
+			if id == cur {
+				return "*"
+			}
+			return " "
+		}
+		fmt.Printf("%s personal\n", mark(""))
+		for _, w := range list {
+			role := ""
+			if w.Role != "" {
+				role = " (" + w.Role + ")"
+			}
+			fmt.Printf("%s %s  %s%s\n", mark(w.ID), w.Name, colorize("90", w.ID), role)
+		}
+
+	case "switch":
+		if len(args) < 2 {
+			exitErr(errors.New("usage: commandref workspace switch <name|id|personal>"))
+		}
+		var ws workspace
+		if !strings.EqualFold(args[1], "personal") {
+			list, err := fetchWorkspaces()
+			if err != nil {
+				exitErr(err)
+			}
+			found := false
+			for _, w := range list {
+				if w.ID == args[1] || strings.EqualFold(w.Name, args[1]) {
+					ws, found = w, true
+					break
+				}
+			}
+			if !found {
+				exitWith(exitNotFound, errNotFound)
+			}
+		}
+		p, err := workspaceStatePath()
+		if err == nil {
+			var b []byte
+			b, err = json.Marshal(ws)
+			if err == nil {
+				err = writeFileAtomic(p, b, 0600)
+			}
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		fmt.Println("Switched to workspace:", ws.label())
+
+	default:
+		exitErr(fmt.Errorf("unknown workspace command: %s", args[0]))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// isWSL reports whether we're running inside Windows Subsystem for Linux.
+func isWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	b, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(b)), "microsoft")
+}
+
+// wslWindowsShell is the Windows shell reached through WSL interop: cmd.exe
+// if the shell setting asks for cmd, else PowerShell.
+func wslWindowsShell() string {
+	if cfg != nil && shellFlavor(cfg.Shell) == "cmd" {
+		return "cmd.exe"
+	}
+	return "powershell.exe"
+}
+
+// applyWSLContext picks Windows or Linux for this run (flags beat the item's
+// context) and translates path-looking pass-through args for that side.
+func applyWSLContext(it *Item, ro *runOptions, win, linux bool) error {
+	if win && linux {
+		return fmt.Errorf("--win and --linux cannot be combined")
+	}
+	if !isWSL() {
+		if win {
+			return fmt.Errorf("--win only works inside WSL")
+		}
+		return nil // context "windows" means nothing outside WSL
+	}
+	if ro.host != "" && (win || it.Context == "windows") {
+		return fmt.Errorf("--host runs on Linux; it cannot be combined with Windows context")
+	}
+
+	ro.win = win || (!linux && it.Context == "windows")
+	for i, a := range ro.extra {
+		if p, ok := translateWSLPath(a, ro.win); ok {
+			ro.extra[i] = p
+		}
+	}
+	return nil
+}
+
+var windowsPath = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+
+// translateWSLPath converts a path argument with wslpath: Linux paths to
+// C:\... for Windows runs, and C:\... to /mnt/c/... for Linux runs. Args
+// that don't look like paths are left alone.
+func translateWSLPath(arg string, toWindows bool) (string, bool) {
+	var flag string
+	switch {
+	case toWindows && (strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, "./") ||
+		strings.HasPrefix(arg, "../") || strings.HasPrefix(arg, "~/")):
+		flag = "-w"
+		arg = expandHome(arg)
+		if abs, err := filepath.Abs(arg); err == nil {
+			arg = abs
+		}
+	case !toWindows && windowsPath.MatchString(arg):
+		flag = "-u"
+	default:
+		return "", false
+	}
+	out, err := exec.Command("wslpath", flag, arg).Output()
+	if err != nil {
+		return "", false // e.g. a Linux path with no Windows equivalent
+	}
+	return strings.TrimSpace(string(out)), true
+}